@@ -0,0 +1,75 @@
+// auditverify 校验 audited_history 哈希链和签名检查点，发现断链时以状态码 1 退出
+//
+//	go run ./cmd/auditverify                    # 校验并打印报告
+//	go run ./cmd/auditverify -checkpoint        # 校验前先给当前链尾签一个检查点
+//	go run ./cmd/auditverify -export cp.json    # 导出检查点和验签公钥，交给外部审计方离线核对
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"djj-inventory-system/config"
+	"djj-inventory-system/internal/database"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	checkpoint := flag.Bool("checkpoint", false, "sign a checkpoint for the current chain head before verifying")
+	export := flag.String("export", "", "write checkpoints and public keys to this JSON file")
+	flag.Parse()
+
+	config.Load()
+	keys := audit.RandomSigningKeys()
+	if spec := config.Get("AUDIT_SIGNING_KEYS"); spec != "" {
+		k, err := audit.ParseSigningKeys(spec)
+		if err != nil {
+			log.Fatalf("invalid AUDIT_SIGNING_KEYS: %v", err)
+		}
+		keys = k
+	} else {
+		log.Println("AUDIT_SIGNING_KEYS not set, existing checkpoints cannot be verified")
+	}
+
+	db := database.InitGormDB(database.InitDB("djjinventory"))
+	svc := service.NewAuditService(repository.NewAuditRepository(db), audit.NewGormAuditor(db), keys, zap.NewNop())
+	ctx := context.Background()
+
+	if *checkpoint {
+		cp, err := svc.CreateCheckpoint(ctx)
+		if err != nil {
+			log.Fatalf("create checkpoint: %v", err)
+		}
+		fmt.Printf("checkpoint #%d at history %d (%s)\n", cp.ID, cp.HistoryID, cp.Hash)
+	}
+
+	rep, err := svc.Verify(ctx)
+	if err != nil {
+		log.Fatalf("verify: %v", err)
+	}
+	out, _ := json.MarshalIndent(rep, "", "  ")
+	fmt.Println(string(out))
+
+	if *export != "" {
+		list, err := svc.Checkpoints(ctx)
+		if err != nil {
+			log.Fatalf("list checkpoints: %v", err)
+		}
+		b, _ := json.MarshalIndent(map[string]interface{}{"publicKeys": svc.PublicKeys(), "checkpoints": list}, "", "  ")
+		if err := os.WriteFile(*export, b, 0o644); err != nil {
+			log.Fatalf("export: %v", err)
+		}
+	}
+
+	if !rep.OK {
+		os.Exit(1)
+	}
+}
@@ -11,7 +11,6 @@ require (
 	github.com/gin-gonic/gin v1.10.1
 	github.com/go-gormigrate/gormigrate/v2 v2.0.1
 	github.com/golang-jwt/jwt/v4 v4.5.2
-	github.com/gorilla/securecookie v1.1.2
 	github.com/gorilla/websocket v1.4.2
 	github.com/joho/godotenv v1.4.0
 	github.com/lib/pq v1.10.9
@@ -25,7 +24,7 @@ require (
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 	gorm.io/datatypes v1.0.7-0.20220608135749-9359a769c0b6
 	gorm.io/driver/postgres v1.3.5
-	gorm.io/driver/sqlite v1.4.0
+	gorm.io/driver/sqlite v1.3.6
 	gorm.io/gen v0.3.4
 	gorm.io/gorm v1.23.5
 )
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 迁移 20250804 给启用哈希链之前的审计记录（audited_histories 表）补链时用到的逻辑，按当时的算法冻结在这里
+// pkg/audit 里的哈希、上链实现以后可以改，已经执行过的迁移不能跟着变
+
+// auditChainLockKey20250804 与 pkg/audit 上链时持有的咨询锁相同，补链期间 Seal 只能等待
+const auditChainLockKey20250804 = 0x61756474
+
+// auditChainBatch20250804 每批读取的记录数
+const auditChainBatch20250804 = 500
+
+// auditChainRow20250804 参与哈希的列
+type auditChainRow20250804 struct {
+	HistoryID int
+	TableName string
+	RecordID  int
+	StoreID   int
+	ChangedBy int
+	Operation string
+	ChangedAt time.Time
+	Payload   []byte
+	OldData   []byte
+	NewData   []byte
+}
+
+// hash 各字段依次写入 SHA-256，每个字段后跟一个 0 字节，结果为小写十六进制
+func (r *auditChainRow20250804) hash(prev string) string {
+	s := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(prev),
+		[]byte(r.TableName),
+		[]byte(strconv.Itoa(r.RecordID)),
+		[]byte(strconv.Itoa(r.StoreID)),
+		[]byte(strconv.Itoa(r.ChangedBy)),
+		[]byte(r.Operation),
+		[]byte(r.ChangedAt.UTC().Format(time.RFC3339Nano)),
+		r.Payload,
+		r.OldData,
+		r.NewData,
+	} {
+		s.Write(part)
+		s.Write([]byte{0})
+	}
+	return hex.EncodeToString(s.Sum(nil))
+}
+
+// rechainAuditHistory20250804 在一个事务里按 history_id 顺序给全部审计记录重新编号、计算哈希
+func rechainAuditHistory20250804(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey20250804).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("UPDATE audited_histories SET chain_seq = NULL WHERE chain_seq IS NOT NULL").Error; err != nil {
+			return err
+		}
+		var (
+			seq    int64
+			prev   string
+			lastID int
+		)
+		for {
+			var batch []auditChainRow20250804
+			if err := tx.Raw(`SELECT history_id, table_name, record_id, store_id, changed_by, operation, changed_at,
+				payload, old_data, new_data
+				FROM audited_histories WHERE history_id > ? ORDER BY history_id LIMIT ?`, lastID, auditChainBatch20250804).
+				Scan(&batch).Error; err != nil {
+				return err
+			}
+			for i := range batch {
+				seq++
+				h := batch[i].hash(prev)
+				if err := tx.Exec("UPDATE audited_histories SET chain_seq = ?, prev_hash = ?, hash = ? WHERE history_id = ?",
+					seq, prev, h, batch[i].HistoryID).Error; err != nil {
+					return err
+				}
+				prev = h
+				lastID = batch[i].HistoryID
+			}
+			if len(batch) < auditChainBatch20250804 {
+				return nil
+			}
+		}
+	})
+}
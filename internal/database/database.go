@@ -7,9 +7,13 @@ import (
 	"djj-inventory-system/internal/model/audit"
 	"djj-inventory-system/internal/model/catalog"
 	"djj-inventory-system/internal/model/company"
+	"djj-inventory-system/internal/model/finance"
 	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/outbox"
 	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/model/realtime"
 	"djj-inventory-system/internal/model/sales"
+	audit2 "djj-inventory-system/internal/pkg/audit"
 	"fmt"
 	"log"
 	"os"
@@ -32,13 +36,22 @@ func Connect() *gorm.DB {
 		log.Fatal(err)
 	}
 	Migrate(gormDB)
+	// 受审计表的增删改自动写 audited_history
+	if err := audit2.RegisterCallbacks(gormDB); err != nil {
+		log.Fatal(err)
+	}
 	return gormDB
 }
+
+// DSN 数据库连接串；事件总线的 LISTEN 连接也用它
+func DSN(dbName string) string {
+	return fmt.Sprintf("host=localhost user=djj password=qq123456 dbname=%s sslmode=disable", dbName)
+}
+
 func InitDB(dbName string) *sql.DB {
 	// 连接到目标数据库
 	config.Load()
-	connStrTarget := fmt.Sprintf("host=localhost user=djj password=qq123456 dbname=%s sslmode=disable", dbName)
-	dbTarget, err := sql.Open("postgres", connStrTarget)
+	dbTarget, err := sql.Open("postgres", DSN(dbName))
 	if err != nil {
 		logger.Fatalf("fail to connect to the %s", dbName, err.Error())
 	}
@@ -358,6 +371,248 @@ func Migrate(db *gorm.DB) {
 				return tx.Exec(`DROP TYPE IF EXISTS transaction_type;`).Error
 			},
 		},
+		{
+			ID: "20250712_extend_transaction_type",
+			Migrate: func(tx *gorm.DB) error {
+				// 初版 enum 只有 IN/OUT/SALE，预留、释放等流水写入会失败，这里补齐所有事务类型
+				for _, t := range []inventory.TransactionType{
+					inventory.TransactionTypeReserve,
+					inventory.TransactionTypeRelease,
+					inventory.TransactionTypeAdjust,
+					inventory.TransactionTypeTransferIn,
+					inventory.TransactionTypeTransferOut,
+					inventory.TransactionTypeReturn,
+					inventory.TransactionTypeDamage,
+					inventory.TransactionTypeExpired,
+					inventory.TransactionTypeStolen,
+				} {
+					if err := tx.Exec(fmt.Sprintf(`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS '%s';`, t)).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250714_add_stock_transfers",
+			Migrate: func(tx *gorm.DB) error {
+				// inventory_transaction 增加 reference 列，调拨两端流水共用调拨单号
+				if err := tx.AutoMigrate(&inventory.InventoryTransaction{}); err != nil {
+					return err
+				}
+				return tx.AutoMigrate(&inventory.StockTransfer{}, &inventory.StockTransferItem{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("stock_transfer_items", "stock_transfers")
+			},
+		},
+		{
+			ID: "20250715_add_stock_adjustments",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&inventory.StockAdjustment{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec("DELETE FROM attachments WHERE ref_type = ?", inventory.AttachmentRefStockAdjustment).Error; err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("stock_adjustments")
+			},
+		},
+		{
+			ID: "20250716_add_stocktakes",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&inventory.Stocktake{}, &inventory.StocktakeLine{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("stocktake_lines", "stocktakes")
+			},
+		},
+		{
+			ID: "20250717_add_stock_reservations",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&inventory.StockReservation{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("stock_reservations")
+			},
+		},
+		{
+			ID: "20250718_add_quote_revisions",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&sales.Quote{}); err != nil {
+					return err
+				}
+				// 已有报价视为各自的版本 A
+				return tx.Exec(`UPDATE quotes SET base_number = quote_number, revision = 'A' WHERE base_number IS NULL OR base_number = ''`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, col := range []string{"BaseNumber", "Revision", "SupersededByID", "CreatedBy", "ReviewedBy", "ReviewedAt", "ReviewNote"} {
+					if err := tx.Migrator().DropColumn(&sales.Quote{}, col); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250719_add_picking_allocation",
+			Migrate: func(tx *gorm.DB) error {
+				// product_stocks 含生成列 available，只单独加库位列，不做 AutoMigrate
+				if !tx.Migrator().HasColumn(&catalog.ProductStock{}, "BinLocation") {
+					if err := tx.Migrator().AddColumn(&catalog.ProductStock{}, "BinLocation"); err != nil {
+						return err
+					}
+				}
+				return tx.AutoMigrate(&sales.PickingList{}, &sales.PickingListItem{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, col := range []string{"OrderItemID", "WarehouseID", "ReservationID", "PickedQty", "PickedBy", "PickedAt"} {
+					if err := tx.Migrator().DropColumn(&sales.PickingListItem{}, col); err != nil {
+						return err
+					}
+				}
+				if err := tx.Migrator().DropColumn(&sales.PickingList{}, "ParentID"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&catalog.ProductStock{}, "BinLocation")
+			},
+		},
+		{
+			ID: "20250720_add_tax_invoices",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&finance.DocumentSequence{},
+					&finance.TaxInvoice{},
+					&finance.TaxInvoiceLine{},
+					&finance.CreditNote{},
+					&finance.CreditNoteLine{},
+					&finance.Payment{},
+				)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("payments", "credit_note_lines", "credit_notes",
+					"tax_invoice_lines", "tax_invoices", "document_sequences")
+			},
+		},
+		{
+			ID: "20250724_add_refresh_tokens",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&rbac.RefreshToken{}, &rbac.RevokedToken{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("revoked_tokens", "refresh_tokens")
+			},
+		},
+		{
+			ID: "20250727_add_login_lockout_and_password_reset",
+			Migrate: func(tx *gorm.DB) error {
+				for _, col := range []string{"FailedLogins", "LockedUntil", "PasswordChangedAt"} {
+					if !tx.Migrator().HasColumn(&rbac.User{}, col) {
+						if err := tx.Migrator().AddColumn(&rbac.User{}, col); err != nil {
+							return err
+						}
+					}
+				}
+				return tx.AutoMigrate(&rbac.PasswordResetToken{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("password_reset_tokens"); err != nil {
+					return err
+				}
+				for _, col := range []string{"FailedLogins", "LockedUntil", "PasswordChangedAt"} {
+					if err := tx.Migrator().DropColumn(&rbac.User{}, col); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250729_add_totp",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&rbac.UserTOTP{}, &rbac.RecoveryCode{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("recovery_codes", "user_totps")
+			},
+		},
+		{
+			ID: "20250731_add_api_keys",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&rbac.APIKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("api_keys")
+			},
+		},
+		{
+			ID: "20250802_add_audit_row_images",
+			Migrate: func(tx *gorm.DB) error {
+				for _, col := range []string{"OldData", "NewData"} {
+					if !tx.Migrator().HasColumn(&audit.AuditedHistory{}, col) {
+						if err := tx.Migrator().AddColumn(&audit.AuditedHistory{}, col); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, col := range []string{"OldData", "NewData"} {
+					if err := tx.Migrator().DropColumn(&audit.AuditedHistory{}, col); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250804_add_audit_hash_chain",
+			Migrate: func(tx *gorm.DB) error {
+				for _, col := range []string{"PrevHash", "Hash"} {
+					if !tx.Migrator().HasColumn(&audit.AuditedHistory{}, col) {
+						if err := tx.Migrator().AddColumn(&audit.AuditedHistory{}, col); err != nil {
+							return err
+						}
+					}
+				}
+				// 同时补上 chain_seq 列（链上位置）和检查点表
+				if err := tx.AutoMigrate(&audit.AuditedHistory{}, &audit.Checkpoint{}); err != nil {
+					return err
+				}
+				// 已有的历史记录按 history_id 顺序补链；用迁移自己冻结的实现，不随 pkg/audit 变化
+				return rechainAuditHistory20250804(tx)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("audit_checkpoints"); err != nil {
+					return err
+				}
+				for _, col := range []string{"ChainSeq", "PrevHash", "Hash"} {
+					if err := tx.Migrator().DropColumn(&audit.AuditedHistory{}, col); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250806_add_realtime_events",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&realtime.Event{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("realtime_events")
+			},
+		},
+		{
+			ID: "20250808_add_outbox",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&outbox.Event{}, &outbox.Delivery{}, &outbox.Consumer{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("outbox_deliveries", "outbox_consumers", "outbox_events")
+			},
+		},
 		// 在你 migrate.go 的 migrations 列表里，追加一段：
 		//{
 		//	ID: "20250611_add_deleted_at_to_users",
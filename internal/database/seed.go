@@ -258,6 +258,9 @@ func (s *Seeder) Run() error {
 			CompanyID:   st.CompanyID,
 			CustomerID:  cust.ID,
 			QuoteNumber: fmt.Sprintf("QTE-%04d", i),
+			BaseNumber:  fmt.Sprintf("QTE-%04d", i),
+			Revision:    sales.FirstQuoteRevision,
+			CreatedBy:   rep.ID,
 			SalesRepID:  rep.ID,
 			QuoteDate:   time.Now(),
 			Currency:    "AUD",
@@ -0,0 +1,195 @@
+// internal/handler/adjustment_handler.go
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdjustmentHandler struct {
+	Svc *service.AdjustmentService
+}
+
+// NewAdjustmentHandler 在 /inventory/adjustments 下挂载库存调整 / 报损路由
+func NewAdjustmentHandler(rg *gin.RouterGroup, svc *service.AdjustmentService) {
+	h := &AdjustmentHandler{Svc: svc}
+	grp := rg.Group("/inventory/adjustments")
+
+	view := RequirePermission("inventory.view")
+	grp.GET("", view, h.List)
+	grp.GET("/reason-codes", view, h.ReasonCodes)
+	grp.GET("/:id", view, h.Get)
+
+	adjust := RequirePermission("inventory.adjust")
+	grp.POST("", adjust, h.Create)
+	grp.POST("/:id/attachments", adjust, h.AddAttachments)
+	grp.POST("/:id/approve", adjust, RequireLeader(), h.Approve)
+	grp.POST("/:id/reject", adjust, RequireLeader(), h.Reject)
+}
+
+// ReasonCodes GET /api/inventory/adjustments/reason-codes
+// 返回各调整 / 报损类型可用的原因码以及当前审批阈值
+func (h *AdjustmentHandler) ReasonCodes(c *gin.Context) {
+	types := []inventory.TransactionType{
+		inventory.TransactionTypeAdjust,
+		inventory.TransactionTypeDamage,
+		inventory.TransactionTypeExpired,
+		inventory.TransactionTypeStolen,
+	}
+	codes := make(map[inventory.TransactionType][]inventory.ReasonCode, len(types))
+	for _, t := range types {
+		codes[t] = inventory.ReasonCodesFor(t)
+	}
+	p := h.Svc.Policy()
+	c.JSON(http.StatusOK, gin.H{
+		"reasonCodes":            codes,
+		"approvalQtyThreshold":   p.QtyThreshold,
+		"approvalValueThreshold": p.ValueThreshold,
+	})
+}
+
+// List GET /api/inventory/adjustments?status=pending&txType=DAMAGE&reasonCode=THEFT&warehouseId=1&productId=2&start=2025-07-01&end=2025-07-31
+func (h *AdjustmentHandler) List(c *gin.Context) {
+	f := repository.AdjustmentFilter{
+		Status:     inventory.AdjustmentStatus(c.Query("status")),
+		TxType:     inventory.TransactionType(c.Query("txType")),
+		ReasonCode: inventory.ReasonCode(c.Query("reasonCode")),
+	}
+	if c.Query("warehouseId") != "" {
+		id, ok := parseIDQuery(c, "warehouseId")
+		if !ok {
+			return
+		}
+		f.WarehouseID = id
+	}
+	if c.Query("productId") != "" {
+		id, ok := parseIDQuery(c, "productId")
+		if !ok {
+			return
+		}
+		f.ProductID = id
+	}
+	if v := c.Query("start"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start date, expected YYYY-MM-DD"})
+			return
+		}
+		f.Start = &t
+	}
+	if v := c.Query("end"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end date, expected YYYY-MM-DD"})
+			return
+		}
+		// end 取当天结束
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		f.End = &t
+	}
+
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "adjustments": list})
+}
+
+// Get GET /api/inventory/adjustments/:id
+func (h *AdjustmentHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	a, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// Create POST /api/inventory/adjustments
+// 未超审批阈值时立即过账（status=posted），否则返回 202 和待审批单据
+func (h *AdjustmentHandler) Create(c *gin.Context) {
+	var req dto.CreateAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := h.Svc.Create(c.Request.Context(), req, currentOperator(c), currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	if a.Status == inventory.AdjustmentStatusPending {
+		c.JSON(http.StatusAccepted, a)
+		return
+	}
+	c.JSON(http.StatusCreated, a)
+}
+
+// AddAttachments POST /api/inventory/adjustments/:id/attachments
+// 文件先通过 /api/upload 上传，这里只登记文件信息
+func (h *AdjustmentHandler) AddAttachments(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req []dto.AttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := h.Svc.AddAttachments(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// Approve POST /api/inventory/adjustments/:id/approve
+func (h *AdjustmentHandler) Approve(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ReviewAdjustmentRequest
+	// 审批意见可选，允许空 body
+	_ = c.ShouldBindJSON(&req)
+	a, err := h.Svc.Approve(c.Request.Context(), id, currentOperator(c), req.Note)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// Reject POST /api/inventory/adjustments/:id/reject
+func (h *AdjustmentHandler) Reject(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ReviewAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := h.Svc.Reject(c.Request.Context(), id, currentOperator(c), req.Note)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyAdminPermission 可以查看、吊销所有人的 API Key
+const apiKeyAdminPermission = "user.permission"
+
+type APIKeyHandler struct {
+	svc *service.APIKeyService
+}
+
+// NewAPIKeyHandler API Key 管理路由；只能用登录会话管理，不能用 API Key 再建 API Key
+func NewAPIKeyHandler(rg *gin.RouterGroup, svc *service.APIKeyService) {
+	h := &APIKeyHandler{svc: svc}
+	grp := rg.Group("/api-keys", RequireInteractiveLogin())
+	grp.GET("", h.List)
+	grp.POST("", h.Create)
+	grp.DELETE("/:id", h.Revoke)
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Permissions []string   `json:"permissions" binding:"required,min=1"`
+	StoreID     *uint      `json:"storeId"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+// List godoc
+// @Summary      API Key 列表
+// @Description  默认列出自己的密钥；all=true 且有 user.permission 权限时列出所有人的
+// @Tags         api-keys
+// @Produce      json
+// @Param        all  query  bool  false  "列出所有人的"
+// @Success      200  {array}  rbac.APIKey
+// @Failure      403  {object} ErrorResponse
+// @Router       /api-keys [get]
+func (h *APIKeyHandler) List(c *gin.Context) {
+	userID := currentUserID(c)
+	if c.Query("all") == "true" {
+		if !hasPermission(c, apiKeyAdminPermission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限: " + apiKeyAdminPermission})
+			return
+		}
+		userID = 0
+	}
+	keys, err := h.svc.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// Create godoc
+// @Summary      新建 API Key
+// @Description  权限必须取自权限模块且是自己拥有的；可限定门店和有效期。明文密钥只在此返回一次
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        payload  body  CreateAPIKeyRequest  true  "密钥信息"
+// @Success      201  {object}  map[string]interface{}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /api-keys [post]
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var in CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, p := range in.Permissions {
+		if !knownPermission(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知权限: " + p})
+			return
+		}
+	}
+	plain, k, err := h.svc.Create(c.Request.Context(), currentUserID(c), service.CreateAPIKeyInput{
+		Name:        in.Name,
+		Permissions: in.Permissions,
+		StoreID:     in.StoreID,
+		ExpiresAt:   in.ExpiresAt,
+	})
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": plain, "apiKey": k})
+}
+
+// Revoke godoc
+// @Summary      吊销 API Key
+// @Description  吊销自己的密钥；有 user.permission 权限时可吊销任何人的
+// @Tags         api-keys
+// @Param        id  path  int  true  "密钥 ID"
+// @Success      204
+// @Failure      404  {object}  ErrorResponse
+// @Router       /api-keys/{id} [delete]
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID := currentUserID(c)
+	if hasPermission(c, apiKeyAdminPermission) {
+		userID = 0
+	}
+	if err := h.svc.Revoke(c.Request.Context(), id, userID); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// knownPermission 权限名是否在 PermissionModules 里
+func knownPermission(name string) bool {
+	for _, mod := range PermissionModules {
+		for _, p := range mod.Permissions {
+			if p.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+// internal/handler/audit_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	Svc *service.AuditService
+}
+
+// NewAuditHandler 在 /audit 下挂载审计历史查询和恢复路由
+func NewAuditHandler(rg *gin.RouterGroup, svc *service.AuditService) {
+	h := &AuditHandler{Svc: svc}
+	grp := rg.Group("/audit")
+	view := RequirePermission("system.log")
+	grp.GET("", view, h.List)
+	grp.GET("/entries/:id", view, h.Get)
+	grp.GET("/records/:table/:id", view, h.Timeline)
+	grp.POST("/entries/:id/restore", RequirePermission("system.restore"), RequireInteractiveLogin(), h.Restore)
+	grp.GET("/verify", view, h.Verify)
+	grp.GET("/checkpoints", view, h.Checkpoints)
+	// 检查点给链头签名，和恢复一样只能由有 system.restore 的账号登录后手工创建
+	grp.POST("/checkpoints", RequirePermission("system.restore"), RequireInteractiveLogin(), h.CreateCheckpoint)
+}
+
+// List GET /api/audit?table=products&recordId=1&userId=2&storeId=3&operation=UPDATE&start=2025-08-01&end=2025-08-31&offset=0&limit=20
+func (h *AuditHandler) List(c *gin.Context) {
+	f := repository.AuditFilter{
+		Table:     audit.AuditedTableEnum(c.Query("table")),
+		Operation: c.Query("operation"),
+	}
+	for name, dst := range map[string]*uint{"recordId": &f.RecordID, "userId": &f.UserID, "storeId": &f.StoreID} {
+		if c.Query(name) != "" {
+			id, ok := parseIDQuery(c, name)
+			if !ok {
+				return
+			}
+			*dst = id
+		}
+	}
+	if v := c.Query("start"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start date, expected YYYY-MM-DD"})
+			return
+		}
+		f.Start = &t
+	}
+	if v := c.Query("end"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end date, expected YYYY-MM-DD"})
+			return
+		}
+		// end 取当天结束
+		t = t.Add(24*time.Hour - time.Nanosecond)
+		f.End = &t
+	}
+
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "entries": list})
+}
+
+// Get GET /api/audit/entries/:id
+func (h *AuditHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	e, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+// Timeline GET /api/audit/records/:table/:id 一条记录的全部变更，按时间先后
+func (h *AuditHandler) Timeline(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.Svc.Timeline(c.Request.Context(), audit.AuditedTableEnum(c.Param("table")), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": list})
+}
+
+// Restore POST /api/audit/entries/:id/restore 把记录恢复到该条历史之后的状态
+func (h *AuditHandler) Restore(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	e, err := h.Svc.Restore(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"restored": gin.H{"table": e.TableName, "recordId": e.RecordID}, "from": e})
+}
+
+// Verify GET /api/audit/verify 校验哈希链和签名检查点，ok 为 false 时报告第一处断点
+func (h *AuditHandler) Verify(c *gin.Context) {
+	rep, err := h.Svc.Verify(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+// Checkpoints GET /api/audit/checkpoints 导出全部签名检查点和验签公钥，供外部审计方离线核对
+func (h *AuditHandler) Checkpoints(c *gin.Context) {
+	list, err := h.Svc.Checkpoints(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKeys": h.Svc.PublicKeys(), "checkpoints": list})
+}
+
+// CreateCheckpoint POST /api/audit/checkpoints 立即给当前链尾签一个检查点
+func (h *AuditHandler) CreateCheckpoint(c *gin.Context) {
+	cp, err := h.Svc.CreateCheckpoint(c.Request.Context())
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "audit history is empty"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, cp)
+}
@@ -1,84 +1,67 @@
 package handler
 
 import (
+	"djj-inventory-system/internal/model/catalog"
 	"djj-inventory-system/internal/model/rbac"
-	"djj-inventory-system/internal/pkg/auth"
 	"djj-inventory-system/internal/service"
-	"fmt"
+	"errors"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtSecret = []byte("DJJ_JWT_SECRET")
-
 type AuthHandler struct {
-	userSvc service.UserService
+	userSvc  service.UserService
+	sessions *service.SessionService
+	mfa      *service.MFAService
 }
 
-// NewAuthHandler 把 Session middleware 和 三个路由都挂到同一个 group
-func NewAuthHandler(rg *gin.RouterGroup, us service.UserService) {
-	h := &AuthHandler{userSvc: us}
-	grp := rg.Group("auth") // 挂在/api下
-	grp.POST("/register", h.Register)
+// NewAuthHandler 登录 / 刷新 / 注销挂在 public；注册只对已登录且有 user.create 权限的人开放，挂在 protected
+func NewAuthHandler(public, protected *gin.RouterGroup, us service.UserService, sessions *service.SessionService, mfa *service.MFAService) {
+	h := &AuthHandler{userSvc: us, sessions: sessions, mfa: mfa}
+	protected.POST("/auth/register", RequirePermission("user.create"), h.Register)
+	grp := public.Group("auth") // 挂在/api下
 	grp.POST("/login", h.Login)
+	grp.POST("/refresh", h.Refresh)
 	grp.POST("/logout", h.Logout)
+	grp.POST("/logout-all", RequireLogin(), RequireInteractiveLogin(), h.LogoutAll)
 	grp.GET("/me", h.GetProfile)
 }
 
 // Register godoc
 // @Summary      用户注册
-// @Description  使用用户名、邮箱、密码和可选角色 ID 列表创建新用户，并下发登录 Cookie
+// @Description  使用用户名、邮箱、密码和可选角色名列表创建新用户；需要 user.create，指定角色还需要 user.permission
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        payload  body     RegisterRequest  true  "注册信息"
 // @Success      201      {object} model.User
 // @Failure      400      {object} ErrorResponse
+// @Failure      403      {object} ErrorResponse
 // @Failure      500      {object} ErrorResponse
-// @Router       /register [post]
+// @Router       /auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
 	var in RegisterRequest
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	// 指定角色等同授权，和 POST /users/:id/roles/:rid 一样要求 user.permission
+	if len(in.RoleNames) > 0 && !hasPermission(c, "user.permission") {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "指定角色需要 user.permission 权限"})
+		return
+	}
 	u, err := h.userSvc.Create(c, in.Username, in.Email, in.Password, in.RoleNames)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(c, err)
 		return
 	}
-
-	// 4. 发 JWT
-	//token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-	//	"sub":         u.ID,
-	//	"role":        u.Roles[0].Name,
-	//	"permissions": []string{}, // 默认无额外权限
-	//	"exp":         time.Now().Add(24 * time.Hour).Unix(),
-	//})
-	//s, _ := token.SignedString(jwtSecret)
-
-	//c.JSON(http.StatusOK, gin.H{
-	//	"token": s,
-	//	"user": gin.H{
-	//		"id":         usr.ID,
-	//		"name":       usr.FullName,
-	//		"email":      usr.Email,
-	//		"role":       role.Name,
-	//		"avatar_url": usr.AvatarURL,
-	//	},
-	//})
-	// 简单起见，注册后直接打登录 cookie
-	c.SetCookie("uid", fmt.Sprint(u.ID), int((7 * 24 * time.Hour).Seconds()), "/", "", false, true)
 	c.JSON(http.StatusCreated, u)
 }
 
 // Login godoc
 // @Summary      用户登录
-// @Description  使用用户名和密码登录，返回 Session Cookie
+// @Description  使用用户名和密码登录，返回 Session Cookie；需要两步验证时返回 mfaToken，再调 /auth/login/totp
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -96,147 +79,157 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Authenticate returns a User with Roles and Permissions preloaded
 	u, sd, err := h.userSvc.Authenticate(c, in.Email, in.Password)
+	if errors.Is(err, service.ErrAccountLocked) {
+		writeLoginError(c, err)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "用户名或密码错误"})
 		return
 	}
 
-	finalPerms := buildFinalPerms(u)
-	role := joinRoleNames(u.Roles)
+	// 已启用两步验证，或角色要求启用但尚未绑定：先发两步验证凭证，不发会话
+	enabled, err := h.mfa.Enabled(c.Request.Context(), u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if enabled || h.mfa.Required(u) {
+		token, exp, err := h.sessions.IssueChallenge(u, !enabled)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfaRequired":        true,
+			"enrollmentRequired": !enabled,
+			"mfaToken":           token,
+			"expiresAt":          exp,
+		})
+		return
+	}
 
-	// 5. 生成 JWT，包含 sub/role/permissions
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":         u.ID,
-		"name":        u.Username, // ← 新增这一行
-		"role":        role,
-		"permissions": finalPerms,
-		"exp":         time.Now().Add(24 * time.Hour).Unix(),
-		"avatar_url":  u.AvatarURL,
-		"sd":          sd,
-	})
-	tokenString, err := token.SignedString(jwtSecret)
+	pair, err := h.sessions.Login(c.Request.Context(), u, sd, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
 		return
 	}
-	// 5. 写入 HttpOnly Cookie
-	//    maxAge 单位是秒； path、domain、secure、httpOnly 根据你的需求调整
-	c.SetCookie(
-		"access_token", // name
-		tokenString,    // value
-		24*3600,        // maxAge: 24h
-		"/",            // path
-		"",             // domain: 改成你的域名，或留空字符串让浏览器自动匹配当前域
-		false,          // secure: 生产环境请设为 true (HTTPS)
-		true,           // httpOnly: JS 无法读取
-	)
-	// 6. 返回给前端
-	payload := gin.H{
-		"id":           u.ID,
-		"name":         u.Username,
-		"email":        u.Email,
-		"role":         role,
-		"permissions":  finalPerms,
-		"storedetails": sd,
-		"profile":      u,
-	}
-	writeAuthResponse(c, tokenString, payload)
+	writeAuthResponse(c, pair, profilePayload(u, sd))
+}
 
+// Refresh godoc
+// @Summary      刷新登录
+// @Description  用 refresh_token Cookie 换新的访问令牌；刷新令牌同时轮换，旧的立即失效
+// @Tags         auth
+// @Produce      json
+// @Success      200      {object} ResponseMessage
+// @Failure      401      {object} ErrorResponse
+// @Router       /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	raw, _ := c.Cookie("refresh_token")
+	pair, u, sd, err := h.sessions.Refresh(c.Request.Context(), raw, clientInfo(c))
+	if errors.Is(err, service.ErrInvalidToken) {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "登录已失效，请重新登录"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// 角色后来变成必须启用两步验证而用户还没绑定：不再续期，重新登录时走绑定流程
+	if h.mfa.Required(u) {
+		enabled, err := h.mfa.Enabled(c.Request.Context(), u.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if !enabled {
+			_ = h.sessions.Logout(c.Request.Context(), nil, pair.RefreshToken)
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "需要启用两步验证，请重新登录"})
+			return
+		}
+	}
+	writeAuthResponse(c, pair, profilePayload(u, sd))
 }
 
 // Logout godoc
 // @Summary      用户登出
-// @Description  清除登录 Cookie
+// @Description  吊销当前访问令牌和刷新令牌，清除登录 Cookie
 // @Tags         auth
 // @Produce      json
 // @Success      200      {object} ResponseMessage
 // @Failure      500      {object} ErrorResponse
 // @Router       /logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// 调用我们 auth 包里的 ClearSession
-	auth.ClearSession(c.Writer)
-
+	var access *service.AccessClaims
+	if token, err := c.Cookie("access_token"); err == nil {
+		// 访问令牌已过期也没关系，刷新令牌作废后就无法续期
+		access, _ = h.sessions.Verify(c.Request.Context(), token)
+	}
+	raw, _ := c.Cookie("refresh_token")
+	if err := h.sessions.Logout(c.Request.Context(), access, raw); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	clearAuthCookies(c)
 	c.JSON(http.StatusOK, ResponseMessage{Message: "logged out"})
 }
 
-func (h *AuthHandler) GetProfile(c *gin.Context) {
-	// 1. 从 Cookie 里读 access_token
-	tokenString, err := c.Cookie("access_token")
+// LogoutAll godoc
+// @Summary      注销全部会话
+// @Description  吊销当前用户在所有设备上的登录
+// @Tags         auth
+// @Produce      json
+// @Success      200      {object} ResponseMessage
+// @Failure      401      {object} ErrorResponse
+// @Router       /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	uid := currentUserID(c)
+	n, err := h.sessions.LogoutAll(c.Request.Context(), uid)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或 Cookie 丢失"})
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 		return
 	}
-
-	// 2. 解析并校验 JWT
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
+	// 当前请求用的访问令牌不一定来自刷新令牌链路（例如刚刷新过），单独吊销
+	if token, err := c.Cookie("access_token"); err == nil {
+		if access, err := h.sessions.Verify(c.Request.Context(), token); err == nil {
+			_ = h.sessions.Logout(c.Request.Context(), access, "")
 		}
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的 token"})
-		return
 	}
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "logged out everywhere", "sessions": n})
+}
 
-	// 3. 提取 sub (用户 ID)
-	claims, _ := token.Claims.(jwt.MapClaims)
-	subFloat, ok := claims["sub"].(float64)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "token sub 字段格式错误"})
+// GetProfile 当前登录用户资料；令牌已由 SessionAuthMiddleware 校验
+func (h *AuthHandler) GetProfile(c *gin.Context) {
+	uid := currentUserID(c)
+	if uid == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或 Cookie 丢失"})
 		return
 	}
-	userID := uint(subFloat)
-	u, sd, err := h.userSvc.GetProfile(c, userID)
-
+	u, sd, err := h.userSvc.GetProfile(c, uid)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
 	}
+	token, _ := c.Cookie("access_token")
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  profilePayload(u, sd),
+	})
+}
 
-	c.SetCookie(
-		"access_token", // name
-		tokenString,    // value
-		24*3600,        // maxAge: 24h
-		"/",            // path
-		"",             // domain: 改成你的域名，或留空字符串让浏览器自动匹配当前域
-		false,          // secure: 生产环境请设为 true (HTTPS)
-		true,           // httpOnly: JS 无法读取
-	)
-	// 6. 返回给前端
-	payload := gin.H{
+// profilePayload 登录 / 刷新 / 查询资料时返回给前端的用户信息
+func profilePayload(u *rbac.User, sd *catalog.StoreDetails) gin.H {
+	return gin.H{
 		"id":           u.ID,
 		"name":         u.Username,
 		"email":        u.Email,
-		"role":         joinRoleNames(u.Roles),
-		"permissions":  buildFinalPerms(u),
+		"role":         service.RoleNames(u.Roles),
+		"permissions":  service.FinalPermissions(u),
 		"storedetails": sd,
 		"profile":      u,
 	}
-	writeAuthResponse(c, tokenString, payload)
-}
-
-func joinRoleNames(roles []rbac.Role) string {
-	join := strings.Join(func() []string {
-		names := make([]string, len(roles))
-		for i, r := range roles {
-			names[i] = r.Name
-		}
-		return names
-	}(), ",")
-	return join
-}
-
-func buildFinalPerms(user *rbac.User) []string {
-	set := make(map[string]struct{}, len(user.Permissions)+len(user.DirectPermissions))
-	for _, p := range user.Permissions {
-		set[p.Name] = struct{}{}
-	}
-	for _, p := range user.DirectPermissions {
-		set[p.Name] = struct{}{}
-	}
-	out := make([]string, 0, len(set))
-	for name := range set {
-		out = append(out, name)
-	}
-	return out
 }
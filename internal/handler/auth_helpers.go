@@ -3,28 +3,88 @@ package handler
 
 import (
 	"net/http"
+	"time"
+
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// refreshCookiePath 刷新令牌 Cookie 只随 /api/auth 下的请求发送
+const refreshCookiePath = "/api/auth"
+
 // writeAuthResponse 负责：
-//  1. 把 token 写到 HttpOnly Cookie
-//  2. 把同名 JSON body 写回客户端
-func writeAuthResponse(c *gin.Context, token string, payload any) {
+//  1. 把访问令牌、刷新令牌写到 HttpOnly Cookie
+//  2. 把访问令牌和用户信息写回客户端（刷新令牌只放在 Cookie 里）
+func writeAuthResponse(c *gin.Context, pair *service.TokenPair, payload any) {
 	// 1) 写 Cookie
+	setAuthCookies(c, pair)
+
+	// 2) 写 JSON
+	c.JSON(http.StatusOK, gin.H{
+		"token":     pair.AccessToken,
+		"expiresAt": pair.AccessExpiresAt,
+		"user":      payload,
+	})
+}
+
+// setAuthCookies 把访问令牌、刷新令牌写到 HttpOnly Cookie
+func setAuthCookies(c *gin.Context, pair *service.TokenPair) {
 	c.SetCookie(
 		"access_token",
-		token,
-		24*3600, // 1 天
+		pair.AccessToken,
+		int(time.Until(pair.AccessExpiresAt).Seconds()),
 		"/",
 		"",    // domain
 		false, // secure
 		true,  // httpOnly
 	)
+	c.SetCookie("refresh_token", pair.RefreshToken, int(time.Until(pair.RefreshExpiresAt).Seconds()),
+		refreshCookiePath, "", false, true)
+}
 
-	// 2) 写 JSON
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user":  payload,
-	})
+// clearAuthCookies 删除访问令牌、刷新令牌和旧的 session Cookie
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("access_token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, refreshCookiePath, "", false, true)
+	auth.ClearSession(c.Writer)
+}
+
+// clientInfo 登录设备信息
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// setPrincipal 把调用者同时放进 gin 上下文和 request context（连同数据范围）
+// 服务层、仓储层和审计都通过 auth.FromContext 读取
+func setPrincipal(c *gin.Context, p *auth.Principal) {
+	c.Set(auth.GinKey, p)
+	c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+}
+
+// currentPrincipal 当前调用者，未登录时为 nil
+func currentPrincipal(c *gin.Context) *auth.Principal {
+	if v, ok := c.Get(auth.GinKey); ok {
+		if p, ok := v.(*auth.Principal); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// currentOperator 返回当前登录用户名，用作库存流水等记录里的操作人
+func currentOperator(c *gin.Context) string {
+	if p := currentPrincipal(c); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// currentUserID 返回当前登录用户 ID，未登录时为 0
+func currentUserID(c *gin.Context) uint {
+	if p := currentPrincipal(c); p != nil {
+		return p.UserID
+	}
+	return 0
 }
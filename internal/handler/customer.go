@@ -2,9 +2,9 @@ package handler
 
 import (
 	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/pkg/eventbus"
 	"djj-inventory-system/internal/service"
-	"djj-inventory-system/internal/websocket"
-	"encoding/json"
 	"net/http"
 	"strconv"
 
@@ -13,17 +13,19 @@ import (
 
 type CustomerHandler struct {
 	svc service.CustomerService
-	hub *websocket.Hub
+	bus eventbus.Bus
 }
 
-func NewCustomerHandler(rg *gin.RouterGroup, svc service.CustomerService, hub *websocket.Hub) {
-	h := &CustomerHandler{svc, hub}
+func NewCustomerHandler(rg *gin.RouterGroup, svc service.CustomerService, bus eventbus.Bus) {
+	h := &CustomerHandler{svc, bus}
 	grp := rg.Group("/customers")
-	grp.GET("", h.List)
-	grp.GET(":id", h.Get)
-	grp.POST("", h.Create)
-	grp.PUT(":id", h.Update)
-	grp.DELETE(":id", h.Delete)
+	// 客户在报价、订单、财务里都会用到
+	view := RequireAnyPermission("sales.view", "quote.view", "finance.view")
+	grp.GET("", view, h.List)
+	grp.GET(":id", view, h.Get)
+	grp.POST("", RequireAnyPermission("sales.create", "quote.create"), h.Create)
+	grp.PUT(":id", RequireAnyPermission("sales.edit", "quote.edit"), h.Update)
+	grp.DELETE(":id", RequirePermission("sales.delete"), h.Delete)
 }
 
 func (h *CustomerHandler) List(c *gin.Context) {
@@ -40,7 +42,7 @@ func (h *CustomerHandler) Get(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	cust, err := h.svc.Get(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		writeServiceError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, cust)
@@ -54,12 +56,11 @@ func (h *CustomerHandler) Create(c *gin.Context) {
 	}
 	out, err := h.svc.Create(c.Request.Context(), &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeServiceError(c, err)
 		return
 	}
 	// broadcast to WebSocket subscribers on topic "customers"
-	msg, _ := json.Marshal(gin.H{"event": "customerCreated", "payload": out})
-	h.hub.Broadcast("customers", msg)
+	h.publish(c, "customerCreated", out.ID, out.Version, out.StoreID, out)
 	c.JSON(http.StatusCreated, out)
 }
 
@@ -72,21 +73,30 @@ func (h *CustomerHandler) Update(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	out, err := h.svc.Update(c.Request.Context(), uint(id), &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeServiceError(c, err)
 		return
 	}
-	msg, _ := json.Marshal(gin.H{"event": "customerUpdated", "payload": out})
-	h.hub.Broadcast("customers", msg)
+	h.publish(c, "customerUpdated", out.ID, out.Version, out.StoreID, out)
 	c.JSON(http.StatusOK, out)
 }
 
 func (h *CustomerHandler) Delete(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	// 删除前先取门店，推送只发给能看到该门店的订阅者
+	cust, err := h.svc.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
 	if err := h.svc.Delete(c.Request.Context(), uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeServiceError(c, err)
 		return
 	}
-	msg, _ := json.Marshal(gin.H{"event": "customerDeleted", "payload": gin.H{"id": id}})
-	h.hub.Broadcast("customers", msg)
+	h.publish(c, "customerDeleted", cust.ID, cust.Version, cust.StoreID, gin.H{"id": id})
 	c.Status(http.StatusNoContent)
 }
+
+// publish 推送到 customers 频道，只发给能看到 storeID 的订阅者
+func (h *CustomerHandler) publish(c *gin.Context, event string, id uint, version int64, storeID uint, payload interface{}) {
+	publish(c, h.bus, realtime.Event{Topic: "customers", Event: event, Entity: "customer", EntityID: id, Version: version, StoreID: storeID}, payload)
+}
@@ -0,0 +1,156 @@
+// internal/handler/finance_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FinanceHandler struct {
+	Svc *service.FinanceService
+}
+
+// NewFinanceHandler 挂载税务发票、收款、贷项通知单路由；收款推进订单状态时的事件随事务写入 outbox
+func NewFinanceHandler(rg *gin.RouterGroup, svc *service.FinanceService) {
+	h := &FinanceHandler{Svc: svc}
+
+	view := RequirePermission("finance.view")
+
+	rg.POST("/orders/:id/invoice", RequirePermission("finance.invoice"), h.CreateInvoice)
+	rg.GET("/orders/:id/payments", view, h.ListPayments)
+	rg.POST("/orders/:id/payments", RequirePermission("finance.payment"), h.RecordPayment)
+
+	grp := rg.Group("/finance")
+	grp.GET("/invoices", view, h.ListInvoices)
+	grp.GET("/invoices/:id", view, h.GetInvoice)
+	grp.POST("/invoices/:id/credit-notes", RequirePermission("finance.refund"), h.CreateCreditNote)
+	grp.GET("/credit-notes/:id", view, h.GetCreditNote)
+}
+
+// ListInvoices GET /api/finance/invoices?status=unpaid&customerId=1&storeId=2&orderId=3&q=INV&outstanding=true&offset=0&limit=20
+func (h *FinanceHandler) ListInvoices(c *gin.Context) {
+	f := repository.InvoiceFilter{
+		Status:      c.Query("status"),
+		Keyword:     c.Query("q"),
+		Outstanding: c.Query("outstanding") == "true",
+	}
+	for name, dst := range map[string]*uint{"customerId": &f.CustomerID, "storeId": &f.StoreID, "orderId": &f.OrderID} {
+		if c.Query(name) == "" {
+			continue
+		}
+		id, ok := parseIDQuery(c, name)
+		if !ok {
+			return
+		}
+		*dst = id
+	}
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.ListInvoices(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "invoices": list})
+}
+
+// GetInvoice GET /api/finance/invoices/:id
+func (h *FinanceHandler) GetInvoice(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	inv, err := h.Svc.GetInvoice(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, inv)
+}
+
+// CreateInvoice POST /api/orders/:id/invoice
+func (h *FinanceHandler) CreateInvoice(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.CreateInvoiceRequest
+	// 参数可选，允许空 body
+	_ = c.ShouldBindJSON(&req)
+	inv, err := h.Svc.CreateInvoice(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, inv)
+}
+
+// ListPayments GET /api/orders/:id/payments
+func (h *FinanceHandler) ListPayments(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.Svc.ListPayments(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payments": list})
+}
+
+// RecordPayment POST /api/orders/:id/payments
+func (h *FinanceHandler) RecordPayment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.RecordPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.Svc.RecordPayment(c.Request.Context(), id, req, currentOperator(c), currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// CreateCreditNote POST /api/finance/invoices/:id/credit-notes
+func (h *FinanceHandler) CreateCreditNote(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.CreateCreditNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cn, err := h.Svc.CreateCreditNote(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, cn)
+}
+
+// GetCreditNote GET /api/finance/credit-notes/:id
+func (h *FinanceHandler) GetCreditNote(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	cn, err := h.Svc.GetCreditNote(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cn)
+}
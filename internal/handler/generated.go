@@ -1,15 +1,15 @@
 package handler
 
 import (
-	"context"
+	"bytes"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/chromedp/chromedp"
+	"djj-inventory-system/internal/model/sales"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -245,7 +245,7 @@ const invoiceTemplate = `
             <tr>
                 <td class="djj-code">{{.DJJCode}}</td>
                 <td class="description">
-                    {{$first, $rest := splitDescription .Description}}
+                    {{$first := firstLine .Description}}{{$rest := restLines .Description}}
                     {{if .DJJCode}}
                         <strong>{{$first}}</strong>
                     {{else}}
@@ -288,65 +288,80 @@ const invoiceTemplate = `
 </html>
 `
 
-func GeneratePDF(c *gin.Context) {
+// invoiceTmpl 启动时解析一次，模板有错直接 panic
+var invoiceTmpl = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"formatPrice":           formatPrice,
+	"formatPriceWithCommas": formatPriceWithCommas,
+	"firstLine":             func(desc string) string { first, _ := splitDescription(desc); return first },
+	"restLines":             func(desc string) string { _, rest := splitDescription(desc); return rest },
+}).Parse(invoiceTemplate))
+
+// fallback 没有 Chrome 时交给纯 Go 版式的数据
+func (i *InvoiceData) fallback() sales.Invoice {
+	inv := sales.Invoice{
+		CompanyName:        i.CompanyName,
+		CompanyEmail:       i.CompanyEmail,
+		CompanyPhone:       i.CompanyPhone,
+		CompanyWebsite:     i.CompanyWebsite,
+		CompanyABN:         i.CompanyABN,
+		CompanyAddress:     i.CompanyAddress,
+		InvoiceNumber:      i.InvoiceNumber,
+		InvoiceDate:        i.InvoiceDate,
+		InvoiceType:        i.InvoiceType,
+		ShowPrices:         i.ShowPrices,
+		BillingAddress:     i.BillingAddress,
+		DeliveryAddress:    i.DeliveryAddress,
+		CustomerCompany:    i.CustomerCompany,
+		CustomerABN:        i.CustomerABN,
+		CustomerContact:    i.CustomerContact,
+		CustomerPhone:      i.CustomerPhone,
+		CustomerEmail:      i.CustomerEmail,
+		SalesRep:           i.SalesRep,
+		BankName:           i.BankName,
+		BSB:                i.BSB,
+		AccountNumber:      i.AccountNumber,
+		TermsAndConditions: i.TermsAndConditions,
+	}
+	if i.ShowPrices {
+		// 请求里没有税额，和 HTML 版一样只给合计
+		inv.SubtotalAmount = i.CalculateTotal()
+		inv.TotalAmount = inv.SubtotalAmount
+	}
+	for _, it := range i.Items {
+		inv.Items = append(inv.Items, sales.Item{
+			ID:          it.ID,
+			DJJCode:     it.DJJCode,
+			Description: it.Description,
+			VinEngine:   it.VinEngine,
+			Quantity:    it.Quantity,
+			Location:    it.Location,
+			UnitPrice:   it.UnitPrice,
+			Subtotal:    it.TotalPrice,
+		})
+	}
+	return inv
+}
+
+// GeneratePDF POST /api/generate-pdf，按前端传来的数据生成单据 PDF
+// 和其他 PDF 接口共用浏览器池，排队已满返回 503
+func (h *InvoiceHandler) GeneratePDF(c *gin.Context) {
 	var invoiceData InvoiceData
 	if err := c.ShouldBindJSON(&invoiceData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Create template with custom functions
-	tmpl := template.New("invoice").Funcs(template.FuncMap{
-		"formatPrice":           formatPrice,
-		"formatPriceWithCommas": formatPriceWithCommas,
-		"splitDescription":      splitDescription,
-	})
-
-	tmpl, err := tmpl.Parse(invoiceTemplate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Template parsing failed"})
-		return
-	}
-
 	// Generate HTML
-	var htmlBuffer strings.Builder
-	if err := tmpl.Execute(&htmlBuffer, invoiceData); err != nil {
+	var htmlBuffer bytes.Buffer
+	if err := invoiceTmpl.Execute(&htmlBuffer, &invoiceData); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Template execution failed"})
 		return
 	}
 
-	// Create context for chromedp
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	// Set timeout
-	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	var pdfBuffer []byte
-
-	// Generate PDF using chromedp
-	err = chromedp.Run(ctx,
-		chromedp.Navigate("data:text/html,"+htmlBuffer.String()),
-		chromedp.WaitVisible("body"),
-		//chromedp.ActionFunc(func(ctx context.Context) error {
-		//	var err error
-		//	pdfBuffer, _, err = chromedp.PrintToPDF().
-		//		WithPrintBackground(true).
-		//		WithPaperWidth(8.27).  // A4 width in inches
-		//		WithPaperHeight(11.7). // A4 height in inches
-		//		WithMarginTop(0.4).
-		//		WithMarginBottom(0.4).
-		//		WithMarginLeft(0.4).
-		//		WithMarginRight(0.4).
-		//		Do(ctx)
-		//	return err
-		//}),
-	)
-
+	pdfBuffer, err := h.Svc.PrintHTML(c.Request.Context(), htmlBuffer.Bytes(), invoiceData.fallback())
 	if err != nil {
 		log.Printf("PDF generation error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "PDF generation failed"})
+		writePDFError(c, err)
 		return
 	}
 
@@ -0,0 +1,307 @@
+// internal/handler/inventory_handler.go
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryHandler struct {
+	Svc *service.InventoryService
+}
+
+// NewInventoryHandler 在 /inventory 下挂载库存查询、库存移动和流水路由
+func NewInventoryHandler(rg *gin.RouterGroup, svc *service.InventoryService) {
+	h := &InventoryHandler{Svc: svc}
+	grp := rg.Group("/inventory")
+
+	// 查询
+	view := RequirePermission("inventory.view")
+	grp.GET("/transaction-types", view, h.TransactionTypes)
+	grp.GET("/products/:id/stocks", view, h.ProductStocks)
+	grp.GET("/products/:id/warehouses/:warehouseId", view, h.ProductStockInWarehouse)
+	grp.GET("/products/:id/summary", view, h.ProductSummary)
+	grp.GET("/products/:id/transactions", view, h.ProductTransactions)
+	grp.GET("/warehouses/:id/stocks", view, h.WarehouseStocks)
+	grp.GET("/warehouses/:id/transactions", view, h.WarehouseTransactions)
+	grp.GET("/stocks/:id/transactions", view, h.StockTransactions)
+	grp.GET("/low-stock", view, h.LowStock)
+	grp.GET("/transactions", view, h.TransactionsByDateRange)
+
+	// 库存移动
+	grp.POST("/in", RequirePermission("inventory.in"), h.StockIn)
+	grp.POST("/out", RequirePermission("inventory.out"), h.StockOut)
+	grp.POST("/sale", RequirePermission("inventory.out"), h.Sale)
+	grp.POST("/batch", h.Batch) // 按每条记录的类型单独校验权限
+}
+
+// TransactionTypes GET /api/inventory/transaction-types
+func (h *InventoryHandler) TransactionTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, inventory.GetTransactionTypeInfo())
+}
+
+// ProductStocks GET /api/inventory/products/:id/stocks
+func (h *InventoryHandler) ProductStocks(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	stocks, err := h.Svc.GetProductStock(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stocks)
+}
+
+// ProductStockInWarehouse GET /api/inventory/products/:id/warehouses/:warehouseId
+func (h *InventoryHandler) ProductStockInWarehouse(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	warehouseID, ok := parseIDParam(c, "warehouseId")
+	if !ok {
+		return
+	}
+	stock, err := h.Svc.GetProductStockInWarehouse(c.Request.Context(), id, warehouseID)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+	c.JSON(http.StatusOK, stock)
+}
+
+// ProductSummary GET /api/inventory/products/:id/summary
+func (h *InventoryHandler) ProductSummary(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	summary, err := h.Svc.GetInventorySummary(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// ProductTransactions GET /api/inventory/products/:id/transactions?offset=0&limit=20
+func (h *InventoryHandler) ProductTransactions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	off, lim := parsePaging(c)
+	txs, total, err := h.Svc.GetProductTransactions(c.Request.Context(), id, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "transactions": txs})
+}
+
+// WarehouseStocks GET /api/inventory/warehouses/:id/stocks?offset=0&limit=20
+func (h *InventoryHandler) WarehouseStocks(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	off, lim := parsePaging(c)
+	stocks, total, err := h.Svc.GetWarehouseStocks(c.Request.Context(), id, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "stocks": stocks})
+}
+
+// WarehouseTransactions GET /api/inventory/warehouses/:id/transactions?offset=0&limit=20
+func (h *InventoryHandler) WarehouseTransactions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	off, lim := parsePaging(c)
+	txs, total, err := h.Svc.GetWarehouseTransactions(c.Request.Context(), id, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "transactions": txs})
+}
+
+// StockTransactions GET /api/inventory/stocks/:id/transactions?offset=0&limit=20
+func (h *InventoryHandler) StockTransactions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	off, lim := parsePaging(c)
+	txs, total, err := h.Svc.GetInventoryTransactions(c.Request.Context(), id, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "transactions": txs})
+}
+
+// LowStock GET /api/inventory/low-stock?threshold=5
+func (h *InventoryHandler) LowStock(c *gin.Context) {
+	threshold, err := strconv.Atoi(c.DefaultQuery("threshold", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid threshold"})
+		return
+	}
+	stocks, err := h.Svc.GetLowStock(c.Request.Context(), threshold)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stocks)
+}
+
+// TransactionsByDateRange GET /api/inventory/transactions?start=2025-07-01&end=2025-07-31
+func (h *InventoryHandler) TransactionsByDateRange(c *gin.Context) {
+	start, err := time.Parse("2006-01-02", c.Query("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start date, expected YYYY-MM-DD"})
+		return
+	}
+	end, err := time.Parse("2006-01-02", c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end date, expected YYYY-MM-DD"})
+		return
+	}
+	// end 取当天结束
+	end = end.Add(24*time.Hour - time.Nanosecond)
+
+	off, lim := parsePaging(c)
+	txs, total, err := h.Svc.GetTransactionsByDateRange(c.Request.Context(), start, end, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "transactions": txs})
+}
+
+// StockIn POST /api/inventory/in
+func (h *InventoryHandler) StockIn(c *gin.Context) {
+	var req dto.StockMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Svc.StockIn(c.Request.Context(), req.ProductID, req.WarehouseID, req.Quantity, currentOperator(c), req.Note); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "stock in processed"})
+}
+
+// StockOut POST /api/inventory/out
+func (h *InventoryHandler) StockOut(c *gin.Context) {
+	var req dto.StockMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Svc.StockOut(c.Request.Context(), req.ProductID, req.WarehouseID, req.Quantity, currentOperator(c), req.Note); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "stock out processed"})
+}
+
+// Sale POST /api/inventory/sale
+func (h *InventoryHandler) Sale(c *gin.Context) {
+	var req dto.StockMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Svc.Sale(c.Request.Context(), req.ProductID, req.WarehouseID, req.Quantity, currentOperator(c), req.Note); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "sale processed"})
+}
+
+// Batch POST /api/inventory/batch
+// 整批在一个事务里执行：校验失败返回 400，任一条库存不足返回 409，都不会留下部分结果
+func (h *InventoryHandler) Batch(c *gin.Context) {
+	var req dto.BatchStockMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make([]service.StockUpdateRequest, len(req.Updates))
+	for i, u := range req.Updates {
+		perm := "inventory.out"
+		if u.Type == "IN" {
+			perm = "inventory.in"
+		}
+		if !hasPermission(c, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限: " + perm})
+			return
+		}
+		updates[i] = service.StockUpdateRequest{
+			ProductID:   u.ProductID,
+			WarehouseID: u.WarehouseID,
+			Quantity:    u.Quantity,
+			Type:        u.Type,
+			Note:        u.Note,
+		}
+	}
+
+	if err := h.Svc.BatchStockUpdate(c.Request.Context(), updates, currentOperator(c)); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "batch stock update processed"})
+}
+
+// parseIDParam 解析路径里的正整数 ID，失败时直接写 400
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseIDQuery 解析查询参数里的正整数 ID，失败时直接写 400
+func parseIDQuery(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Query(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parsePaging 读取 offset / limit 查询参数，默认 0 / 20
+func parsePaging(c *gin.Context) (int, int) {
+	off, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	lim, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if off < 0 {
+		off = 0
+	}
+	if lim <= 0 || lim > 200 {
+		lim = 20
+	}
+	return off, lim
+}
@@ -2,9 +2,10 @@
 package handler
 
 import (
+	"djj-inventory-system/internal/pkg/pdf"
 	"djj-inventory-system/internal/service"
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,50 +14,89 @@ type InvoiceHandler struct {
 	Svc *service.InvoiceService
 }
 
-func NewInvoiceHandler(svc *service.InvoiceService) *InvoiceHandler {
-	return &InvoiceHandler{Svc: svc}
+// NewInvoiceHandler 挂载 PDF 下载路由；generate-pdf 按请求里的数据直接出单据
+func NewInvoiceHandler(rg *gin.RouterGroup, svc *service.InvoiceService) *InvoiceHandler {
+	h := &InvoiceHandler{Svc: svc}
+	rg.GET("/quotes/:id/pdf", RequirePermission("quote.view"), h.QuotePDF)
+	rg.GET("/inventory/picking-lists/:id/pdf", RequirePermission("inventory.view"), h.PickingPDF)
+	rg.GET("/finance/invoices/:id/pdf", RequirePermission("finance.view"), h.TaxInvoicePDF)
+	rg.GET("/finance/credit-notes/:id/pdf", RequirePermission("finance.view"), h.CreditNotePDF)
+	rg.POST("/generate-pdf", RequireAnyPermission("quote.view", "finance.view"), h.GeneratePDF)
+	return h
 }
 
 // GET /api/quotes/:id/pdf
 func (h *InvoiceHandler) QuotePDF(c *gin.Context) {
 	// 1. 解析并校验 ID
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+	id, ok := parseIDParam(c, "id")
+	if !ok {
 		return
 	}
 
 	// 2. 调用 Service，把 Gin 的 Context 里的 context.Context 传下去
-	pdfBytes, err := h.Svc.GenerateQuotePDF(c.Request.Context(), uint(id))
+	pdfBytes, err := h.Svc.GenerateQuotePDF(c.Request.Context(), id)
 	if err != nil {
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "quote not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		writePDFError(c, err)
 		return
 	}
 
 	// 3. 返回 PDF 文件
 	filename := "quote_" + c.Param("id") + ".pdf"
-	c.Header("Content-Type", "application/pdf")
 	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
 	c.Data(http.StatusOK, "application/pdf", pdfBytes)
 }
 
-// GET /api/orders/:id/picking.pdf
+// GET /api/inventory/picking-lists/:id/pdf
 func (h *InvoiceHandler) PickingPDF(c *gin.Context) {
-	id, _ := strconv.Atoi(c.Param("id"))
-	pdf, err := h.Svc.GeneratePickingPDF(c.Request.Context(), uint(id))
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	pdfBytes, err := h.Svc.GeneratePickingPDF(c.Request.Context(), id)
 	if err != nil {
-		if err == service.ErrNotFound {
-			c.JSON(404, gin.H{"error": "order not found"})
-		} else {
-			c.JSON(500, gin.H{"error": err.Error()})
-		}
+		writePDFError(c, err)
 		return
 	}
-	c.Header("Content-Type", "application/pdf")
 	c.Header("Content-Disposition", `attachment; filename="picking_`+c.Param("id")+`.pdf"`)
-	c.Writer.Write(pdf)
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// GET /api/finance/invoices/:id/pdf
+func (h *InvoiceHandler) TaxInvoicePDF(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	pdfBytes, err := h.Svc.GenerateTaxInvoicePDF(c.Request.Context(), id)
+	if err != nil {
+		writePDFError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="invoice_`+c.Param("id")+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// GET /api/finance/credit-notes/:id/pdf
+func (h *InvoiceHandler) CreditNotePDF(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	pdfBytes, err := h.Svc.GenerateCreditNotePDF(c.Request.Context(), id)
+	if err != nil {
+		writePDFError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="credit_note_`+c.Param("id")+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// writePDFError 渲染排队已满返回 503，让前端稍后重试
+func writePDFError(c *gin.Context, err error) {
+	if errors.Is(err, pdf.ErrBusy) {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	writeServiceError(c, err)
 }
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	userSvc  service.UserService
+	sessions *service.SessionService
+	mfa      *service.MFAService
+}
+
+// NewMFAHandler 两步验证路由，挂在公开分组下：
+//   - /auth/login/totp*：登录第二步，凭登录时返回的 mfaToken 调用，不需要会话
+//   - /auth/totp*：已登录用户管理自己的两步验证
+func NewMFAHandler(rg *gin.RouterGroup, us service.UserService, sessions *service.SessionService, mfa *service.MFAService) {
+	h := &MFAHandler{userSvc: us, sessions: sessions, mfa: mfa}
+	login := rg.Group("auth/login/totp")
+	login.POST("", h.LoginVerify)
+	login.POST("/enroll", h.LoginEnroll)
+	login.POST("/activate", h.LoginActivate)
+
+	grp := rg.Group("auth/totp", RequireLogin(), RequireInteractiveLogin())
+	grp.GET("", h.Status)
+	grp.POST("/enroll", h.Enroll)
+	grp.POST("/activate", h.Activate)
+	grp.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+	grp.POST("/disable", h.Disable)
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code"` // TOTP 验证码或恢复码
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginVerify godoc
+// @Summary      登录第二步
+// @Description  用 TOTP 验证码或恢复码完成登录，成功后下发会话 Cookie
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body     MFALoginRequest  true  "mfaToken 和验证码"
+// @Success      200      {object} ResponseMessage
+// @Failure      401      {object} ErrorResponse
+// @Failure      423      {object} ErrorResponse
+// @Router       /auth/login/totp [post]
+func (h *MFAHandler) LoginVerify(c *gin.Context) {
+	var in MFALoginRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	ch, ok := h.challenge(c, in.MFAToken)
+	if !ok {
+		return
+	}
+	if ch.Enroll {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "请先绑定两步验证"})
+		return
+	}
+	if err := h.mfa.VerifyLogin(c.Request.Context(), ch.UserID, in.Code); err != nil {
+		writeLoginError(c, err)
+		return
+	}
+	h.finishLogin(c, ch, nil)
+}
+
+// LoginEnroll godoc
+// @Summary      登录时绑定两步验证
+// @Description  角色要求两步验证但尚未绑定时，凭 mfaToken 生成密钥和二维码链接
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body     MFALoginRequest  true  "mfaToken"
+// @Success      200      {object} service.TOTPEnrollment
+// @Failure      401      {object} ErrorResponse
+// @Router       /auth/login/totp/enroll [post]
+func (h *MFAHandler) LoginEnroll(c *gin.Context) {
+	var in MFALoginRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	ch, ok := h.enrollChallenge(c, in.MFAToken)
+	if !ok {
+		return
+	}
+	u, _, err := h.userSvc.GetProfile(c.Request.Context(), ch.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "登录已失效，请重新登录"})
+		return
+	}
+	h.enroll(c, u)
+}
+
+// LoginActivate godoc
+// @Summary      登录时确认绑定
+// @Description  用验证器上的验证码确认绑定并完成登录；恢复码只在此返回一次
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body     MFALoginRequest  true  "mfaToken 和验证码"
+// @Success      200      {object} ResponseMessage
+// @Failure      400      {object} ErrorResponse
+// @Router       /auth/login/totp/activate [post]
+func (h *MFAHandler) LoginActivate(c *gin.Context) {
+	var in MFALoginRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	ch, ok := h.enrollChallenge(c, in.MFAToken)
+	if !ok {
+		return
+	}
+	codes, err := h.mfa.ConfirmEnrollment(c.Request.Context(), ch.UserID, in.Code)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.finishLogin(c, ch, codes)
+}
+
+// Status godoc
+// @Summary      两步验证状态
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  service.MFAStatus
+// @Router       /auth/totp [get]
+func (h *MFAHandler) Status(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	st, err := h.mfa.Status(c.Request.Context(), u)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// Enroll godoc
+// @Summary      绑定两步验证
+// @Description  生成新密钥和二维码链接，调用 /auth/totp/activate 确认后生效
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  service.TOTPEnrollment
+// @Failure      400  {object}  ErrorResponse
+// @Router       /auth/totp/enroll [post]
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	h.enroll(c, u)
+}
+
+// Activate godoc
+// @Summary      确认绑定两步验证
+// @Description  用验证器上的验证码确认绑定；恢复码只在此返回一次
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body  TOTPCodeRequest  true  "验证码"
+// @Success      200  {object}  map[string][]string
+// @Failure      400  {object}  ErrorResponse
+// @Router       /auth/totp/activate [post]
+func (h *MFAHandler) Activate(c *gin.Context) {
+	var in TOTPCodeRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	codes, err := h.mfa.ConfirmEnrollment(c.Request.Context(), currentUserID(c), in.Code)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      重新生成恢复码
+// @Description  需要当前 TOTP 验证码；旧恢复码全部作废
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body  TOTPCodeRequest  true  "验证码"
+// @Success      200  {object}  map[string][]string
+// @Failure      400  {object}  ErrorResponse
+// @Router       /auth/totp/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var in TOTPCodeRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	codes, err := h.mfa.RegenerateRecoveryCodes(c.Request.Context(), currentUserID(c), in.Code)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// Disable godoc
+// @Summary      关闭两步验证
+// @Description  需要当前 TOTP 验证码；角色要求两步验证的用户不能关闭
+// @Tags         auth
+// @Accept       json
+// @Param        payload  body  TOTPCodeRequest  true  "验证码"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /auth/totp/disable [post]
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var in TOTPCodeRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if err := h.mfa.Disable(c.Request.Context(), u, in.Code); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) enroll(c *gin.Context, u *rbac.User) {
+	e, err := h.mfa.BeginEnrollment(c.Request.Context(), u)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+// finishLogin 第二步完成：作废 mfaToken，签发会话；recoveryCodes 非空时一并返回
+func (h *MFAHandler) finishLogin(c *gin.Context, ch *service.MFAChallenge, recoveryCodes []string) {
+	ctx := c.Request.Context()
+	if err := h.sessions.ConsumeChallenge(ctx, ch); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	u, sd, err := h.userSvc.GetProfile(ctx, ch.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "登录已失效，请重新登录"})
+		return
+	}
+	pair, err := h.sessions.Login(ctx, u, sd, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		return
+	}
+	if recoveryCodes == nil {
+		writeAuthResponse(c, pair, profilePayload(u, sd))
+		return
+	}
+	setAuthCookies(c, pair)
+	c.JSON(http.StatusOK, gin.H{
+		"token":         pair.AccessToken,
+		"expiresAt":     pair.AccessExpiresAt,
+		"user":          profilePayload(u, sd),
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+// challenge 校验 mfaToken，失败时已写好 401
+func (h *MFAHandler) challenge(c *gin.Context, token string) (*service.MFAChallenge, bool) {
+	ch, err := h.sessions.VerifyChallenge(c.Request.Context(), token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "登录已失效，请重新登录"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return ch, true
+}
+
+// enrollChallenge 只接受“需要先绑定”的 mfaToken；已绑定的用户不能借此重新绑定
+func (h *MFAHandler) enrollChallenge(c *gin.Context, token string) (*service.MFAChallenge, bool) {
+	ch, ok := h.challenge(c, token)
+	if ok && !ch.Enroll {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "两步验证已绑定，请输入验证码"})
+		return nil, false
+	}
+	return ch, ok
+}
+
+func (h *MFAHandler) currentUser(c *gin.Context) (*rbac.User, bool) {
+	u, _, err := h.userSvc.GetProfile(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "未登录或用户不存在"})
+		return nil, false
+	}
+	return u, true
+}
+
+// writeLoginError 登录失败：锁定 423，凭证错误 401
+func writeLoginError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAccountLocked):
+		c.JSON(http.StatusLocked, ErrorResponse{Error: "登录失败次数过多，账号已暂时锁定，请稍后再试或找回密码"})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "验证码错误"})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+}
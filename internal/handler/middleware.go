@@ -2,34 +2,35 @@ package handler
 
 import (
 	"context"
-	"djj-inventory-system/internal/model/common"
-	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/scope"
 	"djj-inventory-system/internal/service"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
 )
 
-// AuthMiddleware injects ContextUserIDKey
-func AuthMiddleware(svc service.UserService) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if uid, err := auth.GetUserID(r); err == nil && uid > 0 {
-				ctx := context.WithValue(r.Context(), common.ContextUserIDKey, uid)
-				next.ServeHTTP(w, r.WithContext(ctx))
-				return
-			}
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		})
-	}
-}
+// apiKeyScheme 系统间调用的认证头：Authorization: ApiKey djj_xxx
+const apiKeyScheme = "ApiKey "
 
-func SessionAuthMiddleware() gin.HandlerFunc {
+// SessionAuthMiddleware 校验 access_token Cookie（签名、有效期、吊销列表），把用户信息放进上下文
+// 访问令牌过期后返回 401，前端调 /api/auth/refresh 续期
+// 带 Authorization: ApiKey 头的请求改用 API Key 认证，权限和数据范围在这里一次算好
+func SessionAuthMiddleware(sessions *service.SessionService, apiKeys *service.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.FullPath() == "/api/auth/login" || c.FullPath() == "/api/auth//logout" || c.FullPath() == "/api/auth//register" || c.FullPath() == "/api/auth/roles" {
+		switch c.FullPath() {
+		case "/api/auth/login", "/api/auth/logout", "/api/auth/refresh", "/api/auth/roles",
+			"/api/auth/password/forgot", "/api/auth/password/reset",
+			"/api/auth/login/totp", "/api/auth/login/totp/enroll", "/api/auth/login/totp/activate":
 			c.Next()
-			return // ← 加上这句 跑你的登录 handler 然后直接 return，不会再继续执行后面的登录检查中间件。
+			return // 登录 / 刷新 / 注销自己处理令牌，不做登录检查
+		}
+
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, apiKeyScheme) {
+			authenticateAPIKey(c, apiKeys, strings.TrimSpace(strings.TrimPrefix(header, apiKeyScheme)))
+			return
 		}
 
 		// 1. 从 Cookie 里读 token
@@ -39,62 +40,168 @@ func SessionAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 2. 解析并校验 JWT
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
+		// 2. 校验签名、有效期、吊销列表
+		access, err := sessions.Verify(c.Request.Context(), tokenString)
+		if errors.Is(err, service.ErrInvalidToken) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的 token"})
 			return
 		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "校验 token 失败"})
+			return
+		}
+
+		// 3. 调用者放进 gin 上下文和 request context
+		setPrincipal(c, access.Principal())
+		c.Next()
+	}
+}
+
+// authenticateAPIKey 校验 API Key，以所属用户身份继续处理请求
+func authenticateAPIKey(c *gin.Context, apiKeys *service.APIKeyService, key string) {
+	p, err := apiKeys.Authenticate(c.Request.Context(), key, c.ClientIP())
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的 API Key"})
+		return
+	case errors.Is(err, service.ErrForbidden):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "校验 API Key 失败"})
+		return
+	}
+	setPrincipal(c, p)
+	c.Next()
+}
+
+// RequireInteractiveLogin 只允许用账号密码登录的会话，拒绝 API Key（管理密钥、两步验证等）
+func RequireInteractiveLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p := currentPrincipal(c); p != nil && p.IsAPIKey() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "该操作不支持 API Key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireLogin 只允许已登录的用户继续，未登录的返回 401
+func RequireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentPrincipal(c) == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要先登录"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
 
-		// 3. 取出 claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无法读取 token 声明"})
+// PermissionSource 按用户 ID 查询合并后（角色 + 直接授予）的权限名、角色名和数据范围，实现方负责缓存
+type PermissionSource interface {
+	PermissionNames(ctx context.Context, userID uint) ([]string, error)
+	Roles(ctx context.Context, userID uint) ([]string, error)
+	Scope(ctx context.Context, userID uint) (scope.Scope, error)
+}
+
+// LoadPermissions 按当前用户重新查询权限和角色，覆盖 token 里签发时的值
+// 权限或角色被撤销后立即生效，不用等 token 过期；用户已删除时返回 401
+// 同时补全调用者的门店 / 地区数据范围，供仓储层过滤
+func LoadPermissions(src PermissionSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := currentPrincipal(c)
+		if p == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "需要先登录"})
+			return
+		}
+		if p.IsAPIKey() {
+			c.Next() // API Key 的权限和数据范围已在认证时按密钥收窄
+			return
+		}
+		uid := p.UserID
+		perms, err := src.PermissionNames(c.Request.Context(), uid)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "用户不存在或已删除"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "加载权限失败"})
 			return
 		}
-		// 4. 拿出 sub（userID）和 name（fullName）
-		if sub, ok := claims["sub"].(float64); ok {
-			c.Set("currentUserId", int32(sub))
+		roles, err := src.Roles(c.Request.Context(), uid)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "加载角色失败"})
+			return
 		}
-		if name, ok := claims["name"].(string); ok {
-			c.Set("currentUser", name)
+		sc, err := src.Scope(c.Request.Context(), uid)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "加载数据范围失败"})
+			return
 		}
-		if role, ok := claims["role"].(string); ok {
-			c.Set("currentUserRole", role)
+		p.Permissions = perms
+		p.Roles = roles
+		p.Scope = sc
+		p.StoreID = sc.StoreID
+		setPrincipal(c, p)
+		c.Next()
+	}
+}
+
+// RequirePermission 要求当前用户拥有指定权限
+func RequirePermission(perm string) gin.HandlerFunc {
+	return RequireAnyPermission(perm)
+}
+
+// RequireAnyPermission 拥有其中任意一个权限即可
+func RequireAnyPermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range perms {
+			if hasPermission(c, p) {
+				c.Next()
+				return
+			}
 		}
-		if perms, ok := claims["permissions"].([]interface{}); ok {
-			// 转成 []string
-			pp := make([]string, len(perms))
-			for i, v := range perms {
-				if s, ok := v.(string); ok {
-					pp[i] = s
-				}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "没有权限: " + strings.Join(perms, " | ")})
+	}
+}
+
+// RequireAllPermissions 必须同时拥有全部权限
+func RequireAllPermissions(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range perms {
+			if !hasPermission(c, p) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "没有权限: " + p})
+				return
 			}
-			c.Set("currentUserPermissions", pp)
 		}
-		// 3. 注入到 Gin 自己的上下文里
-		c.Set(string(common.ContextUserIDKey), claims["sub"])
 		c.Next()
 	}
 }
 
-// RequireLogin 只允许已登录的用户继续，未登录的返回 401
-func RequireLogin() gin.HandlerFunc {
+// hasPermission 判断当前请求的用户是否拥有 perm
+func hasPermission(c *gin.Context, perm string) bool {
+	p := currentPrincipal(c)
+	return p != nil && p.HasPermission(perm)
+}
+
+// RequireLeader 要求当前用户的角色为 admin 或某个 *_leader，用于主管审批类操作
+func RequireLeader() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if _, exists := c.Get(string(common.ContextUserIDKey)); !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要先登录"})
-			c.Abort()
+		if !isLeader(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要主管审批权限"})
 			return
 		}
 		c.Next()
 	}
 }
 
+// isLeader 判断当前用户角色是否为 admin 或 *_leader
+func isLeader(c *gin.Context) bool {
+	p := currentPrincipal(c)
+	return p != nil && p.IsLeader()
+}
+
 //// PermissionMiddleware guards by permission name
 //func PermissionMiddleware(svc service.UserService, perm string) func(http.Handler) http.Handler {
 //	return func(next http.Handler) http.Handler {
@@ -0,0 +1,246 @@
+// internal/handler/order_handler.go
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/eventbus"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrderHandler struct {
+	Svc *service.OrderService
+	Bus eventbus.Bus
+}
+
+// NewOrderHandler 在 /orders 下挂载订单路由，变更会广播到 websocket 的 orders 频道
+func NewOrderHandler(rg *gin.RouterGroup, svc *service.OrderService, bus eventbus.Bus) {
+	h := &OrderHandler{Svc: svc, Bus: bus}
+	grp := rg.Group("/orders")
+
+	view := RequirePermission("sales.view")
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.GET("/:id/transitions", view, h.Transitions)
+
+	grp.POST("/from-quote/:quoteId", RequirePermission("sales.create"), h.CreateFromQuote)
+
+	edit := RequirePermission("sales.edit")
+	grp.PUT("/:id", edit, h.Update)
+	grp.POST("/:id/items", edit, h.AddItem)
+	grp.PUT("/:id/items/:itemId", edit, h.UpdateItem)
+	grp.DELETE("/:id/items/:itemId", edit, h.DeleteItem)
+	grp.POST("/:id/status", edit, h.ChangeStatus)
+}
+
+// List GET /api/orders?status=ordered&storeId=1&customerId=2&salesRepId=3&quoteId=4&q=SO-2025&from=2025-07-01&to=2025-07-31&offset=0&limit=20
+func (h *OrderHandler) List(c *gin.Context) {
+	f := repository.OrderFilter{Status: c.Query("status"), Keyword: c.Query("q")}
+	for name, dst := range map[string]*uint{
+		"storeId": &f.StoreID, "customerId": &f.CustomerID, "salesRepId": &f.SalesRepID, "quoteId": &f.QuoteID,
+	} {
+		if c.Query(name) == "" {
+			continue
+		}
+		id, ok := parseIDQuery(c, name)
+		if !ok {
+			return
+		}
+		*dst = id
+	}
+	for name, dst := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + " date, expected YYYY-MM-DD"})
+			return
+		}
+		*dst = &t
+	}
+
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "orders": list})
+}
+
+// Get GET /api/orders/:id
+func (h *OrderHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	o, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, o)
+}
+
+// Transitions GET /api/orders/:id/transitions，返回当前状态下允许手工进入的状态
+func (h *OrderHandler) Transitions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	o, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	// 定金 / 尾款状态由登记收款推进，不在手工可选范围内
+	next := []string{}
+	for _, s := range sales.NextOrderStatuses(o.Status) {
+		if !sales.IsPaymentStatus(s) {
+			next = append(next, s)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": o.Status, "next": next})
+}
+
+// CreateFromQuote POST /api/orders/from-quote/:quoteId
+func (h *OrderHandler) CreateFromQuote(c *gin.Context) {
+	quoteID, ok := parseIDParam(c, "quoteId")
+	if !ok {
+		return
+	}
+	var req dto.CreateOrderFromQuoteRequest
+	// 参数可选，允许空 body
+	_ = c.ShouldBindJSON(&req)
+	o, err := h.Svc.CreateFromQuote(c.Request.Context(), quoteID, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "orderCreated", o)
+	c.JSON(http.StatusCreated, o)
+}
+
+// Update PUT /api/orders/:id，仅草稿
+func (h *OrderHandler) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	o, err := h.Svc.UpdateDraft(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "orderUpdated", o)
+	c.JSON(http.StatusOK, o)
+}
+
+// AddItem POST /api/orders/:id/items，仅草稿
+func (h *OrderHandler) AddItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.OrderItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	o, err := h.Svc.AddItem(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "orderUpdated", o)
+	c.JSON(http.StatusOK, o)
+}
+
+// UpdateItem PUT /api/orders/:id/items/:itemId，仅草稿
+func (h *OrderHandler) UpdateItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	itemID, ok := parseIDParam(c, "itemId")
+	if !ok {
+		return
+	}
+	var req dto.OrderItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	o, err := h.Svc.UpdateItem(c.Request.Context(), id, itemID, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "orderUpdated", o)
+	c.JSON(http.StatusOK, o)
+}
+
+// DeleteItem DELETE /api/orders/:id/items/:itemId，仅草稿
+func (h *OrderHandler) DeleteItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	itemID, ok := parseIDParam(c, "itemId")
+	if !ok {
+		return
+	}
+	o, err := h.Svc.DeleteItem(c.Request.Context(), id, itemID, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "orderUpdated", o)
+	c.JSON(http.StatusOK, o)
+}
+
+// ChangeStatus POST /api/orders/:id/status
+// 非法跳转（如 draft → delivered）返回 409；定金 / 尾款状态只能通过 POST /api/orders/:id/payments 进入，这里返回 400
+// 进入 ordered 时自动预留，shipped 时转销售，cancelled 时释放
+// order.status_changed 事件和状态一起提交，由 outbox 投递到 orders 频道
+func (h *OrderHandler) ChangeStatus(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ChangeOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.Svc.Transition(c.Request.Context(), id, req.Status, currentOperator(c), currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// broadcast 推送到 orders 频道，只发给能看到订单门店的订阅者
+func (h *OrderHandler) broadcast(c *gin.Context, event string, o *sales.Order) {
+	publish(c, h.Bus, orderEvent(event, o), o)
+}
+
+// orderEvent 订单没有乐观锁版本号，用更新时间（毫秒）作为版本
+func orderEvent(event string, o *sales.Order) realtime.Event {
+	return realtime.Event{Topic: "orders", Event: event, Entity: "order", EntityID: o.ID, Version: o.UpdatedAt.UnixMilli(), StoreID: o.StoreID}
+}
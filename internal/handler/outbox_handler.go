@@ -0,0 +1,88 @@
+// internal/handler/outbox_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OutboxHandler struct {
+	Svc *service.OutboxService
+}
+
+// NewOutboxHandler 在 /outbox 下挂载领域事件投递查询和死信重试路由
+func NewOutboxHandler(rg *gin.RouterGroup, svc *service.OutboxService) {
+	h := &OutboxHandler{Svc: svc}
+	grp := rg.Group("/outbox")
+	view := RequirePermission("system.log")
+	grp.GET("/summary", view, h.Summary)
+	grp.GET("/deliveries", view, h.ListDeliveries)
+	grp.GET("/events/:id", view, h.GetEvent)
+	grp.POST("/deliveries/:id/retry", RequirePermission("system.restore"), RequireInteractiveLogin(), h.Retry)
+}
+
+// Summary GET /api/outbox/summary 各下游各状态的投递数
+func (h *OutboxHandler) Summary(c *gin.Context) {
+	list, err := h.Svc.Summary(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"consumers": list})
+}
+
+// ListDeliveries GET /api/outbox/deliveries?status=dead&consumer=websocket&type=stock.moved&eventId=1&offset=0&limit=20
+// status=dead 即死信列表
+func (h *OutboxHandler) ListDeliveries(c *gin.Context) {
+	f := repository.DeliveryFilter{
+		Status:   c.Query("status"),
+		Consumer: c.Query("consumer"),
+		Type:     c.Query("type"),
+	}
+	if c.Query("eventId") != "" {
+		id, ok := parseIDQuery(c, "eventId")
+		if !ok {
+			return
+		}
+		f.EventID = id
+	}
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.ListDeliveries(c.Request.Context(), f, off, lim)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "deliveries": list})
+}
+
+// GetEvent GET /api/outbox/events/:id 事件内容和各下游的投递情况
+func (h *OutboxHandler) GetEvent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	e, err := h.Svc.GetEvent(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+// Retry POST /api/outbox/deliveries/:id/retry 重新投递一条 dead 记录，其他状态返回 409
+func (h *OutboxHandler) Retry(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	d, err := h.Svc.Retry(c.Request.Context(), id, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	svc *service.PasswordResetService
+}
+
+// NewPasswordHandler 找回密码路由，挂在公开分组下（未登录可用）
+func NewPasswordHandler(rg *gin.RouterGroup, svc *service.PasswordResetService) {
+	h := &PasswordHandler{svc: svc}
+	grp := rg.Group("auth/password")
+	grp.POST("/forgot", h.Forgot)
+	grp.POST("/reset", h.Reset)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Forgot godoc
+// @Summary      申请重置密码
+// @Description  向邮箱发送重置链接；无论邮箱是否存在都返回 202
+// @Tags         auth
+// @Accept       json
+// @Param        payload  body  ForgotPasswordRequest  true  "邮箱"
+// @Success      202
+// @Failure      400  {object}  ErrorResponse
+// @Router       /auth/password/forgot [post]
+func (h *PasswordHandler) Forgot(c *gin.Context) {
+	var in ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// 发信失败已记日志；这里不暴露，避免据此判断账号是否存在
+	_ = h.svc.RequestReset(c.Request.Context(), in.Email, c.ClientIP())
+	c.Status(http.StatusAccepted)
+}
+
+// Reset godoc
+// @Summary      重置密码
+// @Description  凭邮件里的一次性令牌设置新密码，成功后注销所有会话
+// @Tags         auth
+// @Accept       json
+// @Param        payload  body  ResetPasswordRequest  true  "令牌和新密码"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Router       /auth/password/reset [post]
+func (h *PasswordHandler) Reset(c *gin.Context) {
+	var in ResetPasswordRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.svc.ResetPassword(c.Request.Context(), in.Token, in.Password); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
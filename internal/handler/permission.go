@@ -18,12 +18,14 @@ type PermHandler struct {
 func NewPermHandler(rg *gin.RouterGroup, svc service.PermService) {
 	h := &PermHandler{svc: svc}
 	grp := rg.Group("/permissions")
-	grp.POST("", h.Create)             // 创建权限
-	grp.GET("", h.List)                // 列表
-	grp.GET("/:id", h.Get)             // 取单条
-	grp.PUT("/:id", h.Update)          // 更新
-	grp.DELETE("/:id", h.Delete)       // 删除
-	grp.GET("/modules", h.ListModules) // 新增：权限模块分组
+	view := RequirePermission("user.view")
+	manage := RequirePermission("user.permission")
+	grp.POST("", manage, h.Create)           // 创建权限
+	grp.GET("", view, h.List)                // 列表
+	grp.GET("/:id", view, h.Get)             // 取单条
+	grp.PUT("/:id", manage, h.Update)        // 更新
+	grp.DELETE("/:id", manage, h.Delete)     // 删除
+	grp.GET("/modules", view, h.ListModules) // 新增：权限模块分组
 }
 
 // Create godoc
@@ -0,0 +1,160 @@
+// internal/handler/picking_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PickingHandler struct {
+	Svc *service.PickingService
+}
+
+// NewPickingHandler 挂载拣货路由：订单下生成拣货单，/inventory/picking-lists 下拣货确认与缺货处理
+func NewPickingHandler(rg *gin.RouterGroup, svc *service.PickingService) {
+	h := &PickingHandler{Svc: svc}
+
+	view := RequirePermission("inventory.view")
+	out := RequirePermission("inventory.out")
+
+	rg.GET("/orders/:id/picking-lists", view, h.ListByOrder)
+	rg.POST("/orders/:id/picking-lists", out, h.Generate)
+
+	grp := rg.Group("/inventory/picking-lists")
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.POST("/:id/picks", out, h.ConfirmPicks)
+	grp.POST("/:id/allocate", out, h.Allocate)
+	grp.POST("/:id/cancel", out, h.Cancel)
+
+	rg.PUT("/inventory/bin-locations", RequirePermission("inventory.adjust"), h.SetBinLocation)
+}
+
+// List GET /api/inventory/picking-lists?status=backorder&orderId=1&warehouseId=2&offset=0&limit=20
+func (h *PickingHandler) List(c *gin.Context) {
+	f := repository.PickingFilter{Status: c.Query("status")}
+	for name, dst := range map[string]*uint{"orderId": &f.OrderID, "warehouseId": &f.WarehouseID} {
+		if c.Query(name) == "" {
+			continue
+		}
+		id, ok := parseIDQuery(c, name)
+		if !ok {
+			return
+		}
+		*dst = id
+	}
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "pickingLists": list})
+}
+
+// ListByOrder GET /api/orders/:id/picking-lists
+func (h *PickingHandler) ListByOrder(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, _, err := h.Svc.List(c.Request.Context(), repository.PickingFilter{OrderID: id}, 0, 200)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pickingLists": list})
+}
+
+// Get GET /api/inventory/picking-lists/:id
+func (h *PickingHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	p, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// Generate POST /api/orders/:id/picking-lists
+func (h *PickingHandler) Generate(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.Svc.Generate(c.Request.Context(), id, currentUserID(c), currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"pickingLists": list})
+}
+
+// ConfirmPicks POST /api/inventory/picking-lists/:id/picks
+func (h *PickingHandler) ConfirmPicks(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ConfirmPicksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.Svc.ConfirmPicks(c.Request.Context(), id, req, currentUserID(c), currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// Allocate POST /api/inventory/picking-lists/:id/allocate，缺货单到货后重新分配
+func (h *PickingHandler) Allocate(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	res, err := h.Svc.Allocate(c.Request.Context(), id, currentUserID(c), currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// Cancel POST /api/inventory/picking-lists/:id/cancel
+func (h *PickingHandler) Cancel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.Svc.Cancel(c.Request.Context(), id, currentUserID(c)); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "picking list cancelled"})
+}
+
+// SetBinLocation PUT /api/inventory/bin-locations
+func (h *PickingHandler) SetBinLocation(c *gin.Context) {
+	var req dto.SetBinLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Svc.SetBinLocation(c.Request.Context(), req); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "bin location updated"})
+}
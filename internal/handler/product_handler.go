@@ -2,34 +2,32 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"djj-inventory-system/internal/model/dto"
 	"djj-inventory-system/internal/service"
-	"djj-inventory-system/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ProductHandler struct {
 	Svc *service.ProductService
-	Hub *websocket.Hub
 }
 
 func NewProductHandler(
 	rg *gin.RouterGroup,
 	svc *service.ProductService,
-	hub *websocket.Hub,
 ) {
-	h := &ProductHandler{Svc: svc, Hub: hub}
+	h := &ProductHandler{Svc: svc}
 	grp := rg.Group("/products")
-	grp.GET("", h.List)
-	grp.GET("/:id", h.Get)
-	grp.POST("", h.Create)
-	grp.PUT("/:id", h.Update)
-	grp.DELETE("/:id", h.Delete)
+	view := RequireAnyPermission("inventory.view", "sales.view", "quote.view")
+	manage := RequirePermission("inventory.adjust")
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.POST("", manage, h.Create)
+	grp.PUT("/:id", manage, h.Update)
+	grp.DELETE("/:id", manage, h.Delete)
 }
 
 // List 返回分页列表： /api/products?offset=0&limit=20
@@ -79,10 +77,6 @@ func (h *ProductHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// 广播给所有订阅 "products" 频道的客户端
-	msg, _ := json.Marshal(gin.H{"event": "productCreated", "payload": pr})
-	h.Hub.Broadcast("products", msg)
-
 	c.JSON(http.StatusCreated, pr)
 }
 
@@ -102,13 +96,10 @@ func (h *ProductHandler) Update(c *gin.Context) {
 
 	pr, err := h.Svc.Update(c.Request.Context(), uint(id), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeServiceError(c, err)
 		return
 	}
 
-	msg, _ := json.Marshal(gin.H{"event": "productUpdated", "payload": pr})
-	h.Hub.Broadcast("products", msg)
-
 	c.JSON(http.StatusOK, pr)
 }
 
@@ -125,9 +116,5 @@ func (h *ProductHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// 仅广播删除的 ID
-	msg, _ := json.Marshal(gin.H{"event": "productDeleted", "payload": gin.H{"id": id}})
-	h.Hub.Broadcast("products", msg)
-
 	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,184 @@
+// internal/handler/quote_handler.go
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/eventbus"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuoteHandler struct {
+	Svc *service.QuoteService
+	Bus eventbus.Bus
+}
+
+// NewQuoteHandler 在 /quotes 下挂载报价路由，变更会广播到 websocket 的 quotes 频道
+func NewQuoteHandler(rg *gin.RouterGroup, svc *service.QuoteService, bus eventbus.Bus) {
+	h := &QuoteHandler{Svc: svc, Bus: bus}
+	grp := rg.Group("/quotes")
+
+	view := RequirePermission("quote.view")
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.GET("/:id/revisions", view, h.Revisions)
+
+	grp.POST("", RequirePermission("quote.create"), h.Create)
+	grp.POST("/:id/revisions", RequirePermission("quote.edit"), h.Revise)
+	grp.POST("/:id/approve", RequirePermission("quote.approve"), h.Approve)
+	grp.POST("/:id/reject", RequirePermission("quote.reject"), h.Reject)
+	grp.POST("/:id/convert", RequirePermission("sales.create"), h.Convert)
+}
+
+// List GET /api/quotes?status=pending&storeId=1&customerId=2&salesRepId=3&q=Q-2025&allRevisions=true&offset=0&limit=20
+func (h *QuoteHandler) List(c *gin.Context) {
+	f := repository.QuoteFilter{
+		Status:       c.Query("status"),
+		Keyword:      c.Query("q"),
+		AllRevisions: c.Query("allRevisions") == "true",
+	}
+	for name, dst := range map[string]*uint{
+		"storeId": &f.StoreID, "customerId": &f.CustomerID, "salesRepId": &f.SalesRepID,
+	} {
+		if c.Query(name) == "" {
+			continue
+		}
+		id, ok := parseIDQuery(c, name)
+		if !ok {
+			return
+		}
+		*dst = id
+	}
+
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "quotes": list})
+}
+
+// Get GET /api/quotes/:id
+func (h *QuoteHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	q, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+// Revisions GET /api/quotes/:id/revisions，同一报价的全部版本
+func (h *QuoteHandler) Revisions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.Svc.Revisions(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revisions": list})
+}
+
+// Create POST /api/quotes
+func (h *QuoteHandler) Create(c *gin.Context) {
+	var req dto.CreateQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, err := h.Svc.Create(c.Request.Context(), req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "quoteCreated", q)
+	c.JSON(http.StatusCreated, q)
+}
+
+// Revise POST /api/quotes/:id/revisions，生成下一个版本
+func (h *QuoteHandler) Revise(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.QuoteContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, err := h.Svc.Revise(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, "quoteRevised", q)
+	c.JSON(http.StatusCreated, q)
+}
+
+// Approve POST /api/quotes/:id/approve
+func (h *QuoteHandler) Approve(c *gin.Context) {
+	h.review(c, h.Svc.Approve, "quoteApproved")
+}
+
+// Reject POST /api/quotes/:id/reject
+func (h *QuoteHandler) Reject(c *gin.Context) {
+	h.review(c, h.Svc.Reject, "quoteRejected")
+}
+
+// Convert POST /api/quotes/:id/convert，生成草稿订单
+func (h *QuoteHandler) Convert(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.CreateOrderFromQuoteRequest
+	// 参数可选，允许空 body
+	_ = c.ShouldBindJSON(&req)
+	o, err := h.Svc.Convert(c.Request.Context(), id, req, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	publish(c, h.Bus, orderEvent("orderCreated", o), o)
+	c.JSON(http.StatusCreated, o)
+}
+
+// quoteReviewFunc 审批 / 拒绝共用的服务方法签名
+type quoteReviewFunc func(ctx context.Context, id uint, note string, reviewer uint) (*sales.Quote, error)
+
+func (h *QuoteHandler) review(c *gin.Context, fn quoteReviewFunc, event string) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ReviewQuoteRequest
+	// 备注可选，允许空 body
+	_ = c.ShouldBindJSON(&req)
+	q, err := fn(c.Request.Context(), id, req.Note, currentUserID(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.broadcast(c, event, q)
+	c.JSON(http.StatusOK, q)
+}
+
+// broadcast 推送到 quotes 频道，只发给能看到报价门店的订阅者；报价没有乐观锁版本号，用更新时间（毫秒）作为版本
+func (h *QuoteHandler) broadcast(c *gin.Context, event string, q *sales.Quote) {
+	publish(c, h.Bus, realtime.Event{Topic: "quotes", Event: event, Entity: "quote", EntityID: q.ID, Version: q.UpdatedAt.UnixMilli(), StoreID: q.StoreID}, q)
+}
@@ -0,0 +1,25 @@
+// internal/handler/realtime.go
+package handler
+
+import (
+	"encoding/json"
+
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/pkg/eventbus"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// publish 把实时事件发布到事件总线，由各实例的 websocket hub 推送给订阅者
+// 业务操作已经成功，发布失败只记日志
+func publish(c *gin.Context, bus eventbus.Bus, e realtime.Event, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		e.Payload = data
+		err = bus.Publish(c.Request.Context(), &e)
+	}
+	if err != nil {
+		zap.L().Warn("Failed to publish realtime event", zap.String("topic", e.Topic), zap.String("event", e.Event), zap.Error(err))
+	}
+}
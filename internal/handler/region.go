@@ -17,11 +17,12 @@ type RegionHandler struct {
 func NewRegionHandler(rg *gin.RouterGroup, svc *service.RegionService) *RegionHandler {
 	h := &RegionHandler{regionSvc: svc}
 	reg := rg.Group("/regions")
+	// 地区 / 门店是各模块共用的基础资料，登录即可查看；维护需要系统配置权限
 	reg.GET("", h.ListRegions)
 	reg.GET("/:id", h.GetByID)
-	reg.POST("", h.CreateRegion)
-	reg.PUT("/:id", h.UpdateRegion)
-	reg.DELETE("/:id", h.DeleteRegion)
+	reg.POST("", RequirePermission("system.config"), h.CreateRegion)
+	reg.PUT("/:id", RequirePermission("system.config"), h.UpdateRegion)
+	reg.DELETE("/:id", RequirePermission("system.config"), h.DeleteRegion)
 	return h
 }
 
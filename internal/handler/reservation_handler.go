@@ -0,0 +1,74 @@
+// internal/handler/reservation_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReservationHandler struct {
+	Svc *service.ReservationService
+}
+
+// NewReservationHandler 挂载库存预留查询和过期清理路由；预留由订单状态变更驱动，见 OrderHandler
+func NewReservationHandler(rg *gin.RouterGroup, svc *service.ReservationService) {
+	h := &ReservationHandler{Svc: svc}
+
+	orders := rg.Group("/orders")
+	orders.GET("/:id/reservations", RequirePermission("sales.view"), h.OrderReservations)
+
+	inv := rg.Group("/inventory/reservations")
+	inv.GET("", RequirePermission("inventory.view"), h.List)
+	inv.POST("/sweep", RequirePermission("inventory.adjust"), h.Sweep)
+}
+
+// OrderReservations GET /api/orders/:id/reservations
+func (h *ReservationHandler) OrderReservations(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.Svc.ListForOrder(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// List GET /api/inventory/reservations?status=active&productId=1&warehouseId=2&orderId=3
+func (h *ReservationHandler) List(c *gin.Context) {
+	f := repository.ReservationFilter{Status: inventory.ReservationStatus(c.Query("status"))}
+	for name, dst := range map[string]*uint{"productId": &f.ProductID, "warehouseId": &f.WarehouseID, "orderId": &f.OrderID} {
+		if c.Query(name) == "" {
+			continue
+		}
+		id, ok := parseIDQuery(c, name)
+		if !ok {
+			return
+		}
+		*dst = id
+	}
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "reservations": list})
+}
+
+// Sweep POST /api/inventory/reservations/sweep，立即执行一次过期清理
+func (h *ReservationHandler) Sweep(c *gin.Context) {
+	n, err := h.Svc.SweepExpired(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"released": n})
+}
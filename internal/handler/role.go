@@ -14,11 +14,13 @@ type RoleHandler struct{ svc service.RoleService }
 func NewRoleHandler(rg *gin.RouterGroup, svc service.RoleService) {
 	h := &RoleHandler{svc}
 	grp := rg.Group("/roles")
-	grp.POST("", h.Create)
-	grp.GET("", h.List)
-	grp.GET("/:id", h.Get)
-	grp.PUT("/:id", h.Update)
-	grp.DELETE("/:id", h.Delete)
+	view := RequirePermission("user.view")
+	manage := RequirePermission("user.permission")
+	grp.POST("", manage, h.Create)
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.PUT("/:id", manage, h.Update)
+	grp.DELETE("/:id", manage, h.Delete)
 }
 
 // Create godoc
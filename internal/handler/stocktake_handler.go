@@ -0,0 +1,218 @@
+// internal/handler/stocktake_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StocktakeHandler struct {
+	Svc *service.StocktakeService
+}
+
+// NewStocktakeHandler 在 /inventory/stocktakes 下挂载盘点路由
+// 计数由有 inventory.adjust 权限的人员完成，审核和过账需要主管
+func NewStocktakeHandler(rg *gin.RouterGroup, svc *service.StocktakeService) {
+	h := &StocktakeHandler{Svc: svc}
+	grp := rg.Group("/inventory/stocktakes")
+
+	view := RequirePermission("inventory.view")
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.GET("/:id/variance-report", view, h.VarianceReport)
+
+	adjust := RequirePermission("inventory.adjust")
+	grp.POST("", adjust, h.Create)
+	grp.POST("/:id/counts", adjust, h.RecordCounts)
+	grp.POST("/:id/counts/import", adjust, h.ImportCounts)
+	grp.POST("/:id/submit", adjust, h.Submit)
+	grp.POST("/:id/cancel", adjust, h.Cancel)
+	grp.POST("/:id/review", adjust, RequireLeader(), h.Review)
+	grp.POST("/:id/post", adjust, RequireLeader(), h.Post)
+}
+
+// List GET /api/inventory/stocktakes?status=counting&warehouseId=1&offset=0&limit=20
+func (h *StocktakeHandler) List(c *gin.Context) {
+	f := repository.StocktakeFilter{Status: inventory.StocktakeStatus(c.Query("status"))}
+	if c.Query("warehouseId") != "" {
+		id, ok := parseIDQuery(c, "warehouseId")
+		if !ok {
+			return
+		}
+		f.WarehouseID = id
+	}
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "stocktakes": list})
+}
+
+// Get GET /api/inventory/stocktakes/:id
+func (h *StocktakeHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	st, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, st)
+}
+
+// Create POST /api/inventory/stocktakes
+func (h *StocktakeHandler) Create(c *gin.Context) {
+	var req dto.CreateStocktakeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	st, err := h.Svc.Create(c.Request.Context(), req, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusCreated, st)
+}
+
+// RecordCounts POST /api/inventory/stocktakes/:id/counts
+func (h *StocktakeHandler) RecordCounts(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.StocktakeCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	st, err := h.Svc.RecordCounts(c.Request.Context(), id, req.Counts, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, st)
+}
+
+// ImportCounts POST /api/inventory/stocktakes/:id/counts/import，field="file"，A 列 DJJ 编码，B 列实盘数量
+func (h *StocktakeHandler) ImportCounts(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot open file"})
+		return
+	}
+	defer file.Close()
+
+	st, err := h.Svc.ImportCounts(c.Request.Context(), id, file, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, st)
+}
+
+// Submit POST /api/inventory/stocktakes/:id/submit
+func (h *StocktakeHandler) Submit(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.SubmitStocktakeRequest
+	// 参数可选，允许空 body
+	_ = c.ShouldBindJSON(&req)
+	st, err := h.Svc.Submit(c.Request.Context(), id, req.UncountedAsZero, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, st)
+}
+
+// Review POST /api/inventory/stocktakes/:id/review
+func (h *StocktakeHandler) Review(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ReviewStocktakeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	st, err := h.Svc.Review(c.Request.Context(), id, req)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, st)
+}
+
+// Post POST /api/inventory/stocktakes/:id/post
+func (h *StocktakeHandler) Post(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	st, err := h.Svc.Post(c.Request.Context(), id, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, st)
+}
+
+// Cancel POST /api/inventory/stocktakes/:id/cancel
+func (h *StocktakeHandler) Cancel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.Svc.Cancel(c.Request.Context(), id); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "stocktake cancelled"})
+}
+
+// VarianceReport GET /api/inventory/stocktakes/:id/variance-report
+func (h *StocktakeHandler) VarianceReport(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	data, filename, err := h.Svc.VarianceReport(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	const xlsx = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, xlsx, data)
+}
+
+// respond 盲盘计数阶段对非主管隐藏账面数量
+func (h *StocktakeHandler) respond(c *gin.Context, status int, st *inventory.Stocktake) {
+	if st.BlindCount && st.Status == inventory.StocktakeStatusCounting && !isLeader(c) {
+		st.HideExpected()
+	}
+	c.JSON(status, st)
+}
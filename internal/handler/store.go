@@ -14,8 +14,10 @@ type StoreHandler struct {
 
 func NewStoreHandler(rg *gin.RouterGroup, svc *service.StoreService) *StoreHandler {
 	handler := &StoreHandler{storeSvc: svc}
-	rg.GET("/stores", handler.ListStores)
-	rg.GET("/stores/:id", handler.GetStoreByID)
+	// 门店是销售、库存、财务、用户管理页面的下拉选项，拥有其中任一模块的查看权限即可读取
+	view := RequireAnyPermission("sales.view", "quote.view", "inventory.view", "finance.view", "user.view")
+	rg.GET("/stores", view, handler.ListStores)
+	rg.GET("/stores/:id", view, handler.GetStoreByID)
 	return handler
 }
 
@@ -0,0 +1,142 @@
+// internal/handler/transfer_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"djj-inventory-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferHandler struct {
+	Svc *service.TransferService
+}
+
+// NewTransferHandler 在 /inventory/transfers 下挂载仓库间调拨路由
+func NewTransferHandler(rg *gin.RouterGroup, svc *service.TransferService) {
+	h := &TransferHandler{Svc: svc}
+	inv := rg.Group("/inventory")
+	inv.GET("/warehouses/:id/in-transit", RequirePermission("inventory.view"), h.InTransit)
+
+	grp := inv.Group("/transfers")
+	grp.GET("", RequirePermission("inventory.view"), h.List)
+	grp.GET("/:id", RequirePermission("inventory.view"), h.Get)
+
+	transfer := RequirePermission("inventory.transfer")
+	grp.POST("", transfer, h.Create)
+	grp.POST("/:id/dispatch", transfer, h.Dispatch)
+	grp.POST("/:id/receive", transfer, h.Receive)
+	grp.POST("/:id/cancel", transfer, h.Cancel)
+}
+
+// List GET /api/inventory/transfers?status=in_transit&warehouseId=1&offset=0&limit=20
+func (h *TransferHandler) List(c *gin.Context) {
+	f := repository.TransferFilter{Status: inventory.TransferStatus(c.Query("status"))}
+	if c.Query("warehouseId") != "" {
+		id, ok := parseIDQuery(c, "warehouseId")
+		if !ok {
+			return
+		}
+		f.WarehouseID = id
+	}
+	off, lim := parsePaging(c)
+	list, total, err := h.Svc.List(c.Request.Context(), f, off, lim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "transfers": list})
+}
+
+// Get GET /api/inventory/transfers/:id
+func (h *TransferHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	t, err := h.Svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// Create POST /api/inventory/transfers
+func (h *TransferHandler) Create(c *gin.Context) {
+	var req dto.CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.Svc.Create(c.Request.Context(), req, currentOperator(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// Dispatch POST /api/inventory/transfers/:id/dispatch
+func (h *TransferHandler) Dispatch(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	t, err := h.Svc.Dispatch(c.Request.Context(), id, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// Receive POST /api/inventory/transfers/:id/receive
+func (h *TransferHandler) Receive(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ReceiveTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.Svc.Receive(c.Request.Context(), id, req, currentOperator(c))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// Cancel POST /api/inventory/transfers/:id/cancel
+func (h *TransferHandler) Cancel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.Svc.Cancel(c.Request.Context(), id); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMessage{Message: "transfer cancelled"})
+}
+
+// InTransit GET /api/inventory/warehouses/:id/in-transit
+func (h *TransferHandler) InTransit(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	list, err := h.Svc.InTransit(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
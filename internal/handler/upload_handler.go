@@ -26,9 +26,11 @@ func NewUploadHandler(rg *gin.RouterGroup, uploadDir, baseURL string) {
 		UploadDir: uploadDir,
 		BaseURL:   baseURL,
 	}
-	rg.POST("/upload", h.UploadFile)
-	rg.POST("/upload/multiple", h.UploadFiles)
-	rg.DELETE("/upload/delete", h.DeleteFile)
+	// 产品图片、单据附件由能编辑这些资料的人上传
+	upload := RequireAnyPermission("inventory.in", "inventory.adjust", "sales.create", "sales.edit", "quote.create", "quote.edit")
+	rg.POST("/upload", upload, h.UploadFile)
+	rg.POST("/upload/multiple", upload, h.UploadFiles)
+	rg.DELETE("/upload/delete", upload, h.DeleteFile)
 }
 
 // UploadFile  单文件上传： field="file", form field "folder" 可选
@@ -17,20 +17,23 @@ type UserHandler struct {
 func NewUserHandler(rg *gin.RouterGroup, svc service.UserService) {
 	h := &UserHandler{svc}
 	grp := rg.Group("/users")
-	grp.POST("", h.Create)
-	grp.GET("", h.List)
-	grp.GET("/:id", h.Get)
-	grp.PUT("/:id", h.Update)
-	grp.DELETE("/:id", h.Delete)
+	view := RequirePermission("user.view")
+	// 改角色 / 授权既要能编辑用户，也要有权限管理权
+	grant := RequireAllPermissions("user.edit", "user.permission")
+	grp.POST("", RequirePermission("user.create"), h.Create)
+	grp.GET("", view, h.List)
+	grp.GET("/:id", view, h.Get)
+	grp.PUT("/:id", RequirePermission("user.edit"), h.Update)
+	grp.DELETE("/:id", RequirePermission("user.delete"), h.Delete)
 	// 角色分配
-	grp.POST("/:id/roles/:rid", h.AssignRole)
-	grp.DELETE("/:id/roles/:rid", h.RemoveRole)
-	grp.GET("/:id/roles", h.ListRoles)
+	grp.POST("/:id/roles/:rid", grant, h.AssignRole)
+	grp.DELETE("/:id/roles/:rid", grant, h.RemoveRole)
+	grp.GET("/:id/roles", view, h.ListRoles)
 
 	// ---- 新增：直接赋予/回收 用户权限 ----
-	grp.POST("/:id/permissions", h.GrantUserPermissions)
-	grp.DELETE("/:id/permissions", h.RevokeUserPermissions)
-	grp.GET("/:id/permissions", h.ListUserPermissions)
+	grp.POST("/:id/permissions", grant, h.GrantUserPermissions)
+	grp.DELETE("/:id/permissions", grant, h.RevokeUserPermissions)
+	grp.GET("/:id/permissions", view, h.ListUserPermissions)
 }
 
 // Create godoc
@@ -57,7 +60,7 @@ func (h *UserHandler) Create(c *gin.Context) {
 	}
 	u, err := h.svc.Create(c, in.Username, in.Email, in.Password, in.RoleNames)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeServiceError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, u)
@@ -150,7 +153,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 	}
 	u, err := h.svc.Update(c, uint(id), in.Email, in.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeServiceError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, u)
@@ -6,15 +6,24 @@ import (
 )
 
 // 对应 DB 中 audited_history 表
+// 服务层手动记录的 Payload 内容由调用方决定；GORM 回调自动记录的行变更（INSERT / UPDATE / DELETE）
+// 在 OldData / NewData 里存变更前后的整行，Payload 存字段级差异 {"列名": {"old": ..., "new": ...}}
+// 每条记录带上一条的哈希组成哈希链，事后修改、删除、插入任意一条都能校验出来（见 pkg/audit.Hash）
+// 记录先以待上链状态写入（ChainSeq 为空），再由 pkg/audit.Seal 按顺序接到链尾
 type AuditedHistory struct {
-	HistoryID int              `gorm:"column:history_id;primaryKey"`
-	TableName AuditedTableEnum `gorm:"column:table_name"`
-	RecordID  int              `gorm:"column:record_id"`
-	StoreID   int              `gorm:"column:store_id"`
-	ChangedBy int              `gorm:"column:changed_by"`
-	Operation string           `gorm:"column:operation"`
-	Payload   json.RawMessage  `gorm:"column:payload"`
-	ChangedAt time.Time        `gorm:"column:changed_at"` // 或 time.Time
+	HistoryID int              `gorm:"column:history_id;primaryKey" json:"historyId"`
+	TableName AuditedTableEnum `gorm:"column:table_name" json:"tableName"`
+	RecordID  int              `gorm:"column:record_id" json:"recordId"`
+	StoreID   int              `gorm:"column:store_id" json:"storeId"`
+	ChangedBy int              `gorm:"column:changed_by" json:"changedBy"`
+	Operation string           `gorm:"column:operation" json:"operation"`
+	Payload   json.RawMessage  `gorm:"column:payload" json:"payload"`
+	OldData   json.RawMessage  `gorm:"column:old_data" json:"oldData"`                    // 变更前整行，新增时为空
+	NewData   json.RawMessage  `gorm:"column:new_data" json:"newData"`                    // 变更后整行，物理删除时为空
+	ChangedAt time.Time        `gorm:"column:changed_at;autoCreateTime" json:"changedAt"` // 或 time.Time
+	ChainSeq  *int64           `gorm:"column:chain_seq;uniqueIndex" json:"chainSeq"`      // 在哈希链上的位置，从 1 开始，待上链时为空
+	PrevHash  string           `gorm:"column:prev_hash;size:64" json:"prevHash"`          // 上一条的 Hash，第一条为空
+	Hash      string           `gorm:"column:hash;size:64;index" json:"hash"`             // 本条内容 + PrevHash 的 SHA-256
 }
 
 // 枚举：把所有需要审计的表名都加进来
@@ -31,7 +40,22 @@ const (
 	AuditedTableQuoteItems      AuditedTableEnum = "quote_items"
 	AuditedTableOrders          AuditedTableEnum = "orders"
 	AuditedTableOrderItems      AuditedTableEnum = "order_items"
-	AuditedTableInventory       AuditedTableEnum = "inventory"
-	AuditedTableInventoryLogs   AuditedTableEnum = "inventory_logs"
+	AuditedTableInventory       AuditedTableEnum = "inventory"      // 旧库存表，已由 product_stocks 取代
+	AuditedTableInventoryLogs   AuditedTableEnum = "inventory_logs" // 旧库存日志，已由 inventory_transaction 取代
+	AuditedTableTaxInvoices     AuditedTableEnum = "tax_invoices"
+	AuditedTablePayments        AuditedTableEnum = "payments"
+	AuditedTableCreditNotes     AuditedTableEnum = "credit_notes"
+	AuditedTableAPIKeys         AuditedTableEnum = "api_keys"
+	AuditedTableProductStocks   AuditedTableEnum = "product_stocks"
+	AuditedTableCustomers       AuditedTableEnum = "customers"
 	// ……按需继续添加
 )
+
+// AuditedTables 由 GORM 回调自动记录行变更的表；只有单一主键的表会被记录，联结表仍由服务层手动记录
+// 库存只审计 product_stocks；inventory_transaction 是只追加的流水，本身就是变动记录
+var AuditedTables = []AuditedTableEnum{
+	AuditedTableUsers, AuditedTableRoles, AuditedTablePermissions, AuditedTableUserRoles, AuditedTableRolePermissions,
+	AuditedTableProducts, AuditedTableProductStocks, AuditedTableCustomers,
+	AuditedTableQuotes, AuditedTableQuoteItems, AuditedTableOrders, AuditedTableOrderItems,
+	AuditedTableTaxInvoices, AuditedTablePayments, AuditedTableCreditNotes, AuditedTableAPIKeys,
+}
@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Checkpoint 审计哈希链的签名检查点：记下某一时刻链尾的记录和哈希，用 Ed25519 签名
+// 导出给外部审计方后，即使链尾的记录被整段删除或重写，也能凭检查点离线发现
+type Checkpoint struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HistoryID int       `gorm:"not null;uniqueIndex" json:"historyId"` // 覆盖到的最后一条审计记录
+	Hash      string    `gorm:"size:64;not null" json:"hash"`          // 该记录的 Hash
+	Count     int64     `gorm:"not null" json:"count"`                 // 该记录在链上的位置（chain_seq），即截至该记录的审计记录总数
+	KeyID     string    `gorm:"size:50;not null" json:"keyId"`
+	Signature string    `gorm:"type:text;not null" json:"signature"` // base64 编码的 Ed25519 签名
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (Checkpoint) TableName() string { return "audit_checkpoints" }
+
+// Message 被签名的内容，外部审计方按同样格式拼接后用公钥验签：
+// djj-audit-checkpoint/v1|<historyId>|<hash>|<count>|<createdAt RFC3339Nano UTC>
+func (c *Checkpoint) Message() []byte {
+	return []byte(fmt.Sprintf("djj-audit-checkpoint/v1|%d|%s|%d|%s",
+		c.HistoryID, c.Hash, c.Count, c.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
@@ -13,6 +13,8 @@ type ProductStock struct {
 	Reserved int `gorm:"not null;default:0" json:"reserved"`
 	// 如果 DB 有生成列，就可以直接 Preload 出 available
 	Available int `gorm:"->;type:integer" json:"available"`
+	// 库位（货架 / 储位编码），拣货单按它指引拣货员
+	BinLocation string `gorm:"size:100" json:"bin_location"`
 
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
@@ -0,0 +1,34 @@
+package dto
+
+// CreateInvoiceRequest 为已交付订单开税务发票；日期为空时开票日为今天，到期日按配置的账期计算
+type CreateInvoiceRequest struct {
+	IssueDate string `json:"issueDate"` // YYYY-MM-DD
+	DueDate   string `json:"dueDate"`   // YYYY-MM-DD
+}
+
+// RecordPaymentRequest 登记定金 / 尾款
+type RecordPaymentRequest struct {
+	Kind       string  `json:"kind" binding:"required,oneof=deposit final"`
+	Amount     float64 `json:"amount" binding:"gt=0"`
+	Method     string  `json:"method" binding:"max=50"`
+	Reference  string  `json:"reference" binding:"max=100"`
+	Note       string  `json:"note"`
+	ReceivedAt string  `json:"receivedAt"` // YYYY-MM-DD，默认今天
+}
+
+// CreateCreditNoteRequest 开贷项通知单；RefundAmount > 0 时同时登记退款
+type CreateCreditNoteRequest struct {
+	Reason       string                  `json:"reason" binding:"required"`
+	Lines        []CreditNoteLineRequest `json:"lines" binding:"required,min=1,dive"`
+	RefundAmount float64                 `json:"refundAmount" binding:"gte=0"`
+	RefundMethod string                  `json:"refundMethod" binding:"max=50"`
+	Reference    string                  `json:"reference" binding:"max=100"`
+}
+
+// CreditNoteLineRequest 贷项通知单一行：带 invoiceLineId 时按发票行退货（单价取发票行），否则为金额调整，需填写描述和不含税单价
+type CreditNoteLineRequest struct {
+	InvoiceLineID *uint   `json:"invoiceLineId"`
+	Description   string  `json:"description"`
+	Quantity      int     `json:"quantity" binding:"gt=0"`
+	UnitPrice     float64 `json:"unitPrice" binding:"gte=0"`
+}
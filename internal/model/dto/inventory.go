@@ -0,0 +1,115 @@
+// internal/dto/inventory.go
+package dto
+
+// StockMovementRequest 入库 / 出库 / 销售 / 预留 / 释放 共用的请求体
+type StockMovementRequest struct {
+	ProductID   uint   `json:"productId" binding:"required"`
+	WarehouseID uint   `json:"warehouseId" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required,gt=0"`
+	Note        string `json:"note"`
+}
+
+// BatchStockMovementRequest 批量库存操作请求体
+type BatchStockMovementRequest struct {
+	Updates []BatchStockMovementItem `json:"updates" binding:"required,min=1,dive"`
+}
+
+// BatchStockMovementItem 批量库存操作中的单条记录，Type 取值 IN / OUT / SALE
+type BatchStockMovementItem struct {
+	ProductID   uint   `json:"productId" binding:"required"`
+	WarehouseID uint   `json:"warehouseId" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required,gt=0"`
+	Type        string `json:"type" binding:"required,oneof=IN OUT SALE"`
+	Note        string `json:"note"`
+}
+
+// CreateTransferRequest 新建仓库间调拨单
+type CreateTransferRequest struct {
+	FromWarehouseID uint                  `json:"fromWarehouseId" binding:"required"`
+	ToWarehouseID   uint                  `json:"toWarehouseId" binding:"required"`
+	Note            string                `json:"note"`
+	Items           []TransferItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// TransferItemRequest 调拨明细
+type TransferItemRequest struct {
+	ProductID uint `json:"productId" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// ReceiveTransferRequest 调拨收货；Close 为 true 时按当前实收关闭调拨单，短缺部分在源仓库按在途丢失报损
+type ReceiveTransferRequest struct {
+	Items []TransferReceiptRequest `json:"items" binding:"dive"`
+	Close bool                     `json:"close"`
+}
+
+// TransferReceiptRequest 单条明细的实收数量与差异说明
+type TransferReceiptRequest struct {
+	ItemID          uint   `json:"itemId" binding:"required"`
+	Quantity        int    `json:"quantity" binding:"gte=0"`
+	DiscrepancyNote string `json:"discrepancyNote"`
+}
+
+// CreateAdjustmentRequest 新建库存调整 / 报损单
+// TxType 为 ADJUST 时 Quantity 带符号；DAMAGE / EXPIRED / STOLEN 时 Quantity 为正的报损数量
+type CreateAdjustmentRequest struct {
+	ProductID   uint                `json:"productId" binding:"required"`
+	WarehouseID uint                `json:"warehouseId" binding:"required"`
+	TxType      string              `json:"txType" binding:"required,oneof=ADJUST DAMAGE EXPIRED STOLEN"`
+	Quantity    int                 `json:"quantity" binding:"required"`
+	ReasonCode  string              `json:"reasonCode" binding:"required"`
+	Note        string              `json:"note"`
+	Attachments []AttachmentRequest `json:"attachments" binding:"dive"`
+}
+
+// AttachmentRequest 已通过 /upload 上传的文件信息
+type AttachmentRequest struct {
+	FileName string `json:"fileName" binding:"required"`
+	FileType string `json:"fileType" binding:"required"`
+	FileSize int    `json:"fileSize"`
+	URL      string `json:"url" binding:"required"`
+}
+
+// ReviewAdjustmentRequest 审批 / 驳回调整单
+type ReviewAdjustmentRequest struct {
+	Note string `json:"note"`
+}
+
+// CreateStocktakeRequest 新建盘点单；ProductIDs 为空时盘点整个仓库
+type CreateStocktakeRequest struct {
+	WarehouseID uint   `json:"warehouseId" binding:"required"`
+	BlindCount  bool   `json:"blindCount"`
+	ProductIDs  []uint `json:"productIds"`
+	Note        string `json:"note"`
+}
+
+// StocktakeCountRequest 录入计数；ProductID 与 DJJCode 二选一，Mode 为 add 时累加（扫码），默认 set 覆盖
+type StocktakeCountRequest struct {
+	Counts []StocktakeCountItem `json:"counts" binding:"required,min=1,dive"`
+}
+
+// StocktakeCountItem 单条计数
+type StocktakeCountItem struct {
+	ProductID uint   `json:"productId"`
+	DJJCode   string `json:"djjCode"`
+	Quantity  int    `json:"quantity" binding:"gte=0"`
+	Mode      string `json:"mode" binding:"omitempty,oneof=set add"`
+}
+
+// SubmitStocktakeRequest 提交计数；UncountedAsZero 为 true 时未计数的行按 0 处理
+type SubmitStocktakeRequest struct {
+	UncountedAsZero bool `json:"uncountedAsZero"`
+}
+
+// ReviewStocktakeRequest 审核差异；ApproveAll 为 true 时批准所有差异行，Lines 中的决定优先
+type ReviewStocktakeRequest struct {
+	ApproveAll bool                     `json:"approveAll"`
+	Lines      []StocktakeDecisionInput `json:"lines" binding:"dive"`
+}
+
+// StocktakeDecisionInput 单行审核结果
+type StocktakeDecisionInput struct {
+	LineID   uint   `json:"lineId" binding:"required"`
+	Approved bool   `json:"approved"`
+	Note     string `json:"note"`
+}
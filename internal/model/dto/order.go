@@ -17,3 +17,27 @@ type OrderItemDTO struct {
 	Quantity  int     `json:"quantity"`
 	UnitPrice float64 `json:"unitPrice"`
 }
+
+// ChangeOrderStatusRequest 修改订单状态
+type ChangeOrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// CreateOrderFromQuoteRequest 由已审批报价单生成草稿订单；字段为空时沿用报价单 / 客户信息
+type CreateOrderFromQuoteRequest struct {
+	ShippingAddress string `json:"shippingAddress"`
+	OrderDate       string `json:"orderDate"` // YYYY-MM-DD，默认今天
+}
+
+// UpdateOrderRequest 修改草稿订单抬头
+type UpdateOrderRequest struct {
+	ShippingAddress *string `json:"shippingAddress"`
+	OrderDate       *string `json:"orderDate"` // YYYY-MM-DD
+}
+
+// OrderItemRequest 新增 / 修改草稿订单明细，UnitPrice 为不含税单价
+type OrderItemRequest struct {
+	ProductID uint    `json:"productId"`
+	Quantity  int     `json:"quantity" binding:"required,gt=0"`
+	UnitPrice float64 `json:"unitPrice" binding:"gte=0"`
+}
@@ -13,3 +13,20 @@ type PickingListItemDTO struct {
 	Quantity  int    `json:"quantity"`
 	Location  string `json:"location"`
 }
+
+// ConfirmPicksRequest 逐行确认实拣数量；实拣少于应拣的部分进入缺货单
+type ConfirmPicksRequest struct {
+	Lines []PickLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type PickLineRequest struct {
+	LineID    uint `json:"lineId" binding:"required"`
+	PickedQty *int `json:"pickedQty" binding:"required,gte=0"`
+}
+
+// SetBinLocationRequest 设置产品在仓库中的库位
+type SetBinLocationRequest struct {
+	ProductID   uint   `json:"productId" binding:"required"`
+	WarehouseID uint   `json:"warehouseId" binding:"required"`
+	BinLocation string `json:"binLocation" binding:"max=100"`
+}
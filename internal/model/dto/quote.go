@@ -23,3 +23,37 @@ type QuoteItemDTO struct {
 	Discount    float64 `json:"discount"`
 	TotalPrice  float64 `json:"totalPrice"`
 }
+
+// QuoteContentRequest 报价内容：新建与修订共用；金额由服务端按明细计算，前端传入的合计会被忽略
+type QuoteContentRequest struct {
+	SalesRepID    uint               `json:"salesRepId"` // 默认当前用户
+	QuoteDate     string             `json:"quoteDate"`  // YYYY-MM-DD，默认今天
+	Currency      string             `json:"currency" binding:"omitempty,oneof=AUD USD CNY EUR GBP"`
+	Remarks       string             `json:"remarks"`
+	WarrantyNotes string             `json:"warrantyNotes"`
+	Items         []QuoteItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// QuoteItemRequest 报价明细；Discount 为整行折扣金额（不含税）
+type QuoteItemRequest struct {
+	ProductID         *uint   `json:"productId"`
+	Description       string  `json:"description" binding:"required"`
+	DetailDescription string  `json:"detailDescription"`
+	Quantity          int     `json:"quantity" binding:"gt=0"`
+	Unit              string  `json:"unit"` // 默认 ea
+	UnitPrice         float64 `json:"unitPrice" binding:"gte=0"`
+	Discount          float64 `json:"discount" binding:"gte=0"`
+	GoodsNature       string  `json:"goodsNature" binding:"omitempty,oneof=contract multi_contract partial_contract warranty gift self_purchased consignment"`
+}
+
+// CreateQuoteRequest 新建报价（版本 A）
+type CreateQuoteRequest struct {
+	StoreID    uint `json:"storeId" binding:"required"`
+	CustomerID uint `json:"customerId" binding:"required"`
+	QuoteContentRequest
+}
+
+// ReviewQuoteRequest 审批 / 拒绝报价
+type ReviewQuoteRequest struct {
+	Note string `json:"note"`
+}
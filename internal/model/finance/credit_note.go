@@ -0,0 +1,45 @@
+// internal/model/finance/credit_note.go
+package finance
+
+import (
+	"djj-inventory-system/internal/model/catalog"
+	"time"
+)
+
+// CreditNote 对应数据库表 credit_notes，冲减税务发票金额；有退款时同时生成一笔负数收款
+// 编号与发票分开，同样按公司连续递增
+type CreditNote struct {
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	CompanyID        uint             `gorm:"not null;uniqueIndex:idx_credit_note_company_seq" json:"companyId"`
+	Sequence         int64            `gorm:"not null;uniqueIndex:idx_credit_note_company_seq" json:"sequence"`
+	CreditNoteNumber string           `gorm:"size:50;unique;not null" json:"creditNoteNumber"`
+	TaxInvoiceID     uint             `gorm:"not null;index" json:"taxInvoiceId"`
+	TaxInvoice       *TaxInvoice      `gorm:"foreignKey:TaxInvoiceID" json:"taxInvoice,omitempty"`
+	IssueDate        time.Time        `gorm:"type:date;not null" json:"issueDate"`
+	Reason           string           `gorm:"type:text;not null" json:"reason"`
+	SubTotal         float64          `gorm:"type:numeric(14,2);not null" json:"subTotal"`
+	GSTTotal         float64          `gorm:"type:numeric(14,2);not null" json:"gstTotal"`
+	TotalAmount      float64          `gorm:"type:numeric(14,2);not null" json:"totalAmount"`
+	RefundAmount     float64          `gorm:"type:numeric(14,2);not null;default:0" json:"refundAmount"`
+	CreatedBy        uint             `gorm:"not null" json:"createdBy"`
+	CreatedAt        time.Time        `json:"createdAt"`
+	Lines            []CreditNoteLine `gorm:"foreignKey:CreditNoteID" json:"lines"`
+}
+
+func (CreditNote) TableName() string { return "credit_notes" }
+
+// CreditNoteLine 对应数据库表 credit_note_lines
+// 按发票行退货时带 TaxInvoiceLineID；纯金额调整（如折让）时为空
+type CreditNoteLine struct {
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	CreditNoteID     uint             `gorm:"not null;index" json:"creditNoteId"`
+	TaxInvoiceLineID *uint            `json:"taxInvoiceLineId,omitempty"`
+	ProductID        *uint            `json:"productId,omitempty"`
+	Product          *catalog.Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Description      string           `gorm:"size:255;not null" json:"description"`
+	Quantity         int              `gorm:"not null" json:"quantity"`
+	UnitPrice        float64          `gorm:"type:numeric(12,2);not null" json:"unitPrice"`
+	LineTotal        float64          `gorm:"type:numeric(14,2);not null" json:"lineTotal"`
+}
+
+func (CreditNoteLine) TableName() string { return "credit_note_lines" }
@@ -0,0 +1,18 @@
+// internal/model/finance/document_sequence.go
+package finance
+
+// 需要连续编号的单据类型
+const (
+	DocTypeTaxInvoice = "INV"
+	DocTypeCreditNote = "CN"
+)
+
+// DocumentSequence 对应数据库表 document_sequences，每个公司每类单据一行
+// 取号时在业务事务内 FOR UPDATE 锁住该行，事务回滚则号码一并回滚，保证不跳号
+type DocumentSequence struct {
+	CompanyID uint   `gorm:"primaryKey;autoIncrement:false" json:"companyId"`
+	DocType   string `gorm:"primaryKey;size:10" json:"docType"`
+	NextValue int64  `gorm:"not null;default:1" json:"nextValue"`
+}
+
+func (DocumentSequence) TableName() string { return "document_sequences" }
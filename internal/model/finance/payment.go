@@ -0,0 +1,35 @@
+// internal/model/finance/payment.go
+package finance
+
+import "time"
+
+// 收款类型：定金 / 尾款对应订单状态 deposit_received / final_payment_received；退款金额为负
+const (
+	PaymentKindDeposit = "deposit"
+	PaymentKindFinal   = "final"
+	PaymentKindRefund  = "refund"
+)
+
+// IsPaymentKind 检查是否为可手工登记的收款类型（退款只能随贷项通知单产生）
+func IsPaymentKind(kind string) bool {
+	return kind == PaymentKindDeposit || kind == PaymentKindFinal
+}
+
+// Payment 对应数据库表 payments
+// 收款挂在订单上；开票前收到的定金在开票时关联到发票
+type Payment struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	OrderID      uint      `gorm:"not null;index" json:"orderId"`
+	TaxInvoiceID *uint     `gorm:"index" json:"taxInvoiceId,omitempty"`
+	CreditNoteID *uint     `gorm:"index" json:"creditNoteId,omitempty"`
+	Kind         string    `gorm:"size:20;not null" json:"kind"`
+	Amount       float64   `gorm:"type:numeric(14,2);not null" json:"amount"`
+	Method       string    `gorm:"size:50" json:"method"`
+	Reference    string    `gorm:"size:100" json:"reference"`
+	Note         string    `gorm:"type:text" json:"note"`
+	ReceivedAt   time.Time `gorm:"not null" json:"receivedAt"`
+	RecordedBy   uint      `gorm:"not null" json:"recordedBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (Payment) TableName() string { return "payments" }
@@ -0,0 +1,87 @@
+// internal/model/finance/tax_invoice.go
+package finance
+
+import (
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/sales"
+	"time"
+)
+
+// 税务发票状态
+const (
+	InvoiceStatusUnpaid        = "unpaid"
+	InvoiceStatusPartiallyPaid = "partially_paid"
+	InvoiceStatusPaid          = "paid"
+	InvoiceStatusCredited      = "credited"
+)
+
+// TaxInvoice 对应数据库表 tax_invoices
+// 由已交付的订单生成，一张订单只开一张；编号按公司连续递增，不跳号
+type TaxInvoice struct {
+	ID             uint             `gorm:"primaryKey" json:"id"`
+	CompanyID      uint             `gorm:"not null;uniqueIndex:idx_tax_invoice_company_seq" json:"companyId"`
+	Sequence       int64            `gorm:"not null;uniqueIndex:idx_tax_invoice_company_seq" json:"sequence"`
+	InvoiceNumber  string           `gorm:"size:50;unique;not null" json:"invoiceNumber"`
+	OrderID        uint             `gorm:"not null;uniqueIndex" json:"orderId"`
+	Order          *sales.Order     `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+	CustomerID     uint             `gorm:"not null;index" json:"customerId"`
+	Customer       catalog.Customer `gorm:"foreignKey:CustomerID" json:"customer"`
+	StoreID        uint             `gorm:"not null;index" json:"storeId"`
+	IssueDate      time.Time        `gorm:"type:date;not null" json:"issueDate"`
+	DueDate        time.Time        `gorm:"type:date;not null" json:"dueDate"`
+	Currency       string           `gorm:"type:currency_code_enum;default:'AUD'" json:"currency"`
+	SubTotal       float64          `gorm:"type:numeric(14,2);not null" json:"subTotal"`
+	GSTTotal       float64          `gorm:"type:numeric(14,2);not null" json:"gstTotal"`
+	TotalAmount    float64          `gorm:"type:numeric(14,2);not null" json:"totalAmount"`
+	AmountPaid     float64          `gorm:"type:numeric(14,2);not null;default:0" json:"amountPaid"`
+	CreditedAmount float64          `gorm:"type:numeric(14,2);not null;default:0" json:"creditedAmount"`
+	BalanceDue     float64          `gorm:"type:numeric(14,2);not null" json:"balanceDue"`
+	Status         string           `gorm:"size:20;not null;default:'unpaid';index" json:"status"`
+	CreatedBy      uint             `gorm:"not null" json:"createdBy"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+	Lines          []TaxInvoiceLine `gorm:"foreignKey:TaxInvoiceID" json:"lines"`
+	Payments       []Payment        `gorm:"foreignKey:TaxInvoiceID" json:"payments,omitempty"`
+	CreditNotes    []CreditNote     `gorm:"foreignKey:TaxInvoiceID" json:"creditNotes,omitempty"`
+}
+
+func (TaxInvoice) TableName() string { return "tax_invoices" }
+
+// TaxInvoiceLine 对应数据库表 tax_invoice_lines，开票时从订单明细复制，之后不随订单变化
+type TaxInvoiceLine struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	TaxInvoiceID uint            `gorm:"not null;index" json:"taxInvoiceId"`
+	OrderItemID  uint            `gorm:"not null" json:"orderItemId"`
+	ProductID    uint            `gorm:"not null" json:"productId"`
+	Product      catalog.Product `gorm:"foreignKey:ProductID" json:"product"`
+	Description  string          `gorm:"size:255" json:"description"`
+	Quantity     int             `gorm:"not null" json:"quantity"`
+	UnitPrice    float64         `gorm:"type:numeric(12,2);not null" json:"unitPrice"`
+	LineTotal    float64         `gorm:"type:numeric(14,2);not null" json:"lineTotal"`
+}
+
+func (TaxInvoiceLine) TableName() string { return "tax_invoice_lines" }
+
+// NetAmount 扣除贷项通知单后客户应付的含税金额
+func (inv *TaxInvoice) NetAmount() float64 {
+	return sales.RoundMoney(inv.TotalAmount - inv.CreditedAmount)
+}
+
+// Settle 按已付 / 已贷记金额重算余额和状态
+// 全额贷记 → credited；余额为 0 → paid；有付款 → partially_paid；否则 unpaid
+func (inv *TaxInvoice) Settle(paid, credited float64) {
+	inv.AmountPaid = sales.RoundMoney(paid)
+	inv.CreditedAmount = sales.RoundMoney(credited)
+	net := inv.NetAmount()
+	inv.BalanceDue = sales.RoundMoney(net - inv.AmountPaid)
+	switch {
+	case net <= 0:
+		inv.Status = InvoiceStatusCredited
+	case inv.BalanceDue <= 0:
+		inv.Status = InvoiceStatusPaid
+	case inv.AmountPaid > 0:
+		inv.Status = InvoiceStatusPartiallyPaid
+	default:
+		inv.Status = InvoiceStatusUnpaid
+	}
+}
@@ -0,0 +1,25 @@
+package finance
+
+import "testing"
+
+func TestTaxInvoiceSettle(t *testing.T) {
+	cases := []struct {
+		paid, credited float64
+		status         string
+		balance        float64
+	}{
+		{0, 0, InvoiceStatusUnpaid, 110},
+		{30, 0, InvoiceStatusPartiallyPaid, 80},
+		{110, 0, InvoiceStatusPaid, 0},
+		{50, 60, InvoiceStatusPaid, 0},
+		{0, 10, InvoiceStatusUnpaid, 100},
+		{110, 110, InvoiceStatusCredited, -110},
+	}
+	for _, c := range cases {
+		inv := TaxInvoice{TotalAmount: 110}
+		inv.Settle(c.paid, c.credited)
+		if inv.Status != c.status || inv.BalanceDue != c.balance {
+			t.Errorf("Settle(%v, %v) = %s / %v, want %s / %v", c.paid, c.credited, inv.Status, inv.BalanceDue, c.status, c.balance)
+		}
+	}
+}
@@ -16,6 +16,7 @@ type InventoryTransaction struct {
 	Quantity    int                  `gorm:"not null" json:"quantity"`
 	Operator    string               `gorm:"size:100;not null" json:"operator"`
 	Note        string               `gorm:"size:500" json:"note"`
+	Reference   string               `gorm:"size:50;index" json:"reference"` // 关联单据号，如调拨单号
 	CreatedAt   time.Time            `gorm:"autoCreateTime" json:"createdAt"`
 }
 
@@ -0,0 +1,99 @@
+package inventory
+
+import (
+	"djj-inventory-system/internal/model/catalog"
+	"time"
+)
+
+const (
+	TableNameStockAdjustment = "stock_adjustments"
+
+	// AttachmentRefStockAdjustment 调整单照片在 attachments.ref_type 中的取值
+	AttachmentRefStockAdjustment = "stock_adjustment"
+)
+
+// AdjustmentStatus 调整单状态
+type AdjustmentStatus string
+
+const (
+	AdjustmentStatusPending  AdjustmentStatus = "pending"  // 超出阈值，等待主管审批
+	AdjustmentStatusPosted   AdjustmentStatus = "posted"   // 已过账，库存已变动
+	AdjustmentStatusRejected AdjustmentStatus = "rejected" // 被驳回，库存未变动
+)
+
+// ReasonCode 调整 / 报损原因码
+type ReasonCode string
+
+const (
+	ReasonCountCorrection  ReasonCode = "COUNT_CORRECTION"  // 盘点差异更正
+	ReasonDataEntryError   ReasonCode = "DATA_ENTRY_ERROR"  // 录入错误更正
+	ReasonFound            ReasonCode = "FOUND"             // 找回 / 盘盈
+	ReasonDamagedStorage   ReasonCode = "DAMAGED_STORAGE"   // 仓储过程损坏
+	ReasonDamagedTransit   ReasonCode = "DAMAGED_TRANSIT"   // 运输过程损坏
+	ReasonDamagedHandling  ReasonCode = "DAMAGED_HANDLING"  // 装卸 / 展示损坏
+	ReasonExpired          ReasonCode = "EXPIRED"           // 超过保质期
+	ReasonObsolete         ReasonCode = "OBSOLETE"          // 淘汰 / 无法销售
+	ReasonTheft            ReasonCode = "THEFT"             // 被盗
+	ReasonLost             ReasonCode = "LOST"              // 丢失，原因不明
+	ReasonSupplierShortage ReasonCode = "SUPPLIER_SHORTAGE" // 供应商少发
+)
+
+// reasonCodesByType 各事务类型允许使用的原因码
+var reasonCodesByType = map[TransactionType][]ReasonCode{
+	TransactionTypeAdjust:  {ReasonCountCorrection, ReasonDataEntryError, ReasonFound, ReasonSupplierShortage},
+	TransactionTypeDamage:  {ReasonDamagedStorage, ReasonDamagedTransit, ReasonDamagedHandling},
+	TransactionTypeExpired: {ReasonExpired, ReasonObsolete},
+	TransactionTypeStolen:  {ReasonTheft, ReasonLost},
+}
+
+// ReasonCodesFor 返回某事务类型可选的原因码；非调整 / 报损类型返回 nil
+func ReasonCodesFor(txType TransactionType) []ReasonCode {
+	return reasonCodesByType[txType]
+}
+
+// IsValidReasonCode 检查原因码是否适用于该事务类型
+func IsValidReasonCode(txType TransactionType, code ReasonCode) bool {
+	for _, c := range reasonCodesByType[txType] {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// StockAdjustment 库存调整 / 报损单
+// ADJUST 的 Quantity 带符号；DAMAGE / EXPIRED / STOLEN 的 Quantity 为正数，表示报损数量
+// 过账时写一条同类型的库存流水，Reference 为 AdjustmentNumber
+type StockAdjustment struct {
+	ID               uint                 `gorm:"primaryKey" json:"id"`
+	AdjustmentNumber string               `gorm:"size:50;unique;not null" json:"adjustmentNumber"`
+	ProductID        uint                 `gorm:"not null;index" json:"productId"`
+	Product          catalog.Product      `gorm:"foreignKey:ProductID" json:"product"`
+	WarehouseID      uint                 `gorm:"not null;index" json:"warehouseId"`
+	Warehouse        catalog.Warehouse    `gorm:"foreignKey:WarehouseID" json:"warehouse"`
+	TxType           TransactionType      `gorm:"column:tx_type;type:transaction_type;not null" json:"txType"`
+	Quantity         int                  `gorm:"not null" json:"quantity"`
+	ReasonCode       ReasonCode           `gorm:"size:30;not null;index" json:"reasonCode"`
+	Note             string               `gorm:"size:500" json:"note"`
+	UnitValue        float64              `gorm:"type:numeric(12,2);not null;default:0" json:"unitValue"`  // 创建时的产品单价快照
+	TotalValue       float64              `gorm:"type:numeric(12,2);not null;default:0" json:"totalValue"` // |Quantity| × UnitValue
+	Status           AdjustmentStatus     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	RequiresApproval bool                 `gorm:"not null;default:false" json:"requiresApproval"`
+	RequestedBy      string               `gorm:"size:100;not null" json:"requestedBy"`
+	ReviewedBy       string               `gorm:"size:100" json:"reviewedBy"`
+	ReviewedAt       *time.Time           `json:"reviewedAt,omitempty"`
+	ReviewNote       string               `gorm:"size:500" json:"reviewNote"`
+	PostedAt         *time.Time           `json:"postedAt,omitempty"`
+	CreatedAt        time.Time            `json:"createdAt"`
+	UpdatedAt        time.Time            `json:"updatedAt"`
+	Attachments      []catalog.Attachment `gorm:"polymorphic:Ref;polymorphicValue:stock_adjustment" json:"attachments,omitempty"`
+}
+
+func (*StockAdjustment) TableName() string {
+	return TableNameStockAdjustment
+}
+
+// StockDelta 过账后现有量的变化
+func (a *StockAdjustment) StockDelta() int {
+	return GetImpactDirection(a.TxType) * a.Quantity
+}
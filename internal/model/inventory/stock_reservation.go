@@ -0,0 +1,41 @@
+package inventory
+
+import (
+	"djj-inventory-system/internal/model/catalog"
+	"time"
+)
+
+const TableNameStockReservation = "stock_reservations"
+
+// ReservationStatus 预留状态
+type ReservationStatus string
+
+const (
+	ReservationStatusActive   ReservationStatus = "active"   // 生效中，占用 ProductStock.Reserved
+	ReservationStatusConsumed ReservationStatus = "consumed" // 已转为销售出库
+	ReservationStatusReleased ReservationStatus = "released" // 订单取消 / 退回草稿后释放
+	ReservationStatusExpired  ReservationStatus = "expired"  // 超时未付款，被清理任务释放
+)
+
+// StockReservation 订单明细在某个仓库上的库存预留
+// 一条订单明细可能按可用量拆到多个仓库，因此对应多条预留
+// 预留 / 释放 / 出库流水的 Reference 都是订单号
+type StockReservation struct {
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	OrderID     uint              `gorm:"not null;index" json:"orderId"`
+	OrderItemID uint              `gorm:"not null;index" json:"orderItemId"`
+	ProductID   uint              `gorm:"not null;index" json:"productId"`
+	WarehouseID uint              `gorm:"not null;index" json:"warehouseId"`
+	Warehouse   catalog.Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse"`
+	Quantity    int               `gorm:"not null" json:"quantity"`
+	Status      ReservationStatus `gorm:"size:20;not null;default:'active';index" json:"status"`
+	ExpiresAt   *time.Time        `gorm:"index" json:"expiresAt,omitempty"` // nil 表示不过期（如已收定金）
+	ClosedAt    *time.Time        `json:"closedAt,omitempty"`
+	CreatedBy   string            `gorm:"size:100;not null" json:"createdBy"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+func (*StockReservation) TableName() string {
+	return TableNameStockReservation
+}
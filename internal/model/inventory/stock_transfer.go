@@ -0,0 +1,68 @@
+package inventory
+
+import (
+	"djj-inventory-system/internal/model/catalog"
+	"time"
+)
+
+const (
+	TableNameStockTransfer     = "stock_transfers"
+	TableNameStockTransferItem = "stock_transfer_items"
+)
+
+// TransferStatus 调拨单状态
+type TransferStatus string
+
+const (
+	TransferStatusDraft             TransferStatus = "draft"              // 草稿，尚未发货
+	TransferStatusInTransit         TransferStatus = "in_transit"         // 已发货，在途
+	TransferStatusPartiallyReceived TransferStatus = "partially_received" // 部分收货
+	TransferStatusReceived          TransferStatus = "received"           // 收货完成（可能带差异）
+	TransferStatusCancelled         TransferStatus = "cancelled"          // 已取消
+)
+
+// StockTransfer 仓库间调拨单
+// 发货时在源仓库写 TRANSFER_OUT，收货时在目标仓库写 TRANSFER_IN，两边流水的 Reference 都是 TransferNumber
+type StockTransfer struct {
+	ID              uint                `gorm:"primaryKey" json:"id"`
+	TransferNumber  string              `gorm:"size:50;unique;not null" json:"transferNumber"`
+	FromWarehouseID uint                `gorm:"not null;index" json:"fromWarehouseId"`
+	FromWarehouse   catalog.Warehouse   `gorm:"foreignKey:FromWarehouseID" json:"fromWarehouse"`
+	ToWarehouseID   uint                `gorm:"not null;index" json:"toWarehouseId"`
+	ToWarehouse     catalog.Warehouse   `gorm:"foreignKey:ToWarehouseID" json:"toWarehouse"`
+	Status          TransferStatus      `gorm:"size:20;not null;default:'draft'" json:"status"`
+	Note            string              `gorm:"size:500" json:"note"`
+	CreatedBy       string              `gorm:"size:100;not null" json:"createdBy"`
+	DispatchedBy    string              `gorm:"size:100" json:"dispatchedBy"`
+	DispatchedAt    *time.Time          `json:"dispatchedAt,omitempty"`
+	ReceivedBy      string              `gorm:"size:100" json:"receivedBy"`
+	ReceivedAt      *time.Time          `json:"receivedAt,omitempty"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt"`
+	Items           []StockTransferItem `gorm:"foreignKey:TransferID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"items"`
+}
+
+func (StockTransfer) TableName() string { return TableNameStockTransfer }
+
+// StockTransferItem 调拨明细
+// 在途数量 = DispatchedQty - ReceivedQty - WrittenOffQty
+type StockTransferItem struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	TransferID      uint            `gorm:"not null;index" json:"transferId"`
+	ProductID       uint            `gorm:"not null;index" json:"productId"`
+	Product         catalog.Product `gorm:"foreignKey:ProductID" json:"product"`
+	Quantity        int             `gorm:"not null" json:"quantity"`
+	DispatchedQty   int             `gorm:"not null;default:0" json:"dispatchedQty"`
+	ReceivedQty     int             `gorm:"not null;default:0" json:"receivedQty"`
+	WrittenOffQty   int             `gorm:"not null;default:0" json:"writtenOffQty"` // 短收关闭时按在途丢失报损的数量
+	DiscrepancyNote string          `gorm:"size:500" json:"discrepancyNote"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+}
+
+func (StockTransferItem) TableName() string { return TableNameStockTransferItem }
+
+// InTransitQty 返回该明细仍在途的数量
+func (i StockTransferItem) InTransitQty() int {
+	return i.DispatchedQty - i.ReceivedQty - i.WrittenOffQty
+}
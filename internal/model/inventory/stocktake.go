@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"djj-inventory-system/internal/model/catalog"
+	"time"
+)
+
+const (
+	TableNameStocktake     = "stocktakes"
+	TableNameStocktakeLine = "stocktake_lines"
+)
+
+// StocktakeStatus 盘点单状态
+type StocktakeStatus string
+
+const (
+	StocktakeStatusCounting  StocktakeStatus = "counting"  // 已冻结快照，录入 / 扫码计数中
+	StocktakeStatusReviewing StocktakeStatus = "reviewing" // 计数已提交，主管审核差异
+	StocktakeStatusPosted    StocktakeStatus = "posted"    // 已批准的差异已按 ADJUST 过账
+	StocktakeStatusCancelled StocktakeStatus = "cancelled" // 已取消，未影响库存
+)
+
+// Stocktake 按仓库的盘点单
+// 创建时冻结 ProductStock.OnHand 快照；过账时按 计数 - 快照 写 ADJUST 流水，Reference 为 StocktakeNumber
+// 这样盘点期间发生的出入库不会被盘点结果覆盖
+type Stocktake struct {
+	ID              uint              `gorm:"primaryKey" json:"id"`
+	StocktakeNumber string            `gorm:"size:50;unique;not null" json:"stocktakeNumber"`
+	WarehouseID     uint              `gorm:"not null;index" json:"warehouseId"`
+	Warehouse       catalog.Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse"`
+	Status          StocktakeStatus   `gorm:"size:20;not null;default:'counting';index" json:"status"`
+	BlindCount      bool              `gorm:"not null;default:false" json:"blindCount"` // 盲盘：计数阶段不向盘点人展示账面数量
+	Note            string            `gorm:"size:500" json:"note"`
+	CreatedBy       string            `gorm:"size:100;not null" json:"createdBy"`
+	SnapshotAt      time.Time         `gorm:"not null" json:"snapshotAt"`
+	SubmittedBy     string            `gorm:"size:100" json:"submittedBy"`
+	SubmittedAt     *time.Time        `json:"submittedAt,omitempty"`
+	PostedBy        string            `gorm:"size:100" json:"postedBy"`
+	PostedAt        *time.Time        `json:"postedAt,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	UpdatedAt       time.Time         `json:"updatedAt"`
+	Lines           []StocktakeLine   `gorm:"foreignKey:StocktakeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"lines,omitempty"`
+
+	// ExpectedHidden 为 true 时，Lines 中的快照与差异已按盲盘要求隐藏
+	ExpectedHidden bool `gorm:"-" json:"expectedHidden"`
+}
+
+func (*Stocktake) TableName() string {
+	return TableNameStocktake
+}
+
+// HideExpected 盲盘计数阶段隐藏账面数量和差异
+func (s *Stocktake) HideExpected() {
+	for i := range s.Lines {
+		s.Lines[i].SnapshotQty = 0
+		s.Lines[i].Variance = 0
+	}
+	s.ExpectedHidden = true
+}
+
+// StocktakeLine 盘点明细：一个产品一行
+type StocktakeLine struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	StocktakeID uint            `gorm:"not null;uniqueIndex:idx_stocktake_product" json:"stocktakeId"`
+	ProductID   uint            `gorm:"not null;uniqueIndex:idx_stocktake_product" json:"productId"`
+	Product     catalog.Product `gorm:"foreignKey:ProductID" json:"product"`
+	SnapshotQty int             `gorm:"not null;default:0" json:"snapshotQty"` // 冻结时的账面现有量
+	CountedQty  *int            `json:"countedQty"`                            // nil 表示尚未计数
+	Variance    int             `gorm:"not null;default:0" json:"variance"`    // CountedQty - SnapshotQty，提交时计算
+	CountedBy   string          `gorm:"size:100" json:"countedBy"`
+	CountedAt   *time.Time      `json:"countedAt,omitempty"`
+	Approved    bool            `gorm:"not null;default:false" json:"approved"`
+	ReviewNote  string          `gorm:"size:500" json:"reviewNote"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+func (*StocktakeLine) TableName() string {
+	return TableNameStocktakeLine
+}
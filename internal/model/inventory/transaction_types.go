@@ -30,7 +30,7 @@ type TransactionTypeInfo struct {
 	Type        TransactionType `json:"type"`
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
-	Impact      string          `json:"impact"` // "positive", "negative", "neutral"
+	Impact      string          `json:"impact"` // "positive", "negative", "neutral", "signed"
 }
 
 // GetTransactionTypeInfo 获取事务类型信息映射表
@@ -70,7 +70,7 @@ func GetTransactionTypeInfo() map[TransactionType]TransactionTypeInfo {
 			Type:        TransactionTypeAdjust,
 			Name:        "调整",
 			Description: "库存数量调整，可正可负",
-			Impact:      "neutral",
+			Impact:      "signed",
 		},
 		TransactionTypeTransferIn: {
 			Type:        TransactionTypeTransferIn,
@@ -119,16 +119,27 @@ func IsValidTransactionType(txType TransactionType) bool {
 
 // GetImpactDirection 获取事务类型对库存的影响方向
 // 返回值：1 表示增加，-1 表示减少，0 表示不影响实际库存
+// ADJUST 的数量本身带符号，方向记为 1，即现有量变化 = 方向 × 数量
 func GetImpactDirection(txType TransactionType) int {
 	switch txType {
-	case TransactionTypeIn, TransactionTypeTransferIn, TransactionTypeReturn:
+	case TransactionTypeIn, TransactionTypeTransferIn, TransactionTypeReturn, TransactionTypeAdjust:
 		return 1
 	case TransactionTypeOut, TransactionTypeSale, TransactionTypeTransferOut,
 		TransactionTypeDamage, TransactionTypeExpired, TransactionTypeStolen:
 		return -1
-	case TransactionTypeReserve, TransactionTypeRelease, TransactionTypeAdjust:
+	case TransactionTypeReserve, TransactionTypeRelease:
 		return 0
 	default:
 		return 0
 	}
 }
+
+// IsWriteOff 是否为报损类事务（损坏 / 过期 / 丢失），这类事务必须带原因码
+func IsWriteOff(txType TransactionType) bool {
+	switch txType {
+	case TransactionTypeDamage, TransactionTypeExpired, TransactionTypeStolen:
+		return true
+	default:
+		return false
+	}
+}
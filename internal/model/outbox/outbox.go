@@ -0,0 +1,63 @@
+// internal/model/outbox/outbox.go
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 领域事件类型：<实体>.<动作>
+const (
+	TypeProductCreated     = "product.created"
+	TypeProductUpdated     = "product.updated"
+	TypeProductDeleted     = "product.deleted"
+	TypeOrderStatusChanged = "order.status_changed"
+	TypeStockMoved         = "stock.moved"
+)
+
+// Event 领域事件，和业务数据在同一个事务里写入，事务回滚时一起消失
+type Event struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Type      string          `gorm:"size:50;not null;index" json:"type"`
+	Entity    string          `gorm:"size:50;not null" json:"entity"`
+	EntityID  uint            `gorm:"column:entity_id;not null" json:"entityId"`
+	StoreID   uint            `gorm:"index" json:"storeId,omitempty"` // 0 表示不属于某个门店
+	Version   int64           `json:"version"`
+	Payload   json.RawMessage `gorm:"column:payload" json:"payload"`
+	CreatedAt time.Time       `gorm:"index" json:"createdAt"`
+}
+
+func (Event) TableName() string { return "outbox_events" }
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // 重试次数用完，等人工处理
+)
+
+// Delivery 一个事件对一个下游的投递记录，各下游独立重试
+type Delivery struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EventID       uint       `gorm:"not null;uniqueIndex:idx_outbox_deliveries_event_consumer,priority:1" json:"eventId"`
+	Event         Event      `gorm:"foreignKey:EventID;constraint:OnDelete:CASCADE" json:"event"`
+	Consumer      string     `gorm:"size:255;not null;uniqueIndex:idx_outbox_deliveries_event_consumer,priority:2;index:idx_outbox_deliveries_due,priority:1" json:"consumer"`
+	Status        string     `gorm:"size:20;not null;default:'pending';index:idx_outbox_deliveries_due,priority:2" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_deliveries_due,priority:3" json:"nextAttemptAt"`
+	LastError     string     `gorm:"type:text" json:"lastError,omitempty"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+func (Delivery) TableName() string { return "outbox_deliveries" }
+
+// Consumer 已登记的下游；StartEventID 之前的事件不投递给它
+type Consumer struct {
+	Name         string    `gorm:"primaryKey;size:255" json:"name"`
+	StartEventID uint      `gorm:"not null" json:"startEventId"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (Consumer) TableName() string { return "outbox_consumers" }
@@ -0,0 +1,41 @@
+package rbac
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// APIKey 给网站、扫码脚本等系统间调用使用的密钥，请求以 UserID 对应用户的身份执行
+//   - 明文只在创建时返回一次，库里只存 SHA-256；Prefix 用于在列表里辨认
+//   - 实际权限 = Permissions ∩ 所属用户当前的权限；StoreID 非空时数据范围收窄到该门店
+type APIKey struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	Prefix      string         `gorm:"size:16;not null" json:"prefix"`
+	KeyHash     string         `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UserID      uint           `gorm:"not null;index" json:"userId"`
+	Permissions datatypes.JSON `gorm:"not null" json:"permissions"` // []string
+	StoreID     *uint          `json:"storeId,omitempty"`
+	ExpiresAt   *time.Time     `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time     `json:"lastUsedAt,omitempty"`
+	LastUsedIP  string         `gorm:"size:64" json:"lastUsedIp,omitempty"`
+	RevokedAt   *time.Time     `json:"revokedAt,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
+
+func (APIKey) TableName() string { return "api_keys" }
+
+// Active 未吊销且未过期
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// PermissionNames 解析 Permissions 字段
+func (k *APIKey) PermissionNames() []string {
+	var names []string
+	_ = json.Unmarshal(k.Permissions, &names)
+	return names
+}
@@ -0,0 +1,33 @@
+package rbac
+
+import "time"
+
+// UserTOTP 用户的 TOTP 两步验证设置；EnabledAt 为空表示已生成密钥但尚未用验证码确认
+type UserTOTP struct {
+	UserID       uint       `gorm:"primaryKey" json:"userId"`
+	Secret       string     `gorm:"size:64;not null" json:"-"`
+	EnabledAt    *time.Time `json:"enabledAt,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // 最近一次通过的时间步，同一验证码不能用两次
+	// 第二步连续失败次数，单独计数：密码正确会清零用户的失败次数，不能让它顺带清零这里
+	FailedAttempts int       `gorm:"not null;default:0" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (UserTOTP) TableName() string { return "user_totps" }
+
+// Enabled 已完成绑定
+func (t *UserTOTP) Enabled() bool {
+	return t != nil && t.EnabledAt != nil
+}
+
+// RecoveryCode 两步验证恢复码：只存 SHA-256，每个只能用一次；重新生成时整批替换
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	CodeHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (RecoveryCode) TableName() string { return "recovery_codes" }
@@ -0,0 +1,51 @@
+package rbac
+
+import "time"
+
+// RefreshToken 服务端保存的刷新令牌；明文只在签发时返回给客户端，库里只存 SHA-256
+// 每次刷新都会轮换：旧令牌标记 ReplacedByID，同一登录链路共享 FamilyID
+// 已被轮换的令牌再次出现说明被盗用，整条链路随之作废
+type RefreshToken struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"userId"`
+	FamilyID        string     `gorm:"size:64;not null;index" json:"familyId"`
+	TokenHash       string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	AccessJTI       string     `gorm:"size:64;not null" json:"-"` // 同时签发的访问令牌，注销时一并吊销
+	AccessExpiresAt time.Time  `gorm:"not null" json:"-"`
+	UserAgent       string     `gorm:"size:255" json:"userAgent"`
+	IP              string     `gorm:"size:64" json:"ip"`
+	ExpiresAt       time.Time  `gorm:"not null;index" json:"expiresAt"`
+	ReplacedByID    *uint      `json:"replacedById,omitempty"`
+	RevokedAt       *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+func (RefreshToken) TableName() string { return "refresh_tokens" }
+
+// Active 未轮换、未吊销且未过期
+func (t *RefreshToken) Active(now time.Time) bool {
+	return t.ReplacedByID == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// RevokedToken 吊销列表：在有效期内被注销的访问令牌（按 jti），过期后可清理
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64" json:"jti"`
+	UserID    uint      `gorm:"not null;index" json:"userId"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (RevokedToken) TableName() string { return "revoked_tokens" }
+
+// PasswordResetToken 找回密码令牌：只存 SHA-256，一次性、有有效期
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	IP        string     `gorm:"size:64" json:"ip"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (PasswordResetToken) TableName() string { return "password_reset_tokens" }
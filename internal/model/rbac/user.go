@@ -22,4 +22,12 @@ type User struct {
 	DirectPermissions []Permission   `gorm:"many2many:user_permissions;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"direct_permissions,omitempty"`
 	Permissions       []Permission   `gorm:"-" json:"permissions,omitempty"`
 	AvatarURL         string         `gorm:"size:255;not null" json:"avatar_url"`
+	FailedLogins      int            `gorm:"not null;default:0" json:"-"`   // 连续登录失败次数，成功或锁定后清零
+	LockedUntil       *time.Time     `json:"locked_until,omitempty"`        // 锁定截止时间，之前拒绝登录
+	PasswordChangedAt *time.Time     `json:"password_changed_at,omitempty"` // 最近一次设置密码的时间
+}
+
+// IsLocked 账号是否处于登录锁定期
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
 }
@@ -0,0 +1,26 @@
+// internal/model/realtime/event.go
+package realtime
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event 推送给 websocket 订阅者的事件，同时写入有界的事件日志
+// Seq 全局递增，客户端记下最后收到的 seq，断线重连后从它之后补发
+type Event struct {
+	Seq       uint64          `gorm:"primaryKey;autoIncrement;index:idx_realtime_events_topic_seq,priority:2" json:"seq"`
+	Event     string          `gorm:"size:50;not null" json:"event"`                                                // 如 orderCreated
+	Topic     string          `gorm:"size:50;not null;index:idx_realtime_events_topic_seq,priority:1" json:"topic"` // 如 orders
+	Entity    string          `gorm:"size:50;not null" json:"entity"`                                               // 如 order
+	EntityID  uint            `gorm:"column:entity_id" json:"id"`
+	Version   int64           `json:"version"`                        // 实体版本，只用于同一实体新旧比较
+	StoreID   uint            `gorm:"index" json:"storeId,omitempty"` // 0 表示不属于某个门店
+	Payload   json.RawMessage `gorm:"column:payload" json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// TableName 显式指定表名
+func (Event) TableName() string {
+	return "realtime_events"
+}
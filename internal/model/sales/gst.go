@@ -0,0 +1,16 @@
+package sales
+
+import "math"
+
+// GSTRate 澳洲商品及服务税税率
+const GSTRate = 0.10
+
+// RoundMoney 金额按分四舍五入
+func RoundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// GSTOf 计算不含税金额对应的 GST
+func GSTOf(net float64) float64 {
+	return RoundMoney(net * GSTRate)
+}
@@ -16,6 +16,7 @@ type Invoice struct {
 	InvoiceDate        string `json:"invoiceDate"`
 	InvoiceType        string `json:"invoiceType"`
 	IsQuote            bool   // 在模板里决定列头是 Unit Price 还是 Location
+	ShowPrices         bool   // 税务发票 / 贷项通知单不是报价，但同样显示价格列和合计
 	BillingAddress     string `json:"billingAddress"`
 	DeliveryAddress    string `json:"deliveryAddress"`
 	CustomerCompany    string `json:"customerCompany"`
@@ -30,10 +31,31 @@ type Invoice struct {
 	AccountNumber      string `json:"accountNumber"`
 	TermsAndConditions string `json:"termsAndConditions"`
 
-	// 以下字段只有报价（SALES QUOTE）会用到
+	// 以下字段报价（SALES QUOTE）和税务发票会用到
 	SubtotalAmount float64 `json:"subtotalAmount,omitempty"`
 	GSTAmount      float64 `json:"gstAmount,omitempty"`
 	TotalAmount    float64 `json:"totalAmount,omitempty"`
+
+	// 以下字段只有税务发票会用到
+	ShowBalance    bool    `json:"showBalance,omitempty"`
+	CreditedAmount float64 `json:"creditedAmount,omitempty"`
+	AmountPaid     float64 `json:"amountPaid,omitempty"`
+	BalanceDue     float64 `json:"balanceDue,omitempty"`
+	DueDate        string  `json:"dueDate,omitempty"`
+}
+
+// PriceColumns 是否显示单价 / 折扣 / 小计列
+func (inv Invoice) PriceColumns() bool {
+	return inv.IsQuote || inv.ShowPrices
+}
+
+// TotalQuantity 所有行数量合计，拣货单底部显示
+func (inv Invoice) TotalQuantity() int {
+	n := 0
+	for _, it := range inv.Items {
+		n += it.Quantity
+	}
+	return n
 }
 
 // Item 对应 items 数组里的每一行
@@ -46,7 +68,7 @@ type Item struct {
 	Quantity          int    `json:"quantity"`
 	Location          string `json:"location,omitempty"`
 
-	// 以下报价专用
+	// 以下报价 / 发票专用
 	UnitPrice float64 `json:"unitPrice,omitempty"`
 	Discount  float64 `json:"discount,omitempty"`
 	Subtotal  float64 `json:"subtotal,omitempty"`
@@ -0,0 +1,54 @@
+package sales
+
+// 订单状态，对应数据库枚举 order_status_enum
+const (
+	OrderStatusDraft                 = "draft"
+	OrderStatusOrdered               = "ordered"
+	OrderStatusDepositReceived       = "deposit_received"
+	OrderStatusFinalPaymentReceived  = "final_payment_received"
+	OrderStatusPreDeliveryInspection = "pre_delivery_inspection"
+	OrderStatusShipped               = "shipped"
+	OrderStatusDelivered             = "delivered"
+	OrderStatusClosed                = "order_closed"
+	OrderStatusCancelled             = "cancelled"
+)
+
+// orderTransitions 订单状态机：key 为当前状态，value 为允许进入的下一个状态
+// ordered 可以退回 draft 修改明细；发货之后不能再取消；cancelled / order_closed 为终态
+var orderTransitions = map[string][]string{
+	OrderStatusDraft:                 {OrderStatusOrdered, OrderStatusCancelled},
+	OrderStatusOrdered:               {OrderStatusDraft, OrderStatusDepositReceived, OrderStatusFinalPaymentReceived, OrderStatusCancelled},
+	OrderStatusDepositReceived:       {OrderStatusFinalPaymentReceived, OrderStatusCancelled},
+	OrderStatusFinalPaymentReceived:  {OrderStatusPreDeliveryInspection, OrderStatusCancelled},
+	OrderStatusPreDeliveryInspection: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:               {OrderStatusDelivered},
+	OrderStatusDelivered:             {OrderStatusClosed},
+	OrderStatusClosed:                nil,
+	OrderStatusCancelled:             nil,
+}
+
+// IsOrderStatus 检查是否为 order_status_enum 中的取值
+func IsOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// NextOrderStatuses 返回从 from 出发允许进入的状态
+func NextOrderStatuses(from string) []string {
+	return orderTransitions[from]
+}
+
+// CanTransitionOrder 判断订单能否从 from 变为 to
+func CanTransitionOrder(from, to string) bool {
+	for _, s := range orderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPaymentStatus 定金 / 尾款状态只能由登记收款推进，不能手工修改
+func IsPaymentStatus(status string) bool {
+	return status == OrderStatusDepositReceived || status == OrderStatusFinalPaymentReceived
+}
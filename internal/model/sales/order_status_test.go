@@ -0,0 +1,40 @@
+package sales
+
+import "testing"
+
+func TestCanTransitionOrder(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{OrderStatusDraft, OrderStatusOrdered, true},
+		{OrderStatusOrdered, OrderStatusDraft, true},
+		{OrderStatusOrdered, OrderStatusDepositReceived, true},
+		{OrderStatusPreDeliveryInspection, OrderStatusShipped, true},
+		{OrderStatusShipped, OrderStatusDelivered, true},
+		{OrderStatusDelivered, OrderStatusClosed, true},
+
+		{OrderStatusDraft, OrderStatusDelivered, false},
+		{OrderStatusDraft, OrderStatusShipped, false},
+		{OrderStatusOrdered, OrderStatusShipped, false},
+		{OrderStatusShipped, OrderStatusCancelled, false},
+		{OrderStatusCancelled, OrderStatusDraft, false},
+		{OrderStatusClosed, OrderStatusDelivered, false},
+		{OrderStatusDraft, "unknown", false},
+	}
+	for _, c := range cases {
+		if got := CanTransitionOrder(c.from, c.to); got != c.want {
+			t.Errorf("CanTransitionOrder(%s, %s) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestEveryTargetIsAKnownStatus(t *testing.T) {
+	for from, next := range orderTransitions {
+		for _, to := range next {
+			if !IsOrderStatus(to) {
+				t.Errorf("%s -> %s: target is not a known status", from, to)
+			}
+		}
+	}
+}
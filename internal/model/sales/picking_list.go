@@ -5,10 +5,21 @@ import (
 	"time"
 )
 
+// 拣货单状态
+const (
+	PickingStatusDraft     = "draft"     // 已分配仓库 / 库位，等待拣货
+	PickingStatusPicking   = "picking"   // 部分行已确认
+	PickingStatusPicked    = "picked"    // 全部行已确认
+	PickingStatusBackorder = "backorder" // 缺货待补，库存到货后重新分配
+	PickingStatusCancelled = "cancelled"
+)
+
 // PickingList 对应数据库表 picking_lists
+// 明细按可用库存分配到具体仓库和库位；拣货短缺的数量会生成一张 ParentID 指向原单的缺货单
 type PickingList struct {
 	ID              uint              `gorm:"primaryKey" json:"id"`
 	OrderID         uint              `gorm:"not null;index" json:"orderId"`
+	ParentID        *uint             `gorm:"index" json:"parentId,omitempty"` // 缺货单指向产生它的拣货单
 	PickingNumber   string            `gorm:"size:50;unique;not null" json:"pickingNumber"`
 	DeliveryAddress string            `gorm:"size:255;not null" json:"deliveryAddress"`
 	Status          string            `gorm:"size:20;default:'draft'" json:"status"`
@@ -23,3 +34,12 @@ type PickingList struct {
 }
 
 func (PickingList) TableName() string { return "picking_lists" }
+
+// IsOpen 草稿、拣货中和缺货单都算未完结
+func (p *PickingList) IsOpen() bool {
+	switch p.Status {
+	case PickingStatusDraft, PickingStatusPicking, PickingStatusBackorder:
+		return true
+	}
+	return false
+}
@@ -6,15 +6,31 @@ import (
 )
 
 // PickingListItem 对应数据库表 picking_list_items
+// 一条订单明细按仓库拆成多行；每行对应一条库存预留，确认拣货时预留转为销售出库
 type PickingListItem struct {
 	ID            uint `gorm:"primaryKey" json:"id"`
 	PickingListID uint `gorm:"not null;index" json:"pickingListId"`
+	OrderItemID   uint `gorm:"index" json:"orderItemId"`
 	ProductID     uint `gorm:"not null" json:"productId"`
 	// 下面这一行：
-	Product   catalog.Product `gorm:"foreignKey:ProductID;references:ID" json:"product"`
-	Quantity  int             `gorm:"not null" json:"quantity"`
-	Location  string          `gorm:"size:100" json:"location"`
-	CreatedAt time.Time       `json:"createdAt"`
+	Product       catalog.Product    `gorm:"foreignKey:ProductID;references:ID" json:"product"`
+	WarehouseID   *uint              `json:"warehouseId,omitempty"` // 缺货单未分配时为空
+	Warehouse     *catalog.Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	ReservationID *uint              `json:"reservationId,omitempty"`
+	Quantity      int                `gorm:"not null" json:"quantity"`
+	Location      string             `gorm:"size:100" json:"location"` // 库位
+	PickedQty     *int               `json:"pickedQty,omitempty"`      // nil 表示尚未确认
+	PickedBy      string             `gorm:"size:100" json:"pickedBy,omitempty"`
+	PickedAt      *time.Time         `json:"pickedAt,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt"`
 }
 
 func (PickingListItem) TableName() string { return "picking_list_items" }
+
+// ShortQty 确认后的短拣数量
+func (it *PickingListItem) ShortQty() int {
+	if it.PickedQty == nil {
+		return 0
+	}
+	return it.Quantity - *it.PickedQty
+}
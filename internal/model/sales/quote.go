@@ -28,9 +28,17 @@ type Quote struct {
 	Remarks       string           `gorm:"type:text" json:"remarks"`
 	WarrantyNotes string           `gorm:"type:text" json:"warrantyNotes"`
 	Status        string           `gorm:"type:approval_status_enum;default:'pending'" json:"status"`
-	CreatedAt     time.Time        `json:"createdAt"`
-	UpdatedAt     time.Time        `json:"updatedAt"`
-	Items         []QuoteItem      `gorm:"foreignKey:QuoteID" json:"items"`
+	// 版本：同一份报价的各个版本共用 BaseNumber，QuoteNumber = BaseNumber-Revision
+	BaseNumber     string      `gorm:"size:50;index" json:"baseNumber"`
+	Revision       string      `gorm:"size:5;default:'A'" json:"revision"`
+	SupersededByID *uint       `json:"supersededById,omitempty"` // 被新版本取代后指向新版本，非空即只读
+	CreatedBy      uint        `json:"createdBy"`
+	ReviewedBy     *uint       `json:"reviewedBy,omitempty"`
+	ReviewedAt     *time.Time  `json:"reviewedAt,omitempty"`
+	ReviewNote     string      `gorm:"type:text" json:"reviewNote"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+	Items          []QuoteItem `gorm:"foreignKey:QuoteID" json:"items"`
 }
 
 func (Quote) TableName() string { return "quotes" }
@@ -0,0 +1,32 @@
+package sales
+
+// 报价状态，对应数据库枚举 approval_status_enum
+const (
+	QuoteStatusPending  = "pending"
+	QuoteStatusApproved = "approved"
+	QuoteStatusRejected = "rejected"
+)
+
+// FirstQuoteRevision 新报价的版本号
+const FirstQuoteRevision = "A"
+
+// NextQuoteRevision 返回下一个版本号：A → B … Z → AA → AB …
+func NextQuoteRevision(rev string) string {
+	if rev == "" {
+		return FirstQuoteRevision
+	}
+	b := []byte(rev)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 'Z' {
+			b[i]++
+			return string(b)
+		}
+		b[i] = 'A'
+	}
+	return "A" + string(b)
+}
+
+// IsCurrent 是否为最新版本；旧版本只读，不能审批、修订或转订单
+func (q *Quote) IsCurrent() bool {
+	return q.SupersededByID == nil
+}
@@ -0,0 +1,20 @@
+package sales
+
+import "testing"
+
+func TestNextQuoteRevision(t *testing.T) {
+	cases := map[string]string{
+		"":   "A",
+		"A":  "B",
+		"Y":  "Z",
+		"Z":  "AA",
+		"AA": "AB",
+		"AZ": "BA",
+		"ZZ": "AAA",
+	}
+	for in, want := range cases {
+		if got := NextQuoteRevision(in); got != want {
+			t.Errorf("NextQuoteRevision(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
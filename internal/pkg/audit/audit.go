@@ -4,8 +4,8 @@ import (
 	"context"
 	"djj-inventory-system/internal/model/audit"
 	"djj-inventory-system/internal/model/common"
+	"djj-inventory-system/internal/pkg/auth"
 	"encoding/json"
-	"errors"
 
 	"gorm.io/gorm"
 )
@@ -39,23 +39,22 @@ func (a *GormAuditor) Record(ctx context.Context, refType audit.AuditedTableEnum
 		raw = b
 	}
 
-	// 从 context 拿当前用户
-	uidVal := ctx.Value(common.ContextUserIDKey)
-	userID, ok := uidVal.(uint)
-	if !ok {
-		return errors.New("audit: missing user ID in context")
+	// 从 context 拿当前调用者；登录前、后台任务等没有调用者的记为 0（系统）
+	var userID, storeID uint
+	if p, ok := auth.FromContext(ctx); ok {
+		userID, storeID = p.UserID, p.StoreID
 	}
 
 	hist := audit.AuditedHistory{
 		TableName: refType,
 		RecordID:  int(refID),
-		StoreID:   0, // 如果有门店，可以改成从 ctx 或 payload 里解析
+		StoreID:   int(storeID),
 		ChangedBy: int(userID),
 		Operation: op,
 		Payload:   raw,
 	}
 
-	return a.db.WithContext(ctx).Create(&hist).Error
+	return Append(a.db.WithContext(ctx), []audit.AuditedHistory{hist})
 }
 
 // MockRecorder 是一个用于测试的 mock audit recorder
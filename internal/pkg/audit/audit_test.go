@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	model "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecordReadsPrincipal(t *testing.T) {
+	db := testdb.Open(t, &model.AuditedHistory{})
+	rec := NewGormAuditor(db)
+	p := &auth.Principal{UserID: 7, StoreID: 3}
+
+	// handler 把 *gin.Context 直接传给服务：靠 ContextWithFallback 读到 request context 里的调用者
+	gin.SetMode(gin.TestMode)
+	c, engine := gin.CreateTestContext(httptest.NewRecorder())
+	engine.ContextWithFallback = true
+	c.Request = httptest.NewRequest("POST", "/", nil)
+	c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+
+	for _, ctx := range []context.Context{c.Request.Context(), c} {
+		if err := rec.Record(ctx, model.AuditedTableUsers, 1, "update", map[string]int{"a": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 没有调用者（后台任务）记为系统
+	if err := rec.Record(context.Background(), model.AuditedTableUsers, 1, "expire", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []model.AuditedHistory
+	if err := db.Order("history_id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	for i, want := range []int{7, 7, 0} {
+		if rows[i].ChangedBy != want {
+			t.Errorf("row %d: changed_by = %d, want %d", i, rows[i].ChangedBy, want)
+		}
+	}
+	if rows[0].StoreID != 3 {
+		t.Errorf("store_id = %d, want 3", rows[0].StoreID)
+	}
+}
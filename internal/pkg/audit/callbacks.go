@@ -0,0 +1,373 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/pkg/auth"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 行变更操作类型，和数据库触发器 fn_audit_generic 的 TG_OP 一致
+const (
+	OpInsert = "INSERT"
+	OpUpdate = "UPDATE"
+	OpDelete = "DELETE"
+)
+
+// oldRowsKey 更新 / 删除前抓取的旧数据，挂在本次语句上
+const oldRowsKey = "audit:old_rows"
+
+// skipKey 会话上带这个标记时回调不记录，见 Skip
+const skipKey = "audit:skip"
+
+// Skip 返回不做行级审计的会话，只用于使用时间这类高频、无业务含义的字段更新
+func Skip(db *gorm.DB) *gorm.DB {
+	return db.Set(skipKey, true)
+}
+
+// sensitiveColumns 只记录是否修改，不落明文
+var sensitiveColumns = map[string]bool{
+	"password_hash": true,
+	"key_hash":      true,
+	"token_hash":    true,
+	"code_hash":     true,
+	"secret":        true,
+}
+
+var redacted = json.RawMessage(`"***"`)
+
+// SensitiveColumn 该列在审计记录里只打码不落明文，按历史记录恢复时也要跳过
+func SensitiveColumn(col string) bool {
+	return sensitiveColumns[col]
+}
+
+// rowImage 一行数据，列名 → JSON 值
+type rowImage map[string]json.RawMessage
+
+// auditedRow 一条记录的主键和整行数据
+type auditedRow struct {
+	id    interface{}
+	image rowImage
+}
+
+// FieldChange 某一列变更前后的值
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// rowAuditor GORM 回调：新增、更新、删除受审计表的记录时，把变更前后的整行和字段差异写入 audited_history
+// 写审计和业务写入在同一个事务里，审计写失败整个操作回滚
+type rowAuditor struct {
+	tables map[string]audit.AuditedTableEnum
+}
+
+// RegisterCallbacks 为 tables 注册行级自动审计，不传时使用 audit.AuditedTables
+// 操作人和门店取自 ctx 里的调用者（db.WithContext），记录本身带 store_id 列时门店以记录为准
+func RegisterCallbacks(db *gorm.DB, tables ...audit.AuditedTableEnum) error {
+	if len(tables) == 0 {
+		tables = audit.AuditedTables
+	}
+	a := &rowAuditor{tables: make(map[string]audit.AuditedTableEnum, len(tables))}
+	for _, t := range tables {
+		a.tables[string(t)] = t
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:after_create").Before("gorm:commit_or_rollback_transaction").
+		Register("audit:after_create", a.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:setup_reflect_value").Before("gorm:update").
+		Register("audit:before_update", a.loadOldRows); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:after_update").Before("gorm:commit_or_rollback_transaction").
+		Register("audit:after_update", a.after(OpUpdate)); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:begin_transaction").Before("gorm:delete").
+		Register("audit:before_delete", a.loadOldRows); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:after_delete").Before("gorm:commit_or_rollback_transaction").
+		Register("audit:after_delete", a.after(OpDelete))
+}
+
+// audited 本次语句是否需要审计
+func (a *rowAuditor) audited(db *gorm.DB) (audit.AuditedTableEnum, bool) {
+	st := db.Statement
+	if db.Error != nil || st.Schema == nil || len(st.Schema.PrimaryFields) != 1 {
+		return "", false
+	}
+	if skip, _ := db.Get(skipKey); skip == true {
+		return "", false
+	}
+	t, ok := a.tables[st.Table]
+	return t, ok
+}
+
+func (a *rowAuditor) afterCreate(db *gorm.DB) {
+	table, ok := a.audited(db)
+	if !ok {
+		return
+	}
+	var rows []auditedRow
+	eachStruct(db.Statement.ReflectValue, func(v reflect.Value) {
+		rows = append(rows, a.row(db, v))
+	})
+	a.write(db, table, OpInsert, nil, rows)
+}
+
+// loadOldRows 执行更新 / 删除前按相同条件查出旧数据
+func (a *rowAuditor) loadOldRows(db *gorm.DB) {
+	if _, ok := a.audited(db); !ok {
+		return
+	}
+	exprs := conditions(db.Statement)
+	if len(exprs) == 0 {
+		return // 没有条件的全表更新 GORM 会拒绝执行
+	}
+	rows, err := a.find(db, exprs, db.Statement.Unscoped)
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: load old rows: %w", err))
+		return
+	}
+	db.InstanceSet(oldRowsKey, rows)
+}
+
+// after 更新 / 删除后按主键重新查出新数据（物理删除后查不到，软删除能查到 deleted_at）
+func (a *rowAuditor) after(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		table, ok := a.audited(db)
+		if !ok {
+			return
+		}
+		v, ok := db.InstanceGet(oldRowsKey)
+		if !ok {
+			return
+		}
+		old := v.([]auditedRow)
+		if len(old) == 0 {
+			return
+		}
+		ids := make([]interface{}, len(old))
+		for i, r := range old {
+			ids[i] = r.id
+		}
+		pk := db.Statement.Schema.PrimaryFields[0].DBName
+		rows, err := a.find(db, []clause.Expression{clause.IN{Column: clause.Column{Name: pk}, Values: ids}}, true)
+		if err != nil {
+			db.AddError(fmt.Errorf("audit: load new rows: %w", err))
+			return
+		}
+		a.write(db, table, op, old, rows)
+	}
+}
+
+// find 在同一连接（事务）里查出符合条件的整行；unscoped 时包括已软删除的
+func (a *rowAuditor) find(db *gorm.DB, exprs []clause.Expression, unscoped bool) ([]auditedRow, error) {
+	st := db.Statement
+	q := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(st.Table).Clauses(clause.Where{Exprs: exprs})
+	if unscoped {
+		q = q.Unscoped()
+	}
+	dest := reflect.New(reflect.SliceOf(st.Schema.ModelType))
+	if err := q.Find(dest.Interface()).Error; err != nil {
+		return nil, err
+	}
+	var rows []auditedRow
+	eachStruct(dest.Elem(), func(v reflect.Value) {
+		rows = append(rows, a.row(db, v))
+	})
+	return rows, nil
+}
+
+// row 取出模型的全部数据库列
+func (a *rowAuditor) row(db *gorm.DB, v reflect.Value) auditedRow {
+	st := db.Statement
+	r := auditedRow{image: rowImage{}}
+	r.id, _ = st.Schema.PrimaryFields[0].ValueOf(st.Context, v)
+	for _, f := range st.Schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		val, _ := f.ValueOf(st.Context, v)
+		b, err := json.Marshal(val)
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprint(val))
+		}
+		r.image[f.DBName] = b
+	}
+	return r
+}
+
+// write 按主键配对新旧数据，算出字段差异后批量写入 audited_history；更新前后没有变化的行不记录
+func (a *rowAuditor) write(db *gorm.DB, table audit.AuditedTableEnum, op string, old, new []auditedRow) {
+	var userID, storeID uint
+	if p, ok := auth.FromContext(db.Statement.Context); ok {
+		userID, storeID = p.UserID, p.StoreID
+	}
+
+	newByID := make(map[string]rowImage, len(new))
+	for _, r := range new {
+		newByID[fmt.Sprint(r.id)] = r.image
+	}
+	pairs := old
+	if op == OpInsert {
+		pairs = new
+	}
+
+	var hist []audit.AuditedHistory
+	for _, r := range pairs {
+		var before, after rowImage
+		if op == OpInsert {
+			after = r.image
+		} else {
+			before, after = r.image, newByID[fmt.Sprint(r.id)]
+		}
+		diff := Diff(before, after)
+		if len(diff) == 0 {
+			continue
+		}
+		h := audit.AuditedHistory{
+			TableName: table,
+			RecordID:  toInt(r.id),
+			StoreID:   int(storeID),
+			ChangedBy: int(userID),
+			Operation: op,
+		}
+		if sid := rowStoreID(before, after); sid != 0 {
+			h.StoreID = sid
+		}
+		var err error
+		if h.Payload, err = json.Marshal(diff); err != nil {
+			db.AddError(err)
+			return
+		}
+		if h.OldData, err = marshalImage(before); err != nil {
+			db.AddError(err)
+			return
+		}
+		if h.NewData, err = marshalImage(after); err != nil {
+			db.AddError(err)
+			return
+		}
+		hist = append(hist, h)
+	}
+	if len(hist) == 0 {
+		return
+	}
+	if err := Append(db.Session(&gorm.Session{NewDB: true, SkipHooks: true}), hist); err != nil {
+		db.AddError(fmt.Errorf("audit: write history: %w", err))
+	}
+}
+
+// Diff 逐列比较两行数据，before 为空表示新增，after 为空表示删除；敏感列只标记修改不带值
+func Diff(before, after map[string]json.RawMessage) map[string]FieldChange {
+	out := map[string]FieldChange{}
+	for col, o := range before {
+		n, ok := after[col]
+		if !ok && after != nil {
+			continue
+		}
+		if ok && bytes.Equal(o, n) {
+			continue
+		}
+		out[col] = FieldChange{Old: o, New: n}
+	}
+	for col, n := range after {
+		if _, ok := before[col]; !ok {
+			out[col] = FieldChange{New: n}
+		}
+	}
+	for col, c := range out {
+		if sensitiveColumns[col] {
+			if c.Old != nil {
+				c.Old = redacted
+			}
+			if c.New != nil {
+				c.New = redacted
+			}
+			out[col] = c
+		}
+	}
+	return out
+}
+
+// marshalImage 敏感列打码后序列化，空行返回 nil
+func marshalImage(img rowImage) (json.RawMessage, error) {
+	if img == nil {
+		return nil, nil
+	}
+	out := make(rowImage, len(img))
+	for col, v := range img {
+		if sensitiveColumns[col] {
+			v = redacted
+		}
+		out[col] = v
+	}
+	return json.Marshal(out)
+}
+
+// rowStoreID 记录本身的 store_id 列
+func rowStoreID(images ...rowImage) int {
+	for _, img := range images {
+		var sid int
+		if raw, ok := img["store_id"]; ok && json.Unmarshal(raw, &sid) == nil && sid != 0 {
+			return sid
+		}
+	}
+	return 0
+}
+
+// conditions 复制语句上的 WHERE 条件，再加上模型自带的主键（db.Model(&x).Updates / db.Delete(&x)）
+func conditions(st *gorm.Statement) []clause.Expression {
+	var exprs []clause.Expression
+	if c, ok := st.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok {
+			exprs = append(exprs, w.Exprs...)
+		}
+	}
+	if st.ReflectValue.IsValid() {
+		_, values := schema.GetIdentityFieldValuesMap(st.Context, st.ReflectValue, st.Schema.PrimaryFields)
+		if len(values) > 0 {
+			column, vs := schema.ToQueryValues(st.Table, st.Schema.PrimaryFieldDBNames, values)
+			exprs = append(exprs, clause.IN{Column: column, Values: vs})
+		}
+	}
+	return exprs
+}
+
+// eachStruct 遍历单个结构体或结构体切片
+func eachStruct(v reflect.Value, fn func(reflect.Value)) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fn(v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			eachStruct(v.Index(i), fn)
+		}
+	}
+}
+
+func toInt(v interface{}) int {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint())
+	}
+	return 0
+}
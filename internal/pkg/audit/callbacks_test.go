@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	model "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/testdb"
+
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID           uint
+	StoreID      uint
+	Name         string
+	Qty          int
+	PasswordHash string
+	DeletedAt    gorm.DeletedAt
+}
+
+func TestRowCallbacks(t *testing.T) {
+	db := testdb.Open(t, &model.AuditedHistory{}, &widget{})
+	if err := RegisterCallbacks(db, "widgets"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 7, StoreID: 1})
+	tx := db.WithContext(ctx)
+
+	w := widget{StoreID: 5, Name: "bolt", Qty: 10, PasswordHash: "h1"}
+	if err := tx.Create(&w).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Model(&w).Updates(map[string]interface{}{"qty": 8, "password_hash": "h2"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 跳过审计的会话：不记录
+	if err := Skip(tx).Model(&w).UpdateColumn("name", "nut").Error; err != nil {
+		t.Fatal(err)
+	}
+	// 条件更新、值没变：不记录
+	if err := tx.Model(&widget{}).Where("name = ?", "nut").Update("qty", 8).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(&widget{}, w.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []model.AuditedHistory
+	if err := db.Order("history_id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	for i, op := range []string{OpInsert, OpUpdate, OpDelete} {
+		r := rows[i]
+		if r.Operation != op || r.RecordID != int(w.ID) || r.ChangedBy != 7 || r.StoreID != 5 {
+			t.Errorf("row %d = %s #%d by %d store %d", i, r.Operation, r.RecordID, r.ChangedBy, r.StoreID)
+		}
+	}
+
+	var diff map[string]FieldChange
+	if err := json.Unmarshal(rows[1].Payload, &diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 2 || string(diff["qty"].Old) != "10" || string(diff["qty"].New) != "8" {
+		t.Errorf("update diff = %s", rows[1].Payload)
+	}
+	if string(diff["password_hash"].New) != `"***"` {
+		t.Errorf("password_hash not redacted: %s", rows[1].Payload)
+	}
+	var before map[string]json.RawMessage
+	if err := json.Unmarshal(rows[1].OldData, &before); err != nil {
+		t.Fatal(err)
+	}
+	if string(before["name"]) != `"bolt"` || string(before["password_hash"]) != `"***"` {
+		t.Errorf("old image = %s", rows[1].OldData)
+	}
+	if rows[0].OldData != nil {
+		t.Errorf("insert old image = %s, want empty", rows[0].OldData)
+	}
+
+	// 软删除：新数据里能看到 deleted_at
+	var deleted map[string]FieldChange
+	if err := json.Unmarshal(rows[2].Payload, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := deleted["deleted_at"]; !ok || len(deleted) != 1 {
+		t.Errorf("delete diff = %s", rows[2].Payload)
+	}
+}
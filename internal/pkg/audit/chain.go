@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"djj-inventory-system/internal/model/audit"
+
+	"gorm.io/gorm"
+)
+
+// chainLockKey 上链时持有的 PostgreSQL 事务级咨询锁；读链尾、算哈希、写回在锁内完成，保证链不分叉
+// 只在 Seal 自己的短事务里持有，业务事务不碰这把锁，也就不会和业务行锁交叉等待
+const chainLockKey = 0x61756474
+
+// sealBatch Seal 每个事务最多上链的记录数
+const sealBatch = 500
+
+// Hash 审计记录的哈希：各字段依次写入 SHA-256，每个字段后跟一个 0 字节，结果为小写十六进制
+// 字段顺序：prev_hash、table_name、record_id、store_id、changed_by、operation、
+// changed_at（UTC，RFC3339Nano）、payload、old_data、new_data（JSON 原始字节，空值写 0 字节）
+func Hash(h *audit.AuditedHistory) string {
+	s := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(h.PrevHash),
+		[]byte(h.TableName),
+		[]byte(strconv.Itoa(h.RecordID)),
+		[]byte(strconv.Itoa(h.StoreID)),
+		[]byte(strconv.Itoa(h.ChangedBy)),
+		[]byte(h.Operation),
+		[]byte(h.ChangedAt.UTC().Format(time.RFC3339Nano)),
+		h.Payload,
+		h.OldData,
+		h.NewData,
+	} {
+		s.Write(part)
+		s.Write([]byte{0})
+	}
+	return hex.EncodeToString(s.Sum(nil))
+}
+
+// Append 写入 audited_history，所有审计写入都必须经过这里
+// db 可以是业务事务，记录和业务写入一起提交或回滚；写入时不加锁，记录先处于待上链状态，由 Seal 接到哈希链末尾
+func Append(db *gorm.DB, rows []audit.AuditedHistory) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	// 从 GORM 回调里调用时 db 带着正在执行的语句（含已拼好的 SQL），先换成干净的语句
+	db = db.Session(&gorm.Session{NewDB: true}).Model(&audit.AuditedHistory{})
+	// PostgreSQL 只保存到微秒，截断后读回来的时间才能算出同样的哈希
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for i := range rows {
+		if rows[i].ChangedAt.IsZero() {
+			rows[i].ChangedAt = now
+		}
+		rows[i].ChangedAt = rows[i].ChangedAt.UTC().Truncate(time.Microsecond)
+		rows[i].ChainSeq, rows[i].PrevHash, rows[i].Hash = nil, "", ""
+	}
+	return db.Create(&rows).Error
+}
+
+// Seal 把已提交的待上链记录按 history_id 顺序接到哈希链末尾，返回本次上链的条数
+// 业务事务提交顺序和 history_id 顺序不一定一致，链上顺序以 chain_seq 为准：晚提交的记录排在后面
+func Seal(db *gorm.DB) (int, error) {
+	total := 0
+	for {
+		n := 0
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := LockChain(tx); err != nil {
+				return err
+			}
+			var pending []audit.AuditedHistory
+			if err := tx.Where("chain_seq IS NULL").Order("history_id").Limit(sealBatch).Find(&pending).Error; err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+			seq, prev, err := chainTail(tx)
+			if err != nil {
+				return err
+			}
+			for i := range pending {
+				seq++
+				if err := link(tx, &pending[i], seq, prev); err != nil {
+					return err
+				}
+				prev = pending[i].Hash
+			}
+			n = len(pending)
+			return nil
+		})
+		total += n
+		if err != nil || n < sealBatch {
+			return total, err
+		}
+	}
+}
+
+// link 给记录填上链位置和哈希并写回
+func link(tx *gorm.DB, h *audit.AuditedHistory, seq int64, prev string) error {
+	h.ChainSeq = &seq
+	h.PrevHash = prev
+	h.Hash = Hash(h)
+	return tx.Model(h).UpdateColumns(map[string]interface{}{"chain_seq": seq, "prev_hash": h.PrevHash, "hash": h.Hash}).Error
+}
+
+// LockChain 在当前事务里锁住审计链（仅 PostgreSQL；SQLite 本身只有一个写者）
+func LockChain(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error
+}
+
+// chainTail 链尾的位置和哈希，链为空时返回 0 和空串
+func chainTail(tx *gorm.DB) (int64, string, error) {
+	var last audit.AuditedHistory
+	err := tx.Where("chain_seq IS NOT NULL").Order("chain_seq DESC").Limit(1).Find(&last).Error
+	if err != nil || last.ChainSeq == nil {
+		return 0, "", err
+	}
+	return *last.ChainSeq, last.Hash, nil
+}
+
+// Rechain 按 history_id 顺序重新排链位置、计算全部哈希，只用于给启用哈希链之前的历史记录补链
+func Rechain(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := LockChain(tx); err != nil {
+			return err
+		}
+		if err := tx.Model(&audit.AuditedHistory{}).Where("chain_seq IS NOT NULL").Update("chain_seq", nil).Error; err != nil {
+			return err
+		}
+		var seq int64
+		prev := ""
+		var batch []audit.AuditedHistory
+		return tx.FindInBatches(&batch, sealBatch, func(b *gorm.DB, _ int) error {
+			for i := range batch {
+				seq++
+				if err := link(tx, &batch[i], seq, prev); err != nil {
+					return err
+				}
+				prev = batch[i].Hash
+			}
+			return nil
+		}).Error
+	})
+}
+
+// ChainReport 哈希链校验结果；OK 为 false 时 BrokenAt 是第一条对不上的记录
+type ChainReport struct {
+	OK            bool   `json:"ok"`
+	Checked       int64  `json:"checked"`
+	LastHistoryID int    `json:"lastHistoryId"`
+	LastHash      string `json:"lastHash"`
+	BrokenAt      int    `json:"brokenAt,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Pending       int64  `json:"pending"` // 已写入但还没上链的记录
+
+	hashes map[int]string // 需要核对检查点的记录哈希
+}
+
+// HashAt 校验过程中记下的某条记录的哈希，供检查点核对
+func (r *ChainReport) HashAt(historyID int) (string, bool) {
+	h, ok := r.hashes[historyID]
+	return h, ok
+}
+
+// VerifyChain 按 chain_seq 顺序逐条重算哈希，遇到第一处断链就停止；还没上链的记录只计数
+// want 里的记录 ID 会在报告里留下哈希（HashAt），用来核对签名检查点
+func VerifyChain(ctx context.Context, db *gorm.DB, want ...int) (*ChainReport, error) {
+	rep := &ChainReport{OK: true, hashes: map[int]string{}}
+	wanted := make(map[int]bool, len(want))
+	for _, id := range want {
+		wanted[id] = true
+	}
+	db = db.WithContext(ctx)
+	prev := ""
+	var seq int64
+	for {
+		var batch []audit.AuditedHistory
+		if err := db.Where("chain_seq > ?", seq).Order("chain_seq").Limit(sealBatch).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			h := &batch[i]
+			switch {
+			case h.PrevHash != prev:
+				rep.Reason = "prev_hash does not match the previous entry (entry removed, inserted or reordered)"
+			case h.Hash != Hash(h):
+				rep.Reason = "content does not match its hash (entry modified)"
+			}
+			if rep.Reason != "" {
+				rep.OK = false
+				rep.BrokenAt = h.HistoryID
+				return rep, nil
+			}
+			if wanted[h.HistoryID] {
+				rep.hashes[h.HistoryID] = h.Hash
+			}
+			prev = h.Hash
+			seq = *h.ChainSeq
+			rep.Checked++
+			rep.LastHistoryID = h.HistoryID
+			rep.LastHash = h.Hash
+		}
+		if len(batch) < sealBatch {
+			break
+		}
+	}
+	err := db.Model(&audit.AuditedHistory{}).Where("chain_seq IS NULL").Count(&rep.Pending).Error
+	return rep, err
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	model "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+func TestHashChain(t *testing.T) {
+	db := testdb.Open(t, &model.AuditedHistory{})
+	ctx := context.Background()
+	rec := NewGormAuditor(db)
+	for i := 1; i <= 5; i++ {
+		if err := rec.Record(ctx, model.AuditedTableProducts, uint(i), "update", map[string]int{"qty": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 写入后先处于待上链状态
+	rep, err := VerifyChain(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.OK || rep.Checked != 0 || rep.Pending != 5 {
+		t.Fatalf("pending chain: %+v", rep)
+	}
+	if n, err := Seal(db); err != nil || n != 5 {
+		t.Fatalf("seal = %d, %v", n, err)
+	}
+	if rep, _ = VerifyChain(ctx, db); !rep.OK || rep.Checked != 5 || rep.Pending != 0 {
+		t.Fatalf("clean chain: %+v", rep)
+	}
+
+	// 事后改内容：在被改的那条断开
+	db.Exec(`UPDATE audited_histories SET payload = ? WHERE history_id = 3`, []byte(`{"qty":99}`))
+	if rep, _ = VerifyChain(ctx, db); rep.OK || rep.BrokenAt != 3 {
+		t.Errorf("modified entry: %+v", rep)
+	}
+
+	// 重新补链后再删掉中间一条：在下一条断开
+	if err := Rechain(db); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`DELETE FROM audited_histories WHERE history_id = 2`)
+	if rep, _ = VerifyChain(ctx, db); rep.OK || rep.BrokenAt != 3 {
+		t.Errorf("removed entry: %+v", rep)
+	}
+}
+
+// 晚提交的记录（history_id 更小）在上链时排到链尾，不影响已上链的部分
+func TestSealLateCommit(t *testing.T) {
+	db := testdb.Open(t, &model.AuditedHistory{})
+	ctx := context.Background()
+	entry := func(id int) model.AuditedHistory {
+		return model.AuditedHistory{HistoryID: id, TableName: model.AuditedTableOrders, RecordID: id, Operation: "update"}
+	}
+	// 2 号记录所在的事务晚于 3 号提交：1、3 先上链，2 后上链
+	if err := Append(db, []model.AuditedHistory{entry(1), entry(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Seal(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(db, []model.AuditedHistory{entry(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Seal(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []model.AuditedHistory
+	db.Order("chain_seq").Find(&rows)
+	var order []int
+	for _, r := range rows {
+		order = append(order, r.HistoryID)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 3 || order[2] != 2 {
+		t.Fatalf("chain order = %v", order)
+	}
+	if rep, _ := VerifyChain(ctx, db); !rep.OK || rep.Checked != 3 {
+		t.Errorf("late commit chain: %+v", rep)
+	}
+}
+
+func TestCheckpointSignature(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	keys, err := ParseSigningKeys("k1:" + seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := &model.Checkpoint{HistoryID: 42, Hash: "abc", Count: 42, CreatedAt: time.Now()}
+	keys.Sign(cp)
+	if cp.KeyID != "k1" {
+		t.Fatalf("key id = %q", cp.KeyID)
+	}
+	if err := keys.Verify(cp); err != nil {
+		t.Fatal(err)
+	}
+	cp.Hash = "abd"
+	if err := keys.Verify(cp); err == nil {
+		t.Error("tampered checkpoint verified")
+	}
+	if _, err := ParseSigningKeys("k1:short"); err == nil {
+		t.Error("short seed accepted")
+	}
+}
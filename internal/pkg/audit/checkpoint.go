@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"djj-inventory-system/internal/model/audit"
+)
+
+// ErrBadSignature 检查点签名不对，或签名密钥不在当前配置里
+var ErrBadSignature = errors.New("invalid checkpoint signature")
+
+// SigningKeys 检查点签名密钥：新检查点用 active 密钥签名，轮换后旧密钥只用于验签
+type SigningKeys struct {
+	active string
+	keys   map[string]ed25519.PrivateKey
+}
+
+// ParseSigningKeys 解析 "kid:base64seed,kid:base64seed" 格式的配置，seed 是 32 字节的 Ed25519 私钥种子
+// 第一个密钥用于签名
+func ParseSigningKeys(spec string) (*SigningKeys, error) {
+	sk := &SigningKeys{keys: make(map[string]ed25519.PrivateKey)}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kid, enc, ok := strings.Cut(part, ":")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:base64seed", part)
+		}
+		seed, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("key %q must be a base64 encoded %d byte seed", kid, ed25519.SeedSize)
+		}
+		if _, dup := sk.keys[kid]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", kid)
+		}
+		sk.keys[kid] = ed25519.NewKeyFromSeed(seed)
+		if sk.active == "" {
+			sk.active = kid
+		}
+	}
+	if len(sk.keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	return sk, nil
+}
+
+// RandomSigningKeys 未配置密钥时（本地开发）临时生成，重启后之前的检查点无法验签
+func RandomSigningKeys() *SigningKeys {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return &SigningKeys{active: "dev", keys: map[string]ed25519.PrivateKey{"dev": priv}}
+}
+
+// Sign 用当前密钥给检查点签名，填好 KeyID 和 Signature
+func (sk *SigningKeys) Sign(c *audit.Checkpoint) {
+	c.KeyID = sk.active
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(sk.keys[sk.active], c.Message()))
+}
+
+// Verify 校验检查点签名
+func (sk *SigningKeys) Verify(c *audit.Checkpoint) error {
+	priv, ok := sk.keys[c.KeyID]
+	if !ok {
+		return fmt.Errorf("%w: unknown key id %q", ErrBadSignature, c.KeyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || !ed25519.Verify(priv.Public().(ed25519.PublicKey), c.Message(), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// PublicKeys kid → base64 公钥，随检查点一起导出给外部审计方
+func (sk *SigningKeys) PublicKeys() map[string]string {
+	out := make(map[string]string, len(sk.keys))
+	for kid, priv := range sk.keys {
+		out[kid] = base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey))
+	}
+	return out
+}
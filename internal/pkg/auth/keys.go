@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrUnknownKey token 头里的 kid 不在当前密钥环里（已下线的旧密钥或伪造）
+var ErrUnknownKey = errors.New("unknown signing key id")
+
+// minSecretLen HS256 密钥至少 32 字节
+const minSecretLen = 32
+
+// KeyRing JWT 签名密钥环：新 token 用 active 密钥签名并在头里写 kid，
+// 校验时按 kid 查找，轮换期间旧密钥签发的 token 仍然有效
+type KeyRing struct {
+	active string
+	keys   map[string][]byte
+}
+
+// ParseKeyRing 解析 "kid:secret,kid:secret" 格式的密钥配置
+// active 为空时使用第一个密钥签名；要下线旧密钥，把它从配置里删掉即可
+func ParseKeyRing(spec, active string) (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[string][]byte)}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(part, ":")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:secret", part)
+		}
+		if len(secret) < minSecretLen {
+			return nil, fmt.Errorf("key %q is shorter than %d bytes", kid, minSecretLen)
+		}
+		if _, dup := kr.keys[kid]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", kid)
+		}
+		kr.keys[kid] = []byte(secret)
+		if kr.active == "" {
+			kr.active = kid
+		}
+	}
+	if len(kr.keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	if active != "" {
+		if _, ok := kr.keys[active]; !ok {
+			return nil, fmt.Errorf("active key %q is not configured", active)
+		}
+		kr.active = active
+	}
+	return kr, nil
+}
+
+// RandomKeyRing 未配置密钥时（本地开发）临时生成一个随机密钥，重启后所有 token 失效
+func RandomKeyRing() *KeyRing {
+	return &KeyRing{active: "dev", keys: map[string][]byte{"dev": randomBytes(minSecretLen)}}
+}
+
+// ActiveKeyID 当前用于签名的 kid
+func (kr *KeyRing) ActiveKeyID() string { return kr.active }
+
+// Sign 用当前密钥签名
+func (kr *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kr.active
+	return token.SignedString(kr.keys[kr.active])
+}
+
+// Parse 校验签名和有效期并返回 claims；没有 kid 的 token 按当前密钥校验
+func (kr *KeyRing) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = kr.active
+		}
+		key, ok := kr.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	oldSecret := strings.Repeat("a", 32)
+	newSecret := strings.Repeat("b", 32)
+	claims := jwt.MapClaims{"sub": 1, "exp": time.Now().Add(time.Minute).Unix()}
+
+	before, err := ParseKeyRing("k1:"+oldSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := before.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换中：新密钥签名，旧 token 仍然有效
+	during, err := ParseKeyRing("k1:"+oldSecret+",k2:"+newSecret, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := during.Parse(oldToken); err != nil {
+		t.Fatalf("old token rejected during rotation: %v", err)
+	}
+	newToken, err := during.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换完成：旧密钥下线，旧 token 失效，新 token 有效
+	after, err := ParseKeyRing("k2:"+newSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := after.Parse(newToken); err != nil {
+		t.Fatalf("new token rejected: %v", err)
+	}
+	if _, err := after.Parse(oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("old token after rotation: want ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestParseKeyRingRejectsWeakConfig(t *testing.T) {
+	for _, spec := range []string{"", "k1:short", "nokid", "k1:" + strings.Repeat("a", 32) + ",k1:" + strings.Repeat("b", 32)} {
+		if _, err := ParseKeyRing(spec, ""); err == nil {
+			t.Errorf("ParseKeyRing(%q): want error", spec)
+		}
+	}
+	if _, err := ParseKeyRing("k1:"+strings.Repeat("a", 32), "k9"); err == nil {
+		t.Error("unknown active kid: want error")
+	}
+}
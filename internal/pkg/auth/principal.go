@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"djj-inventory-system/internal/pkg/scope"
+)
+
+// GinKey gin 上下文里存放 *Principal 的键
+const GinKey = "principal"
+
+// Principal 当前请求的调用者：登录用户，或以所属用户身份执行的 API Key
+// 由认证中间件创建，同时放进 gin 上下文和 request context；权限、门店和数据范围在加载权限后补全
+type Principal struct {
+	UserID      uint        `json:"userId"`
+	Name        string      `json:"name"` // 用户名；API Key 为 "apikey:<名称>"
+	StoreID     uint        `json:"storeId"`
+	Roles       []string    `json:"roles"`
+	Permissions []string    `json:"permissions"`
+	Scope       scope.Scope `json:"scope"`
+	APIKeyID    uint        `json:"apiKeyId,omitempty"` // 非 0 表示通过 API Key 认证
+}
+
+type principalKey struct{}
+
+// WithPrincipal 把调用者和其数据范围放进 context
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return scope.WithContext(context.WithValue(ctx, principalKey{}, p), p.Scope)
+}
+
+// FromContext 取出调用者；后台任务、登录前等没有调用者时 ok 为 false
+func FromContext(ctx context.Context) (*Principal, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserID 调用者的用户 ID，没有调用者时为 0
+func UserID(ctx context.Context) uint {
+	if p, ok := FromContext(ctx); ok {
+		return p.UserID
+	}
+	return 0
+}
+
+// HasPermission 是否拥有 perm
+func (p *Principal) HasPermission(perm string) bool {
+	for _, v := range p.Permissions {
+		if v == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole 是否拥有角色 name
+func (p *Principal) HasRole(name string) bool {
+	for _, r := range p.Roles {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLeader 是否为 admin 或某个 *_leader
+func (p *Principal) IsLeader() bool {
+	for _, r := range p.Roles {
+		if r == "admin" || strings.HasSuffix(r, "_leader") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKey 是否通过 API Key 认证
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != 0
+}
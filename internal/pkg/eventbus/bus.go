@@ -0,0 +1,65 @@
+// internal/pkg/eventbus/bus.go
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"djj-inventory-system/internal/model/realtime"
+)
+
+// Bus 实时事件总线：业务代码只管 Publish，各实例的 websocket hub 通过 Run 收到所有实例发布的事件
+type Bus interface {
+	// Publish 写入事件日志（回填 Seq）并通知所有实例
+	Publish(ctx context.Context, e *realtime.Event) error
+	// Run 把事件按 seq 顺序交给 deliver，直到 ctx 取消；deliver 不能阻塞
+	Run(ctx context.Context, deliver func(*realtime.Event))
+}
+
+// Appender 事件日志的写入端
+type Appender interface {
+	Append(ctx context.Context, e *realtime.Event) error
+}
+
+// Local 单实例总线：事件写入日志后直接交给本进程的 hub；log 为 nil 时只在内存里编号
+type Local struct {
+	log     Appender
+	mu      sync.Mutex // 写日志和投递一起串行，投递顺序和 seq 一致
+	seq     uint64
+	deliver func(*realtime.Event)
+}
+
+func NewLocal(log Appender) *Local {
+	return &Local{log: log}
+}
+
+// Publish 写入日志后同步投递；Run 启动之前发布的事件只写日志
+func (b *Local) Publish(ctx context.Context, e *realtime.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.log != nil {
+		if err := b.log.Append(ctx, e); err != nil {
+			return err
+		}
+	} else {
+		b.seq++
+		e.Seq = b.seq
+		e.CreatedAt = time.Now()
+	}
+	if b.deliver != nil {
+		b.deliver(e)
+	}
+	return nil
+}
+
+// Run 登记投递函数，阻塞到 ctx 取消
+func (b *Local) Run(ctx context.Context, deliver func(*realtime.Event)) {
+	b.mu.Lock()
+	b.deliver = deliver
+	b.mu.Unlock()
+	<-ctx.Done()
+	b.mu.Lock()
+	b.deliver = nil
+	b.mu.Unlock()
+}
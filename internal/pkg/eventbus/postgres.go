@@ -0,0 +1,103 @@
+// internal/pkg/eventbus/postgres.go
+package eventbus
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"djj-inventory-system/internal/model/realtime"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// Channel LISTEN / NOTIFY 频道，通知内容是新事件的 seq
+	Channel = "realtime_events"
+	// publishLockKey 写事件时持有的事务级咨询锁，保证 seq 顺序和提交（通知）顺序一致
+	publishLockKey = 0x72746576
+	// catchUpBatch 每次从事件日志读取的条数
+	catchUpBatch = 500
+	// pollInterval 兜底轮询间隔：通知丢失或监听连接重连期间的事件也能追上
+	pollInterval = 30 * time.Second
+)
+
+// Postgres 多实例总线：事件写入 realtime_events，同一事务里 pg_notify；
+// 各实例 LISTEN 到通知后按 seq 从事件日志读取新事件，通知只起唤醒作用，丢了也不会漏事件
+type Postgres struct {
+	db     *gorm.DB
+	dsn    string // 监听用的独立连接
+	logger *zap.Logger
+}
+
+func NewPostgres(db *gorm.DB, dsn string, logger *zap.Logger) *Postgres {
+	return &Postgres{db: db, dsn: dsn, logger: logger}
+}
+
+// Publish 在一个事务里写入事件并通知所有实例；事务提交后通知才会发出
+func (b *Postgres) Publish(ctx context.Context, e *realtime.Event) error {
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", publishLockKey).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(e).Error; err != nil {
+			return err
+		}
+		return tx.Exec("SELECT pg_notify(?, ?)", Channel, strconv.FormatUint(e.Seq, 10)).Error
+	})
+}
+
+// Run 监听通知并按 seq 顺序投递启动之后的新事件；监听连接断开时自动重连，重连后先追上漏掉的事件
+func (b *Postgres) Run(ctx context.Context, deliver func(*realtime.Event)) {
+	last, err := b.head(ctx)
+	if err != nil {
+		b.logger.Error("Event bus failed to read event log head", zap.Error(err))
+	}
+	l := pq.NewListener(b.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			b.logger.Warn("Event bus listener connection", zap.Int("event", int(ev)), zap.Error(err))
+		}
+	})
+	defer l.Close()
+	if err := l.Listen(Channel); err != nil {
+		b.logger.Error("Event bus LISTEN failed", zap.Error(err))
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.Notify: // 重连后会收到 nil，同样触发追赶
+		case <-ticker.C:
+		}
+		if last, err = b.catchUp(ctx, last, deliver); err != nil {
+			b.logger.Error("Event bus failed to read new events", zap.Uint64("after", last), zap.Error(err))
+		}
+	}
+}
+
+func (b *Postgres) head(ctx context.Context) (uint64, error) {
+	var seq uint64
+	err := b.db.WithContext(ctx).Model(&realtime.Event{}).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
+
+// catchUp 投递 last 之后的全部事件，返回最后投递的 seq
+func (b *Postgres) catchUp(ctx context.Context, last uint64, deliver func(*realtime.Event)) (uint64, error) {
+	for {
+		var batch []realtime.Event
+		if err := b.db.WithContext(ctx).Where("seq > ?", last).Order("seq").Limit(catchUpBatch).Find(&batch).Error; err != nil {
+			return last, err
+		}
+		for i := range batch {
+			deliver(&batch[i])
+			last = batch[i].Seq
+		}
+		if len(batch) < catchUpBatch {
+			return last, nil
+		}
+	}
+}
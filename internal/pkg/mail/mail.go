@@ -0,0 +1,117 @@
+// internal/pkg/mail/mail.go
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender 发信接口；业务只依赖它，生产用 SMTP，本地 / 测试用文件或内存实现
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FileSender 把邮件写成 .eml 文件，便于本地开发时查看重置链接
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+func NewFileSender(dir, from string) *FileSender {
+	return &FileSender{Dir: dir, From: from}
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(s.Dir, name), render(s.From, msg), 0o640)
+}
+
+// SMTPSender 通过 SMTP 发信；Username 为空时不做认证（内网中继）
+type SMTPSender struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	return &SMTPSender{Addr: addr, From: from, Username: username, Password: password}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, render(s.From, msg))
+}
+
+// MemorySender 把邮件留在内存里，测试用
+type MemorySender struct {
+	mu   sync.Mutex
+	Sent []Message
+}
+
+func (s *MemorySender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sent = append(s.Sent, msg)
+	return nil
+}
+
+// Last 最近发出的一封，没有时 ok 为 false
+func (s *MemorySender) Last() (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Sent) == 0 {
+		return Message{}, false
+	}
+	return s.Sent[len(s.Sent)-1], true
+}
+
+// render 生成 RFC 5322 格式的邮件；头部去掉换行，防止注入
+func render(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+func sanitize(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, v)
+}
@@ -0,0 +1,105 @@
+// internal/pkg/outbox/consumers.go
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"djj-inventory-system/internal/model/outbox"
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/pkg/eventbus"
+)
+
+// realtimeRoutes 领域事件对应的 websocket topic 和事件名
+var realtimeRoutes = map[string][2]string{
+	outbox.TypeProductCreated:     {"products", "productCreated"},
+	outbox.TypeProductUpdated:     {"products", "productUpdated"},
+	outbox.TypeProductDeleted:     {"products", "productDeleted"},
+	outbox.TypeOrderStatusChanged: {"orders", "orderStatusChanged"},
+	outbox.TypeStockMoved:         {"inventory", "stockMoved"},
+}
+
+// BusConsumer 把领域事件转成实时事件发布到事件总线，由各实例的 websocket hub 推送
+type BusConsumer struct {
+	Bus eventbus.Bus
+}
+
+func (BusConsumer) Name() string { return "websocket" }
+
+// Deliver 没有对应 topic 的事件直接跳过
+func (b BusConsumer) Deliver(ctx context.Context, e *outbox.Event) error {
+	route, ok := realtimeRoutes[e.Type]
+	if !ok {
+		return nil
+	}
+	return b.Bus.Publish(ctx, &realtime.Event{
+		Topic:    route[0],
+		Event:    route[1],
+		Entity:   e.Entity,
+		EntityID: e.EntityID,
+		Version:  e.Version,
+		StoreID:  e.StoreID,
+		Payload:  e.Payload,
+	})
+}
+
+// WebhookConsumer 把领域事件 POST 给外部地址，非 2xx 视为失败
+// 请求头带事件类型、事件 ID（接收方据此去重）和 HMAC-SHA256 签名：
+//
+//	X-DJJ-Event: product.updated
+//	X-DJJ-Event-Id: 42
+//	X-DJJ-Signature: sha256=<hex(hmac(secret, body))>
+type WebhookConsumer struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookConsumer(url, secret string) *WebhookConsumer {
+	return &WebhookConsumer{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *WebhookConsumer) Name() string { return "webhook:" + w.URL }
+
+func (w *WebhookConsumer) Deliver(ctx context.Context, e *outbox.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-DJJ-Event", e.Type)
+	req.Header.Set("X-DJJ-Event-Id", strconv.FormatUint(uint64(e.ID), 10))
+	if w.Secret != "" {
+		req.Header.Set("X-DJJ-Signature", "sha256="+Sign(w.Secret, body))
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, snippet)
+	}
+	return nil
+}
+
+// Sign 计算 webhook 签名，接收方用同一个 secret 校验
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
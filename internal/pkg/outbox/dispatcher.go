@@ -0,0 +1,270 @@
+// internal/pkg/outbox/dispatcher.go
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"djj-inventory-system/internal/model/outbox"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Consumer 领域事件的一个下游；Deliver 返回错误时按退避重试
+// 投递至少一次：同一事件可能送达多次，下游按事件 ID 去重
+type Consumer interface {
+	Name() string
+	Deliver(ctx context.Context, e *outbox.Event) error
+}
+
+// Policy 投递参数
+type Policy struct {
+	MaxAttempts int           // 失败这么多次后转为 dead，等人工重试
+	BaseDelay   time.Duration // 第 n 次失败后等待 BaseDelay * 2^(n-1)
+	MaxDelay    time.Duration
+	Lease       time.Duration // 领取后多久没有结果视为投递中断（进程崩溃），重新投递
+	Batch       int           // 每个下游每轮最多投递的条数
+	Timeout     time.Duration // 单条投递的超时；一批逐条投递，Batch × Timeout 必须小于 Lease
+}
+
+// DefaultPolicy 默认投递参数：一批最长 25 × 10s，在 5 分钟租约之内
+var DefaultPolicy = Policy{
+	MaxAttempts: 10,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    time.Hour,
+	Lease:       5 * time.Minute,
+	Batch:       25,
+	Timeout:     10 * time.Second,
+}
+
+// Dispatcher 把 outbox 里的事件投递给各下游：先给每个下游生成投递记录，再领取到期的记录逐条投递
+// 多个实例同时运行时用行锁（SKIP LOCKED）和租约错开，不会重复领取
+type Dispatcher struct {
+	db        *gorm.DB
+	policy    Policy
+	consumers []Consumer
+	logger    *zap.Logger
+}
+
+// NewDispatcher 未设置 Timeout 时按 Lease / (Batch + 1) 取；Batch × Timeout 超出租约时缩小 Batch，
+// 保证一批还没投完时已领取的记录不会因租约到期被其它实例重复领取
+func NewDispatcher(db *gorm.DB, policy Policy, logger *zap.Logger, consumers ...Consumer) *Dispatcher {
+	if policy.Lease <= 0 {
+		policy.Lease = DefaultPolicy.Lease
+	}
+	if policy.Batch <= 0 {
+		policy.Batch = 1
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = policy.Lease / time.Duration(policy.Batch+1)
+	}
+	if max := int((policy.Lease - 1) / policy.Timeout); policy.Batch > max {
+		logger.Warn("Outbox batch exceeds lease, shrinking batch",
+			zap.Int("batch", policy.Batch), zap.Duration("timeout", policy.Timeout), zap.Duration("lease", policy.Lease))
+		policy.Batch = max
+		if policy.Batch < 1 {
+			policy.Batch = 1
+		}
+	}
+	return &Dispatcher{db: db, policy: policy, consumers: consumers, logger: logger}
+}
+
+// Run 每个下游一个 goroutine，各自每隔 interval 投递一轮，直到 ctx 取消
+// 一个下游变慢（如 webhook 超时）或出错不会拖住其它下游
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	for _, c := range d.consumers {
+		wg.Add(1)
+		go func(c Consumer) {
+			defer wg.Done()
+			d.runConsumer(ctx, c, interval)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) runConsumer(ctx context.Context, c Consumer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.dispatch(ctx, c); err != nil && ctx.Err() == nil {
+			d.logger.Error("Outbox dispatch failed", zap.String("consumer", c.Name()), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 依次对每个下游投递一轮；一个下游出错不影响其它下游，返回全部错误
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+	var errs []error
+	for _, c := range d.consumers {
+		if err := d.dispatch(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dispatch 给一个下游生成投递记录并投递一批
+func (d *Dispatcher) dispatch(ctx context.Context, c Consumer) error {
+	if err := d.fanOut(ctx, c.Name()); err != nil {
+		return fmt.Errorf("fan out to %s: %w", c.Name(), err)
+	}
+	if err := d.deliver(ctx, c); err != nil {
+		return fmt.Errorf("deliver to %s: %w", c.Name(), err)
+	}
+	return nil
+}
+
+// fanOut 给下游登记之后、还没有投递记录的事件补上记录
+// 第一次运行时登记下游，从当时最新的事件之后开始投递
+func (d *Dispatcher) fanOut(ctx context.Context, name string) error {
+	db := d.db.WithContext(ctx)
+	var c outbox.Consumer
+	err := db.First(&c, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c = outbox.Consumer{Name: name}
+		if err := db.Model(&outbox.Event{}).Select("COALESCE(MAX(id), 0)").Scan(&c.StartEventID).Error; err != nil {
+			return err
+		}
+		// 并发登记时以先登记的为准
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&c).Error; err != nil {
+			return err
+		}
+		return db.First(&c, "name = ?", name).Error
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return db.Exec(`INSERT INTO outbox_deliveries (event_id, consumer, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT e.id, ?, ?, 0, ?, ?, ? FROM outbox_events e
+		WHERE e.id > ? AND NOT EXISTS (SELECT 1 FROM outbox_deliveries d WHERE d.event_id = e.id AND d.consumer = ?)
+		ON CONFLICT DO NOTHING`,
+		name, outbox.DeliveryPending, now, now, now, c.StartEventID, name).Error
+}
+
+// claim 领取到期的投递记录，并把下次尝试时间推到租约之后
+func (d *Dispatcher) claim(ctx context.Context, name string) ([]outbox.Delivery, error) {
+	var list []outbox.Delivery
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("consumer = ? AND status = ? AND next_attempt_at <= ?", name, outbox.DeliveryPending, now).
+			Order("id").Limit(d.policy.Batch).Find(&list).Error
+		if err != nil || len(list) == 0 {
+			return err
+		}
+		ids := make([]uint, len(list))
+		for i := range list {
+			ids[i] = list[i].ID
+		}
+		return tx.Model(&outbox.Delivery{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"next_attempt_at": now.Add(d.policy.Lease), "updated_at": now}).Error
+	})
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	var events []outbox.Event
+	ids := make([]uint, len(list))
+	for i := range list {
+		ids[i] = list[i].EventID
+	}
+	if err := d.db.WithContext(ctx).Where("id IN ?", ids).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]outbox.Event, len(events))
+	for _, e := range events {
+		byID[e.ID] = e
+	}
+	for i := range list {
+		list[i].Event = byID[list[i].EventID]
+	}
+	return list, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, c Consumer) error {
+	list, err := d.claim(ctx, c.Name())
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		dl := &list[i]
+		now := time.Now()
+		updates := map[string]interface{}{"attempts": dl.Attempts + 1, "updated_at": now}
+		dctx, cancel := context.WithTimeout(ctx, d.policy.Timeout)
+		err := c.Deliver(dctx, &dl.Event)
+		cancel()
+		if err != nil {
+			updates["last_error"] = err.Error()
+			if dl.Attempts+1 >= d.policy.MaxAttempts {
+				updates["status"] = outbox.DeliveryDead
+				d.logger.Error("Outbox delivery dead", zap.String("consumer", c.Name()),
+					zap.Uint("eventID", dl.EventID), zap.String("type", dl.Event.Type), zap.Error(err))
+			} else {
+				updates["next_attempt_at"] = now.Add(d.backoff(dl.Attempts + 1))
+				d.logger.Warn("Outbox delivery failed", zap.String("consumer", c.Name()),
+					zap.Uint("eventID", dl.EventID), zap.Int("attempt", dl.Attempts+1), zap.Error(err))
+			}
+		} else {
+			updates["status"] = outbox.DeliveryDelivered
+			updates["delivered_at"] = now
+			updates["last_error"] = ""
+		}
+		if err := d.db.WithContext(ctx).Model(&outbox.Delivery{}).Where("id = ?", dl.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff 第 attempt 次失败后的等待时间
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.policy.BaseDelay
+	for i := 1; i < attempt && delay < d.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.policy.MaxDelay {
+		delay = d.policy.MaxDelay
+	}
+	return delay
+}
+
+// Purge 删除 before 之前、已经投递给全部下游的事件，返回删除条数
+// 还有待投递或 dead 记录的事件保留，供重试和排查
+func (d *Dispatcher) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res := d.db.WithContext(ctx).Exec(`DELETE FROM outbox_events
+		WHERE created_at < ? AND NOT EXISTS (SELECT 1 FROM outbox_deliveries d WHERE d.event_id = outbox_events.id AND d.status <> ?)`,
+		before, outbox.DeliveryDelivered)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	// SQLite 默认不执行外键级联，投递记录单独清理
+	err := d.db.WithContext(ctx).Exec(`DELETE FROM outbox_deliveries WHERE NOT EXISTS (SELECT 1 FROM outbox_events e WHERE e.id = outbox_deliveries.event_id)`).Error
+	return res.RowsAffected, err
+}
+
+// RunPurger 每隔 interval 删除 retention 之前已全部投递的事件，直到 ctx 取消
+func (d *Dispatcher) RunPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Purge(ctx, time.Now().Add(-retention)); err != nil {
+				d.logger.Error("Outbox purge failed", zap.Error(err))
+			}
+		}
+	}
+}
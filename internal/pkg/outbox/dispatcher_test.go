@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	model "djj-inventory-system/internal/model/outbox"
+	"djj-inventory-system/internal/pkg/testdb"
+
+	"go.uber.org/zap"
+)
+
+// recorder 记录收到的事件 ID；fail 为 true 时全部失败
+type recorder struct {
+	name string
+	fail bool
+	got  []uint
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Deliver(_ context.Context, e *model.Event) error {
+	if r.fail {
+		return errors.New("endpoint down")
+	}
+	r.got = append(r.got, e.ID)
+	return nil
+}
+
+func TestDispatcherDeliversAtLeastOnce(t *testing.T) {
+	db := testdb.Open(t, &model.Event{}, &model.Delivery{}, &model.Consumer{})
+	ctx := context.Background()
+	add := func(id uint) {
+		t.Helper()
+		if err := Add(db, &model.Event{Type: model.TypeProductUpdated, Entity: "product", EntityID: id}, map[string]uint{"id": id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add(1) // 登记下游之前的事件不投递
+	ok := &recorder{name: "ok"}
+	down := &recorder{name: "down", fail: true}
+	d := NewDispatcher(db, Policy{MaxAttempts: 2, MaxDelay: time.Hour, Lease: time.Minute, Batch: 10}, zap.NewNop(), ok, down)
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	add(2)
+	add(3)
+	for i := 0; i < 3; i++ {
+		if err := d.RunOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ok.got) != 2 || ok.got[0] != 2 || ok.got[1] != 3 {
+		t.Fatalf("ok got %v", ok.got)
+	}
+
+	var dead []model.Delivery
+	db.Where("consumer = ?", "down").Order("event_id").Find(&dead)
+	if len(dead) != 2 || dead[0].Status != model.DeliveryDead || dead[0].Attempts != 2 || dead[0].LastError != "endpoint down" {
+		t.Fatalf("down deliveries = %+v", dead)
+	}
+
+	// 下游恢复后人工把 dead 重置为 pending，下一轮补投
+	down.fail = false
+	db.Model(&model.Delivery{}).Where("id = ?", dead[0].ID).
+		Updates(map[string]interface{}{"status": model.DeliveryPending, "attempts": 0, "next_attempt_at": time.Now()})
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(down.got) != 1 || down.got[0] != 2 {
+		t.Fatalf("down got %v", down.got)
+	}
+
+	// 事件 1 没有下游、事件 2 已全部投递，可以清理；事件 3 还有 dead 记录，保留
+	if n, err := d.Purge(ctx, time.Now().Add(time.Minute)); err != nil || n != 2 {
+		t.Fatalf("purged %d, err %v", n, err)
+	}
+}
+
+// stuck 在 release 关闭之前一直阻塞，不理会 ctx
+type stuck struct{ release chan struct{} }
+
+func (stuck) Name() string { return "stuck" }
+
+func (s stuck) Deliver(context.Context, *model.Event) error {
+	<-s.release
+	return nil
+}
+
+// notify 把收到的事件 ID 发到 got
+type notify struct{ got chan uint }
+
+func (notify) Name() string { return "notify" }
+
+func (n notify) Deliver(ctx context.Context, e *model.Event) error {
+	select {
+	case n.got <- e.ID:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 每个下游各自一个 goroutine：一个下游卡住时其它下游照常投递
+func TestDispatcherRunsConsumersIndependently(t *testing.T) {
+	db := testdb.Open(t, &model.Event{}, &model.Delivery{}, &model.Consumer{})
+	hang := stuck{release: make(chan struct{})}
+	ok := notify{got: make(chan uint, 1)}
+	d := NewDispatcher(db, Policy{MaxAttempts: 3, MaxDelay: time.Hour, Lease: time.Minute, Batch: 10}, zap.NewNop(), hang, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		close(hang.release)
+		cancel()
+		<-done
+	}()
+
+	// 两个下游都登记之后再写事件
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var n int64
+		db.Model(&model.Consumer{}).Count(&n)
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("consumers were not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := Add(db, &model.Event{Type: model.TypeProductUpdated, Entity: "product", EntityID: 7}, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ok.got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("notify consumer was blocked by the stuck consumer")
+	}
+}
+
+// 单条超时 × 每批条数超出租约时缩小每批条数
+func TestNewDispatcherKeepsBatchWithinLease(t *testing.T) {
+	d := NewDispatcher(nil, Policy{Lease: time.Minute, Batch: 100, Timeout: 10 * time.Second}, zap.NewNop())
+	if d.policy.Batch != 5 {
+		t.Errorf("batch = %d, want 5", d.policy.Batch)
+	}
+	d = NewDispatcher(nil, Policy{Lease: time.Minute, Batch: 9}, zap.NewNop())
+	if d.policy.Timeout != 6*time.Second || d.policy.Batch != 9 {
+		t.Errorf("timeout = %v, batch = %d, want 6s and 9", d.policy.Timeout, d.policy.Batch)
+	}
+	if got := DefaultPolicy.Timeout * time.Duration(DefaultPolicy.Batch); got >= DefaultPolicy.Lease {
+		t.Errorf("default batch takes %v, not within lease %v", got, DefaultPolicy.Lease)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{policy: Policy{BaseDelay: 10 * time.Second, MaxDelay: time.Minute}}
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 10: time.Minute} {
+		if got := d.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// internal/pkg/outbox/outbox.go
+package outbox
+
+import (
+	"encoding/json"
+
+	"djj-inventory-system/internal/model/outbox"
+
+	"gorm.io/gorm"
+)
+
+// Add 在业务事务 tx 里写入领域事件，随事务一起提交或回滚；由 Dispatcher 投递给各下游
+func Add(tx *gorm.DB, e *outbox.Event, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	e.Payload = data
+	return tx.Create(e).Error
+}
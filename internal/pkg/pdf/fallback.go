@@ -0,0 +1,381 @@
+// internal/pkg/pdf/fallback.go
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"djj-inventory-system/internal/model/sales"
+)
+
+// A4，单位 pt
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 40.0
+)
+
+// RenderInvoice 不依赖浏览器、纯 Go 生成报价单 / 拣货单 / 发票 PDF
+// 版式比 HTML 模板简单，只用 PDF 内置的 Helvetica 字体；字体不支持的字符（如中文）显示为 ?
+func RenderInvoice(inv sales.Invoice) ([]byte, error) {
+	d := newDocument()
+	w := pageWidth - 2*margin
+
+	// 抬头：左边公司，右边单据类型 / 编号 / 日期
+	d.text(margin, d.y, 16, true, inv.CompanyName)
+	d.textRight(pageWidth-margin, d.y, 14, true, inv.InvoiceType)
+	d.y -= 16
+	right := []string{"No. " + inv.InvoiceNumber, "Date " + inv.InvoiceDate}
+	left := nonEmpty(inv.CompanyAddress, join(" | ", inv.CompanyPhone, inv.CompanyEmail, inv.CompanyWebsite), prefix("ABN ", inv.CompanyABN))
+	for i := 0; i < len(left) || i < len(right); i++ {
+		if i < len(left) {
+			d.text(margin, d.y, 9, false, left[i])
+		}
+		if i < len(right) {
+			d.textRight(pageWidth-margin, d.y, 10, false, right[i])
+		}
+		d.y -= 12
+	}
+	d.y -= 6
+	d.rule()
+
+	// 客户 / 地址
+	col := margin + w/2
+	d.text(margin, d.y, 10, true, "Bill To")
+	d.text(col, d.y, 10, true, "Deliver To")
+	d.y -= 13
+	billTo := nonEmpty(inv.CustomerCompany, inv.BillingAddress, prefix("ABN ", inv.CustomerABN),
+		join(" | ", inv.CustomerContact, inv.CustomerPhone, inv.CustomerEmail))
+	deliverTo := wrap(inv.DeliveryAddress, 9, w/2-10)
+	for i := 0; i < len(billTo) || i < len(deliverTo); i++ {
+		if i < len(billTo) {
+			d.text(margin, d.y, 9, false, billTo[i])
+		}
+		if i < len(deliverTo) {
+			d.text(col, d.y, 9, false, deliverTo[i])
+		}
+		d.y -= 12
+	}
+	if inv.SalesRep != "" {
+		d.text(margin, d.y, 9, false, "Sales Rep: "+inv.SalesRep)
+		d.y -= 12
+	}
+	d.y -= 8
+
+	// 明细表
+	cols := itemColumns(inv.PriceColumns())
+	header := func() {
+		for _, c := range cols {
+			if c.right {
+				d.textRight(c.x+c.w, d.y, 9, true, c.title)
+			} else {
+				d.text(c.x, d.y, 9, true, c.title)
+			}
+		}
+		d.y -= 4
+		d.rule()
+	}
+	d.onNewPage = header
+	header()
+	for _, it := range inv.Items {
+		desc := wrap(strings.TrimSpace(it.Description+" "+it.DetailDescription), 9, cols[1].w-6)
+		if len(desc) == 0 {
+			desc = []string{""}
+		}
+		d.ensure(float64(len(desc))*11 + 4)
+		cells := []string{it.DJJCode, "", fmt.Sprintf("%d", it.Quantity)}
+		if inv.PriceColumns() {
+			cells = append(cells, money(it.UnitPrice), money(it.Discount), money(it.Subtotal))
+		} else {
+			cells = append(cells, it.Location)
+		}
+		for i, c := range cols {
+			if i == 1 {
+				continue
+			}
+			if c.right {
+				d.textRight(c.x+c.w, d.y, 9, false, cells[i])
+			} else {
+				d.text(c.x, d.y, 9, false, clip(cells[i], 9, c.w-4))
+			}
+		}
+		for i, ln := range desc {
+			d.text(cols[1].x, d.y-float64(i)*11, 9, false, ln)
+		}
+		d.y -= float64(len(desc))*11 + 4
+	}
+	d.onNewPage = nil
+	d.rule()
+
+	// 合计
+	if inv.PriceColumns() || inv.TotalAmount != 0 {
+		rows := [][2]string{
+			{"Subtotal", money(inv.SubtotalAmount)},
+			{"GST", money(inv.GSTAmount)},
+			{"Total", money(inv.TotalAmount)},
+		}
+		if inv.ShowBalance {
+			if inv.CreditedAmount != 0 {
+				rows = append(rows, [2]string{"Credited", money(inv.CreditedAmount)})
+			}
+			rows = append(rows, [2]string{"Paid", money(inv.AmountPaid)}, [2]string{"Balance Due", money(inv.BalanceDue)})
+			if inv.DueDate != "" {
+				rows = append(rows, [2]string{"Due Date", inv.DueDate})
+			}
+		}
+		d.ensure(float64(len(rows)) * 15)
+		for _, row := range rows {
+			bold := row[0] == "Total" || row[0] == "Balance Due"
+			d.textRight(pageWidth-margin-90, d.y, 10, bold, row[0])
+			d.textRight(pageWidth-margin, d.y, 10, bold, row[1])
+			d.y -= 14
+		}
+	}
+
+	// 收款信息 / 条款
+	if inv.BankName != "" {
+		d.y -= 8
+		d.ensure(40)
+		d.text(margin, d.y, 10, true, "Payment Details")
+		d.y -= 13
+		for _, ln := range nonEmpty(inv.BankName, prefix("BSB ", inv.BSB), prefix("Account ", inv.AccountNumber)) {
+			d.text(margin, d.y, 9, false, ln)
+			d.y -= 12
+		}
+	}
+	if inv.TermsAndConditions != "" {
+		d.y -= 8
+		d.ensure(24)
+		d.text(margin, d.y, 10, true, "Terms and Conditions")
+		d.y -= 13
+		for _, ln := range wrap(inv.TermsAndConditions, 8, w) {
+			d.ensure(10)
+			d.text(margin, d.y, 8, false, ln)
+			d.y -= 10
+		}
+	}
+
+	return d.bytes(), nil
+}
+
+// column 明细表的一列
+type column struct {
+	title string
+	x, w  float64
+	right bool
+}
+
+func itemColumns(prices bool) []column {
+	if prices {
+		return []column{
+			{"Code", margin, 70, false},
+			{"Description", margin + 75, 215, false},
+			{"Qty", margin + 290, 35, true},
+			{"Unit Price", margin + 330, 65, true},
+			{"Discount", margin + 400, 50, true},
+			{"Subtotal", margin + 455, 60, true},
+		}
+	}
+	return []column{
+		{"Code", margin, 80, false},
+		{"Description", margin + 85, 250, false},
+		{"Qty", margin + 340, 35, true},
+		{"Location", margin + 385, 130, false},
+	}
+}
+
+// document 极简 PDF 写入器：多页、Helvetica / Helvetica-Bold、文字和横线
+type document struct {
+	pages     []*bytes.Buffer
+	cur       *bytes.Buffer
+	y         float64
+	onNewPage func() // 换页后调用，用于重复表头
+}
+
+func newDocument() *document {
+	d := &document{}
+	d.newPage()
+	return d
+}
+
+func (d *document) newPage() {
+	d.cur = &bytes.Buffer{}
+	d.pages = append(d.pages, d.cur)
+	d.y = pageHeight - margin - 12
+	if d.onNewPage != nil {
+		d.onNewPage()
+	}
+}
+
+// ensure 剩余空间不足 h 时换页
+func (d *document) ensure(h float64) {
+	if d.y-h < margin+20 {
+		d.newPage()
+	}
+}
+
+func (d *document) text(x, y, size float64, bold bool, s string) {
+	if s == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.cur, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+func (d *document) textRight(x, y, size float64, bold bool, s string) {
+	d.text(x-textWidth(s, size), y, size, bold, s)
+}
+
+// rule 在当前位置画一条横线并下移
+func (d *document) rule() {
+	fmt.Fprintf(d.cur, "0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, d.y, pageWidth-margin, d.y)
+	d.y -= 12
+}
+
+// bytes 组装 PDF：目录、页树、两种字体，然后每页一个 Page 对象加一个内容流；最后写页码
+func (d *document) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	n := len(d.pages)
+	kids := make([]string, n)
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, pg := range d.pages {
+		footer := fmt.Sprintf("%d/%d", i+1, n)
+		fmt.Fprintf(pg, "BT /F1 8 Tf %.2f %.2f Td (%s) Tj ET\n", (pageWidth-textWidth(footer, 8))/2, margin/2, footer)
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", pg.Len(), pg.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// escape 转成 WinAnsi 单字节并转义括号、反斜杠；超出 Latin-1 的字符替换为 ?
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r == '\t' || r == '\n' || r == '\r':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth 估算 Helvetica 文本宽度：数字和大写按 0.6em，其余按 0.5em，够右对齐和换行使用
+func textWidth(s string, size float64) float64 {
+	var w float64
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'Z':
+			w += 0.6
+		case r == ' ' || r == '.' || r == ',' || r == 'i' || r == 'l':
+			w += 0.28
+		default:
+			w += 0.5
+		}
+	}
+	return w * size
+}
+
+// wrap 按宽度折行
+func wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		cur := ""
+		for _, word := range strings.Fields(para) {
+			next := strings.TrimSpace(cur + " " + word)
+			if cur != "" && textWidth(next, size) > width {
+				lines = append(lines, cur)
+				next = word
+			}
+			cur = clip(next, size, width)
+		}
+		if cur != "" {
+			lines = append(lines, cur)
+		}
+	}
+	return lines
+}
+
+// clip 超宽时截断
+func clip(s string, size, width float64) string {
+	if textWidth(s, size) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && textWidth(string(r)+"...", size) > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
+
+func money(v float64) string {
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+	s := fmt.Sprintf("%.2f", v)
+	intPart, frac := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if neg {
+		return "-$" + b.String() + frac
+	}
+	return "$" + b.String() + frac
+}
+
+func nonEmpty(ss ...string) []string {
+	var out []string
+	for _, s := range ss {
+		if strings.TrimSpace(s) != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func join(sep string, ss ...string) string {
+	return strings.Join(nonEmpty(ss...), sep)
+}
+
+func prefix(p, s string) string {
+	if s == "" {
+		return ""
+	}
+	return p + s
+}
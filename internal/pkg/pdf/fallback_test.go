@@ -0,0 +1,74 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"djj-inventory-system/internal/model/sales"
+)
+
+func TestRenderInvoiceIsWellFormed(t *testing.T) {
+	inv := sales.Invoice{
+		CompanyName:   "DJJ Equipment (Pty) Ltd",
+		InvoiceNumber: "Q-20250718-00001-A",
+		InvoiceDate:   "2025/07/18",
+		InvoiceType:   "SALES QUOTE",
+		IsQuote:       true,
+		BankName:      "Test Bank",
+	}
+	for i := 0; i < 120; i++ {
+		inv.Items = append(inv.Items, sales.Item{
+			DJJCode:     fmt.Sprintf("DJJ%04d", i),
+			Description: "Compact tractor 拖拉机 with loader",
+			Quantity:    1,
+			UnitPrice:   1234.5,
+			Subtotal:    1234.5,
+		})
+	}
+
+	out, err := RenderInvoice(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	if n := bytes.Count(out, []byte("/Type /Page ")); n < 2 {
+		t.Fatalf("expected items to spill onto several pages, got %d", n)
+	}
+	if !bytes.Contains(out, []byte(`(DJJ Equipment \(Pty\) Ltd)`)) {
+		t.Error("parentheses in text are not escaped")
+	}
+
+	// xref 中每个偏移都必须指向对应的 "N 0 obj"
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := fmt.Sprintf("%d 0 obj", i+1)
+		if !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q, want %q", i+1, out[off:off+len(want)], want)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	cases := map[float64]string{
+		0:        "$0.00",
+		12.5:     "$12.50",
+		1234.5:   "$1,234.50",
+		-98765.4: "-$98,765.40",
+	}
+	for in, want := range cases {
+		if got := money(in); got != want {
+			t.Errorf("money(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// internal/pkg/pdf/pool.go
+package pdf
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	// ErrBrowserUnavailable 找不到或无法启动 Chrome，调用方可以改用 RenderInvoice
+	ErrBrowserUnavailable = errors.New("pdf: headless browser unavailable")
+	// ErrBusy 排队已满
+	ErrBusy = errors.New("pdf: render queue is full, try again later")
+)
+
+// PoolConfig 浏览器池配置
+type PoolConfig struct {
+	ExecPath      string        // Chrome 路径，为空时自动查找
+	MaxConcurrent int           // 同时渲染的标签页数，默认 2
+	MaxQueue      int           // 等待中的请求上限，超出直接返回 ErrBusy；0 取默认 20，负数表示不排队
+	RenderTimeout time.Duration // 单个文档渲染超时，默认 30s
+}
+
+// BrowserPool 共享一个 headless Chrome 进程，每个文档开一个标签页渲染
+// 并发数由 slots 控制，超出的请求排队，队列满了直接拒绝
+// 浏览器在第一次使用时启动，崩溃后下次使用时自动重启
+type BrowserPool struct {
+	cfg     PoolConfig
+	slots   chan struct{}
+	waiting int32
+
+	mu            sync.Mutex
+	browserCtx    context.Context
+	browserCancel context.CancelFunc
+	allocCancel   context.CancelFunc
+}
+
+func NewBrowserPool(cfg PoolConfig) *BrowserPool {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 2
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	} else if cfg.MaxQueue == 0 {
+		cfg.MaxQueue = 20
+	}
+	if cfg.RenderTimeout <= 0 {
+		cfg.RenderTimeout = 30 * time.Second
+	}
+	return &BrowserPool{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.MaxConcurrent),
+	}
+}
+
+// Available 本机是否能找到 Chrome
+func (p *BrowserPool) Available() bool {
+	return p.execPath() != ""
+}
+
+// Print 把 HTML 打印成 A4 PDF；等待空闲标签页期间遵守 ctx 的取消 / 超时
+func (p *BrowserPool) Print(ctx context.Context, html []byte) ([]byte, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { <-p.slots }()
+
+	browserCtx, err := p.browser()
+	if err != nil {
+		return nil, err
+	}
+
+	tabCtx, cancelTab := chromedp.NewContext(browserCtx)
+	defer cancelTab()
+	// 调用方取消（如客户端断开）时同时关闭标签页
+	stop := context.AfterFunc(ctx, cancelTab)
+	defer stop()
+	tabCtx, cancelTimeout := context.WithTimeout(tabCtx, p.cfg.RenderTimeout)
+	defer cancelTimeout()
+
+	var pdf []byte
+	err = chromedp.Run(tabCtx,
+		chromedp.Navigate("data:text/html;base64,"+base64.StdEncoding.EncodeToString(html)),
+		chromedp.WaitReady("body"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			buf, _, err := page.PrintToPDF().
+				WithPrintBackground(true).
+				WithDisplayHeaderFooter(true).
+				WithHeaderTemplate(`<div></div>`).
+				WithFooterTemplate(`
+					<div style="width:100%;text-align:center;font-size:10px;color:#666;">
+					  <span class="pageNumber"></span>/<span class="totalPages"></span>
+					</div>
+				`).
+				WithMarginTop(0.4).
+				WithMarginBottom(0.5).
+				WithMarginLeft(0.3).
+				WithMarginRight(0.3).
+				Do(ctx)
+			if err != nil {
+				return err
+			}
+			pdf = buf
+			return nil
+		}),
+	)
+	if err != nil {
+		if browserCtx.Err() != nil {
+			// 浏览器进程已退出，丢弃后下次重新启动
+			p.reset(browserCtx)
+		}
+		return nil, fmt.Errorf("生成 PDF 失败: %w", err)
+	}
+	return pdf, nil
+}
+
+// Close 关闭浏览器进程
+func (p *BrowserPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.shutdownLocked()
+}
+
+// acquire 排队等待空闲标签页
+func (p *BrowserPool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if int(atomic.AddInt32(&p.waiting, 1)) > p.cfg.MaxQueue {
+		atomic.AddInt32(&p.waiting, -1)
+		return ErrBusy
+	}
+	defer atomic.AddInt32(&p.waiting, -1)
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// browser 返回正在运行的浏览器，没有则启动
+func (p *BrowserPool) browser() (context.Context, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.browserCtx != nil && p.browserCtx.Err() == nil {
+		return p.browserCtx, nil
+	}
+	p.shutdownLocked()
+
+	path := p.execPath()
+	if path == "" {
+		return nil, ErrBrowserUnavailable
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.ExecPath(path))
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	// 空 Run 会真正拉起浏览器进程
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return nil, fmt.Errorf("%w: %v", ErrBrowserUnavailable, err)
+	}
+	p.browserCtx, p.browserCancel, p.allocCancel = browserCtx, browserCancel, allocCancel
+	return browserCtx, nil
+}
+
+// reset 丢弃已经退出的浏览器（只在它仍是当前浏览器时）
+func (p *BrowserPool) reset(dead context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.browserCtx == dead {
+		p.shutdownLocked()
+	}
+}
+
+func (p *BrowserPool) shutdownLocked() {
+	if p.browserCancel != nil {
+		p.browserCancel()
+	}
+	if p.allocCancel != nil {
+		p.allocCancel()
+	}
+	p.browserCtx, p.browserCancel, p.allocCancel = nil, nil, nil
+}
+
+// execPath 配置的路径优先，否则按常见名称在 PATH 中查找
+func (p *BrowserPool) execPath() string {
+	candidates := []string{
+		"headless-shell", "headless_shell", "chromium", "chromium-browser",
+		"google-chrome", "google-chrome-stable", "chrome",
+	}
+	if p.cfg.ExecPath != "" {
+		candidates = []string{p.cfg.ExecPath}
+	}
+	for _, c := range candidates {
+		if found, err := exec.LookPath(c); err == nil {
+			return found
+		}
+	}
+	return ""
+}
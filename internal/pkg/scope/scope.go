@@ -0,0 +1,118 @@
+// internal/pkg/scope/scope.go
+package scope
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrOutOfScope 操作的门店不在当前用户的数据范围内
+var ErrOutOfScope = errors.New("store is outside of your data scope")
+
+// Level 数据范围级别
+type Level int
+
+const (
+	LevelAll    Level = iota // 管理员：全部门店
+	LevelRegion              // 主管：本地区所有门店
+	LevelStore               // 其他人：本门店
+)
+
+// Scope 当前用户能看到的门店范围；由中间件按用户角色和所属门店放进 request context
+type Scope struct {
+	Level    Level `json:"level"`
+	StoreID  uint  `json:"storeId"`
+	RegionID uint  `json:"regionId"`
+}
+
+type ctxKey struct{}
+
+// WithContext 把数据范围放进 context
+func WithContext(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+// FromContext 取出数据范围；没有时（后台任务、迁移等非请求调用）ok 为 false，不做限制
+func FromContext(ctx context.Context) (Scope, bool) {
+	if ctx == nil {
+		return Scope{}, false
+	}
+	s, ok := ctx.Value(ctxKey{}).(Scope)
+	return s, ok
+}
+
+// storeCondition 返回限制门店列的 SQL 条件；不需要限制时返回空
+func storeCondition(ctx context.Context, column string) (string, []interface{}) {
+	s, ok := FromContext(ctx)
+	if !ok || s.Level == LevelAll {
+		return "", nil
+	}
+	if s.Level == LevelRegion {
+		return column + " IN (SELECT id FROM stores WHERE region_id = ?)", []interface{}{s.RegionID}
+	}
+	return column + " = ?", []interface{}{s.StoreID}
+}
+
+// ByStore 按门店列过滤，用法：db.WithContext(ctx).Scopes(scope.ByStore("orders.store_id"))
+// 数据范围取自 db 上绑定的 context，所以必须在 WithContext 之后使用（事务内的 tx 会继承）
+func ByStore(column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		cond, args := storeCondition(db.Statement.Context, column)
+		if cond == "" {
+			return db
+		}
+		return db.Where(cond, args...)
+	}
+}
+
+// ByOrder 没有门店列、挂在订单下的数据（拣货单、收款等），按所属订单的门店过滤
+func ByOrder(column string) func(*gorm.DB) *gorm.DB {
+	return ByParent(column, "orders")
+}
+
+// ByParent 按父表（必须有 store_id 列）的门店过滤，column 是指向父表 id 的外键列
+func ByParent(column, parentTable string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		cond, args := storeCondition(db.Statement.Context, "store_id")
+		if cond == "" {
+			return db
+		}
+		return db.Where(column+" IN (SELECT id FROM "+parentTable+" WHERE "+cond+")", args...)
+	}
+}
+
+// CheckStore 新建 / 改挂门店时校验目标门店在范围内，不在时返回 ErrOutOfScope
+func CheckStore(db *gorm.DB, storeID uint) error {
+	s, ok := FromContext(db.Statement.Context)
+	if !ok || s.Level == LevelAll {
+		return nil
+	}
+	if s.Level == LevelStore {
+		if storeID != s.StoreID {
+			return ErrOutOfScope
+		}
+		return nil
+	}
+	var n int64
+	if err := db.Session(&gorm.Session{NewDB: true}).Table("stores").Where("id = ? AND region_id = ?", storeID, s.RegionID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOutOfScope
+	}
+	return nil
+}
+
+// StoreIDs 数据范围内的全部门店 ID；all 为 true 时不限门店（ids 为空）
+func StoreIDs(db *gorm.DB, s Scope) (ids []uint, all bool, err error) {
+	switch s.Level {
+	case LevelAll:
+		return nil, true, nil
+	case LevelStore:
+		return []uint{s.StoreID}, false, nil
+	}
+	err = db.Table("stores").Where("region_id = ?", s.RegionID).Pluck("id", &ids).Error
+	return ids, false, err
+}
@@ -1,50 +1,75 @@
 package setup
 
 import (
+	"context"
+	"djj-inventory-system/config"
 	_ "djj-inventory-system/docs" // <-- 一定要导入，才能注册 docs.SwaggerInfo
+	"djj-inventory-system/internal/database"
 	"djj-inventory-system/internal/handler"
 	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/eventbus"
+	"djj-inventory-system/internal/pkg/mail"
+	"djj-inventory-system/internal/pkg/outbox"
+	"djj-inventory-system/internal/pkg/pdf"
+	"djj-inventory-system/internal/pkg/scope"
 	"djj-inventory-system/internal/repository"
 	"djj-inventory-system/internal/service"
 	"djj-inventory-system/internal/websocket"
 	"log"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// corsOrigins 允许跨域访问的前端地址，websocket 握手也按它校验 Origin
+var corsOrigins = []string{"https://192.168.1.244:5173"} // 或者 ["*"] 开发时
+
 func ServerStart(db *gorm.DB) {
 
 	// set up repos + services *once*
 	userRepo := repository.NewUserRepo(db)
 	auditor := audit.NewGormAuditor(db)
-	userSvc := service.NewUserService(userRepo, auditor)
+	userSvc := service.NewCachedUserService(service.NewUserService(userRepo, auditor, passwordPolicy(), lockoutPolicy()), envMinutes("PERMISSION_CACHE_MINUTES", 5))
 
 	roleRepo := repository.NewRoleRepo(db)
-	roleSvc := service.NewRoleService(roleRepo, auditor)
+	roleSvc := service.NewRoleService(roleRepo, auditor, userSvc)
 
 	permRepo := repository.NewPermRepo(db)
-	permSvc := service.NewPermService(permRepo, auditor)
+	permSvc := service.NewPermService(permRepo, auditor, userSvc)
+	sessionSvc := newSessionService(db, userSvc)
 
 	// new Gin router
 	r := gin.Default()
+	// handler 直接把 *gin.Context 当 context.Context 传给服务时，也能读到 request context 里的调用者和数据范围
+	r.ContextWithFallback = true
 
 	// 1) global — always try to decode session cookie into context
 	//r.Use(handler.SessionAuthMiddleware())
 
-	// 2) public endpoints: register & login (no RequireLogin here)
+	// 2) public endpoints: login (no RequireLogin here); protected 先建好，注册挂在它下面
 	public := r.Group("/api")
-	handler.NewAuthHandler(public, userSvc)
+	protected := r.Group("/api")
+	protected.Use(handler.RequireLogin(), handler.LoadPermissions(userSvc))
+	mfaSvc := service.NewMFAService(repository.NewMFARepository(db), userRepo, mfaPolicy(), lockoutPolicy(), auditor, zap.L())
+	handler.NewAuthHandler(public, protected, userSvc, sessionSvc, mfaSvc)
+	handler.NewMFAHandler(public, userSvc, sessionSvc, mfaSvc)
+	handler.NewPasswordHandler(public, service.NewPasswordResetService(repository.NewPasswordResetRepository(db), userRepo,
+		mailSender(), sessionSvc, passwordPolicy(), envMinutes("PASSWORD_RESET_TTL_MINUTES", 30), config.Get("PASSWORD_RESET_URL"), zap.L()))
 
 	// 3) protected endpoints: everything under here needs a valid session
-	protected := r.Group("/api")
-	protected.Use(handler.RequireLogin())
 	{
 		handler.NewUserHandler(protected, userSvc)
+		handler.NewAPIKeyHandler(protected, service.NewAPIKeyService(repository.NewAPIKeyRepository(db), userSvc, auditor, zap.L()))
 		handler.NewRoleHandler(protected, roleSvc)
 		handler.NewPermHandler(protected, permSvc)
 
@@ -62,22 +87,51 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 	// repos + svc
 	userRepo := repository.NewUserRepo(db)
 	auditor := audit.NewGormAuditor(db)
-	userSvc := service.NewUserService(userRepo, auditor)
+	// 权限中间件每个请求都要查权限，走缓存；角色 / 权限变更时缓存立即失效
+	userSvc := service.NewCachedUserService(service.NewUserService(userRepo, auditor, passwordPolicy(), lockoutPolicy()), envMinutes("PERMISSION_CACHE_MINUTES", 5))
 
 	roleRepo := repository.NewRoleRepo(db)
-	roleService := service.NewRoleService(roleRepo, auditor)
+	roleService := service.NewRoleService(roleRepo, auditor, userSvc)
 
 	permRepo := repository.NewPermRepo(db)
-	permSvc := service.NewPermService(permRepo, auditor)
-	hub := websocket.NewHub()
+	permSvc := service.NewPermService(permRepo, auditor, userSvc)
+	sessionSvc := newSessionService(db, userSvc)
+	go sessionSvc.RunSweeper(context.Background(), envMinutes("SESSION_SWEEP_MINUTES", 60))
+	// 业务代码把实时事件发布到事件总线，总线把所有实例的事件交给本实例的 hub 推送
+	// 事件写入有界日志，断线重连的客户端按 seq 补发；只保留最近 WS_EVENT_LOG_SIZE 条
+	eventRepository := repository.NewEventRepository(db)
+	bus := eventBus(db, eventRepository)
+	hub := websocket.NewHub(websocket.DefaultConfig, eventRepository)
+	go bus.Run(context.Background(), hub.Dispatch)
+	go hub.RunTrimmer(context.Background(), envMinutes("WS_EVENT_TRIM_MINUTES", 10), envInt("WS_EVENT_LOG_SIZE", 10000), zap.L())
+	// 商品、订单状态、库存变动的领域事件随业务事务写入 outbox，由 dispatcher 至少一次投递给 hub 和 webhook
+	dispatcher := outboxDispatcher(db, bus)
+	go dispatcher.Run(context.Background(), envSeconds("OUTBOX_DISPATCH_SECONDS", 2))
+	go dispatcher.RunPurger(context.Background(), envHours("OUTBOX_PURGE_HOURS", 24), envDays("OUTBOX_RETENTION_DAYS", 7))
 	customerRepo := repository.NewCustomerRepo(db)
 	customerService := service.NewCustomerService(customerRepo)
 	storeService := service.NewStoreService(db)
 	regionService := service.NewRegionService(db)
 
-	stockRepository := repository.NewStockRepository(db)
 	productRepository := repository.NewProductRepository(db)
-	prodSvc := service.NewProductService(productRepository, stockRepository)
+	prodSvc := service.NewProductService(productRepository)
+
+	inventoryRepository := repository.NewInventoryRepository(db)
+	inventorySvc := service.NewInventoryService(inventoryRepository, zap.L())
+	transferSvc := service.NewTransferService(repository.NewTransferRepository(db), zap.L())
+	adjustmentSvc := service.NewAdjustmentService(repository.NewAdjustmentRepository(db), adjustmentPolicy(), zap.L())
+	stocktakeSvc := service.NewStocktakeService(repository.NewStocktakeRepository(db), zap.L())
+	reservationSvc := service.NewReservationService(repository.NewReservationRepository(db), envHours("RESERVATION_TTL_HOURS", 72), zap.L())
+	go reservationSvc.RunSweeper(context.Background(), envMinutes("RESERVATION_SWEEP_MINUTES", 10))
+	quoteRepository := repository.NewQuoteRepository(db)
+	orderSvc := service.NewOrderService(repository.NewOrderRepository(db), quoteRepository, reservationSvc, auditor, zap.L())
+	quoteSvc := service.NewQuoteService(quoteRepository, orderSvc, auditor, zap.L())
+	pickingRepository := repository.NewPickingRepository(db)
+	pickingSvc := service.NewPickingService(pickingRepository, zap.L())
+	financeRepository := repository.NewFinanceRepository(db)
+	financeSvc := service.NewFinanceService(financeRepository, orderSvc, envDays("INVOICE_PAYMENT_TERMS_DAYS", 14), auditor, zap.L())
+	invoiceSvc := service.NewInvoiceService(quoteRepository, repository.NewOrderRepository(db), pickingRepository,
+		repository.NewCompanyRepository(db), financeRepository, pdfPool(), "")
 
 	// router
 	// 假设配置里 STORAGE_PATH="./"（项目根目录）
@@ -86,10 +140,13 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 	log.Println("→ Serving static files from:", uploadDir)
 
 	r := gin.Default()
+	// handler 直接把 *gin.Context 当 context.Context 传给服务时，也能读到 request context 里的调用者和数据范围
+	r.ContextWithFallback = true
 	r.Static("/uploads", uploadDir)
-	r.Use(handler.SessionAuthMiddleware())
+	apiKeySvc := service.NewAPIKeyService(repository.NewAPIKeyRepository(db), userSvc, auditor, zap.L())
+	r.Use(handler.SessionAuthMiddleware(sessionSvc, apiKeySvc))
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"https://192.168.1.244:5173"}, // 或者 ["*"] 开发时
+		AllowOrigins:     corsOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		AllowCredentials: true,
@@ -97,20 +154,258 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	//websocket
-	r.GET("/ws/:topic", websocket.ServeWS(hub))
+	// 和 REST 一样校验会话并加载权限；topic 权限和门店过滤在 ServeWS 里做
+	serveWS := websocket.ServeWS(hub, websocket.Options{
+		Origins: corsOrigins,
+		Stores: func(ctx context.Context, s scope.Scope) ([]uint, bool, error) {
+			return scope.StoreIDs(db.WithContext(ctx), s)
+		},
+	})
+	r.GET("/ws", handler.RequireLogin(), handler.LoadPermissions(userSvc), serveWS)
+	r.GET("/ws/:topic", handler.RequireLogin(), handler.LoadPermissions(userSvc), serveWS)
 	public := r.Group("/api")
-	// 挂载 Swagger UI
-	handler.NewAuthHandler(public, userSvc)
-	r.POST("/api/generate-pdf", handler.GeneratePDF)
-	handler.NewRoleHandler(public, roleService)
 	protected := r.Group("/api")
-	protected.Use(handler.RequireLogin())
+	protected.Use(handler.RequireLogin(), handler.LoadPermissions(userSvc))
+	// 挂载 Swagger UI
+	mfaSvc := service.NewMFAService(repository.NewMFARepository(db), userRepo, mfaPolicy(), lockoutPolicy(), auditor, zap.L())
+	handler.NewAuthHandler(public, protected, userSvc, sessionSvc, mfaSvc)
+	handler.NewMFAHandler(public, userSvc, sessionSvc, mfaSvc)
+	handler.NewPasswordHandler(public, service.NewPasswordResetService(repository.NewPasswordResetRepository(db), userRepo,
+		mailSender(), sessionSvc, passwordPolicy(), envMinutes("PASSWORD_RESET_TTL_MINUTES", 30), config.Get("PASSWORD_RESET_URL"), zap.L()))
 	handler.NewUserHandler(protected, userSvc)
+	handler.NewAPIKeyHandler(protected, apiKeySvc)
+	handler.NewRoleHandler(protected, roleService)
 	handler.NewPermHandler(protected, permSvc)
-	handler.NewCustomerHandler(protected, customerService, hub)
+	handler.NewCustomerHandler(protected, customerService, bus)
 	handler.NewStoreHandler(protected, storeService)
 	handler.NewRegionHandler(protected, regionService)
-	handler.NewProductHandler(protected, prodSvc, hub)
+	handler.NewProductHandler(protected, prodSvc)
+	handler.NewInventoryHandler(protected, inventorySvc)
+	handler.NewTransferHandler(protected, transferSvc)
+	handler.NewAdjustmentHandler(protected, adjustmentSvc)
+	handler.NewStocktakeHandler(protected, stocktakeSvc)
+	handler.NewReservationHandler(protected, reservationSvc)
+	handler.NewOrderHandler(protected, orderSvc, bus)
+	handler.NewQuoteHandler(protected, quoteSvc, bus)
+	handler.NewPickingHandler(protected, pickingSvc)
+	handler.NewFinanceHandler(protected, financeSvc)
+	handler.NewInvoiceHandler(protected, invoiceSvc)
 	handler.NewUploadHandler(protected, "uploads", "")
+	auditSvc := service.NewAuditService(repository.NewAuditRepository(db), auditor, auditSigningKeys(), zap.L())
+	go auditSvc.RunSealer(context.Background(), envSeconds("AUDIT_SEAL_SECONDS", 2))
+	go auditSvc.RunCheckpointer(context.Background(), envMinutes("AUDIT_CHECKPOINT_MINUTES", 60))
+	handler.NewAuditHandler(protected, auditSvc)
+	handler.NewOutboxHandler(protected, service.NewOutboxService(repository.NewOutboxRepository(db), zap.L()))
+	protected.GET("/ws/stats", handler.RequirePermission("system.log"), websocket.ServeStats(hub))
 	return r
 }
+
+// newSessionService 按环境变量加载密钥并创建会话服务
+// JWT_KEYS：JWT 签名密钥 "kid:secret,kid:secret"，每个至少 32 字节；JWT_ACTIVE_KID：签名用的 kid，默认第一个
+// ACCESS_TOKEN_MINUTES：访问令牌有效期，默认 15；REFRESH_TOKEN_DAYS：刷新令牌有效期，默认 7
+// 密钥未配置时使用随机密钥（只适合本地开发，重启后需重新登录），配置有误直接退出
+func newSessionService(db *gorm.DB, users service.UserService) *service.SessionService {
+	keys := auth.RandomKeyRing()
+	if spec := config.Get("JWT_KEYS"); spec != "" {
+		kr, err := auth.ParseKeyRing(spec, config.Get("JWT_ACTIVE_KID"))
+		if err != nil {
+			log.Fatalf("invalid JWT_KEYS: %v", err)
+		}
+		keys = kr
+	} else {
+		log.Println("JWT_KEYS not set, using a random signing key")
+	}
+	return service.NewSessionService(repository.NewSessionRepository(db), users, keys,
+		envMinutes("ACCESS_TOKEN_MINUTES", 15), envDays("REFRESH_TOKEN_DAYS", 7), zap.L())
+}
+
+// eventBus EVENT_BUS：实时事件总线，postgres（默认，多实例部署时各实例通过 LISTEN / NOTIFY 互通）或 local（单实例）
+func eventBus(db *gorm.DB, events *repository.EventRepository) eventbus.Bus {
+	switch v := config.Get("EVENT_BUS"); v {
+	case "", "postgres":
+		return eventbus.NewPostgres(db, database.DSN("djjinventory"), zap.L())
+	case "local":
+		return eventbus.NewLocal(events)
+	default:
+		log.Fatalf("invalid EVENT_BUS %q, expected postgres or local", v)
+		return nil
+	}
+}
+
+// outboxDispatcher 领域事件的下游：本实例的事件总线，以及 WEBHOOK_URLS 里的每个地址（逗号分隔）
+// WEBHOOK_SECRET：webhook 请求的 HMAC-SHA256 签名密钥；OUTBOX_MAX_ATTEMPTS：失败多少次后转为 dead，默认 10
+func outboxDispatcher(db *gorm.DB, bus eventbus.Bus) *outbox.Dispatcher {
+	consumers := []outbox.Consumer{outbox.BusConsumer{Bus: bus}}
+	for _, u := range strings.Split(config.Get("WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			consumers = append(consumers, outbox.NewWebhookConsumer(u, config.Get("WEBHOOK_SECRET")))
+		}
+	}
+	policy := outbox.DefaultPolicy
+	policy.MaxAttempts = envInt("OUTBOX_MAX_ATTEMPTS", policy.MaxAttempts)
+	return outbox.NewDispatcher(db, policy, zap.L(), consumers...)
+}
+
+// auditSigningKeys AUDIT_SIGNING_KEYS：审计检查点签名密钥 "kid:base64seed,..."（32 字节 Ed25519 种子），第一个用于签名
+// 未配置时使用随机密钥（只适合本地开发，重启后旧检查点无法验签），配置有误直接退出
+func auditSigningKeys() *audit.SigningKeys {
+	spec := config.Get("AUDIT_SIGNING_KEYS")
+	if spec == "" {
+		log.Println("AUDIT_SIGNING_KEYS not set, using a random checkpoint signing key")
+		return audit.RandomSigningKeys()
+	}
+	keys, err := audit.ParseSigningKeys(spec)
+	if err != nil {
+		log.Fatalf("invalid AUDIT_SIGNING_KEYS: %v", err)
+	}
+	return keys
+}
+
+// passwordPolicy 从环境变量读取密码复杂度要求，未配置时使用 service.DefaultPasswordPolicy
+// PASSWORD_MIN_LENGTH：最短长度；PASSWORD_REQUIRE_UPPER / LOWER / DIGIT / SYMBOL：true/false
+func passwordPolicy() service.PasswordPolicy {
+	p := service.DefaultPasswordPolicy
+	if v, err := strconv.Atoi(config.Get("PASSWORD_MIN_LENGTH")); err == nil && v > 0 {
+		p.MinLength = v
+	}
+	for key, field := range map[string]*bool{
+		"PASSWORD_REQUIRE_UPPER":  &p.RequireUpper,
+		"PASSWORD_REQUIRE_LOWER":  &p.RequireLower,
+		"PASSWORD_REQUIRE_DIGIT":  &p.RequireDigit,
+		"PASSWORD_REQUIRE_SYMBOL": &p.RequireSymbol,
+	} {
+		if v, err := strconv.ParseBool(config.Get(key)); err == nil {
+			*field = v
+		}
+	}
+	return p
+}
+
+// lockoutPolicy 登录锁定策略
+// LOGIN_MAX_ATTEMPTS：连续失败多少次后锁定，默认 5，0 表示不锁定；LOGIN_LOCKOUT_MINUTES：锁定时长，默认 15
+func lockoutPolicy() service.LockoutPolicy {
+	p := service.LockoutPolicy{MaxAttempts: 5, Duration: envMinutes("LOGIN_LOCKOUT_MINUTES", 15)}
+	if v, err := strconv.Atoi(config.Get("LOGIN_MAX_ATTEMPTS")); err == nil && v >= 0 {
+		p.MaxAttempts = v
+	}
+	return p
+}
+
+// mfaPolicy 两步验证策略
+// MFA_ISSUER：验证器 App 里显示的名称；MFA_REQUIRED_PERMISSIONS / MFA_REQUIRED_ROLES：逗号分隔，
+// 持有其中任一权限或角色的用户必须绑定 TOTP，默认 user.permission,finance.refund
+func mfaPolicy() service.MFAPolicy {
+	p := service.MFAPolicy{Issuer: config.Get("MFA_ISSUER"), RequiredPermissions: []string{"user.permission", "finance.refund"}}
+	if p.Issuer == "" {
+		p.Issuer = "DJJ Inventory"
+	}
+	if v, ok := os.LookupEnv("MFA_REQUIRED_PERMISSIONS"); ok {
+		p.RequiredPermissions = splitList(v)
+	}
+	p.RequiredRoles = splitList(config.Get("MFA_REQUIRED_ROLES"))
+	return p
+}
+
+// splitList 逗号分隔的配置项，去掉空白和空项
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// mailSender 按 MAIL_DRIVER 选择发信方式：smtp 走 SMTP_ADDR / SMTP_USER / SMTP_PASS，
+// 其他情况写到 MAIL_DIR（默认 mail_outbox）下的 .eml 文件；MAIL_FROM 为发件人
+func mailSender() mail.Sender {
+	from := config.Get("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	if config.Get("MAIL_DRIVER") == "smtp" {
+		return mail.NewSMTPSender(config.Get("SMTP_ADDR"), from, config.Get("SMTP_USER"), config.Get("SMTP_PASS"))
+	}
+	dir := config.Get("MAIL_DIR")
+	if dir == "" {
+		dir = "mail_outbox"
+	}
+	return mail.NewFileSender(dir, from)
+}
+
+// adjustmentPolicy 从环境变量读取库存调整审批阈值，未配置或非法时使用默认值
+// ADJUSTMENT_APPROVAL_QTY：数量绝对值阈值，默认 20
+// ADJUSTMENT_APPROVAL_VALUE：金额阈值，默认 500
+func adjustmentPolicy() service.AdjustmentPolicy {
+	p := service.AdjustmentPolicy{QtyThreshold: 20, ValueThreshold: 500}
+	if v, err := strconv.Atoi(config.Get("ADJUSTMENT_APPROVAL_QTY")); err == nil && v >= 0 {
+		p.QtyThreshold = v
+	}
+	if v, err := strconv.ParseFloat(config.Get("ADJUSTMENT_APPROVAL_VALUE"), 64); err == nil && v >= 0 {
+		p.ValueThreshold = v
+	}
+	return p
+}
+
+// envSeconds 读取以秒为单位的时长配置，未配置或非法时使用默认值
+func envSeconds(key string, def int) time.Duration {
+	if v, err := strconv.Atoi(config.Get(key)); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return time.Duration(def) * time.Second
+}
+
+// envHours 读取以小时为单位的时长配置，未配置或非法时使用默认值
+func envHours(key string, def int) time.Duration {
+	if v, err := strconv.Atoi(config.Get(key)); err == nil && v >= 0 {
+		return time.Duration(v) * time.Hour
+	}
+	return time.Duration(def) * time.Hour
+}
+
+// envDays 读取以天为单位的时长配置，未配置或非法时使用默认值
+func envDays(key string, def int) time.Duration {
+	if v, err := strconv.Atoi(config.Get(key)); err == nil && v >= 0 {
+		return time.Duration(v) * 24 * time.Hour
+	}
+	return time.Duration(def) * 24 * time.Hour
+}
+
+// envInt 读取正整数配置，未配置或非法时使用默认值
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(config.Get(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// envMinutes 读取以分钟为单位的时长配置，未配置或非法时使用默认值
+func envMinutes(key string, def int) time.Duration {
+	if v, err := strconv.Atoi(config.Get(key)); err == nil && v > 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return time.Duration(def) * time.Minute
+}
+
+// pdfPool 按环境变量创建 PDF 浏览器池；PDF_RENDERER=builtin 时不用浏览器，直接走纯 Go 版式
+// PDF_CHROME_PATH：Chrome 路径，默认自动查找
+// PDF_MAX_CONCURRENT：同时渲染数，默认 2
+// PDF_MAX_QUEUE：排队上限，默认 20
+// PDF_RENDER_TIMEOUT_SECONDS：单个文档超时，默认 30
+func pdfPool() *pdf.BrowserPool {
+	if config.Get("PDF_RENDERER") == "builtin" {
+		return nil
+	}
+	cfg := pdf.PoolConfig{ExecPath: config.Get("PDF_CHROME_PATH")}
+	if v, err := strconv.Atoi(config.Get("PDF_MAX_CONCURRENT")); err == nil {
+		cfg.MaxConcurrent = v
+	}
+	if v, err := strconv.Atoi(config.Get("PDF_MAX_QUEUE")); err == nil {
+		cfg.MaxQueue = v
+	}
+	if v, err := strconv.Atoi(config.Get("PDF_RENDER_TIMEOUT_SECONDS")); err == nil {
+		cfg.RenderTimeout = time.Duration(v) * time.Second
+	}
+	return pdf.NewBrowserPool(cfg)
+}
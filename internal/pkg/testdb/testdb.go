@@ -0,0 +1,42 @@
+// Package testdb 给各包的测试提供内存 SQLite 数据库，只在 _test.go 里引用
+package testdb
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open 打开一个内存 SQLite 并 AutoMigrate models，测试结束时关闭
+// 连接池只保留一个连接：":memory:" 每个连接都是一个独立的空库，多开连接会看不到已建的表和数据
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// Exec 依次执行建表等原始 SQL，用于模型依赖 PostgreSQL 类型、不能直接 AutoMigrate 的表
+func Exec(t testing.TB, db *gorm.DB, stmts ...string) {
+	t.Helper()
+	for _, s := range stmts {
+		if err := db.Exec(s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// internal/repository/adjustment_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdjustmentRepository 封装库存调整 / 报损单的读写，过账时同步写库存流水
+type AdjustmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAdjustmentRepository(db *gorm.DB) *AdjustmentRepository {
+	return &AdjustmentRepository{db: db}
+}
+
+// AdjustmentFilter 调整单列表筛选条件，零值表示不过滤
+type AdjustmentFilter struct {
+	Status      inventory.AdjustmentStatus
+	TxType      inventory.TransactionType
+	ReasonCode  inventory.ReasonCode
+	WarehouseID uint
+	ProductID   uint
+	Start, End  *time.Time
+}
+
+// Create 新建调整单，单号按 ADJ-日期-ID 生成
+// 不需要审批的单据在同一个事务里直接过账
+func (r *AdjustmentRepository) Create(ctx context.Context, a *inventory.StockAdjustment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先用临时单号占位，拿到 ID 后再生成正式单号
+		a.AdjustmentNumber = fmt.Sprintf("TMP-%d", time.Now().UnixNano())
+		a.Status = inventory.AdjustmentStatusPending
+		for i := range a.Attachments {
+			a.Attachments[i].RefType = inventory.AttachmentRefStockAdjustment
+		}
+		if err := tx.Omit("Product", "Warehouse").Create(a).Error; err != nil {
+			return err
+		}
+		a.AdjustmentNumber = fmt.Sprintf("ADJ-%s-%05d", a.CreatedAt.Format("20060102"), a.ID)
+		if err := tx.Model(a).Update("adjustment_number", a.AdjustmentNumber).Error; err != nil {
+			return err
+		}
+		if a.RequiresApproval {
+			return nil
+		}
+		return postAdjustment(tx, a, a.RequestedBy, "")
+	})
+}
+
+// FindByID 读取调整单及照片
+func (r *AdjustmentRepository) FindByID(ctx context.Context, id uint) (*inventory.StockAdjustment, error) {
+	var a inventory.StockAdjustment
+	err := r.db.WithContext(ctx).
+		Preload("Product").
+		Preload("Warehouse").
+		Preload("Attachments").
+		First(&a, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &a, err
+}
+
+// List 分页列出调整单
+func (r *AdjustmentRepository) List(ctx context.Context, f AdjustmentFilter, offset, limit int) ([]inventory.StockAdjustment, int64, error) {
+	var (
+		list  []inventory.StockAdjustment
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&inventory.StockAdjustment{})
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.TxType != "" {
+		q = q.Where("tx_type = ?", f.TxType)
+	}
+	if f.ReasonCode != "" {
+		q = q.Where("reason_code = ?", f.ReasonCode)
+	}
+	if f.WarehouseID != 0 {
+		q = q.Where("warehouse_id = ?", f.WarehouseID)
+	}
+	if f.ProductID != 0 {
+		q = q.Where("product_id = ?", f.ProductID)
+	}
+	if f.Start != nil {
+		q = q.Where("created_at >= ?", *f.Start)
+	}
+	if f.End != nil {
+		q = q.Where("created_at <= ?", *f.End)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("Product").
+		Preload("Warehouse").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// Approve 审批通过并过账
+func (r *AdjustmentRepository) Approve(ctx context.Context, id uint, reviewer, note string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		a, err := lockAdjustment(tx, id)
+		if err != nil {
+			return err
+		}
+		if a.Status != inventory.AdjustmentStatusPending {
+			return fmt.Errorf("%w: adjustment %s is %s", ErrInvalidState, a.AdjustmentNumber, a.Status)
+		}
+		return postAdjustment(tx, a, reviewer, note)
+	})
+}
+
+// Reject 驳回待审批的调整单，库存不变
+func (r *AdjustmentRepository) Reject(ctx context.Context, id uint, reviewer, note string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		a, err := lockAdjustment(tx, id)
+		if err != nil {
+			return err
+		}
+		if a.Status != inventory.AdjustmentStatusPending {
+			return fmt.Errorf("%w: adjustment %s is %s", ErrInvalidState, a.AdjustmentNumber, a.Status)
+		}
+		now := time.Now()
+		return tx.Model(&inventory.StockAdjustment{}).
+			Where("id = ?", a.ID).
+			Updates(map[string]interface{}{
+				"status":      inventory.AdjustmentStatusRejected,
+				"reviewed_by": reviewer,
+				"reviewed_at": now,
+				"review_note": note,
+			}).Error
+	})
+}
+
+// AddAttachments 为调整单追加照片
+func (r *AdjustmentRepository) AddAttachments(ctx context.Context, id uint, atts []catalog.Attachment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockAdjustment(tx, id); err != nil {
+			return err
+		}
+		for i := range atts {
+			atts[i].RefType = inventory.AttachmentRefStockAdjustment
+			atts[i].RefID = id
+		}
+		return tx.Create(&atts).Error
+	})
+}
+
+// postAdjustment 在事务 tx 中按调整单写库存流水并标记为已过账
+func postAdjustment(tx *gorm.DB, a *inventory.StockAdjustment, reviewer, reviewNote string) error {
+	note := fmt.Sprintf("[%s] %s", a.ReasonCode, a.Note)
+	if err := applyStockMovement(tx, a.ProductID, a.WarehouseID, a.Quantity,
+		a.TxType, a.RequestedBy, note, a.AdjustmentNumber); err != nil {
+		return fmt.Errorf("post adjustment %s: %w", a.AdjustmentNumber, err)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":    inventory.AdjustmentStatusPosted,
+		"posted_at": now,
+	}
+	if a.RequiresApproval {
+		updates["reviewed_by"] = reviewer
+		updates["reviewed_at"] = now
+		updates["review_note"] = reviewNote
+	}
+	return tx.Model(&inventory.StockAdjustment{}).Where("id = ?", a.ID).Updates(updates).Error
+}
+
+// lockAdjustment 在事务中以 FOR UPDATE 读取调整单，防止重复审批
+func lockAdjustment(tx *gorm.DB, id uint) (*inventory.StockAdjustment, error) {
+	var a inventory.StockAdjustment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&a, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ProductUnitValue 读取产品当前单价，用于计算调整金额
+func (r *AdjustmentRepository) ProductUnitValue(ctx context.Context, productID uint) (float64, error) {
+	var p catalog.Product
+	err := r.db.WithContext(ctx).Select("id", "price").First(&p, productID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrNotFound
+	}
+	return p.Price, err
+}
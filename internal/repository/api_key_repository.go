@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/pkg/scope"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository 系统间调用的 API Key
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create 保存新密钥；限定门店时该门店必须在 ctx 的数据范围内
+func (r *APIKeyRepository) Create(ctx context.Context, k *rbac.APIKey) error {
+	db := r.db.WithContext(ctx)
+	if k.StoreID != nil {
+		if err := scope.CheckStore(db, *k.StoreID); err != nil {
+			return err
+		}
+	}
+	return db.Create(k).Error
+}
+
+// FindByHash 按密钥哈希查询，不存在返回 ErrNotFound
+func (r *APIKeyRepository) FindByHash(ctx context.Context, hash string) (*rbac.APIKey, error) {
+	var k rbac.APIKey
+	err := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&k).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// List 列出密钥；userID 为 0 时列出所有人的
+func (r *APIKeyRepository) List(ctx context.Context, userID uint) ([]rbac.APIKey, error) {
+	q := r.db.WithContext(ctx).Order("id DESC")
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	var keys []rbac.APIKey
+	return keys, q.Find(&keys).Error
+}
+
+// Revoke 吊销密钥；userID 非 0 时只能吊销自己的。不存在或已吊销返回 ErrNotFound
+func (r *APIKeyRepository) Revoke(ctx context.Context, id, userID uint, now time.Time) (*rbac.APIKey, error) {
+	var k rbac.APIKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("id = ? AND revoked_at IS NULL", id)
+		if userID != 0 {
+			q = q.Where("user_id = ?", userID)
+		}
+		if err := q.First(&k).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		k.RevokedAt = &now
+		return tx.Model(&k).Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Touch 记录最近使用时间和来源 IP；距上次记录不足 minInterval 时不写库，避免每个请求都更新
+// 使用记录不进审计，否则每次调用都会往 audited_history 写一条
+func (r *APIKeyRepository) Touch(ctx context.Context, id uint, ip string, now time.Time, minInterval time.Duration) error {
+	return audit.Skip(r.db.WithContext(ctx)).Model(&rbac.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-minInterval)).
+		UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+}
+
+// CheckStore 门店是否在 ctx 的数据范围内，不在时返回 scope.ErrOutOfScope
+func (r *APIKeyRepository) CheckStore(ctx context.Context, storeID uint) error {
+	return scope.CheckStore(r.db.WithContext(ctx), storeID)
+}
@@ -0,0 +1,214 @@
+// internal/repository/audit_repository.go
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/rbac"
+	audit2 "djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/pkg/scope"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// restorableModels 可以按历史记录恢复的表及其模型；恢复走模型写入，GORM 审计回调会把恢复本身也记下来
+// 只开放主数据。库存、订单、报价、发票 / 收款 / 红字发票各有流水、状态机或编号规则，整行覆盖会绕过它们；
+// 用户和 API 密钥的停用、吊销状态恢复回去等于重新启用账号或密钥，同样不允许
+var restorableModels = map[audit.AuditedTableEnum]interface{}{
+	audit.AuditedTableRoles:       &rbac.Role{},
+	audit.AuditedTablePermissions: &rbac.Permission{},
+	audit.AuditedTableProducts:    &catalog.Product{},
+	audit.AuditedTableCustomers:   &catalog.Customer{},
+}
+
+// AuditRepository 审计历史查询和按历史记录恢复
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditFilter 审计记录筛选条件，零值表示不过滤
+type AuditFilter struct {
+	Table     audit.AuditedTableEnum
+	RecordID  uint
+	UserID    uint
+	StoreID   uint
+	Operation string
+	Start     *time.Time
+	End       *time.Time
+}
+
+// List 按条件分页查询，最新的在前；只能看到数据范围内门店的记录
+func (r *AuditRepository) List(ctx context.Context, f AuditFilter, offset, limit int) ([]audit.AuditedHistory, int64, error) {
+	var (
+		list  []audit.AuditedHistory
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&audit.AuditedHistory{}).Scopes(scope.ByStore("store_id"))
+	if f.Table != "" {
+		q = q.Where("table_name = ?", f.Table)
+	}
+	if f.RecordID != 0 {
+		q = q.Where("record_id = ?", f.RecordID)
+	}
+	if f.UserID != 0 {
+		q = q.Where("changed_by = ?", f.UserID)
+	}
+	if f.StoreID != 0 {
+		q = q.Where("store_id = ?", f.StoreID)
+	}
+	if f.Operation != "" {
+		q = q.Where("operation = ?", f.Operation)
+	}
+	if f.Start != nil {
+		q = q.Where("changed_at >= ?", *f.Start)
+	}
+	if f.End != nil {
+		q = q.Where("changed_at <= ?", *f.End)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("history_id DESC").Offset(offset).Limit(limit).Find(&list).Error
+	return list, total, err
+}
+
+// Timeline 一条记录的全部历史，按发生顺序
+func (r *AuditRepository) Timeline(ctx context.Context, table audit.AuditedTableEnum, recordID uint) ([]audit.AuditedHistory, error) {
+	var list []audit.AuditedHistory
+	err := r.db.WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
+		Where("table_name = ? AND record_id = ?", table, recordID).
+		Order("history_id").
+		Find(&list).Error
+	return list, err
+}
+
+// FindByID 读取一条审计记录
+func (r *AuditRepository) FindByID(ctx context.Context, id uint) (*audit.AuditedHistory, error) {
+	var h audit.AuditedHistory
+	err := r.db.WithContext(ctx).Scopes(scope.ByStore("store_id")).First(&h, "history_id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &h, err
+}
+
+// Restore 把记录恢复成 h 之后的状态：记录还在（含软删除）时整行覆盖，已物理删除时按原主键重新插入
+// 主键、敏感列、只读列和自动更新时间不恢复，version 列在当前值上加一，避免旧版本的客户端覆盖恢复结果
+// 表不支持恢复、或 h 没有变更后的整行（手动记录、物理删除）时返回 ErrInvalidState
+func (r *AuditRepository) Restore(ctx context.Context, h *audit.AuditedHistory) error {
+	proto, ok := restorableModels[h.TableName]
+	if !ok {
+		return fmt.Errorf("%w: table %s cannot be restored", ErrInvalidState, h.TableName)
+	}
+	var image map[string]json.RawMessage
+	if len(h.NewData) == 0 || json.Unmarshal(h.NewData, &image) != nil || image == nil {
+		return fmt.Errorf("%w: history entry %d has no row image to restore", ErrInvalidState, h.HistoryID)
+	}
+
+	db := r.db.WithContext(ctx)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(proto); err != nil {
+		return err
+	}
+	sch := stmt.Schema
+	pk := sch.PrioritizedPrimaryField
+
+	row := reflect.New(sch.ModelType)
+	if err := pk.Set(ctx, row.Elem(), h.RecordID); err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	for col, raw := range image {
+		f := sch.LookUpField(col)
+		if f == nil || f.DBName == "" || f.PrimaryKey || !f.Updatable || f.AutoUpdateTime > 0 || audit2.SensitiveColumn(col) {
+			continue
+		}
+		v := reflect.New(f.FieldType)
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return fmt.Errorf("restore column %s: %w", col, err)
+		}
+		if err := f.Set(ctx, row.Elem(), v.Elem().Interface()); err != nil {
+			return err
+		}
+		values[col] = v.Elem().Interface()
+	}
+	if _, ok := values["version"]; ok {
+		values["version"] = gorm.Expr("version + 1")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Unscoped().Model(proto).Where(pk.DBName+" = ?", h.RecordID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return tx.Omit(clause.Associations).Create(row.Interface()).Error
+		}
+		target := reflect.New(sch.ModelType)
+		if err := pk.Set(ctx, target.Elem(), h.RecordID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(target.Interface()).Updates(values).Error
+	})
+}
+
+// ChainHead 先把待上链的记录接上，再读当前链尾的记录 ID、哈希和链长；链为空时返回 ErrNotFound
+func (r *AuditRepository) ChainHead(ctx context.Context) (*audit.Checkpoint, error) {
+	if _, err := r.Seal(ctx); err != nil {
+		return nil, err
+	}
+	var last audit.AuditedHistory
+	err := r.db.WithContext(ctx).Where("chain_seq IS NOT NULL").Order("chain_seq DESC").First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &audit.Checkpoint{HistoryID: last.HistoryID, Hash: last.Hash, Count: *last.ChainSeq}, nil
+}
+
+// Seal 把已提交的待上链审计记录接到哈希链末尾
+func (r *AuditRepository) Seal(ctx context.Context) (int, error) {
+	return audit2.Seal(r.db.WithContext(ctx))
+}
+
+// CreateCheckpoint 保存签好名的检查点
+func (r *AuditRepository) CreateCheckpoint(ctx context.Context, c *audit.Checkpoint) error {
+	return r.db.WithContext(ctx).Create(c).Error
+}
+
+// LastCheckpoint 最近的检查点，没有时返回 ErrNotFound
+func (r *AuditRepository) LastCheckpoint(ctx context.Context) (*audit.Checkpoint, error) {
+	var c audit.Checkpoint
+	err := r.db.WithContext(ctx).Order("count DESC").First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &c, err
+}
+
+// ListCheckpoints 全部检查点，按链上位置先后
+func (r *AuditRepository) ListCheckpoints(ctx context.Context) ([]audit.Checkpoint, error) {
+	var list []audit.Checkpoint
+	err := r.db.WithContext(ctx).Order("count").Find(&list).Error
+	return list, err
+}
+
+// VerifyChain 逐条校验哈希链，want 里的记录会在报告里留下哈希
+func (r *AuditRepository) VerifyChain(ctx context.Context, want ...int) (*audit2.ChainReport, error) {
+	return audit2.VerifyChain(ctx, r.db, want...)
+}
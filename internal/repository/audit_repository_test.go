@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/catalog"
+	audit2 "djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+func TestRestoreFromHistory(t *testing.T) {
+	db := testdb.Open(t)
+	testdb.Exec(t, db, `CREATE TABLE customers (id integer primary key, store_id integer, type text, company text, name text,
+		phone text, email text, abn text, address text, version integer default 1, created_at datetime, updated_at datetime,
+		is_deleted numeric default false, contact text)`)
+	if err := db.AutoMigrate(&audit.AuditedHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit2.RegisterCallbacks(db, audit.AuditedTableCustomers); err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 9})
+	tx := db.WithContext(ctx)
+
+	cu := catalog.Customer{StoreID: 2, Name: "Acme", Phone: "111"}
+	if err := tx.Create(&cu).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Model(&cu).Updates(map[string]interface{}{"name": "Acme Pty", "phone": "222"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(&catalog.Customer{}, cu.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewAuditRepository(db)
+	hist, err := repo.Timeline(ctx, audit.AuditedTableCustomers, cu.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 3 {
+		t.Fatalf("timeline has %d entries, want 3", len(hist))
+	}
+
+	// 物理删除后恢复到更新之后的状态：按原主键重新插入
+	if err := repo.Restore(ctx, &hist[1]); err != nil {
+		t.Fatal(err)
+	}
+	var got catalog.Customer
+	if err := db.First(&got, cu.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Acme Pty" || got.Phone != "222" || got.StoreID != 2 {
+		t.Errorf("after restore = %+v", got)
+	}
+
+	// 再恢复到新建时的状态：整行覆盖，恢复本身也有行级记录
+	if err := repo.Restore(ctx, &hist[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&got, cu.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Acme" || got.Phone != "111" {
+		t.Errorf("after second restore = %+v", got)
+	}
+	hist, _ = repo.Timeline(ctx, audit.AuditedTableCustomers, cu.ID)
+	if len(hist) != 5 || hist[3].Operation != audit2.OpInsert || hist[4].Operation != audit2.OpUpdate {
+		t.Errorf("timeline after restores: %d entries", len(hist))
+	}
+
+	// 删除记录没有变更后的整行，不能作为恢复目标
+	if err := repo.Restore(ctx, &hist[2]); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("restore to delete entry: err = %v, want ErrInvalidState", err)
+	}
+
+	// 带流水或状态机的表、API 密钥不能恢复
+	for _, table := range []audit.AuditedTableEnum{audit.AuditedTableProductStocks, audit.AuditedTableOrders,
+		audit.AuditedTableTaxInvoices, audit.AuditedTablePayments, audit.AuditedTableAPIKeys} {
+		h := audit.AuditedHistory{TableName: table, RecordID: 1, NewData: hist[0].NewData}
+		if err := repo.Restore(ctx, &h); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("restore %s: err = %v, want ErrInvalidState", table, err)
+		}
+	}
+}
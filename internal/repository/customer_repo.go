@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/pkg/scope"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,10 +17,11 @@ func NewCustomerRepo(db *gorm.DB) *CustomerRepo {
 	return &CustomerRepo{db}
 }
 
-// GetAll 返回所有未删除的客户，预加载门店、地区和公司
-func (r *CustomerRepo) GetAll() ([]catalog.Customer, error) {
+// GetAll 返回数据范围内所有未删除的客户，预加载门店、地区和公司
+func (r *CustomerRepo) GetAll(ctx context.Context) ([]catalog.Customer, error) {
 	var cs []catalog.Customer
-	if err := r.db.
+	if err := r.db.WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
 		Preload("Store").
 		Preload("Store.Region").
 		Preload("Store.Company").
@@ -29,10 +32,11 @@ func (r *CustomerRepo) GetAll() ([]catalog.Customer, error) {
 	return cs, nil
 }
 
-// GetByID 查询单个客户
-func (r *CustomerRepo) GetByID(id uint) (*catalog.Customer, error) {
+// GetByID 查询单个客户；不在数据范围内的按不存在处理
+func (r *CustomerRepo) GetByID(ctx context.Context, id uint) (*catalog.Customer, error) {
 	var c catalog.Customer
-	if err := r.db.
+	if err := r.db.WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
 		Preload("Store").
 		Preload("Store.Region").
 		Preload("Store.Company").
@@ -42,25 +46,51 @@ func (r *CustomerRepo) GetByID(id uint) (*catalog.Customer, error) {
 	return &c, nil
 }
 
-// Create 新增客户
-func (r *CustomerRepo) Create(cust *catalog.Customer) error {
+// Create 新增客户；只能建在数据范围内的门店下
+func (r *CustomerRepo) Create(ctx context.Context, cust *catalog.Customer) error {
+	db := r.db.WithContext(ctx)
+	if err := scope.CheckStore(db, cust.StoreID); err != nil {
+		return err
+	}
 	cust.CreatedAt = time.Now()
 	cust.UpdatedAt = time.Now()
 	cust.IsDeleted = false
-	return r.db.Create(cust).Error
+	return db.Create(cust).Error
 }
 
-// Update 修改客户（软更新）
-func (r *CustomerRepo) Update(cust *catalog.Customer) error {
+// Update 修改客户（软更新）；改挂门店时目标门店也必须在数据范围内
+func (r *CustomerRepo) Update(ctx context.Context, cust *catalog.Customer) error {
+	db := r.db.WithContext(ctx)
+	if cust.StoreID != 0 {
+		if err := scope.CheckStore(db, cust.StoreID); err != nil {
+			return err
+		}
+	}
 	cust.UpdatedAt = time.Now()
-	return r.db.Model(&catalog.Customer{}).
+	res := db.Model(&catalog.Customer{}).
+		Scopes(scope.ByStore("store_id")).
 		Where("id = ?", cust.ID).
-		Updates(cust).Error
+		Updates(cust)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 // Delete 软删除客户
-func (r *CustomerRepo) Delete(id uint) error {
-	return r.db.Model(&catalog.Customer{}).
+func (r *CustomerRepo) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Model(&catalog.Customer{}).
+		Scopes(scope.ByStore("store_id")).
 		Where("id = ?", id).
-		Update("is_deleted", true).Error
+		Update("is_deleted", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
@@ -5,3 +5,12 @@ import "errors"
 
 // ErrNotFound 表示 RecordNotFound
 var ErrNotFound = errors.New("not found")
+
+// ErrInsufficientStock 表示库存（现有量/可用量/预留量）不足以完成操作
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInvalidState 表示单据当前状态不允许执行该操作
+var ErrInvalidState = errors.New("invalid state for this operation")
+
+// ErrInvalidInput 表示调用方传入的数据不合法（数量为负、超出单据金额等），需要结合库里的数据才能判断
+var ErrInvalidInput = errors.New("invalid input")
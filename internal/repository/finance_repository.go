@@ -0,0 +1,551 @@
+// internal/repository/finance_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/company"
+	"djj-inventory-system/internal/model/finance"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/scope"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FinanceRepository 税务发票、收款、贷项通知单
+// 发票 / 贷项通知单编号在同一事务内从 document_sequences 取号，按公司连续不跳号
+type FinanceRepository struct {
+	db *gorm.DB
+}
+
+func NewFinanceRepository(db *gorm.DB) *FinanceRepository {
+	return &FinanceRepository{db: db}
+}
+
+// InvoiceFilter 发票列表筛选条件，零值表示不过滤
+type InvoiceFilter struct {
+	Status      string
+	CustomerID  uint
+	StoreID     uint
+	OrderID     uint
+	Keyword     string // 发票号模糊匹配
+	Outstanding bool   // 只看仍有欠款的发票
+}
+
+// invoiceableOrderStatuses 允许开税务发票的订单状态
+var invoiceableOrderStatuses = map[string]bool{
+	sales.OrderStatusDelivered: true,
+	sales.OrderStatusClosed:    true,
+}
+
+// FindInvoice 读取发票及明细、收款、贷项通知单
+func (r *FinanceRepository) FindInvoice(ctx context.Context, id uint) (*finance.TaxInvoice, error) {
+	var inv finance.TaxInvoice
+	err := r.db.WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
+		Preload("Customer").
+		Preload("Order").
+		Preload("Order.SalesRepUser").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Lines.Product").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("received_at, id") }).
+		Preload("CreditNotes", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&inv, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// FindInvoiceIDByOrder 订单对应的发票 ID，未开票返回 ErrNotFound
+func (r *FinanceRepository) FindInvoiceIDByOrder(ctx context.Context, orderID uint) (uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Model(&finance.TaxInvoice{}).
+		Scopes(scope.ByStore("store_id")).
+		Where("order_id = ?", orderID).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, ErrNotFound
+	}
+	return ids[0], nil
+}
+
+// ListInvoices 分页列出发票（不含明细）
+func (r *FinanceRepository) ListInvoices(ctx context.Context, f InvoiceFilter, offset, limit int) ([]finance.TaxInvoice, int64, error) {
+	var (
+		list  []finance.TaxInvoice
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&finance.TaxInvoice{}).Scopes(scope.ByStore("store_id"))
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.CustomerID != 0 {
+		q = q.Where("customer_id = ?", f.CustomerID)
+	}
+	if f.StoreID != 0 {
+		q = q.Where("store_id = ?", f.StoreID)
+	}
+	if f.OrderID != 0 {
+		q = q.Where("order_id = ?", f.OrderID)
+	}
+	if f.Keyword != "" {
+		q = q.Where("invoice_number ILIKE ?", "%"+f.Keyword+"%")
+	}
+	if f.Outstanding {
+		q = q.Where("balance_due > 0")
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("Customer").
+		Order("issue_date DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// CreateInvoice 为已交付的订单开税务发票
+//   - 一张订单只能开一张发票
+//   - 明细和金额从订单复制，之后订单变化不影响发票
+//   - 开票前已收的定金 / 尾款关联到新发票，余额随之计算
+func (r *FinanceRepository) CreateInvoice(ctx context.Context, orderID uint, issueDate, dueDate time.Time, createdBy uint) (uint, error) {
+	var id uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		o, err := lockOrderForFinance(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if !invoiceableOrderStatuses[o.Status] {
+			return fmt.Errorf("%w: order %s is %s, only delivered orders can be invoiced", ErrInvalidState, o.OrderNumber, o.Status)
+		}
+		var exists int64
+		if err := tx.Model(&finance.TaxInvoice{}).Where("order_id = ?", o.ID).Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			return fmt.Errorf("%w: order %s has already been invoiced", ErrInvalidState, o.OrderNumber)
+		}
+
+		var items []sales.OrderItem
+		if err := tx.Preload("Product").Where("order_id = ?", o.ID).Order("id").Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("%w: order %s has no items", ErrInvalidState, o.OrderNumber)
+		}
+
+		inv := &finance.TaxInvoice{
+			OrderID:    o.ID,
+			CustomerID: o.CustomerID,
+			StoreID:    o.StoreID,
+			IssueDate:  issueDate,
+			DueDate:    dueDate,
+			Currency:   o.Currency,
+			CreatedBy:  createdBy,
+		}
+		for _, it := range items {
+			line := finance.TaxInvoiceLine{
+				OrderItemID: it.ID,
+				ProductID:   it.ProductID,
+				Description: productDescription(it.Product),
+				Quantity:    it.Quantity,
+				UnitPrice:   it.UnitPrice,
+				LineTotal:   sales.RoundMoney(float64(it.Quantity) * it.UnitPrice),
+			}
+			inv.SubTotal += line.LineTotal
+			inv.Lines = append(inv.Lines, line)
+		}
+		inv.SubTotal = sales.RoundMoney(inv.SubTotal)
+		inv.GSTTotal = sales.GSTOf(inv.SubTotal)
+		inv.TotalAmount = inv.SubTotal + inv.GSTTotal
+		inv.Settle(0, 0)
+
+		co, err := storeCompany(tx, o.StoreID)
+		if err != nil {
+			return err
+		}
+		seq, err := nextDocumentSequence(tx, co.ID, finance.DocTypeTaxInvoice)
+		if err != nil {
+			return err
+		}
+		inv.CompanyID = co.ID
+		inv.Sequence = seq
+		inv.InvoiceNumber = documentNumber(co.Code, finance.DocTypeTaxInvoice, seq)
+
+		if err := tx.Omit("Order", "Customer", "Lines.Product", "Payments", "CreditNotes").Create(inv).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&finance.Payment{}).
+			Where("order_id = ? AND tax_invoice_id IS NULL", o.ID).
+			Update("tax_invoice_id", inv.ID).Error; err != nil {
+			return err
+		}
+		id = inv.ID
+		return refreshInvoice(tx, inv.ID)
+	})
+	return id, err
+}
+
+// ListPayments 订单的全部收款（含退款）
+func (r *FinanceRepository) ListPayments(ctx context.Context, orderID uint) ([]finance.Payment, error) {
+	var list []finance.Payment
+	err := r.db.WithContext(ctx).
+		Scopes(scope.ByOrder("order_id")).
+		Where("order_id = ?", orderID).Order("received_at, id").Find(&list).Error
+	return list, err
+}
+
+// RecordPayment 登记一笔定金或尾款，并在同一个事务里推进订单状态（规则见 paymentOrderStatus）
+//   - 草稿 / 已取消的订单不能收款
+//   - 定金只能在 ordered / deposit_received 阶段收，且必须小于未付金额
+//   - 尾款必须正好结清未付金额
+//
+// 未付金额：已开票按发票余额（已扣除贷项通知单），否则按订单总额减已收
+// 状态推进失败时收款一起回滚；订单状态没变时返回的 OrderTransition 为 nil
+func (r *FinanceRepository) RecordPayment(ctx context.Context, p *finance.Payment, operator string) (*OrderTransition, error) {
+	var out *OrderTransition
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		o, err := lockOrderForFinance(tx, p.OrderID)
+		if err != nil {
+			return err
+		}
+		if o.Status == sales.OrderStatusDraft || o.Status == sales.OrderStatusCancelled {
+			return fmt.Errorf("%w: order %s is %s and cannot take payments", ErrInvalidState, o.OrderNumber, o.Status)
+		}
+
+		outstanding, invoiceID, err := orderOutstanding(tx, o)
+		if err != nil {
+			return err
+		}
+		amount := sales.RoundMoney(p.Amount)
+		switch p.Kind {
+		case finance.PaymentKindDeposit:
+			if o.Status != sales.OrderStatusOrdered && o.Status != sales.OrderStatusDepositReceived {
+				return fmt.Errorf("%w: order %s is %s, deposits are only taken before final payment", ErrInvalidState, o.OrderNumber, o.Status)
+			}
+			if amount >= outstanding {
+				return fmt.Errorf("%w: deposit %.2f must be less than the outstanding %.2f, record a final payment instead", ErrInvalidInput, amount, outstanding)
+			}
+		case finance.PaymentKindFinal:
+			if outstanding <= 0 {
+				return fmt.Errorf("%w: order %s has nothing outstanding", ErrInvalidState, o.OrderNumber)
+			}
+			if amount != outstanding {
+				return fmt.Errorf("%w: final payment %.2f must settle the outstanding %.2f", ErrInvalidInput, amount, outstanding)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported payment kind %q", ErrInvalidInput, p.Kind)
+		}
+
+		p.Amount = amount
+		p.TaxInvoiceID = invoiceID
+		p.CreditNoteID = nil
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		if invoiceID != nil {
+			if err := refreshInvoice(tx, *invoiceID); err != nil {
+				return err
+			}
+		}
+		if to := paymentOrderStatus(o.Status, p.Kind); to != "" {
+			// 付款后的状态只清预留有效期，不新建预留，ttl 用不到
+			out, err = transitionOrder(tx, o, to, operator, p.RecordedBy, 0)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// paymentOrderStatus 收款后订单应推进到的状态，不需要推进时返回空串：
+//   - 定金：ordered → deposit_received
+//   - 尾款：ordered / deposit_received → final_payment_received
+//
+// 订单已过这些阶段（如已交付后补收尾款）时只登记收款，不改状态
+func paymentOrderStatus(current, kind string) string {
+	switch {
+	case kind == finance.PaymentKindDeposit && current == sales.OrderStatusOrdered:
+		return sales.OrderStatusDepositReceived
+	case kind == finance.PaymentKindFinal &&
+		(current == sales.OrderStatusOrdered || current == sales.OrderStatusDepositReceived):
+		return sales.OrderStatusFinalPaymentReceived
+	}
+	return ""
+}
+
+// CreditNoteLineInput 贷项通知单的一行：TaxInvoiceLineID 非空时按发票行退货，否则为金额调整
+type CreditNoteLineInput struct {
+	TaxInvoiceLineID *uint
+	Description      string
+	Quantity         int
+	UnitPrice        float64
+}
+
+// CreateCreditNote 为发票开贷项通知单，refund > 0 时同时登记一笔负数退款
+//   - 按发票行退货的数量累计不能超过开票数量，单价取发票行单价
+//   - 贷记金额（含税）不能超过发票剩余应收（总额 - 已贷记）
+//   - 退款不能超过已收金额，也不能超过本次贷记金额
+func (r *FinanceRepository) CreateCreditNote(ctx context.Context, invoiceID uint, lines []CreditNoteLineInput, reason string, refund float64, method, reference string, createdBy uint) (uint, error) {
+	var id uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var inv finance.TaxInvoice
+		err := tx.Scopes(scope.ByStore("store_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, invoiceID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		cn := &finance.CreditNote{
+			TaxInvoiceID: inv.ID,
+			IssueDate:    time.Now(),
+			Reason:       reason,
+			RefundAmount: sales.RoundMoney(refund),
+			CreatedBy:    createdBy,
+		}
+		for _, in := range lines {
+			ln := finance.CreditNoteLine{
+				TaxInvoiceLineID: in.TaxInvoiceLineID,
+				Description:      in.Description,
+				Quantity:         in.Quantity,
+				UnitPrice:        in.UnitPrice,
+			}
+			if in.TaxInvoiceLineID != nil {
+				il, err := creditableInvoiceLine(tx, inv.ID, *in.TaxInvoiceLineID, in.Quantity)
+				if err != nil {
+					return err
+				}
+				ln.ProductID = &il.ProductID
+				ln.UnitPrice = il.UnitPrice
+				if ln.Description == "" {
+					ln.Description = il.Description
+				}
+			}
+			ln.LineTotal = sales.RoundMoney(float64(ln.Quantity) * ln.UnitPrice)
+			cn.SubTotal += ln.LineTotal
+			cn.Lines = append(cn.Lines, ln)
+		}
+		cn.SubTotal = sales.RoundMoney(cn.SubTotal)
+		cn.GSTTotal = sales.GSTOf(cn.SubTotal)
+		cn.TotalAmount = cn.SubTotal + cn.GSTTotal
+
+		if cn.TotalAmount <= 0 {
+			return fmt.Errorf("%w: credit note total must be positive", ErrInvalidInput)
+		}
+		if remaining := inv.NetAmount(); cn.TotalAmount > remaining {
+			return fmt.Errorf("%w: credit %.2f exceeds the remaining invoice amount %.2f", ErrInvalidInput, cn.TotalAmount, remaining)
+		}
+		if cn.RefundAmount > cn.TotalAmount {
+			return fmt.Errorf("%w: refund %.2f exceeds the credit amount %.2f", ErrInvalidInput, cn.RefundAmount, cn.TotalAmount)
+		}
+		if cn.RefundAmount > inv.AmountPaid {
+			return fmt.Errorf("%w: refund %.2f exceeds the amount paid %.2f", ErrInvalidInput, cn.RefundAmount, inv.AmountPaid)
+		}
+
+		seq, err := nextDocumentSequence(tx, inv.CompanyID, finance.DocTypeCreditNote)
+		if err != nil {
+			return err
+		}
+		var co company.Company
+		if err := tx.First(&co, inv.CompanyID).Error; err != nil {
+			return err
+		}
+		cn.CompanyID = inv.CompanyID
+		cn.Sequence = seq
+		cn.CreditNoteNumber = documentNumber(co.Code, finance.DocTypeCreditNote, seq)
+		if err := tx.Omit("TaxInvoice", "Lines.Product").Create(cn).Error; err != nil {
+			return err
+		}
+
+		if cn.RefundAmount > 0 {
+			p := &finance.Payment{
+				OrderID:      inv.OrderID,
+				TaxInvoiceID: &inv.ID,
+				CreditNoteID: &cn.ID,
+				Kind:         finance.PaymentKindRefund,
+				Amount:       -cn.RefundAmount,
+				Method:       method,
+				Reference:    reference,
+				Note:         fmt.Sprintf("贷项通知单 %s 退款", cn.CreditNoteNumber),
+				ReceivedAt:   cn.IssueDate,
+				RecordedBy:   createdBy,
+			}
+			if err := tx.Create(p).Error; err != nil {
+				return err
+			}
+		}
+		id = cn.ID
+		return refreshInvoice(tx, inv.ID)
+	})
+	return id, err
+}
+
+// FindCreditNote 读取贷项通知单及明细、所属发票
+func (r *FinanceRepository) FindCreditNote(ctx context.Context, id uint) (*finance.CreditNote, error) {
+	var cn finance.CreditNote
+	err := r.db.WithContext(ctx).
+		Scopes(scope.ByParent("tax_invoice_id", "tax_invoices")).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Lines.Product").
+		Preload("TaxInvoice").
+		Preload("TaxInvoice.Customer").
+		Preload("TaxInvoice.Order").
+		Preload("TaxInvoice.Order.SalesRepUser").
+		First(&cn, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cn, nil
+}
+
+// lockOrderForFinance 以 FOR UPDATE 读取订单；收款 / 开票都先锁订单，避免并发重复结清
+// 数据范围外的订单按不存在处理
+func lockOrderForFinance(tx *gorm.DB, id uint) (*sales.Order, error) {
+	var o sales.Order
+	err := tx.Scopes(scope.ByStore("store_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// orderOutstanding 订单未付金额；已开票时同时返回发票 ID
+func orderOutstanding(tx *gorm.DB, o *sales.Order) (float64, *uint, error) {
+	var inv finance.TaxInvoice
+	err := tx.Where("order_id = ?", o.ID).First(&inv).Error
+	if err == nil {
+		return inv.BalanceDue, &inv.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, err
+	}
+	var paid float64
+	if err := tx.Model(&finance.Payment{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("order_id = ?", o.ID).
+		Scan(&paid).Error; err != nil {
+		return 0, nil, err
+	}
+	return sales.RoundMoney(o.TotalAmount - paid), nil, nil
+}
+
+// creditableInvoiceLine 校验发票行存在且累计退货数量不超过开票数量
+func creditableInvoiceLine(tx *gorm.DB, invoiceID, lineID uint, qty int) (*finance.TaxInvoiceLine, error) {
+	var il finance.TaxInvoiceLine
+	err := tx.Where("id = ? AND tax_invoice_id = ?", lineID, invoiceID).First(&il).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: invoice line %d", ErrNotFound, lineID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var credited int
+	if err := tx.Model(&finance.CreditNoteLine{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("tax_invoice_line_id = ?", lineID).
+		Scan(&credited).Error; err != nil {
+		return nil, err
+	}
+	if credited+qty > il.Quantity {
+		return nil, fmt.Errorf("%w: invoice line %d has %d left to credit, requested %d", ErrInvalidInput, lineID, il.Quantity-credited, qty)
+	}
+	return &il, nil
+}
+
+// refreshInvoice 按收款和贷项通知单重算发票的已收、已贷记、余额和状态
+func refreshInvoice(tx *gorm.DB, id uint) error {
+	var inv finance.TaxInvoice
+	if err := tx.First(&inv, id).Error; err != nil {
+		return err
+	}
+	var paid, credited float64
+	if err := tx.Model(&finance.Payment{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("tax_invoice_id = ?", id).
+		Scan(&paid).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&finance.CreditNote{}).
+		Select("COALESCE(SUM(total_amount), 0)").
+		Where("tax_invoice_id = ?", id).
+		Scan(&credited).Error; err != nil {
+		return err
+	}
+	inv.Settle(paid, credited)
+	return tx.Model(&finance.TaxInvoice{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"amount_paid":     inv.AmountPaid,
+		"credited_amount": inv.CreditedAmount,
+		"balance_due":     inv.BalanceDue,
+		"status":          inv.Status,
+		"updated_at":      time.Now(),
+	}).Error
+}
+
+// nextDocumentSequence 取公司下一个单据号；必须在业务事务内调用
+// 号码行被 FOR UPDATE 锁到事务结束，业务失败回滚时号码一并回滚，因此不会跳号
+func nextDocumentSequence(tx *gorm.DB, companyID uint, docType string) (int64, error) {
+	seq := finance.DocumentSequence{CompanyID: companyID, DocType: docType, NextValue: 1}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("company_id = ? AND doc_type = ?", companyID, docType).
+		First(&seq).Error; err != nil {
+		return 0, err
+	}
+	n := seq.NextValue
+	if err := tx.Model(&finance.DocumentSequence{}).
+		Where("company_id = ? AND doc_type = ?", companyID, docType).
+		Update("next_value", n+1).Error; err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// documentNumber 生成单据号，如 DJJ-INV-000001；公司没有编码时省略前缀
+func documentNumber(companyCode, docType string, seq int64) string {
+	if companyCode == "" {
+		return fmt.Sprintf("%s-%06d", docType, seq)
+	}
+	return fmt.Sprintf("%s-%s-%06d", companyCode, docType, seq)
+}
+
+// storeCompany 门店所属公司
+func storeCompany(tx *gorm.DB, storeID uint) (*company.Company, error) {
+	var st catalog.Store
+	if err := tx.Unscoped().Preload("Company").First(&st, storeID).Error; err != nil {
+		return nil, err
+	}
+	return &st.Company, nil
+}
+
+// productDescription 发票行描述，英文名优先
+func productDescription(p catalog.Product) string {
+	if p.NameEN != "" {
+		return p.NameEN
+	}
+	return p.NameCN
+}
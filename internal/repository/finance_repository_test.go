@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/finance"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+// 收款和订单状态在同一个事务里提交；金额不合法按输入错误返回，收款和状态都不变
+func TestRecordPaymentAdvancesOrder(t *testing.T) {
+	db := newOrderTestDB(t)
+	if err := db.AutoMigrate(&finance.Payment{}); err != nil {
+		t.Fatal(err)
+	}
+	testdb.Exec(t, db,
+		`CREATE TABLE tax_invoices (id INTEGER PRIMARY KEY, order_id INTEGER, balance_due NUMERIC)`,
+		`ALTER TABLE orders ADD COLUMN total_amount NUMERIC`,
+	)
+	seedStock(t, db, 1, 1, 10, 0)
+	seedOrder(t, db, 1, "SO-1", [2]int{1, 2})
+	testdb.Exec(t, db, `UPDATE orders SET total_amount = 100 WHERE id = 1`)
+	walkOrder(t, NewReservationRepository(db), 1, time.Hour, sales.OrderStatusOrdered)
+	repo := NewFinanceRepository(db)
+	ctx := context.Background()
+
+	payment := func(kind string, amount float64) *finance.Payment {
+		return &finance.Payment{OrderID: 1, Kind: kind, Amount: amount, ReceivedAt: time.Now(), RecordedBy: 1}
+	}
+	orderStatus := func() string {
+		var o sales.Order
+		if err := db.First(&o, 1).Error; err != nil {
+			t.Fatal(err)
+		}
+		return o.Status
+	}
+
+	if _, err := repo.RecordPayment(ctx, payment(finance.PaymentKindDeposit, 100), "tester"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("deposit settling the order: err = %v, want ErrInvalidInput", err)
+	}
+	if _, err := repo.RecordPayment(ctx, payment(finance.PaymentKindFinal, 60), "tester"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("short final payment: err = %v, want ErrInvalidInput", err)
+	}
+	if s := orderStatus(); s != sales.OrderStatusOrdered {
+		t.Errorf("status after rejected payments = %s, want ordered", s)
+	}
+
+	tr, err := repo.RecordPayment(ctx, payment(finance.PaymentKindDeposit, 30), "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr == nil || tr.From != sales.OrderStatusOrdered || tr.To != sales.OrderStatusDepositReceived {
+		t.Errorf("deposit transition = %+v", tr)
+	}
+	if s := orderStatus(); s != sales.OrderStatusDepositReceived {
+		t.Errorf("status after deposit = %s, want deposit_received", s)
+	}
+	if res := reservationsOf(t, db, 1); len(res) != 1 || res[0].ExpiresAt != nil {
+		t.Errorf("reservations after deposit = %+v, want one without expiry", res)
+	}
+
+	// 第二笔定金：状态已经是 deposit_received，不再推进
+	if tr, err := repo.RecordPayment(ctx, payment(finance.PaymentKindDeposit, 20), "tester"); err != nil || tr != nil {
+		t.Errorf("second deposit: transition %+v, err %v", tr, err)
+	}
+	if tr, err := repo.RecordPayment(ctx, payment(finance.PaymentKindFinal, 50), "tester"); err != nil || tr == nil || tr.To != sales.OrderStatusFinalPaymentReceived {
+		t.Errorf("final payment: transition %+v, err %v", tr, err)
+	}
+	var n int64
+	db.Model(&finance.Payment{}).Count(&n)
+	if n != 3 {
+		t.Errorf("payments = %d, want 3", n)
+	}
+}
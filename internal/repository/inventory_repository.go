@@ -4,12 +4,16 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"djj-inventory-system/internal/model/catalog"
 	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/outbox"
+	outbox2 "djj-inventory-system/internal/pkg/outbox"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type InventoryRepository struct {
@@ -212,6 +216,19 @@ func (r *InventoryRepository) GetTransactionsByWarehouse(ctx context.Context, wa
 	return transactions, total, err
 }
 
+// GetTransactionsByReference 根据关联单据号获取事务记录（如调拨单两端的流水）
+func (r *InventoryRepository) GetTransactionsByReference(ctx context.Context, reference string) ([]inventory.InventoryTransaction, error) {
+	var transactions []inventory.InventoryTransaction
+	err := r.db.WithContext(ctx).
+		Preload("Inventory").
+		Preload("Inventory.Product").
+		Preload("Inventory.Warehouse").
+		Where("reference = ?", reference).
+		Order("created_at ASC").
+		Find(&transactions).Error
+	return transactions, err
+}
+
 // GetTransactionsByDateRange 根据时间范围获取事务记录
 func (r *InventoryRepository) GetTransactionsByDateRange(ctx context.Context, startDate, endDate time.Time, offset, limit int) ([]inventory.InventoryTransaction, int64, error) {
 	var transactions []inventory.InventoryTransaction
@@ -238,138 +255,194 @@ func (r *InventoryRepository) GetTransactionsByDateRange(ctx context.Context, st
 // ProcessStockMovement 处理库存移动（带事务记录）
 func (r *InventoryRepository) ProcessStockMovement(ctx context.Context, productID, warehouseID uint, quantity int, txType inventory.TransactionType, operator, note string) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 1. 更新库存
-		var onHandDelta, reservedDelta int
-		switch txType {
-		case inventory.TransactionTypeIn:
-			onHandDelta = quantity
-		case inventory.TransactionTypeOut, inventory.TransactionTypeSale:
-			onHandDelta = -quantity
-		default:
-			return errors.New("invalid transaction type")
+		return applyStockMovement(tx, productID, warehouseID, quantity, txType, operator, note, "")
+	})
+}
+
+// StockMovement 批量库存操作中的一条
+type StockMovement struct {
+	ProductID   uint
+	WarehouseID uint
+	Quantity    int
+	TxType      inventory.TransactionType
+	Note        string
+}
+
+// ProcessStockMovements 在一个事务里依次处理多条库存操作，任一条失败则整批回滚
+func (r *InventoryRepository) ProcessStockMovements(ctx context.Context, moves []StockMovement, operator string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, m := range moves {
+			if err := applyStockMovement(tx, m.ProductID, m.WarehouseID, m.Quantity, m.TxType, operator, m.Note, ""); err != nil {
+				return fmt.Errorf("update %d (product %d): %w", i, m.ProductID, err)
+			}
 		}
+		return nil
+	})
+}
 
-		// 检查并更新库存
-		var stock catalog.ProductStock
-		if err := tx.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
-			First(&stock).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				// 创建新库存记录
-				stock = catalog.ProductStock{
-					ProductID:   productID,
-					WarehouseID: warehouseID,
-					OnHand:      0,
-					Reserved:    0,
-				}
-				if err := tx.Create(&stock).Error; err != nil {
-					return err
-				}
-			} else {
+// applyStockMovement 在已开启的事务 tx 中更新现有量并写一条流水
+// 供调拨、盘点等需要在同一个事务里处理多条明细的场景复用
+func applyStockMovement(tx *gorm.DB, productID, warehouseID uint, quantity int, txType inventory.TransactionType, operator, note, reference string) error {
+	// 1. 更新库存
+	// 只有影响现有量的类型可以走这里；预留 / 释放有单独的方法
+	dir := inventory.GetImpactDirection(txType)
+	if dir == 0 {
+		return errors.New("invalid transaction type")
+	}
+	if quantity == 0 || (quantity < 0 && txType != inventory.TransactionTypeAdjust) {
+		return errors.New("invalid quantity")
+	}
+	onHandDelta := dir * quantity
+
+	// 检查并更新库存；行锁与 applyReservation / applyRelease 一致，并发单据按行排队
+	var stock catalog.ProductStock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+		First(&stock).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 创建新库存记录
+			stock = catalog.ProductStock{
+				ProductID:   productID,
+				WarehouseID: warehouseID,
+				OnHand:      0,
+				Reserved:    0,
+			}
+			if err := tx.Create(&stock).Error; err != nil {
 				return err
 			}
+		} else {
+			return err
 		}
+	}
 
-		// 检查库存是否足够
-		if stock.OnHand+onHandDelta < 0 {
-			return errors.New("insufficient stock")
-		}
+	// 出库只能动用未预留的部分，已预留给订单的库存要先释放
+	if onHandDelta < 0 && stock.OnHand-stock.Reserved+onHandDelta < 0 {
+		return fmt.Errorf("%w: available stock is not enough", ErrInsufficientStock)
+	}
 
-		// 更新库存
-		if err := tx.Model(&stock).Updates(map[string]interface{}{
-			"on_hand":    stock.OnHand + onHandDelta,
-			"reserved":   stock.Reserved + reservedDelta,
+	// 更新库存：增量写入，并在 WHERE 里再校验一次可用量
+	res := tx.Model(&catalog.ProductStock{}).
+		Where("id = ?", stock.ID).
+		Where("? >= 0 OR on_hand - reserved + ? >= 0", onHandDelta, onHandDelta).
+		Updates(map[string]interface{}{
+			"on_hand":    gorm.Expr("on_hand + ?", onHandDelta),
 			"updated_at": time.Now(),
-		}).Error; err != nil {
-			return err
-		}
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: available stock is not enough", ErrInsufficientStock)
+	}
 
-		// 2. 创建事务记录
-		transaction := &inventory.InventoryTransaction{
-			InventoryID: stock.ID,
-			TxType:      txType,
-			Quantity:    quantity,
-			Operator:    operator,
-			Note:        note,
-			CreatedAt:   time.Now(),
-		}
+	// 2. 创建事务记录
+	transaction := &inventory.InventoryTransaction{
+		InventoryID: stock.ID,
+		TxType:      txType,
+		Quantity:    quantity,
+		Operator:    operator,
+		Note:        note,
+		Reference:   reference,
+		CreatedAt:   time.Now(),
+	}
+	if err := tx.Create(transaction).Error; err != nil {
+		return err
+	}
 
-		return tx.Create(transaction).Error
+	// 3. 领域事件，随业务事务提交；仓库不属于门店，StoreID 为 0
+	return outbox2.Add(tx, &outbox.Event{Type: outbox.TypeStockMoved, Entity: "product", EntityID: productID}, StockMoved{
+		ProductID:   productID,
+		WarehouseID: warehouseID,
+		Type:        txType,
+		Quantity:    quantity,
+		OnHand:      stock.OnHand + onHandDelta,
+		Reference:   reference,
 	})
 }
 
-// ReserveStock 预留库存
-func (r *InventoryRepository) ReserveStock(ctx context.Context, productID, warehouseID uint, quantity int, operator, note string) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 获取当前库存
-		var stock catalog.ProductStock
-		if err := tx.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
-			First(&stock).Error; err != nil {
-			return err
-		}
+// StockMoved stock.moved 事件的负载
+type StockMoved struct {
+	ProductID   uint                      `json:"productId"`
+	WarehouseID uint                      `json:"warehouseId"`
+	Type        inventory.TransactionType `json:"type"`
+	Quantity    int                       `json:"quantity"`
+	OnHand      int                       `json:"onHand"`
+	Reference   string                    `json:"reference,omitempty"`
+}
 
-		// 检查可用库存是否足够
-		available := stock.OnHand - stock.Reserved
-		if available < quantity {
-			return errors.New("insufficient available stock for reservation")
-		}
+// applyReservation 在已开启的事务 tx 中增加预留量并写一条 RESERVE 流水
+func applyReservation(tx *gorm.DB, productID, warehouseID uint, quantity int, operator, note, reference string) error {
+	// 获取当前库存
+	var stock catalog.ProductStock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+		First(&stock).Error; err != nil {
+		return err
+	}
 
-		// 更新预留量
-		if err := tx.Model(&stock).Updates(map[string]interface{}{
-			"reserved":   stock.Reserved + quantity,
-			"updated_at": time.Now(),
-		}).Error; err != nil {
-			return err
-		}
+	// 检查可用库存是否足够
+	available := stock.OnHand - stock.Reserved
+	if available < quantity {
+		return fmt.Errorf("%w: available stock is not enough for reservation", ErrInsufficientStock)
+	}
 
-		// 创建事务记录
-		transaction := &inventory.InventoryTransaction{
-			InventoryID: stock.ID,
-			TxType:      inventory.TransactionTypeReserve,
-			Quantity:    quantity,
-			Operator:    operator,
-			Note:        note,
-			CreatedAt:   time.Now(),
-		}
+	// 更新预留量
+	if err := tx.Model(&stock).Updates(map[string]interface{}{
+		"reserved":   stock.Reserved + quantity,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
 
-		return tx.Create(transaction).Error
-	})
+	// 创建事务记录
+	transaction := &inventory.InventoryTransaction{
+		InventoryID: stock.ID,
+		TxType:      inventory.TransactionTypeReserve,
+		Quantity:    quantity,
+		Operator:    operator,
+		Note:        note,
+		Reference:   reference,
+		CreatedAt:   time.Now(),
+	}
+
+	return tx.Create(transaction).Error
 }
 
-// ReleaseReservedStock 释放预留库存
-func (r *InventoryRepository) ReleaseReservedStock(ctx context.Context, productID, warehouseID uint, quantity int, operator, note string) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 获取当前库存
-		var stock catalog.ProductStock
-		if err := tx.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
-			First(&stock).Error; err != nil {
-			return err
-		}
+// applyRelease 在已开启的事务 tx 中减少预留量并写一条 RELEASE 流水
+func applyRelease(tx *gorm.DB, productID, warehouseID uint, quantity int, operator, note, reference string) error {
+	// 获取当前库存
+	var stock catalog.ProductStock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+		First(&stock).Error; err != nil {
+		return err
+	}
 
-		// 检查预留量是否足够
-		if stock.Reserved < quantity {
-			return errors.New("insufficient reserved stock to release")
-		}
+	// 检查预留量是否足够
+	if stock.Reserved < quantity {
+		return fmt.Errorf("%w: reserved stock is not enough to release", ErrInsufficientStock)
+	}
 
-		// 更新预留量
-		if err := tx.Model(&stock).Updates(map[string]interface{}{
-			"reserved":   stock.Reserved - quantity,
-			"updated_at": time.Now(),
-		}).Error; err != nil {
-			return err
-		}
+	// 更新预留量
+	if err := tx.Model(&stock).Updates(map[string]interface{}{
+		"reserved":   stock.Reserved - quantity,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
 
-		// 创建事务记录
-		transaction := &inventory.InventoryTransaction{
-			InventoryID: stock.ID,
-			TxType:      inventory.TransactionTypeRelease,
-			Quantity:    quantity,
-			Operator:    operator,
-			Note:        note,
-			CreatedAt:   time.Now(),
-		}
+	// 创建事务记录
+	transaction := &inventory.InventoryTransaction{
+		InventoryID: stock.ID,
+		TxType:      inventory.TransactionTypeRelease,
+		Quantity:    quantity,
+		Operator:    operator,
+		Note:        note,
+		Reference:   reference,
+		CreatedAt:   time.Now(),
+	}
 
-		return tx.Create(transaction).Error
-	})
+	return tx.Create(transaction).Error
 }
 
 // GetInventorySummary 获取库存汇总信息
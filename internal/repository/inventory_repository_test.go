@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/outbox"
+	"djj-inventory-system/internal/pkg/testdb"
+
+	"gorm.io/gorm"
+)
+
+// newStockTestDB 库存、流水和领域事件表，再加上各单据自己的表
+func newStockTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	return testdb.Open(t, append([]interface{}{&catalog.ProductStock{}, &inventory.InventoryTransaction{}, &outbox.Event{}}, models...)...)
+}
+
+func seedStock(t *testing.T, db *gorm.DB, productID, warehouseID uint, onHand, reserved int) {
+	t.Helper()
+	if err := db.Create(&catalog.ProductStock{ProductID: productID, WarehouseID: warehouseID, OnHand: onHand, Reserved: reserved}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func stockOf(t *testing.T, db *gorm.DB, productID, warehouseID uint) catalog.ProductStock {
+	t.Helper()
+	var s catalog.ProductStock
+	if err := db.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).First(&s).Error; err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// ledger 按单据号列出流水类型和数量，如 ["TRANSFER_OUT:6"]
+func ledger(t *testing.T, db *gorm.DB, reference string) []string {
+	t.Helper()
+	var txs []inventory.InventoryTransaction
+	if err := db.Where("reference = ?", reference).Order("id").Find(&txs).Error; err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, len(txs))
+	for i, tx := range txs {
+		out[i] = fmt.Sprintf("%s:%d", tx.TxType, tx.Quantity)
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 出库只能动用未预留的部分；每次移动写一条流水和一条 stock.moved 事件
+func TestStockMovementRespectsReserved(t *testing.T) {
+	db := newStockTestDB(t)
+	seedStock(t, db, 1, 1, 10, 6)
+
+	for _, tc := range []struct {
+		typ  inventory.TransactionType
+		qty  int
+		want int // 之后的现有量，-1 表示应当库存不足
+	}{
+		{inventory.TransactionTypeOut, 5, -1},
+		{inventory.TransactionTypeAdjust, -5, -1},
+		{inventory.TransactionTypeOut, 4, 6},
+		{inventory.TransactionTypeIn, 3, 9},
+		{inventory.TransactionTypeAdjust, -3, 6},
+		{inventory.TransactionTypeDamage, 1, -1},
+	} {
+		err := applyStockMovement(db, 1, 1, tc.qty, tc.typ, "tester", "", "REF")
+		if tc.want < 0 {
+			if !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("%s %d: err = %v, want ErrInsufficientStock", tc.typ, tc.qty, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s %d: %v", tc.typ, tc.qty, err)
+		}
+		if s := stockOf(t, db, 1, 1); s.OnHand != tc.want || s.Reserved != 6 {
+			t.Errorf("%s %d: stock = %d/%d, want %d/6", tc.typ, tc.qty, s.OnHand, s.Reserved, tc.want)
+		}
+	}
+
+	if got := ledger(t, db, "REF"); !equalStrings(got, []string{"OUT:4", "IN:3", "ADJUST:-3"}) {
+		t.Errorf("ledger = %v", got)
+	}
+	var events int64
+	db.Model(&outbox.Event{}).Where("type = ?", outbox.TypeStockMoved).Count(&events)
+	if events != 3 {
+		t.Errorf("stock.moved events = %d, want 3", events)
+	}
+
+	// 入库到还没有库存行的仓库会新建一行
+	if err := applyStockMovement(db, 1, 2, 5, inventory.TransactionTypeIn, "tester", "", "REF"); err != nil {
+		t.Fatal(err)
+	}
+	if s := stockOf(t, db, 1, 2); s.OnHand != 5 {
+		t.Errorf("new stock row on_hand = %d, want 5", s.OnHand)
+	}
+}
+
+// 批量操作在一个事务里：任一条库存不足时前面已处理的也回滚
+func TestProcessStockMovementsIsAtomic(t *testing.T) {
+	db := newStockTestDB(t)
+	seedStock(t, db, 1, 1, 10, 0)
+	seedStock(t, db, 2, 1, 3, 0)
+	r := NewInventoryRepository(db)
+
+	err := r.ProcessStockMovements(context.Background(), []StockMovement{
+		{ProductID: 1, WarehouseID: 1, Quantity: 5, TxType: inventory.TransactionTypeIn},
+		{ProductID: 2, WarehouseID: 1, Quantity: 4, TxType: inventory.TransactionTypeSale},
+	}, "tester")
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("err = %v, want ErrInsufficientStock", err)
+	}
+	if s := stockOf(t, db, 1, 1); s.OnHand != 10 {
+		t.Errorf("product 1 on hand = %d, want 10 after rollback", s.OnHand)
+	}
+	var n int64
+	db.Model(&inventory.InventoryTransaction{}).Count(&n)
+	if n != 0 {
+		t.Errorf("ledger rows = %d, want 0", n)
+	}
+
+	if err := r.ProcessStockMovements(context.Background(), []StockMovement{
+		{ProductID: 1, WarehouseID: 1, Quantity: 5, TxType: inventory.TransactionTypeIn},
+		{ProductID: 2, WarehouseID: 1, Quantity: 3, TxType: inventory.TransactionTypeSale},
+	}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := stockOf(t, db, 1, 1).OnHand, stockOf(t, db, 2, 1).OnHand; a != 15 || b != 0 {
+		t.Errorf("on hand = %d, %d, want 15, 0", a, b)
+	}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MFARepository TOTP 密钥和恢复码
+type MFARepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// FindTOTP 读取用户的 TOTP 设置，未设置时返回 ErrNotFound
+func (r *MFARepository) FindTOTP(ctx context.Context, userID uint) (*rbac.UserTOTP, error) {
+	var t rbac.UserTOTP
+	err := r.db.WithContext(ctx).First(&t, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SavePendingTOTP 保存待确认的新密钥，覆盖之前未确认的；已启用时返回 ErrInvalidState
+func (r *MFARepository) SavePendingTOTP(ctx context.Context, userID uint, secret string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t rbac.UserTOTP
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "user_id = ?", userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&rbac.UserTOTP{UserID: userID, Secret: secret}).Error
+		}
+		if err != nil {
+			return err
+		}
+		if t.Enabled() {
+			return fmt.Errorf("%w: two-factor authentication is already enabled", ErrInvalidState)
+		}
+		return tx.Model(&t).Updates(map[string]interface{}{"secret": secret, "last_used_step": 0}).Error
+	})
+}
+
+// EnableTOTP 确认绑定：记下本次通过的时间步，并用新的一批恢复码替换旧的
+func (r *MFARepository) EnableTOTP(ctx context.Context, userID uint, step int64, codeHashes []string, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t rbac.UserTOTP
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "user_id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if t.Enabled() {
+			return fmt.Errorf("%w: two-factor authentication is already enabled", ErrInvalidState)
+		}
+		if err := tx.Model(&t).Updates(map[string]interface{}{"enabled_at": now, "last_used_step": step}).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// AdvanceTOTPStep 把最近使用的时间步推进到 step；step 不大于已用过的（验证码被重放）时返回 ErrTokenReused
+func (r *MFARepository) AdvanceTOTPStep(ctx context.Context, userID uint, step int64) error {
+	res := r.db.WithContext(ctx).Model(&rbac.UserTOTP{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTokenReused
+	}
+	return nil
+}
+
+// RecordFailure 记一次第二步验证失败；达到 maxAttempts 时锁定用户账号到 now+lockFor 并清零，返回锁定截止时间
+func (r *MFARepository) RecordFailure(ctx context.Context, userID uint, maxAttempts int, lockFor time.Duration, now time.Time) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t rbac.UserTOTP
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		n := t.FailedAttempts + 1
+		if maxAttempts > 0 && n >= maxAttempts {
+			until := now.Add(lockFor)
+			if err := tx.Model(&rbac.User{}).Where("id = ?", userID).
+				UpdateColumns(map[string]interface{}{"locked_until": until}).Error; err != nil {
+				return err
+			}
+			lockedUntil, n = &until, 0
+		}
+		return tx.Model(&t).UpdateColumn("failed_attempts", n).Error
+	})
+	return lockedUntil, err
+}
+
+// ResetFailures 第二步验证通过后清零失败次数
+func (r *MFARepository) ResetFailures(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&rbac.UserTOTP{}).Where("user_id = ?", userID).
+		UpdateColumn("failed_attempts", 0).Error
+}
+
+// UseRecoveryCode 核销一个恢复码；不存在或已用过时返回 ErrNotFound
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, now time.Time) error {
+	res := r.db.WithContext(ctx).Model(&rbac.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes 作废旧恢复码，换成新的一批
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// RemainingRecoveryCodes 未使用的恢复码数量
+func (r *MFARepository) RemainingRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&rbac.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+// DeleteTOTP 关闭两步验证：删除密钥和全部恢复码
+func (r *MFARepository) DeleteTOTP(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&rbac.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&rbac.UserTOTP{}).Error
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&rbac.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]rbac.RecoveryCode, len(codeHashes))
+	for i, h := range codeHashes {
+		codes[i] = rbac.RecoveryCode{UserID: userID, CodeHash: h}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
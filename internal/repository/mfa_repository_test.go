@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+func TestTOTPEnrollmentReplayAndRecoveryCodes(t *testing.T) {
+	db := testdb.Open(t)
+	testdb.Exec(t, db, `CREATE TABLE users (id integer primary key, locked_until datetime, deleted_at datetime)`)
+	if err := db.AutoMigrate(&rbac.UserTOTP{}, &rbac.RecoveryCode{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`INSERT INTO users (id) VALUES (1)`)
+	repo := NewMFARepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := repo.SavePendingTOTP(ctx, 1, "SECRET1"); err != nil {
+		t.Fatal(err)
+	}
+	// 未确认前验证码不能用于登录
+	if err := repo.AdvanceTOTPStep(ctx, 1, 100); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("pending totp: want ErrTokenReused, got %v", err)
+	}
+	if err := repo.EnableTOTP(ctx, 1, 100, []string{"r1", "r2"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SavePendingTOTP(ctx, 1, "SECRET2"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("re-enroll while enabled: want ErrInvalidState, got %v", err)
+	}
+
+	// 同一时间步不能用两次
+	if err := repo.AdvanceTOTPStep(ctx, 1, 100); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("replayed step: want ErrTokenReused, got %v", err)
+	}
+	if err := repo.AdvanceTOTPStep(ctx, 1, 101); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复码一次性
+	if err := repo.UseRecoveryCode(ctx, 1, "r1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UseRecoveryCode(ctx, 1, "r1", now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("reused recovery code: want ErrNotFound, got %v", err)
+	}
+	if n, _ := repo.RemainingRecoveryCodes(ctx, 1); n != 1 {
+		t.Fatalf("remaining recovery codes = %d, want 1", n)
+	}
+
+	// 连续失败达到上限后锁定账号
+	for i := 1; i <= 3; i++ {
+		until, err := repo.RecordFailure(ctx, 1, 3, time.Minute, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (until != nil) != (i == 3) {
+			t.Fatalf("attempt %d: locked=%v", i, until != nil)
+		}
+	}
+	var locked int64
+	db.Table("users").Where("id = 1 AND locked_until IS NOT NULL").Count(&locked)
+	if locked != 1 {
+		t.Fatal("user should be locked after repeated second-step failures")
+	}
+}
@@ -3,9 +3,13 @@ package repository
 import (
 	"context"
 	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/scope"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrderRepository 封装对 orders 表的访问
@@ -19,12 +23,13 @@ func NewOrderRepository(db *gorm.DB) *OrderRepository {
 }
 
 // FindByID 根据主键读取订单（并且把 Store、Customer、Items，以及每个 Item 的 Product 一起 Preload 进来）
+// 数据范围外的订单按不存在处理
 func (r *OrderRepository) FindByID(ctx context.Context, id uint) (*sales.Order, error) {
 	var o sales.Order
 	err := r.DB.WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
 		Preload("Store").
 		Preload("Customer").
-		Preload("CreatedByUser").
 		Preload("SalesRepUser").
 		Preload("Items.Product").
 		First(&o, "id = ?", id).Error
@@ -40,6 +45,9 @@ func (r *OrderRepository) FindByID(ctx context.Context, id uint) (*sales.Order,
 // Create 在数据库中新增一条订单记录（包含关联的 Items）
 func (r *OrderRepository) Create(ctx context.Context, order *sales.Order) error {
 	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := scope.CheckStore(tx, order.StoreID); err != nil {
+			return err
+		}
 		if err := tx.Create(order).Error; err != nil {
 			return err
 		}
@@ -58,3 +66,192 @@ func (r *OrderRepository) Update(ctx context.Context, order *sales.Order) error
 		return nil
 	})
 }
+
+// OrderFilter 订单列表筛选条件，零值表示不过滤
+type OrderFilter struct {
+	Status     string
+	StoreID    uint
+	CustomerID uint
+	SalesRepID uint
+	QuoteID    uint
+	Keyword    string // 按订单号模糊匹配
+	From, To   *time.Time
+}
+
+// List 分页列出订单（不含明细）
+func (r *OrderRepository) List(ctx context.Context, f OrderFilter, offset, limit int) ([]sales.Order, int64, error) {
+	var (
+		list  []sales.Order
+		total int64
+	)
+	q := r.DB.WithContext(ctx).Model(&sales.Order{}).Scopes(scope.ByStore("store_id"))
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.StoreID != 0 {
+		q = q.Where("store_id = ?", f.StoreID)
+	}
+	if f.CustomerID != 0 {
+		q = q.Where("customer_id = ?", f.CustomerID)
+	}
+	if f.SalesRepID != 0 {
+		q = q.Where("sales_rep_id = ?", f.SalesRepID)
+	}
+	if f.QuoteID != 0 {
+		q = q.Where("quote_id = ?", f.QuoteID)
+	}
+	if f.Keyword != "" {
+		q = q.Where("order_number ILIKE ?", "%"+f.Keyword+"%")
+	}
+	if f.From != nil {
+		q = q.Where("order_date >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("order_date <= ?", *f.To)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("Store").
+		Preload("Customer").
+		Preload("SalesRepUser").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// CreateDraft 新建草稿订单（含明细），单号按 SO-日期-ID 生成
+// 同一报价单只能转一张订单，且必须是已审批的最新版本
+func (r *OrderRepository) CreateDraft(ctx context.Context, o *sales.Order) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := scope.CheckStore(tx, o.StoreID); err != nil {
+			return err
+		}
+		if o.QuoteID != nil {
+			// 锁住报价单，防止与修订 / 审批并发
+			q, err := lockCurrentQuote(tx, *o.QuoteID)
+			if err != nil {
+				return err
+			}
+			if q.Status != sales.QuoteStatusApproved {
+				return fmt.Errorf("%w: quote %s is %s, only approved quotes can be converted", ErrInvalidState, q.QuoteNumber, q.Status)
+			}
+			var n int64
+			if err := tx.Model(&sales.Order{}).Where("quote_id = ?", *o.QuoteID).Count(&n).Error; err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("%w: quote %d has already been converted to an order", ErrInvalidState, *o.QuoteID)
+			}
+		}
+
+		// 先用临时单号占位，拿到 ID 后再生成正式单号
+		o.OrderNumber = fmt.Sprintf("TMP-%d", time.Now().UnixNano())
+		o.Status = sales.OrderStatusDraft
+		if err := tx.Omit("Store", "Customer", "SalesRepUser", "Items.Product").Create(o).Error; err != nil {
+			return err
+		}
+		o.OrderNumber = fmt.Sprintf("SO-%s-%05d", o.CreatedAt.Format("20060102"), o.ID)
+		return tx.Model(o).Update("order_number", o.OrderNumber).Error
+	})
+}
+
+// UpdateDraftHeader 修改草稿订单的收货地址等抬头信息
+func (r *OrderRepository) UpdateDraftHeader(ctx context.Context, id uint, updates map[string]interface{}) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockDraftOrder(tx, id); err != nil {
+			return err
+		}
+		updates["updated_at"] = time.Now()
+		return tx.Model(&sales.Order{}).Where("id = ?", id).Updates(updates).Error
+	})
+}
+
+// AddItem 草稿订单新增明细并重算总额
+func (r *OrderRepository) AddItem(ctx context.Context, orderID uint, item *sales.OrderItem, updatedBy uint) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockDraftOrder(tx, orderID); err != nil {
+			return err
+		}
+		item.OrderID = orderID
+		if err := tx.Omit("Product").Create(item).Error; err != nil {
+			return err
+		}
+		return recalcOrderTotal(tx, orderID, updatedBy)
+	})
+}
+
+// UpdateItem 草稿订单修改明细数量 / 单价并重算总额
+func (r *OrderRepository) UpdateItem(ctx context.Context, orderID, itemID uint, quantity int, unitPrice float64, updatedBy uint) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockDraftOrder(tx, orderID); err != nil {
+			return err
+		}
+		res := tx.Model(&sales.OrderItem{}).
+			Where("id = ? AND order_id = ?", itemID, orderID).
+			Updates(map[string]interface{}{"quantity": quantity, "unit_price": unitPrice})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return recalcOrderTotal(tx, orderID, updatedBy)
+	})
+}
+
+// DeleteItem 草稿订单删除明细并重算总额
+func (r *OrderRepository) DeleteItem(ctx context.Context, orderID, itemID uint, updatedBy uint) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockDraftOrder(tx, orderID); err != nil {
+			return err
+		}
+		res := tx.Where("id = ? AND order_id = ?", itemID, orderID).Delete(&sales.OrderItem{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return recalcOrderTotal(tx, orderID, updatedBy)
+	})
+}
+
+// lockDraftOrder 以 FOR UPDATE 读取订单，并要求订单处于草稿状态；数据范围外的按不存在处理
+func lockDraftOrder(tx *gorm.DB, id uint) (*sales.Order, error) {
+	var o sales.Order
+	err := tx.Scopes(scope.ByStore("store_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != sales.OrderStatusDraft {
+		return nil, fmt.Errorf("%w: order %s is %s, only draft orders can be edited", ErrInvalidState, o.OrderNumber, o.Status)
+	}
+	return &o, nil
+}
+
+// recalcOrderTotal 按明细重算订单含税总额
+func recalcOrderTotal(tx *gorm.DB, orderID uint, updatedBy uint) error {
+	var net float64
+	if err := tx.Model(&sales.OrderItem{}).
+		Select("COALESCE(SUM(quantity * unit_price), 0)").
+		Where("order_id = ?", orderID).
+		Scan(&net).Error; err != nil {
+		return err
+	}
+	net = sales.RoundMoney(net)
+	updates := map[string]interface{}{
+		"total_amount": net + sales.GSTOf(net),
+		"updated_at":   time.Now(),
+	}
+	if updatedBy != 0 {
+		updates["updated_by"] = updatedBy
+	}
+	return tx.Model(&sales.Order{}).Where("id = ?", orderID).Updates(updates).Error
+}
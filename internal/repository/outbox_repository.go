@@ -0,0 +1,121 @@
+// internal/repository/outbox_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/outbox"
+
+	"gorm.io/gorm"
+)
+
+// OutboxRepository 查询 outbox 事件的投递情况，人工重试 dead 投递；投递本身由 outbox.Dispatcher 负责
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// DeliveryFilter 投递记录查询条件
+type DeliveryFilter struct {
+	Status   string
+	Consumer string
+	EventID  uint
+	Type     string
+}
+
+// DeliverySummary 某个下游各状态的投递数
+type DeliverySummary struct {
+	Consumer string `json:"consumer"`
+	Status   string `json:"status"`
+	Count    int64  `json:"count"`
+}
+
+// ListDeliveries 分页查询投递记录（带事件），最新的在前
+func (r *OutboxRepository) ListDeliveries(ctx context.Context, f DeliveryFilter, offset, limit int) ([]outbox.Delivery, int64, error) {
+	var (
+		list  []outbox.Delivery
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&outbox.Delivery{})
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.Consumer != "" {
+		q = q.Where("consumer = ?", f.Consumer)
+	}
+	if f.EventID != 0 {
+		q = q.Where("event_id = ?", f.EventID)
+	}
+	if f.Type != "" {
+		q = q.Where("event_id IN (?)", r.db.Model(&outbox.Event{}).Select("id").Where("type = ?", f.Type))
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Preload("Event").Order("id DESC").Offset(offset).Limit(limit).Find(&list).Error
+	return list, total, err
+}
+
+// Summary 按下游和状态统计投递数
+func (r *OutboxRepository) Summary(ctx context.Context) ([]DeliverySummary, error) {
+	var out []DeliverySummary
+	err := r.db.WithContext(ctx).Model(&outbox.Delivery{}).
+		Select("consumer, status, COUNT(*) AS count").
+		Group("consumer, status").
+		Order("consumer, status").
+		Scan(&out).Error
+	return out, err
+}
+
+// FindEvent 读取事件及其全部投递记录，不存在返回 ErrNotFound
+func (r *OutboxRepository) FindEvent(ctx context.Context, id uint) (*outbox.Event, []outbox.Delivery, error) {
+	var e outbox.Event
+	err := r.db.WithContext(ctx).First(&e, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []outbox.Delivery
+	err = r.db.WithContext(ctx).Where("event_id = ?", id).Order("consumer").Find(&list).Error
+	return &e, list, err
+}
+
+// RetryDelivery 把 dead 投递重置为 pending 并立即到期，重新计算重试次数
+// 不存在返回 ErrNotFound，不是 dead 状态返回 ErrInvalidState
+func (r *OutboxRepository) RetryDelivery(ctx context.Context, id uint) (*outbox.Delivery, error) {
+	var d outbox.Delivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&d, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if d.Status != outbox.DeliveryDead {
+			return fmt.Errorf("%w: delivery %d is %s, only dead deliveries can be retried", ErrInvalidState, id, d.Status)
+		}
+		now := time.Now()
+		res := tx.Model(&outbox.Delivery{}).Where("id = ? AND status = ?", id, outbox.DeliveryDead).
+			Updates(map[string]interface{}{"status": outbox.DeliveryPending, "attempts": 0, "next_attempt_at": now, "updated_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: delivery %d changed concurrently", ErrInvalidState, id)
+		}
+		return tx.Preload("Event").First(&d, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
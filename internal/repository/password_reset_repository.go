@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/rbac"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PasswordResetRepository 找回密码令牌
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create 保存新令牌，同时作废该用户之前未使用的令牌（只有最近一封邮件里的链接有效）
+func (r *PasswordResetRepository) Create(ctx context.Context, t *rbac.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&rbac.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", t.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(t).Error
+	})
+}
+
+// FindValid 按哈希读取未使用、未过期的令牌；不存在或已失效都返回 ErrNotFound
+func (r *PasswordResetRepository) FindValid(ctx context.Context, hash string, now time.Time) (*rbac.PasswordResetToken, error) {
+	var t rbac.PasswordResetToken
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Consume 使用令牌重置密码：令牌标记已用，写入新密码并解除登录锁定
+// 令牌在此期间已被使用或过期时返回 ErrInvalidState
+func (r *PasswordResetRepository) Consume(ctx context.Context, id uint, passwordHash string, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t rbac.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, id).Error; err != nil {
+			return err
+		}
+		if t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+			return fmt.Errorf("%w: reset token has already been used or expired", ErrInvalidState)
+		}
+		if err := tx.Model(&t).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&rbac.User{}).Where("id = ?", t.UserID).UpdateColumns(map[string]interface{}{
+			"password_hash":       passwordHash,
+			"password_changed_at": now,
+			"failed_logins":       0,
+			"locked_until":        nil,
+		}).Error
+	})
+}
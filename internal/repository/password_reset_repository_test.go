@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+func TestPasswordResetAndLockout(t *testing.T) {
+	db := testdb.Open(t)
+	testdb.Exec(t, db, `CREATE TABLE users (id integer primary key, username text, email text, password_hash text,
+		failed_logins integer not null default 0, locked_until datetime, password_changed_at datetime, deleted_at datetime)`)
+	if err := db.AutoMigrate(&rbac.PasswordResetToken{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`INSERT INTO users (id, username, email, password_hash) VALUES (1, 'alice', 'alice@example.com', 'old')`)
+	ctx := context.Background()
+	now := time.Now()
+
+	// 连续失败：第三次达到上限后锁定并清零计数
+	users := &userRepo{db: db}
+	for i := 1; i <= 3; i++ {
+		until, err := users.RecordLoginFailure(ctx, 1, 3, 15*time.Minute, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (until != nil) != (i == 3) {
+			t.Fatalf("attempt %d: locked=%v", i, until != nil)
+		}
+	}
+
+	repo := NewPasswordResetRepository(db)
+	first := &rbac.PasswordResetToken{UserID: 1, TokenHash: "h1", ExpiresAt: now.Add(30 * time.Minute)}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	second := &rbac.PasswordResetToken{UserID: 1, TokenHash: "h2", ExpiresAt: now.Add(30 * time.Minute)}
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	// 新令牌让旧令牌失效
+	if _, err := repo.FindValid(ctx, "h1", now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("superseded token: want ErrNotFound, got %v", err)
+	}
+	if _, err := repo.FindValid(ctx, "h2", now.Add(time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired token: want ErrNotFound, got %v", err)
+	}
+	tok, err := repo.FindValid(ctx, "h2", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Consume(ctx, tok.ID, "new", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Consume(ctx, tok.ID, "again", now); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("reused token: want ErrInvalidState, got %v", err)
+	}
+
+	var u rbac.User
+	if err := db.Table("users").Select("password_hash", "failed_logins", "locked_until").First(&u, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if u.PasswordHash != "new" || u.FailedLogins != 0 || u.LockedUntil != nil {
+		t.Fatalf("reset did not update user: %+v", u)
+	}
+}
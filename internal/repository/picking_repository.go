@@ -0,0 +1,594 @@
+// internal/repository/picking_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/scope"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PickingRepository 拣货单：按订单预留 / 可用库存分配仓库和库位，逐行确认拣货并出库，短拣生成缺货单
+type PickingRepository struct {
+	db *gorm.DB
+}
+
+func NewPickingRepository(db *gorm.DB) *PickingRepository {
+	return &PickingRepository{db: db}
+}
+
+// PickingFilter 拣货单列表筛选条件，零值表示不过滤
+type PickingFilter struct {
+	Status      string
+	OrderID     uint
+	WarehouseID uint // 含有该仓库明细的拣货单
+}
+
+// PickConfirmation 一行的实拣数量
+type PickConfirmation struct {
+	LineID    uint
+	PickedQty int
+}
+
+// pickableOrderStatuses 允许生成 / 分配拣货单的订单状态
+var pickableOrderStatuses = map[string]bool{
+	sales.OrderStatusOrdered:               true,
+	sales.OrderStatusDepositReceived:       true,
+	sales.OrderStatusFinalPaymentReceived:  true,
+	sales.OrderStatusPreDeliveryInspection: true,
+}
+
+// FindByID 读取拣货单及明细（含产品、仓库）
+func (r *PickingRepository) FindByID(ctx context.Context, id uint) (*sales.PickingList, error) {
+	var p sales.PickingList
+	err := r.db.WithContext(ctx).
+		Scopes(scope.ByOrder("order_id")).
+		Preload("SalesRepUser").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Product").
+		Preload("Items.Warehouse").
+		First(&p, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List 分页列出拣货单（不含明细）
+func (r *PickingRepository) List(ctx context.Context, f PickingFilter, offset, limit int) ([]sales.PickingList, int64, error) {
+	var (
+		list  []sales.PickingList
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&sales.PickingList{}).Scopes(scope.ByOrder("order_id"))
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.OrderID != 0 {
+		q = q.Where("order_id = ?", f.OrderID)
+	}
+	if f.WarehouseID != 0 {
+		q = q.Where("id IN (?)", r.db.Model(&sales.PickingListItem{}).Select("picking_list_id").Where("warehouse_id = ?", f.WarehouseID))
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("SalesRepUser").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// Generate 为订单尚未拣货的数量生成拣货单，返回新建的单据 ID（拣货单和 / 或缺货单）
+//   - 先使用订单已有的生效预留（仓库已确定）
+//   - 不足的部分按可用库存分配并新增预留，本门店所在地区的仓库优先
+//   - 仍然分配不到的数量直接进入缺货单
+//
+// 同一订单同时只能有一张未完结的拣货单 / 缺货单
+func (r *PickingRepository) Generate(ctx context.Context, orderID, createdBy uint, operator string) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		o, err := lockPickableOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		var open sales.PickingList
+		err = tx.Where("order_id = ? AND status IN ?", o.ID,
+			[]string{sales.PickingStatusDraft, sales.PickingStatusPicking, sales.PickingStatusBackorder}).
+			First(&open).Error
+		if err == nil {
+			return fmt.Errorf("%w: order %s already has open picking list %s", ErrInvalidState, o.OrderNumber, open.PickingNumber)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Where("order_id = ?", o.ID).Order("id").Find(&o.Items).Error; err != nil {
+			return err
+		}
+		picked, err := pickedByOrderItem(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		var active []inventory.StockReservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ? AND status = ?", o.ID, inventory.ReservationStatusActive).
+			Order("id").
+			Find(&active).Error; err != nil {
+			return err
+		}
+
+		var lines, short []sales.PickingListItem
+		for _, it := range o.Items {
+			need := it.Quantity - picked[it.ID]
+			for _, res := range active {
+				if need <= 0 {
+					break
+				}
+				if res.OrderItemID != it.ID {
+					continue
+				}
+				qty := res.Quantity
+				if qty > need {
+					qty = need
+				}
+				line, err := pickingLine(tx, it.ID, it.ProductID, res.WarehouseID, res.ID, qty)
+				if err != nil {
+					return err
+				}
+				lines = append(lines, line)
+				need -= qty
+			}
+			if need <= 0 {
+				continue
+			}
+			allocated, left, err := allocatePickingLines(tx, o, it.ID, it.ProductID, need, operator)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, allocated...)
+			if left > 0 {
+				short = append(short, sales.PickingListItem{OrderItemID: it.ID, ProductID: it.ProductID, Quantity: left})
+			}
+		}
+		if len(lines) == 0 && len(short) == 0 {
+			return fmt.Errorf("%w: order %s has nothing left to pick", ErrInvalidState, o.OrderNumber)
+		}
+
+		var parentID *uint
+		if len(lines) > 0 {
+			p, err := createPickingList(tx, o, nil, sales.PickingStatusDraft, lines, createdBy)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, p.ID)
+			parentID = &p.ID
+		}
+		if len(short) > 0 {
+			p, err := createPickingList(tx, o, parentID, sales.PickingStatusBackorder, short, createdBy)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, p.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Allocate 库存到货后为缺货单重新分配：能分配的行留在本单并转为 draft，仍不足的数量转入新的缺货单
+// 返回新缺货单 ID（全部分配成功时为 nil）
+func (r *PickingRepository) Allocate(ctx context.Context, id, updatedBy uint, operator string) (*uint, error) {
+	var remainder *uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		p, err := lockPickingList(tx, id)
+		if err != nil {
+			return err
+		}
+		if p.Status != sales.PickingStatusBackorder {
+			return fmt.Errorf("%w: picking list %s is %s, only backorders can be allocated", ErrInvalidState, p.PickingNumber, p.Status)
+		}
+		o, err := lockPickableOrder(tx, p.OrderID)
+		if err != nil {
+			return err
+		}
+		var old []sales.PickingListItem
+		if err := tx.Where("picking_list_id = ?", p.ID).Order("id").Find(&old).Error; err != nil {
+			return err
+		}
+
+		var lines, short []sales.PickingListItem
+		for _, ln := range old {
+			allocated, left, err := allocatePickingLines(tx, o, ln.OrderItemID, ln.ProductID, ln.Quantity, operator)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, allocated...)
+			if left > 0 {
+				short = append(short, sales.PickingListItem{OrderItemID: ln.OrderItemID, ProductID: ln.ProductID, Quantity: left})
+			}
+		}
+		if len(lines) == 0 {
+			return fmt.Errorf("%w: no stock available yet for backorder %s", ErrInsufficientStock, p.PickingNumber)
+		}
+
+		if err := tx.Where("picking_list_id = ?", p.ID).Delete(&sales.PickingListItem{}).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].PickingListID = p.ID
+		}
+		if err := tx.Omit("Product", "Warehouse").Create(&lines).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&sales.PickingList{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+			"status":     sales.PickingStatusDraft,
+			"updated_by": updatedBy,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if len(short) > 0 {
+			bo, err := createPickingList(tx, o, &p.ID, sales.PickingStatusBackorder, short, updatedBy)
+			if err != nil {
+				return err
+			}
+			remainder = &bo.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return remainder, nil
+}
+
+// ConfirmPicks 逐行确认实拣数量：预留转为 SALE 出库（短拣部分只释放预留）
+// 全部行确认后拣货单转为 picked，短拣数量汇总生成缺货单；返回缺货单 ID（没有短拣时为 nil）
+func (r *PickingRepository) ConfirmPicks(ctx context.Context, id uint, picks []PickConfirmation, updatedBy uint, operator string) (*uint, error) {
+	var backorder *uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		p, err := lockPickingList(tx, id)
+		if err != nil {
+			return err
+		}
+		if p.Status != sales.PickingStatusDraft && p.Status != sales.PickingStatusPicking {
+			return fmt.Errorf("%w: picking list %s is %s", ErrInvalidState, p.PickingNumber, p.Status)
+		}
+		var o sales.Order
+		if err := tx.Select("id", "order_number", "store_id", "shipping_address", "location", "sales_rep_id").
+			First(&o, p.OrderID).Error; err != nil {
+			return err
+		}
+
+		var lines []sales.PickingListItem
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("picking_list_id = ?", p.ID).
+			Order("id").
+			Find(&lines).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*sales.PickingListItem, len(lines))
+		for i := range lines {
+			byID[lines[i].ID] = &lines[i]
+		}
+
+		now := time.Now()
+		for _, pk := range picks {
+			ln, ok := byID[pk.LineID]
+			if !ok {
+				return fmt.Errorf("%w: line %d is not on picking list %s", ErrNotFound, pk.LineID, p.PickingNumber)
+			}
+			if ln.PickedQty != nil {
+				return fmt.Errorf("%w: line %d has already been confirmed", ErrInvalidState, ln.ID)
+			}
+			if pk.PickedQty < 0 || pk.PickedQty > ln.Quantity {
+				return fmt.Errorf("%w: line %d picked quantity must be between 0 and %d", ErrInvalidState, ln.ID, ln.Quantity)
+			}
+			if err := consumeForPick(tx, ln, pk.PickedQty, o.OrderNumber, p.PickingNumber, operator); err != nil {
+				return err
+			}
+			qty := pk.PickedQty
+			ln.PickedQty = &qty
+			if err := tx.Model(&sales.PickingListItem{}).Where("id = ?", ln.ID).Updates(map[string]interface{}{
+				"picked_qty": qty,
+				"picked_by":  operator,
+				"picked_at":  now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		status := sales.PickingStatusPicked
+		var short []sales.PickingListItem
+		shortByItem := map[uint]int{}
+		for _, ln := range lines {
+			if ln.PickedQty == nil {
+				status = sales.PickingStatusPicking
+				break
+			}
+			if s := ln.ShortQty(); s > 0 {
+				if _, seen := shortByItem[ln.OrderItemID]; !seen {
+					short = append(short, sales.PickingListItem{OrderItemID: ln.OrderItemID, ProductID: ln.ProductID})
+				}
+				shortByItem[ln.OrderItemID] += s
+			}
+		}
+		if err := tx.Model(&sales.PickingList{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+			"status":     status,
+			"updated_by": updatedBy,
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if status == sales.PickingStatusPicked && len(short) > 0 {
+			for i := range short {
+				short[i].Quantity = shortByItem[short[i].OrderItemID]
+			}
+			bo, err := createPickingList(tx, &o, &p.ID, sales.PickingStatusBackorder, short, updatedBy)
+			if err != nil {
+				return err
+			}
+			backorder = &bo.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return backorder, nil
+}
+
+// Cancel 取消尚未开始拣货的拣货单或缺货单；预留仍归属订单，重新生成拣货单时继续使用
+func (r *PickingRepository) Cancel(ctx context.Context, id, updatedBy uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		p, err := lockPickingList(tx, id)
+		if err != nil {
+			return err
+		}
+		if p.Status != sales.PickingStatusDraft && p.Status != sales.PickingStatusBackorder {
+			return fmt.Errorf("%w: picking list %s is %s and cannot be cancelled", ErrInvalidState, p.PickingNumber, p.Status)
+		}
+		return tx.Model(&sales.PickingList{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+			"status":     sales.PickingStatusCancelled,
+			"updated_by": updatedBy,
+			"updated_at": time.Now(),
+		}).Error
+	})
+}
+
+// SetBinLocation 设置产品在某仓库的库位
+func (r *PickingRepository) SetBinLocation(ctx context.Context, productID, warehouseID uint, bin string) error {
+	res := r.db.WithContext(ctx).Model(&catalog.ProductStock{}).
+		Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+		Update("bin_location", bin)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// settlePickingLists 订单状态变化时同步拣货单：
+//   - 已有拣货出库的订单不能取消或退回草稿（需先做退货）
+//   - 发货前必须完成或取消拣货中的单据；发货后剩余缺货单作废
+//   - 取消 / 退回草稿时作废所有未完结的拣货单
+func settlePickingLists(tx *gorm.DB, o *sales.Order, to string) error {
+	open := []string{sales.PickingStatusDraft, sales.PickingStatusPicking, sales.PickingStatusBackorder}
+	switch to {
+	case sales.OrderStatusCancelled, sales.OrderStatusDraft:
+		var n int64
+		if err := tx.Model(&sales.PickingListItem{}).
+			Joins("JOIN picking_lists ON picking_lists.id = picking_list_items.picking_list_id").
+			Where("picking_lists.order_id = ? AND picking_list_items.picked_qty > 0", o.ID).
+			Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: order %s has picked stock, return it before moving to %s", ErrInvalidState, o.OrderNumber, to)
+		}
+	case sales.OrderStatusShipped:
+		var p sales.PickingList
+		err := tx.Where("order_id = ? AND status IN ?", o.ID, []string{sales.PickingStatusDraft, sales.PickingStatusPicking}).
+			First(&p).Error
+		if err == nil {
+			return fmt.Errorf("%w: picking list %s is still %s", ErrInvalidState, p.PickingNumber, p.Status)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	default:
+		return nil
+	}
+	return tx.Model(&sales.PickingList{}).
+		Where("order_id = ? AND status IN ?", o.ID, open).
+		Updates(map[string]interface{}{"status": sales.PickingStatusCancelled, "updated_at": time.Now()}).Error
+}
+
+// lockPickableOrder 以 FOR UPDATE 读取订单，并要求订单处于可拣货状态；数据范围外的按不存在处理
+func lockPickableOrder(tx *gorm.DB, id uint) (*sales.Order, error) {
+	var o sales.Order
+	err := tx.Scopes(scope.ByStore("store_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !pickableOrderStatuses[o.Status] {
+		return nil, fmt.Errorf("%w: order %s is %s and cannot be picked", ErrInvalidState, o.OrderNumber, o.Status)
+	}
+	return &o, nil
+}
+
+// lockPickingList 以 FOR UPDATE 读取拣货单；数据范围外的按不存在处理
+func lockPickingList(tx *gorm.DB, id uint) (*sales.PickingList, error) {
+	var p sales.PickingList
+	err := tx.Scopes(scope.ByOrder("order_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// pickedByOrderItem 汇总订单各明细已经拣货出库的数量
+func pickedByOrderItem(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Picked      int
+	}
+	err := tx.Model(&sales.PickingListItem{}).
+		Select("picking_list_items.order_item_id AS order_item_id, SUM(picking_list_items.picked_qty) AS picked").
+		Joins("JOIN picking_lists ON picking_lists.id = picking_list_items.picking_list_id").
+		Where("picking_lists.order_id = ? AND picking_list_items.picked_qty IS NOT NULL", orderID).
+		Group("picking_list_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		out[r.OrderItemID] = r.Picked
+	}
+	return out, nil
+}
+
+// allocatePickingLines 按可用库存为 need 数量分配仓库并新增预留，返回分配出的行和分配不到的数量
+func allocatePickingLines(tx *gorm.DB, o *sales.Order, orderItemID, productID uint, need int, operator string) ([]sales.PickingListItem, int, error) {
+	candidates, err := reservableStocks(tx, o.StoreID, productID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var lines []sales.PickingListItem
+	for _, c := range candidates {
+		if need == 0 {
+			break
+		}
+		qty := c.Available
+		if qty > need {
+			qty = need
+		}
+		note := fmt.Sprintf("订单 %s 拣货分配", o.OrderNumber)
+		if err := applyReservation(tx, productID, c.WarehouseID, qty, operator, note, o.OrderNumber); err != nil {
+			return nil, 0, fmt.Errorf("reserve product %d: %w", productID, err)
+		}
+		res := inventory.StockReservation{
+			OrderID:     o.ID,
+			OrderItemID: orderItemID,
+			ProductID:   productID,
+			WarehouseID: c.WarehouseID,
+			Quantity:    qty,
+			Status:      inventory.ReservationStatusActive,
+			CreatedBy:   operator,
+		}
+		if err := tx.Omit("Warehouse").Create(&res).Error; err != nil {
+			return nil, 0, err
+		}
+		line, err := pickingLine(tx, orderItemID, productID, c.WarehouseID, res.ID, qty)
+		if err != nil {
+			return nil, 0, err
+		}
+		lines = append(lines, line)
+		need -= qty
+	}
+	return lines, need, nil
+}
+
+// pickingLine 组装一条已分配的拣货行，库位取 product_stocks.bin_location
+func pickingLine(tx *gorm.DB, orderItemID, productID, warehouseID, reservationID uint, qty int) (sales.PickingListItem, error) {
+	var bin string
+	if err := tx.Model(&catalog.ProductStock{}).
+		Select("COALESCE(bin_location, '')").
+		Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+		Scan(&bin).Error; err != nil {
+		return sales.PickingListItem{}, err
+	}
+	wid, rid := warehouseID, reservationID
+	return sales.PickingListItem{
+		OrderItemID:   orderItemID,
+		ProductID:     productID,
+		WarehouseID:   &wid,
+		ReservationID: &rid,
+		Quantity:      qty,
+		Location:      bin,
+	}, nil
+}
+
+// createPickingList 新建拣货单 / 缺货单（含明细），单号按 PL-日期-ID 生成
+func createPickingList(tx *gorm.DB, o *sales.Order, parentID *uint, status string, lines []sales.PickingListItem, createdBy uint) (*sales.PickingList, error) {
+	p := &sales.PickingList{
+		OrderID:         o.ID,
+		ParentID:        parentID,
+		PickingNumber:   fmt.Sprintf("TMP-%d", time.Now().UnixNano()),
+		DeliveryAddress: o.ShippingAddress,
+		Status:          status,
+		Location:        o.Location,
+		CreatedBy:       createdBy,
+		SalesRepID:      o.SalesRepID,
+		Items:           lines,
+	}
+	if err := tx.Omit("SalesRepUser", "Items.Product", "Items.Warehouse").Create(p).Error; err != nil {
+		return nil, err
+	}
+	p.PickingNumber = fmt.Sprintf("PL-%s-%05d", p.CreatedAt.Format("20060102"), p.ID)
+	if err := tx.Model(p).Update("picking_number", p.PickingNumber).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// consumeForPick 确认一行拣货：释放该行的整条预留，实拣数量写 SALE 出库，预留标记为 consumed
+func consumeForPick(tx *gorm.DB, ln *sales.PickingListItem, picked int, orderNumber, pickingNumber, operator string) error {
+	if ln.ReservationID == nil {
+		return fmt.Errorf("%w: line %d has not been allocated", ErrInvalidState, ln.ID)
+	}
+	var res inventory.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&res, *ln.ReservationID).Error; err != nil {
+		return err
+	}
+	if res.Status != inventory.ReservationStatusActive {
+		return fmt.Errorf("%w: reservation for line %d is %s", ErrInvalidState, ln.ID, res.Status)
+	}
+
+	note := fmt.Sprintf("拣货单 %s 拣货出库", pickingNumber)
+	if err := applyRelease(tx, res.ProductID, res.WarehouseID, res.Quantity, operator, note, orderNumber); err != nil {
+		return fmt.Errorf("release reservation %d: %w", res.ID, err)
+	}
+	if picked > 0 {
+		if err := applyStockMovement(tx, res.ProductID, res.WarehouseID, picked,
+			inventory.TransactionTypeSale, operator, note, orderNumber); err != nil {
+			return fmt.Errorf("pick line %d: %w", ln.ID, err)
+		}
+	}
+	return tx.Model(&inventory.StockReservation{}).
+		Where("id = ?", res.ID).
+		Updates(map[string]interface{}{
+			"status":    inventory.ReservationStatusConsumed,
+			"closed_at": time.Now(),
+		}).Error
+}
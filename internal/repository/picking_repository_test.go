@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/sales"
+
+	"gorm.io/gorm"
+)
+
+func pickingLines(t *testing.T, db *gorm.DB, id uint) []sales.PickingListItem {
+	t.Helper()
+	var lines []sales.PickingListItem
+	if err := db.Where("picking_list_id = ?", id).Order("id").Find(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func pickingStatus(t *testing.T, db *gorm.DB, id uint) string {
+	t.Helper()
+	var p sales.PickingList
+	if err := db.First(&p, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return p.Status
+}
+
+// pickAll 按每行的分配数量全部确认
+func pickAll(t *testing.T, r *PickingRepository, db *gorm.DB, id uint) {
+	t.Helper()
+	var picks []PickConfirmation
+	for _, ln := range pickingLines(t, db, id) {
+		picks = append(picks, PickConfirmation{LineID: ln.ID, PickedQty: ln.Quantity})
+	}
+	if bo, err := r.ConfirmPicks(context.Background(), id, picks, 1, "picker"); err != nil || bo != nil {
+		t.Fatalf("pick all of %d: backorder %v, err %v", id, bo, err)
+	}
+}
+
+// 预留不足生成缺货单，短拣再生成缺货单；到货后分配、拣完，发货时不再重复出库
+func TestPickingWithBackorders(t *testing.T) {
+	db := newOrderTestDB(t)
+	seedStock(t, db, 1, 1, 3, 0)
+	db.Exec(`UPDATE product_stocks SET bin_location = 'A-01' WHERE product_id = 1 AND warehouse_id = 1`)
+	seedOrder(t, db, 1, "SO-1", [2]int{1, 5})
+	walkOrder(t, NewReservationRepository(db), 1, 0, sales.OrderStatusOrdered)
+	r := NewPickingRepository(db)
+	ctx := context.Background()
+
+	ids, err := r.Generate(ctx, 1, 1, "picker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("generated %v, want a picking list and a backorder", ids)
+	}
+	pl, bo := ids[0], ids[1]
+	lines := pickingLines(t, db, pl)
+	if len(lines) != 1 || lines[0].Quantity != 3 || lines[0].Location != "A-01" || lines[0].ReservationID == nil {
+		t.Fatalf("picking lines = %+v", lines)
+	}
+	if got := pickingStatus(t, db, bo); got != sales.PickingStatusBackorder {
+		t.Errorf("backorder status = %s", got)
+	}
+	if bl := pickingLines(t, db, bo); len(bl) != 1 || bl[0].Quantity != 2 || bl[0].WarehouseID != nil {
+		t.Errorf("backorder lines = %+v", bl)
+	}
+	if _, err := r.Generate(ctx, 1, 1, "picker"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second generate: err = %v, want ErrInvalidState", err)
+	}
+	if _, err := r.Allocate(ctx, bo, 1, "picker"); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("allocate without stock: err = %v, want ErrInsufficientStock", err)
+	}
+
+	// 实拣 2 件，短拣的 1 件转入新缺货单，预留全部释放
+	bo2, err := r.ConfirmPicks(ctx, pl, []PickConfirmation{{LineID: lines[0].ID, PickedQty: 2}}, 1, "picker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bo2 == nil {
+		t.Fatal("short pick did not create a backorder")
+	}
+	if bl := pickingLines(t, db, *bo2); len(bl) != 1 || bl[0].Quantity != 1 {
+		t.Errorf("short-pick backorder lines = %+v", bl)
+	}
+	if got := pickingStatus(t, db, pl); got != sales.PickingStatusPicked {
+		t.Errorf("picking list status = %s", got)
+	}
+	if s := stockOf(t, db, 1, 1); s.OnHand != 1 || s.Reserved != 0 {
+		t.Errorf("after pick = %d/%d, want 1/0", s.OnHand, s.Reserved)
+	}
+	if _, err := r.ConfirmPicks(ctx, pl, nil, 1, "picker"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("confirm picked list: err = %v, want ErrInvalidState", err)
+	}
+	if _, err := NewReservationRepository(db).TransitionOrder(ctx, 1, sales.OrderStatusCancelled, "tester", 1, 0); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("cancel with picked stock: err = %v, want ErrInvalidState", err)
+	}
+
+	// 仓库 2 到货 2 件；第一张缺货单先用本地区仓库 1 剩下的 1 件
+	if err := applyStockMovement(db, 1, 2, 2, inventory.TransactionTypeIn, "tester", "", "PO-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint{bo, *bo2} {
+		if rest, err := r.Allocate(ctx, id, 1, "picker"); err != nil || rest != nil {
+			t.Fatalf("allocate %d: remainder %v, err %v", id, rest, err)
+		}
+		if got := pickingStatus(t, db, id); got != sales.PickingStatusDraft {
+			t.Errorf("allocated backorder %d status = %s", id, got)
+		}
+	}
+	if bl := pickingLines(t, db, bo); len(bl) != 2 || *bl[0].WarehouseID != 1 || *bl[1].WarehouseID != 2 {
+		t.Errorf("allocated lines = %+v", bl)
+	}
+	pickAll(t, r, db, bo)
+	pickAll(t, r, db, *bo2)
+
+	walkOrder(t, NewReservationRepository(db), 1, 0, sales.OrderStatusDepositReceived, sales.OrderStatusFinalPaymentReceived,
+		sales.OrderStatusPreDeliveryInspection, sales.OrderStatusShipped)
+	for _, w := range []uint{1, 2} {
+		if s := stockOf(t, db, 1, w); s.OnHand != 0 || s.Reserved != 0 {
+			t.Errorf("warehouse %d = %d/%d, want 0/0", w, s.OnHand, s.Reserved)
+		}
+	}
+	var sold int64
+	db.Model(&inventory.InventoryTransaction{}).Select("COALESCE(SUM(quantity), 0)").
+		Where("reference = ? AND tx_type = ?", "SO-1", inventory.TransactionTypeSale).Scan(&sold)
+	if sold != 5 {
+		t.Errorf("SALE total = %d, want 5", sold)
+	}
+}
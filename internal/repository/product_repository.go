@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"fmt"
+
 	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/outbox"
+	outbox2 "djj-inventory-system/internal/pkg/outbox"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository struct {
@@ -15,25 +20,105 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{DB: db}
 }
 
-func (r *ProductRepository) Create(ctx context.Context, p *catalog.Product) error {
-	return r.DB.
-		Session(&gorm.Session{FullSaveAssociations: true}).
-		WithContext(ctx).
-		Create(p).
-		Error
+// ProductSnapshot 把事务里重新读出的商品转成事件负载
+type ProductSnapshot func(p *catalog.Product) interface{}
+
+// Create 在一个事务里写商品（含图片附件）、写入各仓库存，并记录 product.created 事件
+func (r *ProductRepository) Create(ctx context.Context, p *catalog.Product, stocks []catalog.ProductStock, snapshot ProductSnapshot) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Create(p).Error; err != nil {
+			return err
+		}
+		if err := replaceStocks(tx, p.ID, stocks); err != nil {
+			return err
+		}
+		return addProductEvent(tx, outbox.TypeProductCreated, p.ID, snapshot)
+	})
 }
 
-func (r *ProductRepository) Update(ctx context.Context, p *catalog.Product) error {
-	return r.DB.WithContext(ctx).Save(p).Error
+// Update 在一个事务里保存商品、补上新增仓库的库存行，并记录 product.updated 事件
+// 已有库存行原样保留（预留量、库位不动），现有量只能通过库存移动（调整、调拨、盘点）变化，
+// stocks 里的现有量和库中不一致时返回 ErrInvalidState
+func (r *ProductRepository) Update(ctx context.Context, p *catalog.Product, stocks []catalog.ProductStock, snapshot ProductSnapshot) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(p).Error; err != nil {
+			return err
+		}
+		if err := syncStocks(tx, p.ID, stocks); err != nil {
+			return err
+		}
+		return addProductEvent(tx, outbox.TypeProductUpdated, p.ID, snapshot)
+	})
 }
 
+// Delete 删除商品并记录 product.deleted 事件；商品不存在时什么也不做
 func (r *ProductRepository) Delete(ctx context.Context, id uint) error {
-	return r.DB.WithContext(ctx).Delete(&catalog.Product{}, id).Error
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&catalog.Product{}, id)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return outbox2.Add(tx, &outbox.Event{Type: outbox.TypeProductDeleted, Entity: "product", EntityID: id}, map[string]uint{"id": id})
+	})
+}
+
+// syncStocks 给表单里新出现的仓库建零库存行；表单里的现有量必须和库中一致，表单里没有的仓库不删除
+func syncStocks(tx *gorm.DB, productID uint, stocks []catalog.ProductStock) error {
+	var existing []catalog.ProductStock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ?", productID).Find(&existing).Error; err != nil {
+		return err
+	}
+	onHand := make(map[uint]int, len(existing))
+	for _, ps := range existing {
+		onHand[ps.WarehouseID] = ps.OnHand
+	}
+	for _, s := range stocks {
+		cur, ok := onHand[s.WarehouseID]
+		if s.OnHand != cur {
+			return fmt.Errorf("%w: on_hand of warehouse %d is %d, change it through a stock adjustment", ErrInvalidState, s.WarehouseID, cur)
+		}
+		if ok {
+			continue
+		}
+		if err := tx.Create(&catalog.ProductStock{ProductID: productID, WarehouseID: s.WarehouseID}).Error; err != nil {
+			return err
+		}
+		onHand[s.WarehouseID] = 0
+	}
+	return nil
+}
+
+// replaceStocks 先删后增，只用于新建商品
+func replaceStocks(tx *gorm.DB, productID uint, stocks []catalog.ProductStock) error {
+	if err := tx.Where("product_id = ?", productID).Delete(&catalog.ProductStock{}).Error; err != nil {
+		return err
+	}
+	for i := range stocks {
+		stocks[i].ProductID = productID
+		if err := tx.Create(&stocks[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addProductEvent 在事务里重新读出商品作为事件负载；商品不属于某个门店，StoreID 为 0
+func addProductEvent(tx *gorm.DB, typ string, id uint, snapshot ProductSnapshot) error {
+	p, err := findProduct(tx, id)
+	if err != nil {
+		return err
+	}
+	return outbox2.Add(tx, &outbox.Event{Type: typ, Entity: "product", EntityID: p.ID, Version: p.Version}, snapshot(p))
 }
 
 func (r *ProductRepository) FindByID(ctx context.Context, id uint) (*catalog.Product, error) {
+	return findProduct(r.DB.WithContext(ctx), id)
+}
+
+func findProduct(db *gorm.DB, id uint) (*catalog.Product, error) {
 	var p catalog.Product
-	err := r.DB.WithContext(ctx).
+	err := db.
 		Preload("Stocks.Warehouse").
 		Preload("Images").
 		Preload("Attachments").
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+// 编辑商品不动已有库存行的预留量和库位，表单改现有量时拒绝
+func TestSyncStocksKeepsExistingRows(t *testing.T) {
+	db := testdb.Open(t, &catalog.ProductStock{})
+	testdb.Exec(t, db, `INSERT INTO product_stocks (id, product_id, warehouse_id, on_hand, reserved, bin_location) VALUES (1, 7, 1, 10, 4, 'A-01')`)
+
+	// 现有量不变，新增仓库 2，表单里没有的仓库不删除
+	if err := syncStocks(db, 7, []catalog.ProductStock{{WarehouseID: 1, OnHand: 10}, {WarehouseID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	var rows []catalog.ProductStock
+	db.Order("warehouse_id").Find(&rows, "product_id = ?", 7)
+	if len(rows) != 2 || rows[0].ID != 1 || rows[0].Reserved != 4 || rows[0].BinLocation != "A-01" || rows[1].OnHand != 0 {
+		t.Fatalf("stocks after sync = %+v", rows)
+	}
+	if err := syncStocks(db, 7, nil); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Model(&catalog.ProductStock{}).Where("product_id = ?", 7).Count(&n)
+	if n != 2 {
+		t.Errorf("rows after empty form = %d, want 2", n)
+	}
+
+	// 改现有量、新仓库带数量都要走库存单据
+	for _, stocks := range [][]catalog.ProductStock{{{WarehouseID: 1, OnHand: 12}}, {{WarehouseID: 3, OnHand: 5}}} {
+		if err := syncStocks(db, 7, stocks); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("sync %+v: err = %v, want ErrInvalidState", stocks, err)
+		}
+	}
+}
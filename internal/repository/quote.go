@@ -3,10 +3,15 @@ package repository
 
 import (
 	"context"
+	"djj-inventory-system/internal/model/catalog"
 	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/scope"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // QuoteRepository 封装对 quotes 表的访问
@@ -23,6 +28,7 @@ func (r *QuoteRepository) FindByID(ctx context.Context, id uint) (*sales.Quote,
 	var q sales.Quote
 	err := r.DB.
 		WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
 		// 公司信息
 		Preload("Company").
 		// 门店信息：门店自身、门店负责人、门店所属区域、以及该区域下的所有仓库
@@ -32,8 +38,9 @@ func (r *QuoteRepository) FindByID(ctx context.Context, id uint) (*sales.Quote,
 		Preload("Store.Region.Warehouses").
 		// 客户信息：客户本身；以及客户所在门店的负责人/区域/仓库
 		Preload("Customer").
+		Preload("SalesRepUser").
 		// 报价明细及明细关联的产品
-		Preload("Items").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
 		Preload("Items.Product").
 		First(&q, "id = ?", id).
 		Error
@@ -46,3 +53,164 @@ func (r *QuoteRepository) FindByID(ctx context.Context, id uint) (*sales.Quote,
 	}
 	return &q, nil
 }
+
+// QuoteFilter 报价列表筛选条件，零值表示不过滤
+type QuoteFilter struct {
+	Status       string
+	StoreID      uint
+	CustomerID   uint
+	SalesRepID   uint
+	Keyword      string // 按报价号模糊匹配
+	AllRevisions bool   // 默认只列最新版本
+}
+
+// List 分页列出报价单（不含明细）
+func (r *QuoteRepository) List(ctx context.Context, f QuoteFilter, offset, limit int) ([]sales.Quote, int64, error) {
+	var (
+		list  []sales.Quote
+		total int64
+	)
+	q := r.DB.WithContext(ctx).Model(&sales.Quote{}).Scopes(scope.ByStore("store_id"))
+	if !f.AllRevisions {
+		q = q.Where("superseded_by_id IS NULL")
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.StoreID != 0 {
+		q = q.Where("store_id = ?", f.StoreID)
+	}
+	if f.CustomerID != 0 {
+		q = q.Where("customer_id = ?", f.CustomerID)
+	}
+	if f.SalesRepID != 0 {
+		q = q.Where("sales_rep_id = ?", f.SalesRepID)
+	}
+	if f.Keyword != "" {
+		q = q.Where("quote_number ILIKE ?", "%"+f.Keyword+"%")
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("Store").
+		Preload("Customer").
+		Preload("SalesRepUser").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// Revisions 按版本顺序列出同一报价的全部版本
+func (r *QuoteRepository) Revisions(ctx context.Context, baseNumber string) ([]sales.Quote, error) {
+	var list []sales.Quote
+	err := r.DB.WithContext(ctx).
+		Scopes(scope.ByStore("store_id")).
+		Where("base_number = ?", baseNumber).
+		Order("id").
+		Find(&list).Error
+	return list, err
+}
+
+// Create 新建报价单（版本 A，含明细），单号按 Q-日期-ID 生成；公司取门店所属公司
+func (r *QuoteRepository) Create(ctx context.Context, q *sales.Quote) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := scope.CheckStore(tx, q.StoreID); err != nil {
+			return err
+		}
+		var store catalog.Store
+		if err := tx.First(&store, q.StoreID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: store %d", ErrNotFound, q.StoreID)
+			}
+			return err
+		}
+		q.CompanyID = store.CompanyID
+
+		// 先用临时单号占位，拿到 ID 后再生成正式单号
+		q.QuoteNumber = fmt.Sprintf("TMP-%d", time.Now().UnixNano())
+		q.Revision = sales.FirstQuoteRevision
+		q.Status = sales.QuoteStatusPending
+		if err := tx.Omit("Store", "Company", "Customer", "SalesRepUser", "Items.Product").Create(q).Error; err != nil {
+			return err
+		}
+		q.BaseNumber = fmt.Sprintf("Q-%s-%05d", q.CreatedAt.Format("20060102"), q.ID)
+		q.QuoteNumber = q.BaseNumber + "-" + q.Revision
+		return tx.Model(q).Updates(map[string]interface{}{
+			"base_number":  q.BaseNumber,
+			"quote_number": q.QuoteNumber,
+		}).Error
+	})
+}
+
+// CreateRevision 基于 prevID 生成下一个版本：旧版本标记为被取代后只读，新版本重新进入待审批
+// 只有最新版本、且尚未转订单的报价可以修订
+func (r *QuoteRepository) CreateRevision(ctx context.Context, prevID uint, next *sales.Quote) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		prev, err := lockCurrentQuote(tx, prevID)
+		if err != nil {
+			return err
+		}
+		var n int64
+		if err := tx.Model(&sales.Order{}).Where("quote_id = ?", prev.ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: quote %s has already been converted to an order", ErrInvalidState, prev.QuoteNumber)
+		}
+
+		next.StoreID = prev.StoreID
+		next.CompanyID = prev.CompanyID
+		next.CustomerID = prev.CustomerID
+		next.BaseNumber = prev.BaseNumber
+		next.Revision = sales.NextQuoteRevision(prev.Revision)
+		next.QuoteNumber = next.BaseNumber + "-" + next.Revision
+		next.Status = sales.QuoteStatusPending
+		if err := tx.Omit("Store", "Company", "Customer", "SalesRepUser", "Items.Product").Create(next).Error; err != nil {
+			return err
+		}
+		return tx.Model(&sales.Quote{}).Where("id = ?", prev.ID).Updates(map[string]interface{}{
+			"superseded_by_id": next.ID,
+			"updated_at":       time.Now(),
+		}).Error
+	})
+}
+
+// Review 审批或拒绝最新版本的待审批报价
+func (r *QuoteRepository) Review(ctx context.Context, id uint, status string, reviewer uint, note string) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q, err := lockCurrentQuote(tx, id)
+		if err != nil {
+			return err
+		}
+		if q.Status != sales.QuoteStatusPending {
+			return fmt.Errorf("%w: quote %s is already %s", ErrInvalidState, q.QuoteNumber, q.Status)
+		}
+		now := time.Now()
+		return tx.Model(&sales.Quote{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewer,
+			"reviewed_at": now,
+			"review_note": note,
+			"updated_at":  now,
+		}).Error
+	})
+}
+
+// lockCurrentQuote 以 FOR UPDATE 读取报价，并要求它是最新版本；数据范围外的按不存在处理
+func lockCurrentQuote(tx *gorm.DB, id uint) (*sales.Quote, error) {
+	var q sales.Quote
+	err := tx.Scopes(scope.ByStore("store_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&q, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !q.IsCurrent() {
+		return nil, fmt.Errorf("%w: quote %s has been superseded by a newer revision", ErrInvalidState, q.QuoteNumber)
+	}
+	return &q, nil
+}
@@ -0,0 +1,50 @@
+// internal/repository/realtime_event_repository.go
+package repository
+
+import (
+	"context"
+
+	"djj-inventory-system/internal/model/realtime"
+
+	"gorm.io/gorm"
+)
+
+// EventRepository websocket 事件日志，只保留最近的一段供断线重连补发
+type EventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// Append 写入事件并回填 Seq
+func (r *EventRepository) Append(ctx context.Context, e *realtime.Event) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+// Since topic 下 seq 之后的事件，按 seq 升序，最多 limit 条
+func (r *EventRepository) Since(ctx context.Context, topic string, seq uint64, limit int) ([]realtime.Event, error) {
+	var list []realtime.Event
+	err := r.db.WithContext(ctx).Where("topic = ? AND seq > ?", topic, seq).
+		Order("seq").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// FirstSeq 日志里最早一条事件的 seq，日志为空时为 0；比它更早的事件已被清理，无法补发
+func (r *EventRepository) FirstSeq(ctx context.Context) (uint64, error) {
+	var seqs []uint64
+	err := r.db.WithContext(ctx).Model(&realtime.Event{}).Order("seq").Limit(1).Pluck("seq", &seqs).Error
+	if err != nil || len(seqs) == 0 {
+		return 0, err
+	}
+	return seqs[0], nil
+}
+
+// Trim 只保留最近 keep 条事件，返回删除条数
+func (r *EventRepository) Trim(ctx context.Context, keep int) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Where("seq <= (SELECT MAX(seq) FROM realtime_events) - ?", keep).
+		Delete(&realtime.Event{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,420 @@
+// internal/repository/reservation_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/outbox"
+	"djj-inventory-system/internal/model/sales"
+	outbox2 "djj-inventory-system/internal/pkg/outbox"
+	"djj-inventory-system/internal/pkg/scope"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationRepository 维护订单驱动的库存预留：下单预留、发货转销售、取消释放、超时清理
+type ReservationRepository struct {
+	db *gorm.DB
+}
+
+func NewReservationRepository(db *gorm.DB) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+// ReservationFilter 预留列表筛选条件，零值表示不过滤
+type ReservationFilter struct {
+	Status      inventory.ReservationStatus
+	ProductID   uint
+	WarehouseID uint
+	OrderID     uint
+}
+
+// ReservationShortage 下单时某条明细可用库存不足、未能预留的数量
+type ReservationShortage struct {
+	OrderItemID uint `json:"orderItemId"`
+	ProductID   uint `json:"productId"`
+	Requested   int  `json:"requested"`
+	Reserved    int  `json:"reserved"`
+	Short       int  `json:"short"`
+}
+
+// OrderTransition 一次订单状态变更的结果
+type OrderTransition struct {
+	OrderID     uint                  `json:"orderId"`
+	OrderNumber string                `json:"orderNumber"`
+	StoreID     uint                  `json:"storeId"`
+	From        string                `json:"from"`
+	To          string                `json:"to"`
+	Shortages   []ReservationShortage `json:"shortages,omitempty"`
+}
+
+// TransitionOrder 在一个事务里修改订单状态并同步预留：
+//   - ordered：为每条明细预留库存，优先本门店所在地区的仓库，可用量不足的部分记为短缺
+//   - deposit_received / final_payment_received / pre_delivery_inspection：已付款，预留不再过期
+//   - shipped：把仍生效的预留转为 SALE 出库（已拣货的预留在确认拣货时已出库），
+//     预留过期或下单时短缺的数量从可用库存直接出库，可用库存不够时不能发货
+//   - cancelled / draft：释放仍生效的预留
+//
+// ttl 为新预留的有效期，<= 0 表示不过期
+func (r *ReservationRepository) TransitionOrder(ctx context.Context, orderID uint, to string, operator string, updatedBy uint, ttl time.Duration) (*OrderTransition, error) {
+	var out *OrderTransition
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var o sales.Order
+		err := tx.Scopes(scope.ByStore("store_id")).Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, orderID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		out, err = transitionOrder(tx, &o, to, operator, updatedBy, ttl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// transitionOrder 在已开启的事务 tx 中推进已加锁的订单 o，规则见 TransitionOrder
+// 供收款等需要和订单状态在同一个事务里提交的场景复用
+func transitionOrder(tx *gorm.DB, o *sales.Order, to, operator string, updatedBy uint, ttl time.Duration) (*OrderTransition, error) {
+	out := &OrderTransition{OrderID: o.ID, OrderNumber: o.OrderNumber, StoreID: o.StoreID, From: o.Status, To: to}
+	if o.Status == to {
+		return out, nil
+	}
+	if !sales.CanTransitionOrder(o.Status, to) {
+		return nil, fmt.Errorf("%w: order %s cannot move from %s to %s", ErrInvalidState, o.OrderNumber, o.Status, to)
+	}
+
+	if err := settlePickingLists(tx, o, to); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": to, "updated_at": now}
+	if updatedBy != 0 {
+		updates["updated_by"] = updatedBy
+	}
+	if err := tx.Model(&sales.Order{}).Where("id = ?", o.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if err := syncOrderReservations(tx, o, to, operator, ttl, out); err != nil {
+		return nil, err
+	}
+	// 订单没有乐观锁版本号，用更新时间（毫秒）作为版本
+	if err := outbox2.Add(tx, &outbox.Event{
+		Type: outbox.TypeOrderStatusChanged, Entity: "order", EntityID: o.ID, StoreID: o.StoreID, Version: now.UnixMilli(),
+	}, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// syncOrderReservations 按订单的新状态处理预留，短缺记到 out
+func syncOrderReservations(tx *gorm.DB, o *sales.Order, to, operator string, ttl time.Duration, out *OrderTransition) error {
+	switch to {
+	case sales.OrderStatusOrdered:
+		if err := tx.Where("order_id = ?", o.ID).Order("id").Find(&o.Items).Error; err != nil {
+			return err
+		}
+		var err error
+		out.Shortages, err = reserveForOrder(tx, o, operator, ttl)
+		return err
+	case sales.OrderStatusDepositReceived, sales.OrderStatusFinalPaymentReceived, sales.OrderStatusPreDeliveryInspection:
+		return tx.Model(&inventory.StockReservation{}).
+			Where("order_id = ? AND status = ?", o.ID, inventory.ReservationStatusActive).
+			Update("expires_at", nil).Error
+	case sales.OrderStatusShipped:
+		return shipOrder(tx, o, operator)
+	case sales.OrderStatusCancelled, sales.OrderStatusDraft:
+		_, err := closeReservations(tx, o.ID, o.OrderNumber, inventory.ReservationStatusReleased, operator)
+		return err
+	}
+	return nil
+}
+
+// ListByOrder 列出订单的全部预留；订单不在调用者数据范围内时返回空列表
+func (r *ReservationRepository) ListByOrder(ctx context.Context, orderID uint) ([]inventory.StockReservation, error) {
+	var list []inventory.StockReservation
+	err := r.db.WithContext(ctx).
+		Scopes(scope.ByOrder("order_id")).
+		Preload("Warehouse").
+		Where("order_id = ?", orderID).
+		Order("id").
+		Find(&list).Error
+	return list, err
+}
+
+// List 分页列出预留
+func (r *ReservationRepository) List(ctx context.Context, f ReservationFilter, offset, limit int) ([]inventory.StockReservation, int64, error) {
+	var (
+		list  []inventory.StockReservation
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&inventory.StockReservation{})
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.ProductID != 0 {
+		q = q.Where("product_id = ?", f.ProductID)
+	}
+	if f.WarehouseID != 0 {
+		q = q.Where("warehouse_id = ?", f.WarehouseID)
+	}
+	if f.OrderID != 0 {
+		q = q.Where("order_id = ?", f.OrderID)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("Warehouse").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// ExpireDue 释放所有已过期的生效预留，返回处理条数
+// 使用 SKIP LOCKED，多实例同时运行清理任务时不会互相阻塞或重复释放
+func (r *ReservationRepository) ExpireDue(ctx context.Context, now time.Time, operator string, batch int) (int, error) {
+	var n int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []inventory.StockReservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", inventory.ReservationStatusActive, now).
+			Order("expires_at").
+			Limit(batch).
+			Find(&due).Error; err != nil {
+			return err
+		}
+
+		numbers, err := orderNumbers(tx, due)
+		if err != nil {
+			return err
+		}
+		for i := range due {
+			if err := closeReservation(tx, &due[i], numbers[due[i].OrderID], inventory.ReservationStatusExpired, operator); err != nil {
+				return err
+			}
+		}
+		n = len(due)
+		return nil
+	})
+	return n, err
+}
+
+// reserveForOrder 为订单每条明细预留尚未预留的数量
+func reserveForOrder(tx *gorm.DB, o *sales.Order, operator string, ttl time.Duration) ([]ReservationShortage, error) {
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	var shortages []ReservationShortage
+	for _, it := range o.Items {
+		// 重新进入 ordered（如退回草稿后再次下单）时只补足差额
+		var already int64
+		if err := tx.Model(&inventory.StockReservation{}).
+			Select("COALESCE(SUM(quantity), 0)").
+			Where("order_item_id = ? AND status = ?", it.ID, inventory.ReservationStatusActive).
+			Scan(&already).Error; err != nil {
+			return nil, err
+		}
+		need := it.Quantity - int(already)
+		if need <= 0 {
+			continue
+		}
+
+		candidates, err := reservableStocks(tx, o.StoreID, it.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		reserved := 0
+		for _, c := range candidates {
+			if need == 0 {
+				break
+			}
+			qty := c.Available
+			if qty > need {
+				qty = need
+			}
+			note := fmt.Sprintf("订单 %s 预留", o.OrderNumber)
+			if err := applyReservation(tx, it.ProductID, c.WarehouseID, qty, operator, note, o.OrderNumber); err != nil {
+				return nil, fmt.Errorf("reserve product %d: %w", it.ProductID, err)
+			}
+			res := inventory.StockReservation{
+				OrderID:     o.ID,
+				OrderItemID: it.ID,
+				ProductID:   it.ProductID,
+				WarehouseID: c.WarehouseID,
+				Quantity:    qty,
+				Status:      inventory.ReservationStatusActive,
+				ExpiresAt:   expiresAt,
+				CreatedBy:   operator,
+			}
+			if err := tx.Omit("Warehouse").Create(&res).Error; err != nil {
+				return nil, err
+			}
+			need -= qty
+			reserved += qty
+		}
+		if need > 0 {
+			shortages = append(shortages, ReservationShortage{
+				OrderItemID: it.ID,
+				ProductID:   it.ProductID,
+				Requested:   it.Quantity,
+				Reserved:    int(already) + reserved,
+				Short:       need,
+			})
+		}
+	}
+	return shortages, nil
+}
+
+// reservableStock 可用于预留的库存行
+type reservableStock struct {
+	WarehouseID uint
+	Available   int
+}
+
+// reservableStocks 按优先级列出某产品有可用量的仓库：本门店所在地区的仓库优先，其次按可用量从大到小
+func reservableStocks(tx *gorm.DB, storeID, productID uint) ([]reservableStock, error) {
+	var out []reservableStock
+	err := tx.Table("product_stocks AS ps").
+		Select("ps.warehouse_id AS warehouse_id, ps.on_hand - ps.reserved AS available").
+		Joins("LEFT JOIN region_warehouses AS rw ON rw.warehouse_id = ps.warehouse_id AND rw.region_id = (SELECT region_id FROM stores WHERE id = ?)", storeID).
+		Where("ps.product_id = ? AND ps.on_hand > ps.reserved", productID).
+		Order("(rw.warehouse_id IS NOT NULL) DESC, available DESC, ps.warehouse_id").
+		Scan(&out).Error
+	return out, err
+}
+
+// shipOrder 发货时让订单每条明细的全部数量都出库：
+// 已拣货的部分在确认拣货时已写 SALE；生效预留转为 SALE；剩下的（预留过期、下单时短缺）按可用库存直接写 SALE
+// 可用库存不足以发完时返回 ErrInsufficientStock
+func shipOrder(tx *gorm.DB, o *sales.Order, operator string) error {
+	if err := tx.Where("order_id = ?", o.ID).Order("id").Find(&o.Items).Error; err != nil {
+		return err
+	}
+	picked, err := pickedByOrderItem(tx, o.ID)
+	if err != nil {
+		return err
+	}
+	consumed, err := closeReservations(tx, o.ID, o.OrderNumber, inventory.ReservationStatusConsumed, operator)
+	if err != nil {
+		return err
+	}
+	shipped := make(map[uint]int, len(o.Items))
+	for _, res := range consumed {
+		shipped[res.OrderItemID] += res.Quantity
+	}
+
+	note := fmt.Sprintf("订单 %s 发货，未预留部分直接出库", o.OrderNumber)
+	for _, it := range o.Items {
+		need := it.Quantity - picked[it.ID] - shipped[it.ID]
+		if need <= 0 {
+			continue
+		}
+		candidates, err := reservableStocks(tx, o.StoreID, it.ProductID)
+		if err != nil {
+			return err
+		}
+		for _, c := range candidates {
+			if need == 0 {
+				break
+			}
+			qty := c.Available
+			if qty > need {
+				qty = need
+			}
+			if err := applyStockMovement(tx, it.ProductID, c.WarehouseID, qty,
+				inventory.TransactionTypeSale, operator, note, o.OrderNumber); err != nil {
+				return fmt.Errorf("ship product %d: %w", it.ProductID, err)
+			}
+			need -= qty
+		}
+		if need > 0 {
+			return fmt.Errorf("%w: order %s is %d short of product %d and cannot be shipped",
+				ErrInsufficientStock, o.OrderNumber, need, it.ProductID)
+		}
+	}
+	return nil
+}
+
+// closeReservations 关闭订单所有生效预留：consumed 转为销售出库，其余只释放预留量；返回关闭的预留
+func closeReservations(tx *gorm.DB, orderID uint, orderNumber string, status inventory.ReservationStatus, operator string) ([]inventory.StockReservation, error) {
+	var active []inventory.StockReservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, inventory.ReservationStatusActive).
+		Order("id").
+		Find(&active).Error; err != nil {
+		return nil, err
+	}
+	for i := range active {
+		if err := closeReservation(tx, &active[i], orderNumber, status, operator); err != nil {
+			return nil, err
+		}
+	}
+	return active, nil
+}
+
+// closeReservation 释放一条预留；status 为 consumed 时随后写 SALE 扣减现有量
+func closeReservation(tx *gorm.DB, res *inventory.StockReservation, orderNumber string, status inventory.ReservationStatus, operator string) error {
+	var note string
+	switch status {
+	case inventory.ReservationStatusConsumed:
+		note = fmt.Sprintf("订单 %s 发货，预留转销售", orderNumber)
+	case inventory.ReservationStatusExpired:
+		note = fmt.Sprintf("订单 %s 预留过期", orderNumber)
+	default:
+		note = fmt.Sprintf("订单 %s 释放预留", orderNumber)
+	}
+
+	if err := applyRelease(tx, res.ProductID, res.WarehouseID, res.Quantity, operator, note, orderNumber); err != nil {
+		return fmt.Errorf("release reservation %d: %w", res.ID, err)
+	}
+	if status == inventory.ReservationStatusConsumed {
+		if err := applyStockMovement(tx, res.ProductID, res.WarehouseID, res.Quantity,
+			inventory.TransactionTypeSale, operator, note, orderNumber); err != nil {
+			return fmt.Errorf("ship reservation %d: %w", res.ID, err)
+		}
+	}
+
+	now := time.Now()
+	return tx.Model(&inventory.StockReservation{}).
+		Where("id = ?", res.ID).
+		Updates(map[string]interface{}{
+			"status":    status,
+			"closed_at": now,
+		}).Error
+}
+
+// orderNumbers 批量读取预留对应的订单号，用作流水 Reference
+func orderNumbers(tx *gorm.DB, list []inventory.StockReservation) (map[uint]string, error) {
+	out := make(map[uint]string)
+	if len(list) == 0 {
+		return out, nil
+	}
+	ids := make([]uint, 0, len(list))
+	for _, r := range list {
+		ids = append(ids, r.OrderID)
+	}
+	var orders []sales.Order
+	if err := tx.Select("id", "order_number").Where("id IN ?", ids).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		out[o.ID] = o.OrderNumber
+	}
+	return out, nil
+}
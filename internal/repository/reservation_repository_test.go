@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/testdb"
+
+	"gorm.io/gorm"
+)
+
+// newOrderTestDB 门店 1 在地区 1，仓库 1 属于地区 1、仓库 2 不属于；订单表只建状态流转和拣货用到的列
+func newOrderTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := newStockTestDB(t, &inventory.StockReservation{}, &sales.PickingList{}, &sales.PickingListItem{}, &catalog.RegionWarehouse{})
+	testdb.Exec(t, db,
+		`CREATE TABLE stores (id INTEGER PRIMARY KEY, region_id INTEGER)`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY, store_id INTEGER, order_number TEXT, shipping_address TEXT,
+			location TEXT, status TEXT, sales_rep_id INTEGER, updated_by INTEGER, updated_at DATETIME)`,
+		`CREATE TABLE order_items (id INTEGER PRIMARY KEY, order_id INTEGER, product_id INTEGER, quantity INTEGER)`,
+		`INSERT INTO stores (id, region_id) VALUES (1, 1)`,
+		`INSERT INTO region_warehouses (region_id, warehouse_id) VALUES (1, 1)`,
+	)
+	return db
+}
+
+// seedOrder 在门店 1 建一张草稿订单，items 为 产品 ID → 数量，明细 ID 依次从 orderID*10+1 开始
+func seedOrder(t *testing.T, db *gorm.DB, orderID uint, number string, items ...[2]int) {
+	t.Helper()
+	if err := db.Exec(`INSERT INTO orders (id, store_id, order_number, shipping_address, location, status, sales_rep_id)
+		VALUES (?, 1, ?, 'addr', 'store', 'draft', 1)`, orderID, number).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i, it := range items {
+		if err := db.Exec(`INSERT INTO order_items (id, order_id, product_id, quantity) VALUES (?, ?, ?, ?)`,
+			orderID*10+uint(i)+1, orderID, it[0], it[1]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func reservationsOf(t *testing.T, db *gorm.DB, orderID uint) []inventory.StockReservation {
+	t.Helper()
+	var list []inventory.StockReservation
+	if err := db.Where("order_id = ?", orderID).Order("id").Find(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func walkOrder(t *testing.T, r *ReservationRepository, orderID uint, ttl time.Duration, statuses ...string) {
+	t.Helper()
+	for _, s := range statuses {
+		if _, err := r.TransitionOrder(context.Background(), orderID, s, "tester", 1, ttl); err != nil {
+			t.Fatalf("-> %s: %v", s, err)
+		}
+	}
+}
+
+// 下单预留本地区仓库优先；过期的预留被释放，收款后的预留不再过期
+func TestReservationExpiry(t *testing.T) {
+	db := newOrderTestDB(t)
+	seedStock(t, db, 1, 1, 4, 0)
+	seedStock(t, db, 1, 2, 10, 0)
+	seedOrder(t, db, 1, "SO-1", [2]int{1, 6})
+	seedOrder(t, db, 2, "SO-2", [2]int{1, 2})
+	r := NewReservationRepository(db)
+	ctx := context.Background()
+
+	walkOrder(t, r, 1, time.Hour, sales.OrderStatusOrdered)
+	res := reservationsOf(t, db, 1)
+	if len(res) != 2 || res[0].WarehouseID != 1 || res[0].Quantity != 4 || res[1].WarehouseID != 2 || res[1].Quantity != 2 {
+		t.Fatalf("reservations = %+v, want 4 from warehouse 1 then 2 from warehouse 2", res)
+	}
+	walkOrder(t, r, 2, time.Hour, sales.OrderStatusOrdered, sales.OrderStatusDepositReceived)
+
+	if n, err := r.ExpireDue(ctx, time.Now(), "sweeper", 100); err != nil || n != 0 {
+		t.Fatalf("early sweep: n = %d, err = %v", n, err)
+	}
+	if n, err := r.ExpireDue(ctx, time.Now().Add(2*time.Hour), "sweeper", 100); err != nil || n != 2 {
+		t.Fatalf("sweep: n = %d, err = %v, want 2", n, err)
+	}
+	for _, res := range reservationsOf(t, db, 1) {
+		if res.Status != inventory.ReservationStatusExpired || res.ClosedAt == nil {
+			t.Errorf("reservation %d: status %s", res.ID, res.Status)
+		}
+	}
+	if res := reservationsOf(t, db, 2); len(res) != 1 || res[0].Status != inventory.ReservationStatusActive {
+		t.Errorf("paid order reservation = %+v, want still active", res)
+	}
+	if s := stockOf(t, db, 1, 1); s.OnHand != 4 || s.Reserved != 0 {
+		t.Errorf("warehouse 1 = %d/%d, want 4/0", s.OnHand, s.Reserved)
+	}
+	if s := stockOf(t, db, 1, 2); s.OnHand != 10 || s.Reserved != 2 {
+		t.Errorf("warehouse 2 = %d/%d, want 10/2", s.OnHand, s.Reserved)
+	}
+}
+
+// 发货时订单全部数量都要出库：生效预留转 SALE，过期和下单短缺的部分从可用库存直接出库
+func TestShipOrderPostsFullQuantity(t *testing.T) {
+	db := newOrderTestDB(t)
+	seedStock(t, db, 1, 1, 10, 0)
+	seedStock(t, db, 2, 1, 3, 0)
+	seedOrder(t, db, 1, "SO-1", [2]int{1, 6}, [2]int{2, 5})
+	r := NewReservationRepository(db)
+	ctx := context.Background()
+
+	tr, err := r.TransitionOrder(ctx, 1, sales.OrderStatusOrdered, "tester", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Shortages) != 1 || tr.Shortages[0].ProductID != 2 || tr.Shortages[0].Short != 2 {
+		t.Fatalf("shortages = %+v, want product 2 short 2", tr.Shortages)
+	}
+	// 产品 1 的预留过期
+	if _, err := r.ExpireDue(ctx, time.Now().Add(2*time.Hour), "sweeper", 100); err != nil {
+		t.Fatal(err)
+	}
+	walkOrder(t, r, 1, 0, sales.OrderStatusDepositReceived, sales.OrderStatusFinalPaymentReceived, sales.OrderStatusPreDeliveryInspection)
+
+	// 短缺的 2 件还没到货，不能发货，整个事务回滚
+	if _, err := r.TransitionOrder(ctx, 1, sales.OrderStatusShipped, "tester", 1, 0); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("ship short order: err = %v, want ErrInsufficientStock", err)
+	}
+	var status string
+	db.Raw(`SELECT status FROM orders WHERE id = 1`).Scan(&status)
+	if status != sales.OrderStatusPreDeliveryInspection {
+		t.Errorf("status after failed ship = %s", status)
+	}
+	if s := stockOf(t, db, 1, 1); s.OnHand != 10 {
+		t.Errorf("product 1 on hand = %d, want 10 after rollback", s.OnHand)
+	}
+
+	// 到货 2 件到仓库 2 后可以发货
+	if err := applyStockMovement(db, 2, 2, 2, inventory.TransactionTypeIn, "tester", "", "PO-1"); err != nil {
+		t.Fatal(err)
+	}
+	walkOrder(t, r, 1, 0, sales.OrderStatusShipped)
+
+	for _, tc := range []struct {
+		product, warehouse uint
+		onHand             int
+	}{{1, 1, 4}, {2, 1, 0}, {2, 2, 0}} {
+		if s := stockOf(t, db, tc.product, tc.warehouse); s.OnHand != tc.onHand || s.Reserved != 0 {
+			t.Errorf("product %d warehouse %d = %d/%d, want %d/0", tc.product, tc.warehouse, s.OnHand, s.Reserved, tc.onHand)
+		}
+	}
+	var sold int64
+	db.Model(&inventory.InventoryTransaction{}).Select("COALESCE(SUM(quantity), 0)").
+		Where("reference = ? AND tx_type = ?", "SO-1", inventory.TransactionTypeSale).Scan(&sold)
+	if sold != 11 {
+		t.Errorf("SALE total = %d, want 11", sold)
+	}
+	for _, res := range reservationsOf(t, db, 1) {
+		if res.Status == inventory.ReservationStatusActive {
+			t.Errorf("reservation %d still active after shipping", res.ID)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/pkg/scope"
+	"djj-inventory-system/internal/pkg/testdb"
+
+	"gorm.io/gorm"
+)
+
+// 两个地区：地区 1 有门店 1、2，地区 2 有门店 3；每个门店一个客户、一张订单
+func newScopeTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := testdb.Open(t)
+	stmts := []string{
+		`CREATE TABLE regions (id INTEGER PRIMARY KEY, name TEXT, deleted_at DATETIME)`,
+		`CREATE TABLE companies (id INTEGER PRIMARY KEY, code TEXT, name TEXT, deleted_at DATETIME)`,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT, deleted_at DATETIME)`,
+		`CREATE TABLE stores (id INTEGER PRIMARY KEY, code TEXT, name TEXT, region_id INTEGER, company_id INTEGER,
+			manager_id INTEGER, is_deleted BOOLEAN DEFAULT false, deleted_at DATETIME)`,
+		`CREATE TABLE customers (id INTEGER PRIMARY KEY AUTOINCREMENT, store_id INTEGER, type TEXT, company TEXT, name TEXT,
+			phone TEXT, email TEXT, abn TEXT, address TEXT, contact TEXT, version INTEGER DEFAULT 1,
+			created_at DATETIME, updated_at DATETIME, is_deleted BOOLEAN DEFAULT false)`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY, store_id INTEGER, customer_id INTEGER, sales_rep_id INTEGER,
+			order_number TEXT, status TEXT, created_at DATETIME)`,
+		`CREATE TABLE order_items (id INTEGER PRIMARY KEY, order_id INTEGER, product_id INTEGER, quantity INTEGER)`,
+		`INSERT INTO regions (id, name) VALUES (1, 'north'), (2, 'south')`,
+		`INSERT INTO companies (id, code, name) VALUES (1, 'DJJ', 'DJJ')`,
+		`INSERT INTO stores (id, code, name, region_id, company_id) VALUES (1, 'S1', 's1', 1, 1), (2, 'S2', 's2', 1, 1), (3, 'S3', 's3', 2, 1)`,
+		`INSERT INTO customers (id, store_id, name) VALUES (1, 1, 'c1'), (2, 2, 'c2'), (3, 3, 'c3')`,
+		`INSERT INTO orders (id, store_id, customer_id, order_number, status) VALUES (1, 1, 1, 'SO-1', 'ordered'), (2, 2, 2, 'SO-2', 'ordered'), (3, 3, 3, 'SO-3', 'ordered')`,
+	}
+	testdb.Exec(t, db, stmts...)
+	return db
+}
+
+func TestScopedReads(t *testing.T) {
+	db := newScopeTestDB(t)
+	customers := NewCustomerRepo(db)
+	orders := NewOrderRepository(db)
+
+	cases := []struct {
+		name  string
+		scope scope.Scope
+		want  []uint // 能看到的客户 / 订单 ID（测试数据里两者一一对应）
+	}{
+		{"rep", scope.Scope{Level: scope.LevelStore, StoreID: 1, RegionID: 1}, []uint{1}},
+		{"leader", scope.Scope{Level: scope.LevelRegion, StoreID: 1, RegionID: 1}, []uint{1, 2}},
+		{"admin", scope.Scope{Level: scope.LevelAll}, []uint{1, 2, 3}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := scope.WithContext(context.Background(), tc.scope)
+			visible := map[uint]bool{}
+			for _, id := range tc.want {
+				visible[id] = true
+			}
+
+			list, err := customers.GetAll(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != len(tc.want) {
+				t.Errorf("customers: got %d, want %d", len(list), len(tc.want))
+			}
+			orderList, total, err := orders.List(ctx, OrderFilter{}, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != int64(len(tc.want)) || len(orderList) != len(tc.want) {
+				t.Errorf("orders: got %d (total %d), want %d", len(orderList), total, len(tc.want))
+			}
+
+			for id := uint(1); id <= 3; id++ {
+				_, err := customers.GetByID(ctx, id)
+				if visible[id] && err != nil {
+					t.Errorf("customer %d: unexpected error %v", id, err)
+				}
+				if !visible[id] && !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("customer %d: want not found, got %v", id, err)
+				}
+				_, err = orders.FindByID(ctx, id)
+				if visible[id] && err != nil {
+					t.Errorf("order %d: unexpected error %v", id, err)
+				}
+				if !visible[id] && !errors.Is(err, ErrNotFound) {
+					t.Errorf("order %d: want ErrNotFound, got %v", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestScopedWrites(t *testing.T) {
+	db := newScopeTestDB(t)
+	customers := NewCustomerRepo(db)
+	rep := scope.WithContext(context.Background(), scope.Scope{Level: scope.LevelStore, StoreID: 1, RegionID: 1})
+	leader := scope.WithContext(context.Background(), scope.Scope{Level: scope.LevelRegion, StoreID: 1, RegionID: 1})
+
+	if err := customers.Create(rep, &catalog.Customer{StoreID: 2, Name: "x"}); !errors.Is(err, scope.ErrOutOfScope) {
+		t.Errorf("rep create in other store: want ErrOutOfScope, got %v", err)
+	}
+	if err := customers.Create(leader, &catalog.Customer{StoreID: 3, Name: "x"}); !errors.Is(err, scope.ErrOutOfScope) {
+		t.Errorf("leader create in other region: want ErrOutOfScope, got %v", err)
+	}
+	if err := customers.Create(leader, &catalog.Customer{StoreID: 2, Name: "x"}); err != nil {
+		t.Errorf("leader create in own region: %v", err)
+	}
+	if err := customers.Update(rep, &catalog.Customer{ID: 2, Name: "renamed"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rep update other store's customer: want ErrNotFound, got %v", err)
+	}
+	if err := customers.Update(rep, &catalog.Customer{ID: 1, StoreID: 3}); !errors.Is(err, scope.ErrOutOfScope) {
+		t.Errorf("rep move customer out of scope: want ErrOutOfScope, got %v", err)
+	}
+	if err := customers.Delete(rep, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rep delete other store's customer: want ErrNotFound, got %v", err)
+	}
+
+	// 没有数据范围的调用（后台任务）不受限制
+	if _, err := customers.GetByID(context.Background(), 3); err != nil {
+		t.Errorf("unscoped read: %v", err)
+	}
+}
+
+func TestScopedReservationsByOrder(t *testing.T) {
+	db := newScopeTestDB(t)
+	if err := db.AutoMigrate(&inventory.StockReservation{}); err != nil {
+		t.Fatal(err)
+	}
+	testdb.Exec(t, db,
+		`INSERT INTO warehouses (id, name) VALUES (1, 'w1')`,
+		`INSERT INTO stock_reservations (order_id, order_item_id, product_id, warehouse_id, quantity, status, created_by)
+			VALUES (1, 1, 1, 1, 2, 'active', 'seed'), (3, 3, 1, 1, 5, 'active', 'seed')`)
+	reservations := NewReservationRepository(db)
+	rep := scope.WithContext(context.Background(), scope.Scope{Level: scope.LevelStore, StoreID: 1, RegionID: 1})
+
+	if list, err := reservations.ListByOrder(rep, 1); err != nil || len(list) != 1 {
+		t.Errorf("own order: got %d reservations, err %v", len(list), err)
+	}
+	// 门店 3 的订单对门店 1 的销售不可见，它的预留也不能按订单 ID 直接读出来
+	if list, err := reservations.ListByOrder(rep, 3); err != nil || len(list) != 0 {
+		t.Errorf("other store's order: got %d reservations, err %v", len(list), err)
+	}
+}
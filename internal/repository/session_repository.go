@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/rbac"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTokenReused 已轮换 / 已吊销的刷新令牌再次被使用，整条登录链路已作废
+var ErrTokenReused = errors.New("refresh token has already been used")
+
+// SessionRepository 刷新令牌与访问令牌吊销列表
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// CreateRefresh 保存新签发的刷新令牌（登录时，新的 FamilyID）
+func (r *SessionRepository) CreateRefresh(ctx context.Context, t *rbac.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+// Rotate 用旧刷新令牌换新令牌，返回旧令牌（调用方据此取用户）
+//   - 不存在：ErrNotFound
+//   - 已过期：ErrInvalidState
+//   - 已被轮换或吊销：判定为重放，作废整条链路（含仍在有效期的访问令牌）后返回 ErrTokenReused
+func (r *SessionRepository) Rotate(ctx context.Context, oldHash string, next *rbac.RefreshToken, now time.Time) (*rbac.RefreshToken, error) {
+	var (
+		old    rbac.RefreshToken
+		reused bool
+	)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", oldHash).First(&old).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if old.ReplacedByID != nil || old.RevokedAt != nil {
+			// 吊销要提交，不能随错误一起回滚
+			reused = true
+			return revokeFamily(tx, old.FamilyID, now)
+		}
+		if !now.Before(old.ExpiresAt) {
+			return fmt.Errorf("%w: refresh token expired", ErrInvalidState)
+		}
+
+		next.UserID = old.UserID
+		next.FamilyID = old.FamilyID
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		return tx.Model(&rbac.RefreshToken{}).Where("id = ?", old.ID).Update("replaced_by_id", next.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrTokenReused
+	}
+	return &old, nil
+}
+
+// RevokeFamily 注销一次登录：作废刷新令牌所在链路，返回所属用户；令牌不存在时返回 ErrNotFound
+func (r *SessionRepository) RevokeFamily(ctx context.Context, hash string, now time.Time) (uint, error) {
+	var t rbac.RefreshToken
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("token_hash = ?", hash).First(&t).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return revokeFamily(tx, t.FamilyID, now)
+	})
+	return t.UserID, err
+}
+
+// RevokeUser 注销用户的全部登录，返回作废的刷新令牌数
+func (r *SessionRepository) RevokeUser(ctx context.Context, userID uint, now time.Time) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := revokeAccessOf(tx, now, "user_id = ? AND revoked_at IS NULL", userID); err != nil {
+			return err
+		}
+		res := tx.Model(&rbac.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now)
+		n = res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}
+
+// RevokeAccess 把单个访问令牌加入吊销列表（重复吊销忽略）
+func (r *SessionRepository) RevokeAccess(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&rbac.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}).Error
+}
+
+// IsRevoked 访问令牌是否在吊销列表中
+func (r *SessionRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&rbac.RevokedToken{}).Where("jti = ?", jti).Count(&n).Error
+	return n > 0, err
+}
+
+// PurgeExpired 清理已过期的吊销记录和刷新令牌（过期后它们不再有任何作用）
+func (r *SessionRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("expires_at < ?", now).Delete(&rbac.RevokedToken{})
+		if res.Error != nil {
+			return res.Error
+		}
+		n = res.RowsAffected
+		res = tx.Where("expires_at < ?", now).Delete(&rbac.RefreshToken{})
+		n += res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}
+
+// revokeFamily 作废整条链路：未吊销的刷新令牌标记 revoked_at，同时签发的访问令牌进吊销列表
+func revokeFamily(tx *gorm.DB, familyID string, now time.Time) error {
+	if err := revokeAccessOf(tx, now, "family_id = ? AND revoked_at IS NULL", familyID); err != nil {
+		return err
+	}
+	return tx.Model(&rbac.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+// revokeAccessOf 把 query 选中的刷新令牌对应、仍在有效期内的访问令牌加入吊销列表
+func revokeAccessOf(tx *gorm.DB, now time.Time, query string, args ...interface{}) error {
+	var live []rbac.RefreshToken
+	if err := tx.Where(query, args...).Where("access_expires_at > ?", now).Find(&live).Error; err != nil {
+		return err
+	}
+	if len(live) == 0 {
+		return nil
+	}
+	revoked := make([]rbac.RevokedToken, len(live))
+	for i, t := range live {
+		revoked[i] = rbac.RevokedToken{JTI: t.AccessJTI, UserID: t.UserID, ExpiresAt: t.AccessExpiresAt}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
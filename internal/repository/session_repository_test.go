@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/testdb"
+)
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	db := testdb.Open(t, &rbac.RefreshToken{}, &rbac.RevokedToken{})
+	repo := NewSessionRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	first := &rbac.RefreshToken{UserID: 7, FamilyID: "fam", TokenHash: "h1", AccessJTI: "j1",
+		AccessExpiresAt: now.Add(15 * time.Minute), ExpiresAt: now.Add(24 * time.Hour)}
+	if err := repo.CreateRefresh(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := &rbac.RefreshToken{TokenHash: "h2", AccessJTI: "j2",
+		AccessExpiresAt: now.Add(15 * time.Minute), ExpiresAt: now.Add(24 * time.Hour)}
+	old, err := repo.Rotate(ctx, "h1", second, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.UserID != 7 || second.UserID != 7 || second.FamilyID != "fam" {
+		t.Fatalf("rotation did not carry user / family: old=%+v next=%+v", old, second)
+	}
+
+	// 旧令牌被重放：整条链路作废，两次签发的访问令牌都进吊销列表
+	third := &rbac.RefreshToken{TokenHash: "h3", AccessJTI: "j3",
+		AccessExpiresAt: now.Add(15 * time.Minute), ExpiresAt: now.Add(24 * time.Hour)}
+	if _, err := repo.Rotate(ctx, "h1", third, now); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("replayed token: want ErrTokenReused, got %v", err)
+	}
+	for _, jti := range []string{"j1", "j2"} {
+		revoked, err := repo.IsRevoked(ctx, jti)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !revoked {
+			t.Errorf("access token %s should be revoked after reuse", jti)
+		}
+	}
+	if _, err := repo.Rotate(ctx, "h2", third, now); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("token of revoked family: want ErrTokenReused, got %v", err)
+	}
+	if _, err := repo.Rotate(ctx, "missing", third, now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown token: want ErrNotFound, got %v", err)
+	}
+}
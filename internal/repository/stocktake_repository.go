@@ -0,0 +1,372 @@
+// internal/repository/stocktake_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StocktakeRepository 封装盘点单的读写，过账时把已批准的差异写成 ADJUST 流水
+type StocktakeRepository struct {
+	db *gorm.DB
+}
+
+func NewStocktakeRepository(db *gorm.DB) *StocktakeRepository {
+	return &StocktakeRepository{db: db}
+}
+
+// StocktakeFilter 盘点单列表筛选条件，零值表示不过滤
+type StocktakeFilter struct {
+	Status      inventory.StocktakeStatus
+	WarehouseID uint
+}
+
+// StocktakeCount 一条计数：Add 为 true 时累加（扫码），否则覆盖（手工录入）
+type StocktakeCount struct {
+	ProductID uint
+	Quantity  int
+	Add       bool
+}
+
+// StocktakeDecision 主管对一条差异的审核结果
+type StocktakeDecision struct {
+	LineID   uint
+	Approved bool
+	Note     string
+}
+
+// Create 新建盘点单并冻结快照；productIDs 为空时盘点该仓库全部有库存记录的产品
+func (r *StocktakeRepository) Create(ctx context.Context, st *inventory.Stocktake, productIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 同一仓库同时只允许一张未完成的盘点单
+		var open int64
+		if err := tx.Model(&inventory.Stocktake{}).
+			Where("warehouse_id = ? AND status IN ?", st.WarehouseID,
+				[]inventory.StocktakeStatus{inventory.StocktakeStatusCounting, inventory.StocktakeStatusReviewing}).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return fmt.Errorf("%w: warehouse %d already has an open stocktake", ErrInvalidState, st.WarehouseID)
+		}
+
+		// 快照读取时锁住库存行，保证快照和建单时刻一致
+		var stocks []catalog.ProductStock
+		q := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("warehouse_id = ?", st.WarehouseID)
+		if len(productIDs) > 0 {
+			q = q.Where("product_id IN ?", productIDs)
+		}
+		if err := q.Order("product_id").Find(&stocks).Error; err != nil {
+			return err
+		}
+		onHand := make(map[uint]int, len(stocks))
+		for _, s := range stocks {
+			onHand[s.ProductID] = s.OnHand
+			st.Lines = append(st.Lines, inventory.StocktakeLine{ProductID: s.ProductID, SnapshotQty: s.OnHand})
+		}
+		// 指定了但仓库里还没有库存记录的产品，快照为 0
+		for _, pid := range productIDs {
+			if _, ok := onHand[pid]; !ok {
+				onHand[pid] = 0
+				st.Lines = append(st.Lines, inventory.StocktakeLine{ProductID: pid})
+			}
+		}
+		if len(st.Lines) == 0 {
+			return fmt.Errorf("%w: nothing to count in warehouse %d", ErrNotFound, st.WarehouseID)
+		}
+
+		// 先用临时单号占位，拿到 ID 后再生成正式单号
+		st.StocktakeNumber = fmt.Sprintf("TMP-%d", time.Now().UnixNano())
+		st.Status = inventory.StocktakeStatusCounting
+		st.SnapshotAt = time.Now()
+		if err := tx.Omit("Warehouse", "Lines").Create(st).Error; err != nil {
+			return err
+		}
+		for i := range st.Lines {
+			st.Lines[i].StocktakeID = st.ID
+		}
+		if err := tx.Omit("Product").CreateInBatches(&st.Lines, 500).Error; err != nil {
+			return err
+		}
+		st.StocktakeNumber = fmt.Sprintf("ST-%s-%05d", st.CreatedAt.Format("20060102"), st.ID)
+		return tx.Model(st).Update("stocktake_number", st.StocktakeNumber).Error
+	})
+}
+
+// FindByID 读取盘点单及明细
+func (r *StocktakeRepository) FindByID(ctx context.Context, id uint) (*inventory.Stocktake, error) {
+	var st inventory.Stocktake
+	err := r.db.WithContext(ctx).
+		Preload("Warehouse").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id") }).
+		Preload("Lines.Product").
+		First(&st, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &st, err
+}
+
+// List 分页列出盘点单（不含明细）
+func (r *StocktakeRepository) List(ctx context.Context, f StocktakeFilter, offset, limit int) ([]inventory.Stocktake, int64, error) {
+	var (
+		list  []inventory.Stocktake
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&inventory.Stocktake{})
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.WarehouseID != 0 {
+		q = q.Where("warehouse_id = ?", f.WarehouseID)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("Warehouse").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// ProductIDsByCode 按 DJJ 编码查产品 ID，用于扫码 / Excel 导入计数
+func (r *StocktakeRepository) ProductIDsByCode(ctx context.Context, codes []string) (map[string]uint, error) {
+	var rows []catalog.Product
+	if err := r.db.WithContext(ctx).Select("id", "djj_code").Where("djj_code IN ?", codes).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]uint, len(rows))
+	for _, p := range rows {
+		out[p.DJJCode] = p.ID
+	}
+	return out, nil
+}
+
+// RecordCounts 录入计数；快照中没有的产品（账外盘盈）按当前现有量补一行快照
+func (r *StocktakeRepository) RecordCounts(ctx context.Context, id uint, counts []StocktakeCount, operator string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		st, err := lockStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if st.Status != inventory.StocktakeStatusCounting {
+			return fmt.Errorf("%w: stocktake %s is %s", ErrInvalidState, st.StocktakeNumber, st.Status)
+		}
+
+		lines := make(map[uint]*inventory.StocktakeLine, len(st.Lines))
+		for i := range st.Lines {
+			lines[st.Lines[i].ProductID] = &st.Lines[i]
+		}
+
+		now := time.Now()
+		for _, c := range counts {
+			ln, ok := lines[c.ProductID]
+			if !ok {
+				var stock catalog.ProductStock
+				err := tx.Where("product_id = ? AND warehouse_id = ?", c.ProductID, st.WarehouseID).First(&stock).Error
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+				ln = &inventory.StocktakeLine{StocktakeID: st.ID, ProductID: c.ProductID, SnapshotQty: stock.OnHand}
+				if err := tx.Omit("Product").Create(ln).Error; err != nil {
+					return err
+				}
+				lines[c.ProductID] = ln
+			}
+
+			qty := c.Quantity
+			if c.Add && ln.CountedQty != nil {
+				qty += *ln.CountedQty
+			}
+			if qty < 0 {
+				return fmt.Errorf("product %d: counted quantity must not be negative", c.ProductID)
+			}
+			ln.CountedQty = &qty
+			if err := tx.Model(&inventory.StocktakeLine{}).
+				Where("id = ?", ln.ID).
+				Updates(map[string]interface{}{
+					"counted_qty": qty,
+					"counted_by":  operator,
+					"counted_at":  now,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Submit 结束计数并计算差异；uncountedAsZero 为 false 时要求每一行都已计数
+// 无差异的行自动批准，有差异的行等待主管审核
+func (r *StocktakeRepository) Submit(ctx context.Context, id uint, uncountedAsZero bool, operator string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		st, err := lockStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if st.Status != inventory.StocktakeStatusCounting {
+			return fmt.Errorf("%w: stocktake %s is %s", ErrInvalidState, st.StocktakeNumber, st.Status)
+		}
+
+		uncounted := 0
+		for _, ln := range st.Lines {
+			if ln.CountedQty == nil {
+				uncounted++
+			}
+		}
+		if uncounted > 0 && !uncountedAsZero {
+			return fmt.Errorf("%w: %d lines have not been counted", ErrInvalidState, uncounted)
+		}
+
+		for _, ln := range st.Lines {
+			counted := 0
+			if ln.CountedQty != nil {
+				counted = *ln.CountedQty
+			}
+			variance := counted - ln.SnapshotQty
+			if err := tx.Model(&inventory.StocktakeLine{}).
+				Where("id = ?", ln.ID).
+				Updates(map[string]interface{}{
+					"counted_qty": counted,
+					"variance":    variance,
+					"approved":    variance == 0,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&inventory.Stocktake{}).
+			Where("id = ?", st.ID).
+			Updates(map[string]interface{}{
+				"status":       inventory.StocktakeStatusReviewing,
+				"submitted_by": operator,
+				"submitted_at": time.Now(),
+			}).Error
+	})
+}
+
+// Review 记录主管对差异行的批准 / 驳回
+func (r *StocktakeRepository) Review(ctx context.Context, id uint, decisions []StocktakeDecision) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		st, err := lockStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if st.Status != inventory.StocktakeStatusReviewing {
+			return fmt.Errorf("%w: stocktake %s is %s", ErrInvalidState, st.StocktakeNumber, st.Status)
+		}
+
+		owned := make(map[uint]bool, len(st.Lines))
+		for _, ln := range st.Lines {
+			owned[ln.ID] = true
+		}
+		for _, d := range decisions {
+			if !owned[d.LineID] {
+				return fmt.Errorf("%w: line %d does not belong to stocktake %s", ErrNotFound, d.LineID, st.StocktakeNumber)
+			}
+			if err := tx.Model(&inventory.StocktakeLine{}).
+				Where("id = ?", d.LineID).
+				Updates(map[string]interface{}{
+					"approved":    d.Approved,
+					"review_note": d.Note,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Post 在一个事务里把所有已批准的差异写成 ADJUST 流水，任一行失败则整体回滚
+func (r *StocktakeRepository) Post(ctx context.Context, id uint, operator string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		st, err := lockStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if st.Status != inventory.StocktakeStatusReviewing {
+			return fmt.Errorf("%w: stocktake %s is %s", ErrInvalidState, st.StocktakeNumber, st.Status)
+		}
+
+		for _, ln := range st.Lines {
+			if !ln.Approved || ln.Variance == 0 {
+				continue
+			}
+			note := fmt.Sprintf("[%s] 盘点差异 %s", inventory.ReasonCountCorrection, st.StocktakeNumber)
+			if ln.ReviewNote != "" {
+				note += " " + ln.ReviewNote
+			}
+			if err := applyStockMovement(tx, ln.ProductID, st.WarehouseID, ln.Variance,
+				inventory.TransactionTypeAdjust, operator, note, st.StocktakeNumber); err != nil {
+				return fmt.Errorf("post product %d: %w", ln.ProductID, err)
+			}
+		}
+
+		return tx.Model(&inventory.Stocktake{}).
+			Where("id = ?", st.ID).
+			Updates(map[string]interface{}{
+				"status":    inventory.StocktakeStatusPosted,
+				"posted_by": operator,
+				"posted_at": time.Now(),
+			}).Error
+	})
+}
+
+// Cancel 取消尚未过账的盘点单
+func (r *StocktakeRepository) Cancel(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		st, err := lockStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if st.Status != inventory.StocktakeStatusCounting && st.Status != inventory.StocktakeStatusReviewing {
+			return fmt.Errorf("%w: stocktake %s is %s", ErrInvalidState, st.StocktakeNumber, st.Status)
+		}
+		return tx.Model(&inventory.Stocktake{}).
+			Where("id = ?", st.ID).
+			Update("status", inventory.StocktakeStatusCancelled).Error
+	})
+}
+
+// CurrentOnHand 读取仓库当前各产品现有量，用于审核时对照快照
+func (r *StocktakeRepository) CurrentOnHand(ctx context.Context, warehouseID uint) (map[uint]int, error) {
+	var stocks []catalog.ProductStock
+	if err := r.db.WithContext(ctx).
+		Select("product_id", "on_hand").
+		Where("warehouse_id = ?", warehouseID).
+		Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]int, len(stocks))
+	for _, s := range stocks {
+		out[s.ProductID] = s.OnHand
+	}
+	return out, nil
+}
+
+// lockStocktake 在事务中以 FOR UPDATE 读取盘点单及明细
+func lockStocktake(tx *gorm.DB, id uint) (*inventory.Stocktake, error) {
+	var st inventory.Stocktake
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&st, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("stocktake_id = ?", st.ID).Order("product_id").Find(&st.Lines).Error; err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
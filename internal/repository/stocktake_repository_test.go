@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/inventory"
+
+	"gorm.io/gorm"
+)
+
+func stocktakeLines(t *testing.T, db *gorm.DB, id uint) map[uint]inventory.StocktakeLine {
+	t.Helper()
+	var lines []inventory.StocktakeLine
+	if err := db.Where("stocktake_id = ?", id).Find(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[uint]inventory.StocktakeLine, len(lines))
+	for _, ln := range lines {
+		out[ln.ProductID] = ln
+	}
+	return out
+}
+
+// 过账只写 计数 - 快照 的差异，盘点期间的出入库不被覆盖；驳回的差异不过账
+func TestStocktakePostsApprovedVariances(t *testing.T) {
+	db := newStockTestDB(t, &inventory.Stocktake{}, &inventory.StocktakeLine{})
+	seedStock(t, db, 1, 1, 10, 0)
+	seedStock(t, db, 2, 1, 5, 0)
+	seedStock(t, db, 3, 1, 8, 0)
+	r := NewStocktakeRepository(db)
+	ctx := context.Background()
+
+	st := &inventory.Stocktake{WarehouseID: 1, CreatedBy: "alice"}
+	if err := r.Create(ctx, st, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Create(ctx, &inventory.Stocktake{WarehouseID: 1, CreatedBy: "alice"}, nil); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second open stocktake: err = %v, want ErrInvalidState", err)
+	}
+
+	// 快照之后出库 3，计数时已经少了
+	if err := applyStockMovement(db, 1, 1, 3, inventory.TransactionTypeOut, "bob", "", "SO-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RecordCounts(ctx, st.ID, []StocktakeCount{
+		{ProductID: 1, Quantity: 8},
+		{ProductID: 2, Quantity: 2},
+		{ProductID: 2, Quantity: 2, Add: true},
+		{ProductID: 4, Quantity: 1}, // 账外盘盈
+	}, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Submit(ctx, st.ID, false, "carol"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("submit with uncounted lines: err = %v, want ErrInvalidState", err)
+	}
+	if err := r.Submit(ctx, st.ID, true, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RecordCounts(ctx, st.ID, []StocktakeCount{{ProductID: 3, Quantity: 8}}, "carol"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("count after submit: err = %v, want ErrInvalidState", err)
+	}
+
+	lines := stocktakeLines(t, db, st.ID)
+	for pid, want := range map[uint]int{1: -2, 2: -1, 3: -8, 4: 1} {
+		if got := lines[pid].Variance; got != want || lines[pid].Approved {
+			t.Errorf("product %d: variance %d approved %v, want %d unapproved", pid, got, lines[pid].Approved, want)
+		}
+	}
+	if err := r.Review(ctx, st.ID, []StocktakeDecision{
+		{LineID: lines[1].ID, Approved: true},
+		{LineID: lines[2].ID, Approved: true, Note: "broken"},
+		{LineID: lines[4].ID, Approved: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Review(ctx, st.ID, []StocktakeDecision{{LineID: 999, Approved: true}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("foreign line: err = %v, want ErrNotFound", err)
+	}
+
+	if err := r.Post(ctx, st.ID, "dave"); err != nil {
+		t.Fatal(err)
+	}
+	for pid, want := range map[uint]int{1: 5, 2: 4, 3: 8, 4: 1} {
+		if got := stockOf(t, db, pid, 1).OnHand; got != want {
+			t.Errorf("product %d on hand = %d, want %d", pid, got, want)
+		}
+	}
+	var posted inventory.Stocktake
+	db.First(&posted, st.ID)
+	if posted.Status != inventory.StocktakeStatusPosted || posted.PostedBy != "dave" {
+		t.Errorf("status %s posted by %q", posted.Status, posted.PostedBy)
+	}
+	if got := ledger(t, db, posted.StocktakeNumber); !equalStrings(got, []string{"ADJUST:-2", "ADJUST:-1", "ADJUST:1"}) {
+		t.Errorf("ledger = %v", got)
+	}
+	if err := r.Post(ctx, st.ID, "dave"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second post: err = %v, want ErrInvalidState", err)
+	}
+	if err := r.Cancel(ctx, st.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("cancel posted: err = %v, want ErrInvalidState", err)
+	}
+}
+
+// 任一行过账失败时整张盘点单回滚，状态保持待审核
+func TestStocktakePostRollsBack(t *testing.T) {
+	db := newStockTestDB(t, &inventory.Stocktake{}, &inventory.StocktakeLine{})
+	seedStock(t, db, 1, 1, 10, 0)
+	seedStock(t, db, 2, 1, 10, 0)
+	r := NewStocktakeRepository(db)
+	ctx := context.Background()
+
+	st := &inventory.Stocktake{WarehouseID: 1, CreatedBy: "alice"}
+	if err := r.Create(ctx, st, []uint{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RecordCounts(ctx, st.ID, []StocktakeCount{{ProductID: 1, Quantity: 12}, {ProductID: 2, Quantity: 4}}, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Submit(ctx, st.ID, false, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	lines := stocktakeLines(t, db, st.ID)
+	if err := r.Review(ctx, st.ID, []StocktakeDecision{
+		{LineID: lines[1].ID, Approved: true},
+		{LineID: lines[2].ID, Approved: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 审核期间产品 2 的库存被预留，盘亏 6 会动到预留部分
+	db.Exec("UPDATE product_stocks SET reserved = 8 WHERE product_id = 2")
+	if err := r.Post(ctx, st.ID, "dave"); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("post: err = %v, want ErrInsufficientStock", err)
+	}
+	if got := stockOf(t, db, 1, 1).OnHand; got != 10 {
+		t.Errorf("product 1 on hand = %d, want 10 after rollback", got)
+	}
+	var after inventory.Stocktake
+	db.First(&after, st.ID)
+	if after.Status != inventory.StocktakeStatusReviewing {
+		t.Errorf("status = %s, want reviewing", after.Status)
+	}
+	if got := ledger(t, db, after.StocktakeNumber); len(got) != 0 {
+		t.Errorf("ledger = %v, want empty", got)
+	}
+}
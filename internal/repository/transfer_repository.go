@@ -0,0 +1,286 @@
+// internal/repository/transfer_repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/inventory"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TransferRepository 封装仓库间调拨单的读写，发货 / 收货时同步写库存流水
+type TransferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+// TransferFilter 调拨单列表筛选条件，零值表示不过滤
+type TransferFilter struct {
+	Status      inventory.TransferStatus
+	WarehouseID uint // 源仓库或目标仓库
+}
+
+// TransferReceipt 一条明细的收货数量和差异说明
+type TransferReceipt struct {
+	ItemID          uint
+	Quantity        int
+	DiscrepancyNote string
+}
+
+// InTransitStock 某仓库某产品的在途汇总
+type InTransitStock struct {
+	ProductID   uint `json:"productId"`
+	WarehouseID uint `json:"warehouseId"`
+	Quantity    int  `json:"quantity"`
+}
+
+// Create 新建调拨单（草稿），单号按 TR-日期-ID 生成
+func (r *TransferRepository) Create(ctx context.Context, t *inventory.StockTransfer) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先用临时单号占位，拿到 ID 后再生成正式单号
+		t.TransferNumber = fmt.Sprintf("TMP-%d", time.Now().UnixNano())
+		t.Status = inventory.TransferStatusDraft
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		t.TransferNumber = fmt.Sprintf("TR-%s-%05d", t.CreatedAt.Format("20060102"), t.ID)
+		return tx.Model(t).Update("transfer_number", t.TransferNumber).Error
+	})
+}
+
+// FindByID 读取调拨单及明细
+func (r *TransferRepository) FindByID(ctx context.Context, id uint) (*inventory.StockTransfer, error) {
+	var t inventory.StockTransfer
+	err := r.db.WithContext(ctx).
+		Preload("FromWarehouse").
+		Preload("ToWarehouse").
+		Preload("Items.Product").
+		First(&t, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &t, err
+}
+
+// List 分页列出调拨单
+func (r *TransferRepository) List(ctx context.Context, f TransferFilter, offset, limit int) ([]inventory.StockTransfer, int64, error) {
+	var (
+		list  []inventory.StockTransfer
+		total int64
+	)
+	q := r.db.WithContext(ctx).Model(&inventory.StockTransfer{})
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.WarehouseID != 0 {
+		q = q.Where("from_warehouse_id = ? OR to_warehouse_id = ?", f.WarehouseID, f.WarehouseID)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.
+		Preload("FromWarehouse").
+		Preload("ToWarehouse").
+		Preload("Items").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// Dispatch 发货：源仓库逐条写 TRANSFER_OUT，货物进入在途
+func (r *TransferRepository) Dispatch(ctx context.Context, id uint, operator string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, err := lockTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+		if t.Status != inventory.TransferStatusDraft {
+			return fmt.Errorf("%w: transfer %s is %s", ErrInvalidState, t.TransferNumber, t.Status)
+		}
+
+		for _, it := range t.Items {
+			note := fmt.Sprintf("调拨发往仓库 %d", t.ToWarehouseID)
+			if err := applyStockMovement(tx, it.ProductID, t.FromWarehouseID, it.Quantity,
+				inventory.TransactionTypeTransferOut, operator, note, t.TransferNumber); err != nil {
+				return fmt.Errorf("dispatch product %d: %w", it.ProductID, err)
+			}
+			if err := tx.Model(&inventory.StockTransferItem{}).
+				Where("id = ?", it.ID).
+				Update("dispatched_qty", it.Quantity).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(&inventory.StockTransfer{}).
+			Where("id = ?", t.ID).
+			Updates(map[string]interface{}{
+				"status":        inventory.TransferStatusInTransit,
+				"dispatched_by": operator,
+				"dispatched_at": now,
+			}).Error
+	})
+}
+
+// Receive 收货：目标仓库按实收数量写 TRANSFER_IN，支持多次部分收货
+// closeShort 为 true 时，即使仍有在途数量也把调拨单关闭，短缺部分按在途丢失报损（见 writeOffInTransit）
+func (r *TransferRepository) Receive(ctx context.Context, id uint, receipts []TransferReceipt, closeShort bool, operator string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, err := lockTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+		if t.Status != inventory.TransferStatusInTransit && t.Status != inventory.TransferStatusPartiallyReceived {
+			return fmt.Errorf("%w: transfer %s is %s", ErrInvalidState, t.TransferNumber, t.Status)
+		}
+
+		items := make(map[uint]*inventory.StockTransferItem, len(t.Items))
+		for i := range t.Items {
+			items[t.Items[i].ID] = &t.Items[i]
+		}
+
+		for _, rc := range receipts {
+			it, ok := items[rc.ItemID]
+			if !ok {
+				return fmt.Errorf("%w: item %d does not belong to transfer %s", ErrNotFound, rc.ItemID, t.TransferNumber)
+			}
+			if rc.Quantity < 0 {
+				return fmt.Errorf("%w: item %d: receive quantity %d is negative", ErrInvalidInput, it.ID, rc.Quantity)
+			}
+			if rc.Quantity > it.InTransitQty() {
+				return fmt.Errorf("%w: item %d: receive quantity %d exceeds in-transit quantity %d", ErrInvalidState, it.ID, rc.Quantity, it.InTransitQty())
+			}
+			if rc.Quantity > 0 {
+				note := fmt.Sprintf("调拨来自仓库 %d", t.FromWarehouseID)
+				if err := applyStockMovement(tx, it.ProductID, t.ToWarehouseID, rc.Quantity,
+					inventory.TransactionTypeTransferIn, operator, note, t.TransferNumber); err != nil {
+					return fmt.Errorf("receive product %d: %w", it.ProductID, err)
+				}
+			}
+			it.ReceivedQty += rc.Quantity
+			if rc.DiscrepancyNote != "" {
+				it.DiscrepancyNote = rc.DiscrepancyNote
+			}
+			if err := tx.Model(&inventory.StockTransferItem{}).
+				Where("id = ?", it.ID).
+				Updates(map[string]interface{}{
+					"received_qty":     it.ReceivedQty,
+					"discrepancy_note": it.DiscrepancyNote,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		complete := true
+		for _, it := range items {
+			if it.InTransitQty() > 0 {
+				complete = false
+				break
+			}
+		}
+		if !complete && closeShort {
+			for i := range t.Items {
+				if err := writeOffInTransit(tx, t, &t.Items[i], operator); err != nil {
+					return err
+				}
+			}
+		}
+
+		updates := map[string]interface{}{"received_by": operator}
+		if complete || closeShort {
+			updates["status"] = inventory.TransferStatusReceived
+			updates["received_at"] = time.Now()
+		} else {
+			updates["status"] = inventory.TransferStatusPartiallyReceived
+		}
+		return tx.Model(&inventory.StockTransfer{}).Where("id = ?", t.ID).Updates(updates).Error
+	})
+}
+
+// writeOffInTransit 关闭短收的调拨单时处理一条明细的在途余量：
+// 货物发货时已随 TRANSFER_OUT 从源仓库扣减，这里只在源仓库写一条 STOLEN 流水记录在途丢失，不再改现有量；
+// 短缺数量记到 written_off_qty，明细不再有在途
+func writeOffInTransit(tx *gorm.DB, t *inventory.StockTransfer, it *inventory.StockTransferItem, operator string) error {
+	short := it.InTransitQty()
+	if short <= 0 {
+		return nil
+	}
+	var stock catalog.ProductStock
+	if err := tx.Where("product_id = ? AND warehouse_id = ?", it.ProductID, t.FromWarehouseID).
+		First(&stock).Error; err != nil {
+		return fmt.Errorf("write off product %d: %w", it.ProductID, err)
+	}
+	if err := tx.Create(&inventory.InventoryTransaction{
+		InventoryID: stock.ID,
+		TxType:      inventory.TransactionTypeStolen,
+		Quantity:    short,
+		Operator:    operator,
+		Note:        fmt.Sprintf("调拨单 %s 短收 %d，在途丢失报损", t.TransferNumber, short),
+		Reference:   t.TransferNumber,
+		CreatedAt:   time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("write off product %d: %w", it.ProductID, err)
+	}
+	it.WrittenOffQty += short
+	return tx.Model(&inventory.StockTransferItem{}).
+		Where("id = ?", it.ID).
+		Update("written_off_qty", it.WrittenOffQty).Error
+}
+
+// Cancel 取消草稿状态的调拨单
+func (r *TransferRepository) Cancel(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, err := lockTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+		if t.Status != inventory.TransferStatusDraft {
+			return fmt.Errorf("%w: only draft transfers can be cancelled", ErrInvalidState)
+		}
+		return tx.Model(&inventory.StockTransfer{}).
+			Where("id = ?", t.ID).
+			Update("status", inventory.TransferStatusCancelled).Error
+	})
+}
+
+// InTransitByWarehouse 汇总发往某仓库、尚未收货的在途数量
+func (r *TransferRepository) InTransitByWarehouse(ctx context.Context, warehouseID uint) ([]InTransitStock, error) {
+	var out []InTransitStock
+	err := r.db.WithContext(ctx).
+		Table("stock_transfer_items AS i").
+		Select("i.product_id AS product_id, t.to_warehouse_id AS warehouse_id, SUM(i.dispatched_qty - i.received_qty - i.written_off_qty) AS quantity").
+		Joins("JOIN stock_transfers AS t ON t.id = i.transfer_id").
+		Where("t.to_warehouse_id = ? AND t.status IN ?", warehouseID,
+			[]inventory.TransferStatus{inventory.TransferStatusInTransit, inventory.TransferStatusPartiallyReceived}).
+		Group("i.product_id, t.to_warehouse_id").
+		Having("SUM(i.dispatched_qty - i.received_qty - i.written_off_qty) > 0").
+		Scan(&out).Error
+	return out, err
+}
+
+// lockTransfer 在事务中以 FOR UPDATE 读取调拨单及明细，防止并发发货 / 收货
+func lockTransfer(tx *gorm.DB, id uint) (*inventory.StockTransfer, error) {
+	var t inventory.StockTransfer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("transfer_id = ?", t.ID).Order("id").Find(&t.Items).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
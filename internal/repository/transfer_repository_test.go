@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/inventory"
+)
+
+// 发货扣源仓库可用量，分次收货，短收关闭时短缺部分在源仓库报损，在途清零
+func TestTransferDispatchReceiveAndCloseShort(t *testing.T) {
+	db := newStockTestDB(t, &inventory.StockTransfer{}, &inventory.StockTransferItem{})
+	seedStock(t, db, 1, 1, 10, 3)
+	repo := NewTransferRepository(db)
+	ctx := context.Background()
+
+	tooMany := &inventory.StockTransfer{FromWarehouseID: 1, ToWarehouseID: 2, CreatedBy: "alice",
+		Items: []inventory.StockTransferItem{{ProductID: 1, Quantity: 8}}}
+	if err := repo.Create(ctx, tooMany); err != nil {
+		t.Fatal(err)
+	}
+	// 可用量只有 7，已预留的 3 不能调走
+	if err := repo.Dispatch(ctx, tooMany.ID, "alice"); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("dispatch over available: err = %v, want ErrInsufficientStock", err)
+	}
+
+	tr := &inventory.StockTransfer{FromWarehouseID: 1, ToWarehouseID: 2, CreatedBy: "alice",
+		Items: []inventory.StockTransferItem{{ProductID: 1, Quantity: 6}}}
+	if err := repo.Create(ctx, tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Dispatch(ctx, tr.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Dispatch(ctx, tr.ID, "alice"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second dispatch: err = %v, want ErrInvalidState", err)
+	}
+	if s := stockOf(t, db, 1, 1); s.OnHand != 4 || s.Reserved != 3 {
+		t.Errorf("source after dispatch = %d/%d, want 4/3", s.OnHand, s.Reserved)
+	}
+
+	itemID := tr.Items[0].ID
+	if err := repo.Receive(ctx, tr.ID, []TransferReceipt{{ItemID: itemID, Quantity: 3}}, false, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Receive(ctx, tr.ID, []TransferReceipt{{ItemID: itemID, Quantity: 4}}, false, "bob"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("over-receive: err = %v, want ErrInvalidState", err)
+	}
+	if err := repo.Receive(ctx, tr.ID, []TransferReceipt{{ItemID: itemID, Quantity: -1}}, false, "bob"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("negative receipt: err = %v, want ErrInvalidInput", err)
+	}
+	transit, err := repo.InTransitByWarehouse(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transit) != 1 || transit[0].Quantity != 3 {
+		t.Errorf("in transit after partial receipt = %+v, want 3", transit)
+	}
+
+	// 再收 1 件后短收关闭：剩下 2 件在源仓库直接报损，发货时已扣过现有量，不再扣
+	if err := repo.Receive(ctx, tr.ID, []TransferReceipt{{ItemID: itemID, Quantity: 1, DiscrepancyNote: "2 missing"}}, true, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := lockTransfer(db, tr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != inventory.TransferStatusReceived || got.Items[0].ReceivedQty != 4 || got.Items[0].WrittenOffQty != 2 {
+		t.Errorf("closed transfer = %s, item %+v", got.Status, got.Items[0])
+	}
+	if transit, _ = repo.InTransitByWarehouse(ctx, 2); len(transit) != 0 {
+		t.Errorf("in transit after close = %+v, want none", transit)
+	}
+	if s := stockOf(t, db, 1, 1); s.OnHand != 4 {
+		t.Errorf("source after close = %d, want 4", s.OnHand)
+	}
+	if s := stockOf(t, db, 1, 2); s.OnHand != 4 {
+		t.Errorf("destination after close = %d, want 4", s.OnHand)
+	}
+	want := []string{"TRANSFER_OUT:6", "TRANSFER_IN:3", "TRANSFER_IN:1", "STOLEN:2"}
+	if l := ledger(t, db, got.TransferNumber); !equalStrings(l, want) {
+		t.Errorf("ledger = %v, want %v", l, want)
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"djj-inventory-system/internal/model/rbac"
 	"fmt"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepo interface {
@@ -44,6 +46,11 @@ type UserRepo interface {
 	FindRolesByNames(ctx context.Context, names []string) ([]rbac.Role, error)
 	// 获取该用户权限最后一次变更的审计记录
 	LastPermissionChange(userID uint) (*audit.AuditedHistory, error)
+
+	// 登录失败计数：达到 maxAttempts 时锁定到 now+lockFor 并返回锁定截止时间
+	RecordLoginFailure(ctx context.Context, userID uint, maxAttempts int, lockFor time.Duration, now time.Time) (*time.Time, error)
+	// 登录成功后清零失败计数
+	ResetLoginFailures(ctx context.Context, userID uint) error
 }
 
 type userRepo struct{ db *gorm.DB }
@@ -406,3 +413,29 @@ func (r *userRepo) ListWarehousesByRegion(ctx context.Context, regionID uint) ([
 	}
 	return whs, nil
 }
+
+func (r *userRepo) RecordLoginFailure(ctx context.Context, userID uint, maxAttempts int, lockFor time.Duration, now time.Time) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var u rbac.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_logins").First(&u, userID).Error; err != nil {
+			return err
+		}
+		failed := u.FailedLogins + 1
+		updates := map[string]interface{}{"failed_logins": failed}
+		if maxAttempts > 0 && failed >= maxAttempts {
+			until := now.Add(lockFor)
+			lockedUntil = &until
+			updates = map[string]interface{}{"failed_logins": 0, "locked_until": until}
+		}
+		// UpdateColumns：不改 updated_at（列表里当作最近登录时间展示）
+		return tx.Model(&rbac.User{}).Where("id = ?", userID).UpdateColumns(updates).Error
+	})
+	return lockedUntil, err
+}
+
+func (r *userRepo) ResetLoginFailures(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&rbac.User{}).Where("id = ?", userID).
+		UpdateColumns(map[string]interface{}{"failed_logins": 0, "locked_until": nil}).Error
+}
@@ -0,0 +1,196 @@
+// internal/service/adjustment_service.go
+package service
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// AdjustmentPolicy 调整单审批阈值；数量绝对值或金额超过任一阈值即需主管审批，0 表示不按该项限制
+type AdjustmentPolicy struct {
+	QtyThreshold   int
+	ValueThreshold float64
+}
+
+// RequiresApproval 判断一张调整单是否超出阈值
+func (p AdjustmentPolicy) RequiresApproval(qty int, value float64) bool {
+	if qty < 0 {
+		qty = -qty
+	}
+	if p.QtyThreshold > 0 && qty > p.QtyThreshold {
+		return true
+	}
+	if p.ValueThreshold > 0 && value > p.ValueThreshold {
+		return true
+	}
+	return false
+}
+
+// AdjustmentService 库存调整与报损：带符号的盘盈盘亏调整、损坏 / 过期 / 丢失报损，超阈值需主管审批
+type AdjustmentService struct {
+	repo   *repository.AdjustmentRepository
+	policy AdjustmentPolicy
+	logger *zap.Logger
+}
+
+func NewAdjustmentService(repo *repository.AdjustmentRepository, policy AdjustmentPolicy, logger *zap.Logger) *AdjustmentService {
+	return &AdjustmentService{
+		repo:   repo,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// Policy 返回当前审批阈值
+func (s *AdjustmentService) Policy() AdjustmentPolicy {
+	return s.policy
+}
+
+// Create 新建调整单；未超阈值的直接过账，超出的进入待审批
+func (s *AdjustmentService) Create(ctx context.Context, req dto.CreateAdjustmentRequest, operator string, operatorID uint) (*inventory.StockAdjustment, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	txType := inventory.TransactionType(req.TxType)
+	if txType != inventory.TransactionTypeAdjust && !inventory.IsWriteOff(txType) {
+		return nil, fmt.Errorf("%w: transaction type %s is not an adjustment", ErrInvalidInput, req.TxType)
+	}
+	if req.Quantity == 0 {
+		return nil, fmt.Errorf("%w: quantity must not be zero", ErrInvalidInput)
+	}
+	if inventory.IsWriteOff(txType) && req.Quantity < 0 {
+		return nil, fmt.Errorf("%w: write-off quantity must be positive", ErrInvalidInput)
+	}
+	reason := inventory.ReasonCode(req.ReasonCode)
+	if !inventory.IsValidReasonCode(txType, reason) {
+		return nil, fmt.Errorf("%w: reason code %s is not valid for %s", ErrInvalidInput, req.ReasonCode, req.TxType)
+	}
+
+	unit, err := s.repo.ProductUnitValue(ctx, req.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load product %d: %w", req.ProductID, err)
+	}
+	qty := req.Quantity
+	if qty < 0 {
+		qty = -qty
+	}
+	total := unit * float64(qty)
+
+	a := &inventory.StockAdjustment{
+		ProductID:        req.ProductID,
+		WarehouseID:      req.WarehouseID,
+		TxType:           txType,
+		Quantity:         req.Quantity,
+		ReasonCode:       reason,
+		Note:             req.Note,
+		UnitValue:        unit,
+		TotalValue:       total,
+		RequiresApproval: s.policy.RequiresApproval(req.Quantity, total),
+		RequestedBy:      operator,
+		Attachments:      toAttachments(req.Attachments, operatorID),
+	}
+	if err := s.repo.Create(ctx, a); err != nil {
+		s.logger.Error("Failed to create adjustment", zap.Error(err))
+		return nil, fmt.Errorf("failed to create adjustment: %w", err)
+	}
+
+	s.logger.Info("Adjustment created",
+		zap.String("adjustmentNumber", a.AdjustmentNumber),
+		zap.String("txType", string(txType)),
+		zap.String("reasonCode", string(reason)),
+		zap.Int("quantity", a.Quantity),
+		zap.Float64("totalValue", total),
+		zap.Bool("requiresApproval", a.RequiresApproval),
+		zap.String("operator", operator))
+
+	return s.repo.FindByID(ctx, a.ID)
+}
+
+// Get 读取调整单
+func (s *AdjustmentService) Get(ctx context.Context, id uint) (*inventory.StockAdjustment, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// List 分页列出调整单
+func (s *AdjustmentService) List(ctx context.Context, f repository.AdjustmentFilter, offset, limit int) ([]inventory.StockAdjustment, int64, error) {
+	return s.repo.List(ctx, f, offset, limit)
+}
+
+// Approve 主管审批通过并过账；提交人不能审批自己的单据
+func (s *AdjustmentService) Approve(ctx context.Context, id uint, reviewer, note string) (*inventory.StockAdjustment, error) {
+	if err := s.checkReviewer(ctx, id, reviewer); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Approve(ctx, id, reviewer, note); err != nil {
+		s.logger.Error("Failed to approve adjustment", zap.Uint("adjustmentID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to approve adjustment: %w", err)
+	}
+
+	s.logger.Info("Adjustment approved", zap.Uint("adjustmentID", id), zap.String("reviewer", reviewer))
+	return s.repo.FindByID(ctx, id)
+}
+
+// Reject 主管驳回，库存不变
+func (s *AdjustmentService) Reject(ctx context.Context, id uint, reviewer, note string) (*inventory.StockAdjustment, error) {
+	if err := s.checkReviewer(ctx, id, reviewer); err != nil {
+		return nil, err
+	}
+	if note == "" {
+		return nil, fmt.Errorf("%w: a note is required when rejecting", ErrInvalidInput)
+	}
+	if err := s.repo.Reject(ctx, id, reviewer, note); err != nil {
+		return nil, fmt.Errorf("failed to reject adjustment: %w", err)
+	}
+
+	s.logger.Info("Adjustment rejected", zap.Uint("adjustmentID", id), zap.String("reviewer", reviewer))
+	return s.repo.FindByID(ctx, id)
+}
+
+// AddAttachments 为调整单追加照片
+func (s *AdjustmentService) AddAttachments(ctx context.Context, id uint, reqs []dto.AttachmentRequest, operatorID uint) (*inventory.StockAdjustment, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("%w: no attachments given", ErrInvalidInput)
+	}
+	if err := s.repo.AddAttachments(ctx, id, toAttachments(reqs, operatorID)); err != nil {
+		return nil, fmt.Errorf("failed to add attachments: %w", err)
+	}
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *AdjustmentService) checkReviewer(ctx context.Context, id uint, reviewer string) error {
+	if reviewer == "" {
+		return fmt.Errorf("%w: reviewer is required", ErrInvalidInput)
+	}
+	a, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if a.RequestedBy == reviewer {
+		return fmt.Errorf("%w: cannot review your own adjustment", ErrForbidden)
+	}
+	return nil
+}
+
+// toAttachments 把请求里的文件信息转成多态附件，RefType / RefID 由仓储层填充
+func toAttachments(reqs []dto.AttachmentRequest, uploadedBy uint) []catalog.Attachment {
+	if len(reqs) == 0 {
+		return nil
+	}
+	out := make([]catalog.Attachment, len(reqs))
+	for i, r := range reqs {
+		out[i] = catalog.Attachment{
+			FileName:   r.FileName,
+			FileType:   r.FileType,
+			FileSize:   r.FileSize,
+			URL:        r.URL,
+			UploadedBy: uploadedBy,
+		}
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/outbox"
+	"djj-inventory-system/internal/pkg/testdb"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// newInventoryTestDB 产品单价 10、仓库 1 现有量 100 的库存库
+func newInventoryTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db := testdb.Open(t, append([]interface{}{&catalog.Product{}, &catalog.Warehouse{}, &catalog.ProductStock{},
+		&catalog.Attachment{}, &inventory.InventoryTransaction{}, &outbox.Event{}}, models...)...)
+	if err := db.Create(&catalog.Product{ID: 1, DJJCode: "P-1", Price: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&catalog.Warehouse{ID: 1, Name: "main"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&catalog.ProductStock{ProductID: 1, WarehouseID: 1, OnHand: 100}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func onHand(t *testing.T, db *gorm.DB) int {
+	t.Helper()
+	var s catalog.ProductStock
+	if err := db.First(&s, "product_id = 1 AND warehouse_id = 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	return s.OnHand
+}
+
+// 阈值内直接过账；超阈值待审批，提交人不能自审，驳回不动库存
+func TestAdjustmentApproval(t *testing.T) {
+	db := newInventoryTestDB(t, &inventory.StockAdjustment{})
+	s := NewAdjustmentService(repository.NewAdjustmentRepository(db), AdjustmentPolicy{QtyThreshold: 20, ValueThreshold: 500}, zap.NewNop())
+	ctx := context.Background()
+	req := func(typ string, qty int, reason string) dto.CreateAdjustmentRequest {
+		return dto.CreateAdjustmentRequest{ProductID: 1, WarehouseID: 1, TxType: typ, Quantity: qty, ReasonCode: reason}
+	}
+
+	a, err := s.Create(ctx, req("ADJUST", -5, "COUNT_CORRECTION"), "alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != inventory.AdjustmentStatusPosted || onHand(t, db) != 95 {
+		t.Fatalf("small adjustment: status %s, on hand %d", a.Status, onHand(t, db))
+	}
+
+	// 数量超阈值：待审批，库存不变
+	a, err = s.Create(ctx, req("DAMAGE", 30, "DAMAGED_STORAGE"), "alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != inventory.AdjustmentStatusPending || !a.RequiresApproval || onHand(t, db) != 95 {
+		t.Fatalf("large write-off: status %s, on hand %d", a.Status, onHand(t, db))
+	}
+	if _, err := s.Approve(ctx, a.ID, "alice", ""); !errors.Is(err, ErrForbidden) {
+		t.Errorf("self approval: err = %v, want ErrForbidden", err)
+	}
+	if a, err = s.Approve(ctx, a.ID, "bob", "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != inventory.AdjustmentStatusPosted || a.ReviewedBy != "bob" || onHand(t, db) != 65 {
+		t.Errorf("approved: status %s, reviewer %s, on hand %d", a.Status, a.ReviewedBy, onHand(t, db))
+	}
+	if _, err := s.Approve(ctx, a.ID, "bob", ""); !errors.Is(err, repository.ErrInvalidState) {
+		t.Errorf("second approval: err = %v, want ErrInvalidState", err)
+	}
+
+	// 金额超阈值（60 × 10）后驳回
+	a, err = s.Create(ctx, req("STOLEN", 60, "THEFT"), "alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reject(ctx, a.ID, "bob", ""); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("reject without note: err = %v, want ErrInvalidInput", err)
+	}
+	if a, err = s.Reject(ctx, a.ID, "bob", "found it"); err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != inventory.AdjustmentStatusRejected || onHand(t, db) != 65 {
+		t.Errorf("rejected: status %s, on hand %d", a.Status, onHand(t, db))
+	}
+
+	// 已预留的库存不能报损，失败时整张单据回滚
+	db.Model(&catalog.ProductStock{}).Where("product_id = 1").Update("reserved", 60)
+	if _, err := s.Create(ctx, req("EXPIRED", 10, "EXPIRED"), "alice", 1); !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Errorf("write-off of reserved stock: err = %v, want ErrInsufficientStock", err)
+	}
+	var n int64
+	db.Model(&inventory.StockAdjustment{}).Count(&n)
+	if n != 3 {
+		t.Errorf("adjustments = %d, want 3", n)
+	}
+}
+
+func TestAdjustmentValidation(t *testing.T) {
+	s := NewAdjustmentService(nil, AdjustmentPolicy{}, zap.NewNop())
+	ctx := context.Background()
+	for name, r := range map[string]dto.CreateAdjustmentRequest{
+		"wrong type":         {ProductID: 1, WarehouseID: 1, TxType: "IN", Quantity: 1, ReasonCode: "FOUND"},
+		"zero quantity":      {ProductID: 1, WarehouseID: 1, TxType: "ADJUST", ReasonCode: "FOUND"},
+		"negative write-off": {ProductID: 1, WarehouseID: 1, TxType: "DAMAGE", Quantity: -1, ReasonCode: "DAMAGED_STORAGE"},
+		"reason mismatch":    {ProductID: 1, WarehouseID: 1, TxType: "STOLEN", Quantity: 1, ReasonCode: "EXPIRED"},
+	} {
+		if _, err := s.Create(ctx, r, "alice", 1); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+	if _, err := s.Create(ctx, dto.CreateAdjustmentRequest{TxType: "ADJUST", Quantity: 1, ReasonCode: "FOUND"}, "", 0); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("no operator: err = %v, want ErrInvalidInput", err)
+	}
+
+	p := AdjustmentPolicy{QtyThreshold: 20, ValueThreshold: 500}
+	for _, tc := range []struct {
+		qty   int
+		value float64
+		want  bool
+	}{{20, 500, false}, {-21, 0, true}, {1, 500.01, true}} {
+		if got := p.RequiresApproval(tc.qty, tc.value); got != tc.want {
+			t.Errorf("RequiresApproval(%d, %v) = %v", tc.qty, tc.value, got)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// internal/service/api_key_service.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	audit2 "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/scope"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix 明文密钥前缀，便于在日志、代码仓库扫描中识别泄露的密钥
+const apiKeyPrefix = "djj_"
+
+// apiKeyTouchInterval 最近使用时间的记录粒度
+const apiKeyTouchInterval = time.Minute
+
+// PermissionLookup 按用户查询当前权限和数据范围（CachedUserService 实现）
+type PermissionLookup interface {
+	PermissionNames(ctx context.Context, userID uint) ([]string, error)
+	Scope(ctx context.Context, userID uint) (scope.Scope, error)
+}
+
+// CreateAPIKeyInput 新建 API Key 的参数；Permissions 必须是创建者自己拥有的权限
+type CreateAPIKeyInput struct {
+	Name        string
+	Permissions []string
+	StoreID     *uint
+	ExpiresAt   *time.Time
+}
+
+// APIKeyService 系统间调用的 API Key：创建、校验、吊销
+// 密钥以所属用户身份执行，权限取密钥权限与用户当前权限的交集，用户被删除或降权后立即生效
+type APIKeyService struct {
+	repo   *repository.APIKeyRepository
+	perms  PermissionLookup
+	aud    audit.Recorder
+	logger *zap.Logger
+}
+
+func NewAPIKeyService(repo *repository.APIKeyRepository, perms PermissionLookup, aud audit.Recorder, logger *zap.Logger) *APIKeyService {
+	return &APIKeyService{repo: repo, perms: perms, aud: aud, logger: logger}
+}
+
+// Create 为 userID 创建密钥，返回明文（只此一次）；ctx 需带创建者的数据范围，用于校验门店
+func (s *APIKeyService) Create(ctx context.Context, userID uint, in CreateAPIKeyInput) (string, *rbac.APIKey, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return "", nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if len(in.Permissions) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one permission is required", ErrInvalidInput)
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidInput)
+	}
+	own, err := s.perms.PermissionNames(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	perms := uniqueStrings(in.Permissions)
+	for _, p := range perms {
+		if !containsString(own, p) {
+			return "", nil, fmt.Errorf("%w: cannot grant permission you do not have: %s", ErrForbidden, p)
+		}
+	}
+	raw, err := json.Marshal(perms)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret := randomToken(32)
+	plain := apiKeyPrefix + secret
+	k := &rbac.APIKey{
+		Name:        in.Name,
+		Prefix:      plain[:len(apiKeyPrefix)+8],
+		KeyHash:     hashToken(plain),
+		UserID:      userID,
+		Permissions: raw,
+		StoreID:     in.StoreID,
+		ExpiresAt:   in.ExpiresAt,
+	}
+	if err := s.repo.Create(ctx, k); err != nil {
+		return "", nil, err
+	}
+	s.aud.Record(ctx, audit2.AuditedTableAPIKeys, k.ID, "create", k)
+	return plain, k, nil
+}
+
+// List userID 为 0 时列出全部密钥
+func (s *APIKeyService) List(ctx context.Context, userID uint) ([]rbac.APIKey, error) {
+	return s.repo.List(ctx, userID)
+}
+
+// Revoke 吊销密钥；userID 非 0 时只能吊销自己的，不存在返回 repository.ErrNotFound
+func (s *APIKeyService) Revoke(ctx context.Context, id, userID uint) error {
+	k, err := s.repo.Revoke(ctx, id, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	s.aud.Record(ctx, audit2.AuditedTableAPIKeys, k.ID, "revoke", map[string]interface{}{"name": k.Name, "userId": k.UserID})
+	return nil
+}
+
+// Authenticate 校验请求里的明文密钥，返回以所属用户身份执行的调用者，权限和数据范围已按密钥收窄
+// 密钥不存在、已吊销、已过期或所属用户已删除时返回 ErrInvalidToken；限定门店已不在用户范围内返回 ErrForbidden
+func (s *APIKeyService) Authenticate(ctx context.Context, plain, ip string) (*auth.Principal, error) {
+	if !strings.HasPrefix(plain, apiKeyPrefix) {
+		return nil, ErrInvalidToken
+	}
+	k, err := s.repo.FindByHash(ctx, hashToken(plain))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !k.Active(now) {
+		return nil, ErrInvalidToken
+	}
+
+	own, err := s.perms.PermissionNames(ctx, k.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	sc, err := s.perms.Scope(ctx, k.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if k.StoreID != nil {
+		// 用户调岗后密钥的门店可能已不在其范围内
+		if err := s.repo.CheckStore(scope.WithContext(ctx, sc), *k.StoreID); err != nil {
+			if errors.Is(err, scope.ErrOutOfScope) {
+				return nil, fmt.Errorf("%w: %v", ErrForbidden, err)
+			}
+			return nil, err
+		}
+		sc = scope.Scope{Level: scope.LevelStore, StoreID: *k.StoreID}
+	}
+
+	var perms []string
+	for _, p := range k.PermissionNames() {
+		if containsString(own, p) {
+			perms = append(perms, p)
+		}
+	}
+
+	if err := s.repo.Touch(ctx, k.ID, ip, now, apiKeyTouchInterval); err != nil {
+		s.logger.Warn("Failed to record API key usage", zap.Uint("keyID", k.ID), zap.Error(err))
+	}
+	return &auth.Principal{
+		UserID:      k.UserID,
+		Name:        "apikey:" + k.Name,
+		StoreID:     sc.StoreID,
+		Permissions: perms,
+		Scope:       sc,
+		APIKeyID:    k.ID,
+	}, nil
+}
+
+func uniqueStrings(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, v := range in {
+		if v = strings.TrimSpace(v); v != "" && !containsString(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/scope"
+	"djj-inventory-system/internal/pkg/testdb"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// fakeLookup 固定的用户权限和数据范围
+type fakeLookup struct {
+	perms []string
+	scope scope.Scope
+}
+
+func (f *fakeLookup) PermissionNames(ctx context.Context, userID uint) ([]string, error) {
+	return f.perms, nil
+}
+
+func (f *fakeLookup) Scope(ctx context.Context, userID uint) (scope.Scope, error) {
+	return f.scope, nil
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(ctx context.Context, refType audit.AuditedTableEnum, refID uint, op string, payload interface{}) error {
+	return nil
+}
+
+func TestAPIKeyPermissionsAndScope(t *testing.T) {
+	db := testdb.Open(t, &rbac.APIKey{})
+	owner := &fakeLookup{
+		perms: []string{"inventory.view", "inventory.in", "user.permission"},
+		scope: scope.Scope{Level: scope.LevelStore, StoreID: 3},
+	}
+	svc := NewAPIKeyService(repository.NewAPIKeyRepository(db), owner, nopRecorder{}, zap.NewNop())
+	ctx := scope.WithContext(context.Background(), owner.scope)
+
+	// 不能授予自己没有的权限，不能限定到范围外的门店
+	if _, _, err := svc.Create(ctx, 1, CreateAPIKeyInput{Name: "x", Permissions: []string{"finance.refund"}}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("want ErrForbidden, got %v", err)
+	}
+	other := uint(4)
+	if _, _, err := svc.Create(ctx, 1, CreateAPIKeyInput{Name: "x", Permissions: []string{"inventory.view"}, StoreID: &other}); !errors.Is(err, scope.ErrOutOfScope) {
+		t.Fatalf("want ErrOutOfScope, got %v", err)
+	}
+
+	store := uint(3)
+	plain, k, err := svc.Create(ctx, 1, CreateAPIKeyInput{Name: "scanner", Permissions: []string{"inventory.view", "inventory.in"}, StoreID: &store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用户后来失去 inventory.in：密钥随之失去
+	owner.perms = []string{"inventory.view"}
+	p, err := svc.Authenticate(context.Background(), plain, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Permissions) != 1 || p.Permissions[0] != "inventory.view" {
+		t.Fatalf("permissions = %v, want [inventory.view]", p.Permissions)
+	}
+	if p.Scope.Level != scope.LevelStore || p.Scope.StoreID != 3 || p.APIKeyID != k.ID || p.UserID != 1 {
+		t.Fatalf("principal = %+v, want key %d of user 1 scoped to store 3", p, k.ID)
+	}
+
+	// 用户调到别的门店：限定门店的密钥不可用
+	owner.scope = scope.Scope{Level: scope.LevelStore, StoreID: 5}
+	if _, err := svc.Authenticate(context.Background(), plain, ""); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("store outside owner scope: want ErrForbidden, got %v", err)
+	}
+	owner.scope = scope.Scope{Level: scope.LevelStore, StoreID: 3}
+
+	if err := svc.Revoke(context.Background(), k.ID, 2); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("revoke someone else's key: want ErrNotFound, got %v", err)
+	}
+	if err := svc.Revoke(context.Background(), k.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Authenticate(context.Background(), plain, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("revoked key: want ErrInvalidToken, got %v", err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	plain, _, err = svc.Create(ctx, 1, CreateAPIKeyInput{Name: "site", Permissions: []string{"inventory.view"}, ExpiresAt: &expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&rbac.APIKey{}).Where("name = ?", "site").Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := svc.Authenticate(context.Background(), plain, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired key: want ErrInvalidToken, got %v", err)
+	}
+}
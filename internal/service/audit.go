@@ -2,13 +2,13 @@ package service
 
 import (
 	"djj-inventory-system/internal/model/audit"
+	audit2 "djj-inventory-system/internal/pkg/audit"
 	"encoding/json"
-	"time"
 
 	"gorm.io/gorm"
 )
 
-// RecordAudit 在 audit_histories 里写一条记录
+// RecordAudit 在 audited_history 里写一条记录（接到哈希链末尾）
 func RecordAudit(db *gorm.DB, table audit.AuditedTableEnum, recordID int, changedBy int, operation string, payload interface{}) error {
 	raw, err := json.Marshal(payload)
 	if err != nil {
@@ -20,7 +20,6 @@ func RecordAudit(db *gorm.DB, table audit.AuditedTableEnum, recordID int, change
 		ChangedBy: changedBy,
 		Operation: operation,
 		Payload:   raw,
-		ChangedAt: time.Now(),
 	}
-	return db.Create(&ah).Error
+	return audit2.Append(db, []audit.AuditedHistory{ah})
 }
@@ -0,0 +1,179 @@
+// internal/service/audit_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	audit2 "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// AuditService 审计历史查询、单条记录时间线、按历史记录恢复，以及哈希链校验和签名检查点
+type AuditService struct {
+	repo   *repository.AuditRepository
+	aud    audit.Recorder
+	keys   *audit.SigningKeys
+	logger *zap.Logger
+}
+
+func NewAuditService(repo *repository.AuditRepository, aud audit.Recorder, keys *audit.SigningKeys, logger *zap.Logger) *AuditService {
+	return &AuditService{repo: repo, aud: aud, keys: keys, logger: logger}
+}
+
+// AuditVerifyReport 哈希链和检查点的校验结果
+type AuditVerifyReport struct {
+	OK               bool               `json:"ok"`
+	Chain            *audit.ChainReport `json:"chain"`
+	Checkpoints      int                `json:"checkpoints"`
+	BrokenCheckpoint uint               `json:"brokenCheckpoint,omitempty"`
+	Reason           string             `json:"reason,omitempty"`
+}
+
+// List 按条件分页查询审计记录
+func (s *AuditService) List(ctx context.Context, f repository.AuditFilter, offset, limit int) ([]audit2.AuditedHistory, int64, error) {
+	return s.repo.List(ctx, f, offset, limit)
+}
+
+// Get 读取一条审计记录，不存在返回 repository.ErrNotFound
+func (s *AuditService) Get(ctx context.Context, id uint) (*audit2.AuditedHistory, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// Timeline 某条业务记录从创建到现在的全部变更，行级记录的 payload 是字段差异
+func (s *AuditService) Timeline(ctx context.Context, table audit2.AuditedTableEnum, recordID uint) ([]audit2.AuditedHistory, error) {
+	return s.repo.Timeline(ctx, table, recordID)
+}
+
+// Restore 把业务记录恢复到 historyID 那次变更之后的状态
+// 恢复写入本身会被行级审计记下字段差异，另外再记一条 restore 操作指明来源记录
+// 该记录不能恢复时返回 ErrInvalidInput
+func (s *AuditService) Restore(ctx context.Context, historyID uint) (*audit2.AuditedHistory, error) {
+	h, err := s.repo.FindByID(ctx, historyID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.Restore(ctx, h)
+	if errors.Is(err, repository.ErrInvalidState) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.aud.Record(ctx, h.TableName, uint(h.RecordID), "restore", map[string]interface{}{
+		"historyId": h.HistoryID,
+		"changedAt": h.ChangedAt,
+	}); err != nil {
+		s.logger.Warn("Failed to record restore", zap.Int("historyID", h.HistoryID), zap.Error(err))
+	}
+	s.logger.Info("Record restored from audit history",
+		zap.String("table", string(h.TableName)), zap.Int("recordID", h.RecordID), zap.Int("historyID", h.HistoryID))
+	return h, nil
+}
+
+// Verify 校验整条哈希链，再逐个核对检查点：签名有效，且检查点记下的记录仍在链上、哈希未变
+// 只靠哈希链发现不了链尾被整段删除或整体重算，检查点补上这一块
+func (s *AuditService) Verify(ctx context.Context) (*AuditVerifyReport, error) {
+	cps, err := s.repo.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(cps))
+	for i, c := range cps {
+		ids[i] = c.HistoryID
+	}
+	chain, err := s.repo.VerifyChain(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	rep := &AuditVerifyReport{OK: chain.OK, Chain: chain, Checkpoints: len(cps)}
+	for i := range cps {
+		c := &cps[i]
+		if !chain.OK && c.Count > chain.Checked {
+			break // 断链之后的检查点已经没有意义
+		}
+		if err := s.keys.Verify(c); err != nil {
+			rep.Reason = err.Error()
+		} else if h, ok := chain.HashAt(c.HistoryID); !ok {
+			rep.Reason = fmt.Sprintf("checkpointed entry %d is missing from the chain", c.HistoryID)
+		} else if h != c.Hash {
+			rep.Reason = fmt.Sprintf("entry %d no longer matches the checkpointed hash", c.HistoryID)
+		}
+		if rep.Reason != "" {
+			rep.OK = false
+			rep.BrokenCheckpoint = c.ID
+			break
+		}
+	}
+	return rep, nil
+}
+
+// CreateCheckpoint 给当前链尾签一个检查点；上次检查点之后没有新记录时返回上次的
+// 审计表为空时返回 repository.ErrNotFound
+func (s *AuditService) CreateCheckpoint(ctx context.Context) (*audit2.Checkpoint, error) {
+	head, err := s.repo.ChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last, err := s.repo.LastCheckpoint(ctx)
+	if err == nil && last.Count >= head.Count {
+		return last, nil
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	head.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	s.keys.Sign(head)
+	if err := s.repo.CreateCheckpoint(ctx, head); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Audit checkpoint created", zap.Int("historyID", head.HistoryID), zap.Int64("count", head.Count))
+	return head, nil
+}
+
+// Checkpoints 全部检查点
+func (s *AuditService) Checkpoints(ctx context.Context) ([]audit2.Checkpoint, error) {
+	return s.repo.ListCheckpoints(ctx)
+}
+
+// PublicKeys 检查点验签公钥，kid → base64
+func (s *AuditService) PublicKeys() map[string]string {
+	return s.keys.PublicKeys()
+}
+
+// RunSealer 每隔 interval 把业务事务里写下的待上链审计记录接到哈希链末尾，直到 ctx 取消
+func (s *AuditService) RunSealer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.Seal(ctx); err != nil {
+				s.logger.Error("Audit chain seal failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// RunCheckpointer 每隔 interval 签一个检查点，直到 ctx 取消
+func (s *AuditService) RunCheckpointer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.CreateCheckpoint(ctx); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				s.logger.Error("Audit checkpoint failed", zap.Error(err))
+			}
+		}
+	}
+}
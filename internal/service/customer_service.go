@@ -24,11 +24,11 @@ func NewCustomerService(repo *repository.CustomerRepo) CustomerService {
 }
 
 func (s *customerService) List(ctx context.Context) ([]catalog.Customer, error) {
-	return s.repo.GetAll()
+	return s.repo.GetAll(ctx)
 }
 
 func (s *customerService) Get(ctx context.Context, id uint) (*catalog.Customer, error) {
-	c, err := s.repo.GetByID(id)
+	c, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("service: get customer %d: %w", id, err)
 	}
@@ -36,7 +36,7 @@ func (s *customerService) Get(ctx context.Context, id uint) (*catalog.Customer,
 }
 
 func (s *customerService) Create(ctx context.Context, input *catalog.Customer) (*catalog.Customer, error) {
-	if err := s.repo.Create(input); err != nil {
+	if err := s.repo.Create(ctx, input); err != nil {
 		return nil, fmt.Errorf("service: create customer: %w", err)
 	}
 	return input, nil
@@ -44,14 +44,14 @@ func (s *customerService) Create(ctx context.Context, input *catalog.Customer) (
 
 func (s *customerService) Update(ctx context.Context, id uint, input *catalog.Customer) (*catalog.Customer, error) {
 	input.ID = id
-	if err := s.repo.Update(input); err != nil {
+	if err := s.repo.Update(ctx, input); err != nil {
 		return nil, fmt.Errorf("service: update customer %d: %w", id, err)
 	}
 	return input, nil
 }
 
 func (s *customerService) Delete(ctx context.Context, id uint) error {
-	if err := s.repo.Delete(id); err != nil {
+	if err := s.repo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("service: delete customer %d: %w", id, err)
 	}
 	return nil
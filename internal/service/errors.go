@@ -4,3 +4,15 @@ import "errors"
 
 // ErrNotFound 表示在数据库或其它存储中没找到对应记录
 var ErrNotFound = errors.New("resource not found")
+
+// ErrInvalidInput 表示请求参数未通过业务校验
+var ErrInvalidInput = errors.New("invalid input")
+
+// ErrForbidden 表示当前操作人无权执行该业务操作（如审批自己提交的单据）
+var ErrForbidden = errors.New("operation not allowed for this operator")
+
+// ErrInvalidCredentials 用户名或密码错误（不区分用户不存在，避免枚举账号）
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrAccountLocked 连续登录失败次数过多，账号暂时锁定
+var ErrAccountLocked = errors.New("account temporarily locked")
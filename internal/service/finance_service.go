@@ -0,0 +1,189 @@
+// internal/service/finance_service.go
+package service
+
+import (
+	"context"
+	audit2 "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/finance"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FinanceService 税务发票、收款、贷项通知单；收到定金 / 尾款后按状态机推进订单
+type FinanceService struct {
+	repo         *repository.FinanceRepository
+	orders       *OrderService
+	paymentTerms time.Duration // 开票日到到期日的默认账期
+	aud          audit.Recorder
+	logger       *zap.Logger
+}
+
+func NewFinanceService(repo *repository.FinanceRepository, orders *OrderService, paymentTerms time.Duration, aud audit.Recorder, logger *zap.Logger) *FinanceService {
+	return &FinanceService{
+		repo:         repo,
+		orders:       orders,
+		paymentTerms: paymentTerms,
+		aud:          aud,
+		logger:       logger,
+	}
+}
+
+// PaymentResult 登记收款的结果；订单状态因此推进时带上 Transition
+type PaymentResult struct {
+	Payment    *finance.Payment            `json:"payment"`
+	Transition *repository.OrderTransition `json:"transition,omitempty"`
+}
+
+// ListInvoices 分页列出发票
+func (s *FinanceService) ListInvoices(ctx context.Context, f repository.InvoiceFilter, offset, limit int) ([]finance.TaxInvoice, int64, error) {
+	return s.repo.ListInvoices(ctx, f, offset, limit)
+}
+
+// GetInvoice 读取发票及明细、收款、贷项通知单
+func (s *FinanceService) GetInvoice(ctx context.Context, id uint) (*finance.TaxInvoice, error) {
+	return s.repo.FindInvoice(ctx, id)
+}
+
+// GetCreditNote 读取贷项通知单
+func (s *FinanceService) GetCreditNote(ctx context.Context, id uint) (*finance.CreditNote, error) {
+	return s.repo.FindCreditNote(ctx, id)
+}
+
+// ListPayments 订单的全部收款（含退款）
+func (s *FinanceService) ListPayments(ctx context.Context, orderID uint) ([]finance.Payment, error) {
+	if _, err := s.orders.Get(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPayments(ctx, orderID)
+}
+
+// CreateInvoice 为已交付的订单开税务发票
+func (s *FinanceService) CreateInvoice(ctx context.Context, orderID uint, req dto.CreateInvoiceRequest, userID uint) (*finance.TaxInvoice, error) {
+	issue, err := parseDateOr(req.IssueDate, "issueDate", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	due, err := parseDateOr(req.DueDate, "dueDate", issue.Add(s.paymentTerms))
+	if err != nil {
+		return nil, err
+	}
+	if due.Format("2006-01-02") < issue.Format("2006-01-02") {
+		return nil, fmt.Errorf("%w: dueDate is before issueDate", ErrInvalidInput)
+	}
+
+	id, err := s.repo.CreateInvoice(ctx, orderID, issue, due, userID)
+	if err != nil {
+		s.logger.Error("Failed to create tax invoice", zap.Uint("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+	inv, err := s.repo.FindInvoice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, audit2.AuditedTableTaxInvoices, inv.ID, "create", map[string]interface{}{
+		"invoiceNumber": inv.InvoiceNumber,
+		"orderId":       inv.OrderID,
+		"totalAmount":   inv.TotalAmount,
+		"balanceDue":    inv.BalanceDue,
+	})
+	return inv, nil
+}
+
+// RecordPayment 登记定金 / 尾款，订单状态在同一个事务里推进：
+//   - 定金：ordered → deposit_received
+//   - 尾款：ordered / deposit_received → final_payment_received
+//
+// 订单已过这些阶段（如已交付后补收尾款）时只登记收款，不改状态；状态推进失败时收款不入账
+func (s *FinanceService) RecordPayment(ctx context.Context, orderID uint, req dto.RecordPaymentRequest, operator string, userID uint) (*PaymentResult, error) {
+	if !finance.IsPaymentKind(req.Kind) {
+		return nil, fmt.Errorf("%w: unsupported payment kind %q", ErrInvalidInput, req.Kind)
+	}
+	received, err := parseDateOr(req.ReceivedAt, "receivedAt", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	p := &finance.Payment{
+		OrderID:    orderID,
+		Kind:       req.Kind,
+		Amount:     req.Amount,
+		Method:     strings.TrimSpace(req.Method),
+		Reference:  strings.TrimSpace(req.Reference),
+		Note:       req.Note,
+		ReceivedAt: received,
+		RecordedBy: userID,
+	}
+	t, err := s.repo.RecordPayment(ctx, p, operator)
+	if err != nil {
+		s.logger.Error("Failed to record payment", zap.Uint("orderID", orderID), zap.Error(err))
+		return nil, err
+	}
+	s.record(ctx, audit2.AuditedTablePayments, p.ID, "create", p)
+	if t != nil && t.From != t.To {
+		s.orders.record(ctx, audit2.AuditedTableOrders, orderID, "status:"+t.To, t)
+	}
+	return &PaymentResult{Payment: p, Transition: t}, nil
+}
+
+// CreateCreditNote 为发票开贷项通知单（可同时退款）
+func (s *FinanceService) CreateCreditNote(ctx context.Context, invoiceID uint, req dto.CreateCreditNoteRequest, userID uint) (*finance.CreditNote, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidInput)
+	}
+	lines := make([]repository.CreditNoteLineInput, 0, len(req.Lines))
+	for i, ln := range req.Lines {
+		if ln.InvoiceLineID == nil && strings.TrimSpace(ln.Description) == "" {
+			return nil, fmt.Errorf("%w: line %d needs invoiceLineId or description", ErrInvalidInput, i+1)
+		}
+		lines = append(lines, repository.CreditNoteLineInput{
+			TaxInvoiceLineID: ln.InvoiceLineID,
+			Description:      strings.TrimSpace(ln.Description),
+			Quantity:         ln.Quantity,
+			UnitPrice:        ln.UnitPrice,
+		})
+	}
+
+	id, err := s.repo.CreateCreditNote(ctx, invoiceID, lines, reason, req.RefundAmount,
+		strings.TrimSpace(req.RefundMethod), strings.TrimSpace(req.Reference), userID)
+	if err != nil {
+		s.logger.Error("Failed to create credit note", zap.Uint("invoiceID", invoiceID), zap.Error(err))
+		return nil, err
+	}
+	cn, err := s.repo.FindCreditNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, audit2.AuditedTableCreditNotes, cn.ID, "create", map[string]interface{}{
+		"creditNoteNumber": cn.CreditNoteNumber,
+		"taxInvoiceId":     cn.TaxInvoiceID,
+		"totalAmount":      cn.TotalAmount,
+		"refundAmount":     cn.RefundAmount,
+	})
+	return cn, nil
+}
+
+// parseDateOr 解析 YYYY-MM-DD，为空时返回 def
+func parseDateOr(v, field string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s, expected YYYY-MM-DD", ErrInvalidInput, field)
+	}
+	return t, nil
+}
+
+// record 写审计；审计失败不影响业务结果，只记日志
+func (s *FinanceService) record(ctx context.Context, table audit2.AuditedTableEnum, id uint, op string, payload interface{}) {
+	if err := s.aud.Record(ctx, table, id, op, payload); err != nil {
+		s.logger.Warn("Failed to record audit",
+			zap.String("table", string(table)), zap.Uint("id", id), zap.String("op", op), zap.Error(err))
+	}
+}
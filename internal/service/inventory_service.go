@@ -10,8 +10,8 @@ import (
 	"djj-inventory-system/internal/model/catalog"
 	"djj-inventory-system/internal/model/inventory"
 	"djj-inventory-system/internal/repository"
-	"errors"
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -55,15 +55,52 @@ func (s *InventoryService) GetProductStockInWarehouse(ctx context.Context, produ
 	return stock, nil
 }
 
+// GetWarehouseStocks 获取仓库下所有产品的库存（分页）
+func (s *InventoryService) GetWarehouseStocks(ctx context.Context, warehouseID uint, offset, limit int) ([]catalog.ProductStock, int64, error) {
+	stocks, total, err := s.repo.GetStocksByWarehouse(ctx, warehouseID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to get warehouse stocks", zap.Uint("warehouseID", warehouseID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get warehouse stocks: %w", err)
+	}
+
+	return stocks, total, nil
+}
+
+// GetLowStock 获取现有量不高于阈值的库存
+func (s *InventoryService) GetLowStock(ctx context.Context, threshold int) ([]catalog.ProductStock, error) {
+	if threshold < 0 {
+		return nil, fmt.Errorf("%w: threshold must not be negative", ErrInvalidInput)
+	}
+
+	stocks, err := s.repo.GetLowStockProducts(ctx, threshold)
+	if err != nil {
+		s.logger.Error("Failed to get low stock products", zap.Int("threshold", threshold), zap.Error(err))
+		return nil, fmt.Errorf("failed to get low stock products: %w", err)
+	}
+
+	return stocks, nil
+}
+
+// GetInventorySummary 获取产品在所有仓库的库存汇总
+func (s *InventoryService) GetInventorySummary(ctx context.Context, productID uint) (map[string]interface{}, error) {
+	summary, err := s.repo.GetInventorySummary(ctx, productID)
+	if err != nil {
+		s.logger.Error("Failed to get inventory summary", zap.Uint("productID", productID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get inventory summary: %w", err)
+	}
+
+	return summary, nil
+}
+
 // ==== 库存操作相关 ====
 
 // StockIn 入库操作
 func (s *InventoryService) StockIn(ctx context.Context, productID, warehouseID uint, quantity int, operator, note string) error {
 	if quantity <= 0 {
-		return errors.New("quantity must be positive")
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
 	}
 	if operator == "" {
-		return errors.New("operator is required")
+		return fmt.Errorf("%w: operator is required", ErrInvalidInput)
 	}
 
 	err := s.repo.ProcessStockMovement(ctx, productID, warehouseID, quantity,
@@ -89,10 +126,10 @@ func (s *InventoryService) StockIn(ctx context.Context, productID, warehouseID u
 // StockOut 出库操作
 func (s *InventoryService) StockOut(ctx context.Context, productID, warehouseID uint, quantity int, operator, note string) error {
 	if quantity <= 0 {
-		return errors.New("quantity must be positive")
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
 	}
 	if operator == "" {
-		return errors.New("operator is required")
+		return fmt.Errorf("%w: operator is required", ErrInvalidInput)
 	}
 
 	err := s.repo.ProcessStockMovement(ctx, productID, warehouseID, quantity,
@@ -118,10 +155,10 @@ func (s *InventoryService) StockOut(ctx context.Context, productID, warehouseID
 // Sale 销售操作
 func (s *InventoryService) Sale(ctx context.Context, productID, warehouseID uint, quantity int, operator, note string) error {
 	if quantity <= 0 {
-		return errors.New("quantity must be positive")
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
 	}
 	if operator == "" {
-		return errors.New("operator is required")
+		return fmt.Errorf("%w: operator is required", ErrInvalidInput)
 	}
 
 	err := s.repo.ProcessStockMovement(ctx, productID, warehouseID, quantity,
@@ -157,40 +194,79 @@ func (s *InventoryService) GetProductTransactions(ctx context.Context, productID
 	return transactions, total, nil
 }
 
-// BatchStockUpdate 批量更新库存
+// GetWarehouseTransactions 获取仓库的所有事务记录
+func (s *InventoryService) GetWarehouseTransactions(ctx context.Context, warehouseID uint, offset, limit int) ([]inventory.InventoryTransaction, int64, error) {
+	transactions, total, err := s.repo.GetTransactionsByWarehouse(ctx, warehouseID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to get warehouse transactions",
+			zap.Uint("warehouseID", warehouseID),
+			zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get warehouse transactions: %w", err)
+	}
+
+	return transactions, total, nil
+}
+
+// GetInventoryTransactions 获取某条库存记录的事务记录
+func (s *InventoryService) GetInventoryTransactions(ctx context.Context, inventoryID uint, offset, limit int) ([]inventory.InventoryTransaction, int64, error) {
+	transactions, total, err := s.repo.GetTransactionsByInventory(ctx, inventoryID, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to get inventory transactions",
+			zap.Uint("inventoryID", inventoryID),
+			zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get inventory transactions: %w", err)
+	}
+
+	return transactions, total, nil
+}
+
+// GetTransactionsByDateRange 获取时间范围内的事务记录
+func (s *InventoryService) GetTransactionsByDateRange(ctx context.Context, startDate, endDate time.Time, offset, limit int) ([]inventory.InventoryTransaction, int64, error) {
+	if endDate.Before(startDate) {
+		return nil, 0, fmt.Errorf("%w: end date must not be before start date", ErrInvalidInput)
+	}
+
+	transactions, total, err := s.repo.GetTransactionsByDateRange(ctx, startDate, endDate, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to get transactions by date range",
+			zap.Time("startDate", startDate),
+			zap.Time("endDate", endDate),
+			zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get transactions by date range: %w", err)
+	}
+
+	return transactions, total, nil
+}
+
+// BatchStockUpdate 批量更新库存：全部校验通过后在一个事务里执行，任一条失败则整批回滚
 func (s *InventoryService) BatchStockUpdate(ctx context.Context, updates []StockUpdateRequest, operator string) error {
 	if operator == "" {
-		return errors.New("operator is required")
+		return fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if len(updates) == 0 {
+		return fmt.Errorf("%w: no updates", ErrInvalidInput)
 	}
 
+	moves := make([]repository.StockMovement, len(updates))
 	for i, update := range updates {
 		if err := s.validateStockUpdate(update); err != nil {
 			return fmt.Errorf("validation failed for update %d: %w", i, err)
 		}
-	}
-
-	// 处理每个更新
-	for _, update := range updates {
-		var err error
-		switch update.Type {
-		case "IN":
-			err = s.StockIn(ctx, update.ProductID, update.WarehouseID, update.Quantity, operator, update.Note)
-		case "OUT":
-			err = s.StockOut(ctx, update.ProductID, update.WarehouseID, update.Quantity, operator, update.Note)
-		case "SALE":
-			err = s.Sale(ctx, update.ProductID, update.WarehouseID, update.Quantity, operator, update.Note)
-		default:
-			err = fmt.Errorf("unknown update type: %s", update.Type)
+		moves[i] = repository.StockMovement{
+			ProductID:   update.ProductID,
+			WarehouseID: update.WarehouseID,
+			Quantity:    update.Quantity,
+			TxType:      inventory.TransactionType(update.Type),
+			Note:        update.Note,
 		}
+	}
 
-		if err != nil {
-			s.logger.Error("Failed to process batch stock update",
-				zap.Uint("productID", update.ProductID),
-				zap.Uint("warehouseID", update.WarehouseID),
-				zap.String("type", update.Type),
-				zap.Error(err))
-			return fmt.Errorf("failed to process update for product %d: %w", update.ProductID, err)
-		}
+	if err := s.repo.ProcessStockMovements(ctx, moves, operator); err != nil {
+		s.logger.Error("Failed to process batch stock update",
+			zap.Int("updateCount", len(updates)),
+			zap.String("operator", operator),
+			zap.Error(err))
+		return fmt.Errorf("failed to process batch stock update: %w", err)
 	}
 
 	s.logger.Info("Batch stock update completed successfully",
@@ -202,16 +278,16 @@ func (s *InventoryService) BatchStockUpdate(ctx context.Context, updates []Stock
 
 func (s *InventoryService) validateStockUpdate(update StockUpdateRequest) error {
 	if update.ProductID == 0 {
-		return errors.New("product ID is required")
+		return fmt.Errorf("%w: product ID is required", ErrInvalidInput)
 	}
 	if update.WarehouseID == 0 {
-		return errors.New("warehouse ID is required")
+		return fmt.Errorf("%w: warehouse ID is required", ErrInvalidInput)
 	}
 	if update.Quantity <= 0 {
-		return errors.New("quantity must be positive")
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
 	}
 	if update.Type != "IN" && update.Type != "OUT" && update.Type != "SALE" {
-		return errors.New("invalid update type")
+		return fmt.Errorf("%w: invalid update type", ErrInvalidInput)
 	}
 	return nil
 }
@@ -6,30 +6,36 @@ import (
 	"context"
 	"djj-inventory-system/assets"
 	"djj-inventory-system/internal/logger"
+	"djj-inventory-system/internal/pkg/pdf"
 	"djj-inventory-system/internal/repository"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
-	"time"
 
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/company"
+	"djj-inventory-system/internal/model/finance"
 	"djj-inventory-system/internal/model/sales"
-
-	"github.com/chromedp/cdproto/page"
-	"github.com/chromedp/chromedp"
 )
 
 type InvoiceService struct {
 	QuoteRepo   *repository.QuoteRepository
 	OrderRepo   *repository.OrderRepository
+	PickingRepo *repository.PickingRepository
 	CompanyRepo *repository.CompanyRepository
+	FinanceRepo *repository.FinanceRepository
 	tmpl        *template.Template
 	logoBase64  string
+	pool        *pdf.BrowserPool
 }
 
 func NewInvoiceService(
 	qr *repository.QuoteRepository,
 	or *repository.OrderRepository,
+	pr *repository.PickingRepository,
 	cr *repository.CompanyRepository,
+	fr *repository.FinanceRepository,
+	pool *pdf.BrowserPool,
 	tplPath string,
 ) *InvoiceService {
 	// 1) base64 logo（同理 embed logo.png）
@@ -42,8 +48,11 @@ func NewInvoiceService(
 	return &InvoiceService{
 		QuoteRepo:   qr,
 		OrderRepo:   or,
+		PickingRepo: pr,
 		CompanyRepo: cr,
+		FinanceRepo: fr,
 		tmpl:        tmpl,
+		pool:        pool,
 	}
 }
 
@@ -53,6 +62,9 @@ func (s *InvoiceService) GenerateQuotePDF(ctx context.Context, quoteID uint) ([]
 	if err != nil {
 		return nil, err
 	}
+	if q == nil {
+		return nil, repository.ErrNotFound
+	}
 
 	// 2) 读公司
 	co, err := s.CompanyRepo.FindDefault(ctx)
@@ -114,9 +126,14 @@ func toInvoiceItemsFromQuote(qis []sales.QuoteItem) []sales.Item {
 	return out
 }
 
-func (s *InvoiceService) GeneratePickingPDF(ctx context.Context, orderID uint) ([]byte, error) {
-	// 1) 读拣货单
-	o, err := s.OrderRepo.FindByID(ctx, orderID)
+// GeneratePickingPDF 按拣货单打印，每行显示分配到的仓库和库位
+func (s *InvoiceService) GeneratePickingPDF(ctx context.Context, pickingListID uint) ([]byte, error) {
+	// 1) 读拣货单及其订单
+	p, err := s.PickingRepo.FindByID(ctx, pickingListID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.OrderRepo.FindByID(ctx, p.OrderID)
 	if err != nil {
 		return nil, err
 	}
@@ -136,19 +153,19 @@ func (s *InvoiceService) GeneratePickingPDF(ctx context.Context, orderID uint) (
 		CompanyWebsite:     co.Website,
 		CompanyABN:         co.ABN,
 		CompanyAddress:     co.Address,
-		InvoiceNumber:      o.OrderNumber,
-		InvoiceDate:        o.OrderDate.Format("2006/01/02"),
+		InvoiceNumber:      p.PickingNumber,
+		InvoiceDate:        p.CreatedAt.Format("2006/01/02"),
 		InvoiceType:        "PICKING LIST",
 		IsQuote:            false,
 		BillingAddress:     o.ShippingAddress,
-		DeliveryAddress:    o.ShippingAddress,
+		DeliveryAddress:    p.DeliveryAddress,
 		CustomerCompany:    o.Customer.Name,
 		CustomerABN:        o.Customer.ABN,
 		CustomerContact:    o.Customer.Contact,
 		CustomerPhone:      o.Customer.Phone,
 		CustomerEmail:      o.Customer.Email,
 		SalesRep:           o.SalesRepUser.Username,
-		Items:              toInvoiceItemsFromOrder(o.Items, o.Location),
+		Items:              toInvoiceItemsFromPicking(p.Items),
 		BankName:           co.BankName,
 		BSB:                co.BSB,
 		AccountNumber:      co.AccountNumber,
@@ -161,10 +178,141 @@ func (s *InvoiceService) GeneratePickingPDF(ctx context.Context, orderID uint) (
 	return s.renderAndPrintPDF(ctx, inv)
 }
 
+// GenerateTaxInvoicePDF 打印税务发票，底部显示已收金额和余额
+func (s *InvoiceService) GenerateTaxInvoicePDF(ctx context.Context, invoiceID uint) ([]byte, error) {
+	ti, err := s.FinanceRepo.FindInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	// 按开票公司而不是默认公司打印抬头和收款账户
+	co, err := s.CompanyRepo.FindByID(ctx, ti.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("加载公司信息失败: %w", err)
+	}
+
+	inv := companyInvoice(co)
+	inv.InvoiceNumber = ti.InvoiceNumber
+	inv.InvoiceDate = ti.IssueDate.Format("2006/01/02")
+	inv.InvoiceType = "TAX INVOICE"
+	inv.ShowPrices = true
+	fillCustomer(&inv, ti.Customer, ti.Order)
+	inv.Items = toInvoiceItemsFromTaxInvoice(ti.Lines)
+	inv.SubtotalAmount = ti.SubTotal
+	inv.GSTAmount = ti.GSTTotal
+	inv.TotalAmount = ti.TotalAmount
+	inv.ShowBalance = true
+	inv.AmountPaid = ti.AmountPaid
+	inv.BalanceDue = ti.BalanceDue
+	inv.DueDate = ti.DueDate.Format("2006/01/02")
+	inv.CreditedAmount = ti.CreditedAmount
+
+	return s.renderAndPrintPDF(ctx, inv)
+}
+
+// GenerateCreditNotePDF 打印贷项通知单，条款位置显示原发票号和原因
+func (s *InvoiceService) GenerateCreditNotePDF(ctx context.Context, creditNoteID uint) ([]byte, error) {
+	cn, err := s.FinanceRepo.FindCreditNote(ctx, creditNoteID)
+	if err != nil {
+		return nil, err
+	}
+	co, err := s.CompanyRepo.FindByID(ctx, cn.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("加载公司信息失败: %w", err)
+	}
+
+	inv := companyInvoice(co)
+	inv.InvoiceNumber = cn.CreditNoteNumber
+	inv.InvoiceDate = cn.IssueDate.Format("2006/01/02")
+	inv.InvoiceType = "ADJUSTMENT NOTE"
+	inv.ShowPrices = true
+	if cn.TaxInvoice != nil {
+		fillCustomer(&inv, cn.TaxInvoice.Customer, cn.TaxInvoice.Order)
+		inv.TermsAndConditions = fmt.Sprintf("Credit against tax invoice %s. Reason: %s", cn.TaxInvoice.InvoiceNumber, cn.Reason)
+	}
+	if cn.RefundAmount > 0 {
+		inv.TermsAndConditions += fmt.Sprintf(" Refunded: $%.2f", cn.RefundAmount)
+	}
+	inv.Items = toInvoiceItemsFromCreditNote(cn.Lines)
+	inv.SubtotalAmount = cn.SubTotal
+	inv.GSTAmount = cn.GSTTotal
+	inv.TotalAmount = cn.TotalAmount
+
+	return s.renderAndPrintPDF(ctx, inv)
+}
+
+// companyInvoice 用公司资料填好抬头和收款账户
+func companyInvoice(co *company.Company) sales.Invoice {
+	return sales.Invoice{
+		LogoBase64:     LogoBase64,
+		CompanyName:    co.Name,
+		CompanyEmail:   co.Email,
+		CompanyPhone:   co.Phone,
+		CompanyWebsite: co.Website,
+		CompanyABN:     co.ABN,
+		CompanyAddress: co.Address,
+		BankName:       co.BankName,
+		BSB:            co.BSB,
+		AccountNumber:  co.AccountNumber,
+	}
+}
+
+// fillCustomer 填客户信息；订单存在时送货地址和销售取订单上的
+func fillCustomer(inv *sales.Invoice, cu catalog.Customer, o *sales.Order) {
+	inv.BillingAddress = cu.Address
+	inv.DeliveryAddress = cu.Address
+	inv.CustomerCompany = cu.Name
+	inv.CustomerABN = cu.ABN
+	inv.CustomerContact = cu.Contact
+	inv.CustomerPhone = cu.Phone
+	inv.CustomerEmail = cu.Email
+	if o != nil {
+		inv.DeliveryAddress = o.ShippingAddress
+		inv.SalesRep = o.SalesRepUser.Username
+	}
+}
+
+// toInvoiceItemsFromTaxInvoice 把发票行转成 []Item
+func toInvoiceItemsFromTaxInvoice(lines []finance.TaxInvoiceLine) []sales.Item {
+	out := make([]sales.Item, len(lines))
+	for i, ln := range lines {
+		out[i] = sales.Item{
+			DJJCode:     ln.Product.DJJCode,
+			Description: ln.Description,
+			VinEngine:   ln.Product.VinEngine,
+			Quantity:    ln.Quantity,
+			UnitPrice:   ln.UnitPrice,
+			Subtotal:    ln.LineTotal,
+		}
+	}
+	return out
+}
+
+// toInvoiceItemsFromCreditNote 把贷项通知单行转成 []Item；金额调整行没有产品
+func toInvoiceItemsFromCreditNote(lines []finance.CreditNoteLine) []sales.Item {
+	out := make([]sales.Item, len(lines))
+	for i, ln := range lines {
+		out[i] = sales.Item{
+			Description: ln.Description,
+			Quantity:    ln.Quantity,
+			UnitPrice:   ln.UnitPrice,
+			Subtotal:    ln.LineTotal,
+		}
+		if ln.Product != nil {
+			out[i].DJJCode = ln.Product.DJJCode
+			out[i].VinEngine = ln.Product.VinEngine
+		}
+	}
+	return out
+}
+
 // renderAndPrintPDF 负责：
 //  1. 渲染模板为 HTML
-//  2. 用 chromedp 打印成 PDF
+//  2. 交给浏览器池打印成 PDF；本机没有 Chrome 时改用纯 Go 版式
 func (s *InvoiceService) renderAndPrintPDF(ctx context.Context, inv sales.Invoice) ([]byte, error) {
+	if s.pool == nil || !s.pool.Available() {
+		return pdf.RenderInvoice(inv)
+	}
+
 	// 渲染
 	var htmlBuf bytes.Buffer
 	if err := s.tmpl.Execute(&htmlBuf, inv); err != nil {
@@ -172,41 +320,20 @@ func (s *InvoiceService) renderAndPrintPDF(ctx context.Context, inv sales.Invoic
 	}
 
 	// 打印
-	pdfCtx, cancel := chromedp.NewContext(ctx)
-	defer cancel()
-	pdfCtx, cancel = context.WithTimeout(pdfCtx, 20*time.Second)
-	defer cancel()
-
-	var pdf []byte
-	if err := chromedp.Run(pdfCtx,
-		chromedp.Navigate("data:text/html;base64,"+base64.StdEncoding.EncodeToString(htmlBuf.Bytes())),
-		chromedp.WaitReady("body"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			buf, _, err := page.PrintToPDF().
-				WithPrintBackground(true).
-				WithDisplayHeaderFooter(true).
-				WithHeaderTemplate(`<div></div>`).
-				WithFooterTemplate(`
-					<div style="width:100%;text-align:center;font-size:10px;color:#666;">
-					  <span class="pageNumber"></span>/<span class="totalPages"></span>
-					</div>
-				`).
-				WithMarginTop(0.4).
-				WithMarginBottom(0.5).
-				WithMarginLeft(0.3).
-				WithMarginRight(0.3).
-				Do(ctx)
-			if err != nil {
-				return err
-			}
-			pdf = buf
-			return nil
-		}),
-	); err != nil {
-		return nil, fmt.Errorf("生成 PDF 失败: %w", err)
-	}
+	return s.PrintHTML(ctx, htmlBuf.Bytes(), inv)
+}
 
-	return pdf, nil
+// PrintHTML 把调用方渲染好的 HTML 交给浏览器池打印；本机没有 Chrome 时按 fallback 用纯 Go 版式生成
+func (s *InvoiceService) PrintHTML(ctx context.Context, html []byte, fallback sales.Invoice) ([]byte, error) {
+	if s.pool == nil || !s.pool.Available() {
+		return pdf.RenderInvoice(fallback)
+	}
+	out, err := s.pool.Print(ctx, html)
+	if errors.Is(err, pdf.ErrBrowserUnavailable) {
+		logger.Errorf("headless browser unavailable, falling back to built-in PDF renderer: %v", err)
+		return pdf.RenderInvoice(fallback)
+	}
+	return out, err
 }
 
 // toInvoiceItems 把 repo QuoteItem 转到 Invoice Item
@@ -242,3 +369,24 @@ func toInvoiceItemsFromOrder(items []sales.OrderItem, location string) []sales.I
 	}
 	return out
 }
+
+// toInvoiceItemsFromPicking 把拣货行转成 []Item，Location 显示“仓库 / 库位”
+func toInvoiceItemsFromPicking(items []sales.PickingListItem) []sales.Item {
+	out := make([]sales.Item, len(items))
+	for i, it := range items {
+		loc := it.Location
+		if it.Warehouse != nil {
+			loc = it.Warehouse.Name
+			if it.Location != "" {
+				loc += " / " + it.Location
+			}
+		}
+		out[i] = sales.Item{
+			DJJCode:     it.Product.DJJCode,
+			Description: it.Product.NameCN,
+			Quantity:    it.Quantity,
+			Location:    loc,
+		}
+	}
+	return out
+}
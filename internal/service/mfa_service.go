@@ -0,0 +1,321 @@
+// internal/service/mfa_service.go
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	audit2 "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/pkg/totp"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+// MFAPolicy 两步验证策略：持有任一 RequiredRoles 角色或 RequiredPermissions 权限的用户必须绑定 TOTP
+type MFAPolicy struct {
+	Issuer              string // 验证器 App 里显示的名称
+	RequiredRoles       []string
+	RequiredPermissions []string
+}
+
+// Required 该用户是否必须启用两步验证
+func (p MFAPolicy) Required(u *rbac.User) bool {
+	for _, r := range u.Roles {
+		if containsString(p.RequiredRoles, r.Name) {
+			return true
+		}
+	}
+	for _, name := range FinalPermissions(u) {
+		if containsString(p.RequiredPermissions, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MFAStatus 当前用户的两步验证状态
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Required          bool       `json:"required"`
+	EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesLeft int64      `json:"recoveryCodesLeft"`
+}
+
+// TOTPEnrollment 绑定时返回给用户的密钥；URI 由前端渲染成二维码
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAService TOTP 两步验证：绑定、登录第二步校验、恢复码
+//   - 验证码允许前后一个时间步的误差，同一时间步只能用一次
+//   - 恢复码每个只能用一次，可在验证 TOTP 后整批重新生成
+//   - 第二步验证连续失败同样按 LockoutPolicy 锁定账号
+type MFAService struct {
+	repo    *repository.MFARepository
+	users   repository.UserRepo
+	policy  MFAPolicy
+	lockout LockoutPolicy
+	aud     audit.Recorder
+	logger  *zap.Logger
+}
+
+func NewMFAService(repo *repository.MFARepository, users repository.UserRepo, policy MFAPolicy, lockout LockoutPolicy, aud audit.Recorder, logger *zap.Logger) *MFAService {
+	return &MFAService{repo: repo, users: users, policy: policy, lockout: lockout, aud: aud, logger: logger}
+}
+
+// Required 该用户是否必须启用两步验证
+func (s *MFAService) Required(u *rbac.User) bool {
+	return s.policy.Required(u)
+}
+
+// Enabled 用户是否已完成 TOTP 绑定
+func (s *MFAService) Enabled(ctx context.Context, userID uint) (bool, error) {
+	t, err := s.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.Enabled(), nil
+}
+
+// Status 两步验证状态
+func (s *MFAService) Status(ctx context.Context, u *rbac.User) (*MFAStatus, error) {
+	st := &MFAStatus{Required: s.policy.Required(u)}
+	t, err := s.repo.FindTOTP(ctx, u.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled() {
+		st.Enabled = true
+		st.EnabledAt = t.EnabledAt
+		if st.RecoveryCodesLeft, err = s.repo.RemainingRecoveryCodes(ctx, u.ID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// BeginEnrollment 生成新密钥，待用户用验证码确认后才生效；已启用时返回 ErrInvalidInput
+func (s *MFAService) BeginEnrollment(ctx context.Context, u *rbac.User) (*TOTPEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.SavePendingTOTP(ctx, u.ID, secret)
+	if errors.Is(err, repository.ErrInvalidState) {
+		return nil, fmt.Errorf("%w: two-factor authentication is already enabled", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totp.ProvisioningURI(s.policy.Issuer, u.Email, secret)}, nil
+}
+
+// ConfirmEnrollment 用验证器 App 上的验证码确认绑定，返回恢复码明文（只此一次）
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	t, err := s.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: start enrollment first", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled() {
+		return nil, fmt.Errorf("%w: two-factor authentication is already enabled", ErrInvalidInput)
+	}
+	step, ok := totp.Validate(t.Secret, code, time.Now(), 1)
+	if !ok {
+		return nil, fmt.Errorf("%w: verification code is incorrect", ErrInvalidInput)
+	}
+	codes, hashes := newRecoveryCodes()
+	err = s.repo.EnableTOTP(ctx, userID, step, hashes, time.Now())
+	if errors.Is(err, repository.ErrInvalidState) || errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.aud.Record(ctx, audit2.AuditedTableUsers, userID, "mfa_enable", nil)
+	return codes, nil
+}
+
+// VerifyLogin 登录第二步：校验 TOTP 验证码或恢复码
+// 错误时计入第二步失败次数，返回 ErrInvalidCredentials；达到上限锁定账号并返回 ErrAccountLocked
+func (s *MFAService) VerifyLogin(ctx context.Context, userID uint, code string) error {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if u.IsLocked(now) {
+		return fmt.Errorf("%w until %s", ErrAccountLocked, u.LockedUntil.Format(time.RFC3339))
+	}
+	t, err := s.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+	if !t.Enabled() {
+		return ErrInvalidCredentials
+	}
+
+	ok, err := s.checkCode(ctx, t, code, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		until, err := s.repo.RecordFailure(ctx, userID, s.lockout.MaxAttempts, s.lockout.Duration, now)
+		if err != nil {
+			return err
+		}
+		if until != nil {
+			s.aud.Record(ctx, audit2.AuditedTableUsers, userID, "lock", map[string]interface{}{"lockedUntil": until})
+			return fmt.Errorf("%w until %s", ErrAccountLocked, until.Format(time.RFC3339))
+		}
+		return ErrInvalidCredentials
+	}
+	if t.FailedAttempts > 0 {
+		return s.repo.ResetFailures(ctx, userID)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 凭当前 TOTP 验证码重新生成恢复码，旧的全部作废
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	if err := s.verifyTOTP(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	s.aud.Record(ctx, audit2.AuditedTableUsers, userID, "mfa_recovery_regenerate", nil)
+	return codes, nil
+}
+
+// Disable 凭当前 TOTP 验证码关闭两步验证；策略要求必须启用的用户返回 ErrForbidden
+func (s *MFAService) Disable(ctx context.Context, u *rbac.User, code string) error {
+	if s.policy.Required(u) {
+		return fmt.Errorf("%w: two-factor authentication is mandatory for your role", ErrForbidden)
+	}
+	if err := s.verifyTOTP(ctx, u.ID, code); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteTOTP(ctx, u.ID); err != nil {
+		return err
+	}
+	s.aud.Record(ctx, audit2.AuditedTableUsers, u.ID, "mfa_disable", nil)
+	return nil
+}
+
+// verifyTOTP 已登录用户做敏感操作前的校验，只接受 TOTP 验证码；错误返回 ErrInvalidInput
+func (s *MFAService) verifyTOTP(ctx context.Context, userID uint, code string) error {
+	t, err := s.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !t.Enabled()) {
+		return fmt.Errorf("%w: two-factor authentication is not enabled", ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(t.Secret, code, time.Now(), 1)
+	if !ok {
+		return fmt.Errorf("%w: verification code is incorrect", ErrInvalidInput)
+	}
+	err = s.repo.AdvanceTOTPStep(ctx, userID, step)
+	if errors.Is(err, repository.ErrTokenReused) {
+		return fmt.Errorf("%w: verification code has already been used", ErrInvalidInput)
+	}
+	return err
+}
+
+// checkCode 6 位数字按 TOTP 校验，其余按恢复码校验
+func (s *MFAService) checkCode(ctx context.Context, t *rbac.UserTOTP, code string, now time.Time) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(t.Secret, code, now, 1)
+		if !ok {
+			return false, nil
+		}
+		err := s.repo.AdvanceTOTPStep(ctx, t.UserID, step)
+		if errors.Is(err, repository.ErrTokenReused) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err := s.repo.UseRecoveryCode(ctx, t.UserID, hashToken(normalizeRecoveryCode(code)), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	left, err := s.repo.RemainingRecoveryCodes(ctx, t.UserID)
+	if err == nil {
+		s.logger.Info("Recovery code used for login", zap.Uint("userID", t.UserID), zap.Int64("remaining", left))
+	}
+	s.aud.Record(ctx, audit2.AuditedTableUsers, t.UserID, "mfa_recovery_use", map[string]interface{}{"remaining": left})
+	return true, nil
+}
+
+// recoveryAlphabet 去掉易混淆字符（0/o、1/l/i）
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCodes 生成恢复码明文（xxxxx-xxxxx）及其哈希
+func newRecoveryCodes() (codes, hashes []string) {
+	codes = make([]string, recoveryCodeCount)
+	hashes = make([]string, recoveryCodeCount)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			panic(err)
+		}
+		var b strings.Builder
+		for j, v := range buf {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(recoveryAlphabet[int(v)%len(recoveryAlphabet)])
+		}
+		codes[i] = b.String()
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
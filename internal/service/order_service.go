@@ -0,0 +1,218 @@
+// internal/service/order_service.go
+package service
+
+import (
+	"context"
+	audit2 "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OrderService 订单：由报价单生成草稿、草稿期间编辑明细、按状态机推进状态（同步库存预留）
+type OrderService struct {
+	orders       *repository.OrderRepository
+	quotes       *repository.QuoteRepository
+	reservations *ReservationService
+	aud          audit.Recorder
+	logger       *zap.Logger
+}
+
+func NewOrderService(
+	orders *repository.OrderRepository,
+	quotes *repository.QuoteRepository,
+	reservations *ReservationService,
+	aud audit.Recorder,
+	logger *zap.Logger,
+) *OrderService {
+	return &OrderService{
+		orders:       orders,
+		quotes:       quotes,
+		reservations: reservations,
+		aud:          aud,
+		logger:       logger,
+	}
+}
+
+// List 分页列出订单
+func (s *OrderService) List(ctx context.Context, f repository.OrderFilter, offset, limit int) ([]sales.Order, int64, error) {
+	if f.Status != "" && !sales.IsOrderStatus(f.Status) {
+		return nil, 0, fmt.Errorf("%w: unknown order status %s", ErrInvalidInput, f.Status)
+	}
+	return s.orders.List(ctx, f, offset, limit)
+}
+
+// Get 读取订单及明细
+func (s *OrderService) Get(ctx context.Context, id uint) (*sales.Order, error) {
+	return s.orders.FindByID(ctx, id)
+}
+
+// CreateFromQuote 由已审批的报价单生成草稿订单
+// 只复制关联了产品的报价行，单价取折后不含税单价；订单总额按明细加 GST 重算
+func (s *OrderService) CreateFromQuote(ctx context.Context, quoteID uint, req dto.CreateOrderFromQuoteRequest, userID uint) (*sales.Order, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	q, err := s.quotes.FindByID(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return nil, repository.ErrNotFound
+	}
+	if !q.IsCurrent() {
+		return nil, fmt.Errorf("%w: quote %s has been superseded by a newer revision", repository.ErrInvalidState, q.QuoteNumber)
+	}
+	if q.Status != sales.QuoteStatusApproved {
+		return nil, fmt.Errorf("%w: quote %s is %s, only approved quotes can be converted", repository.ErrInvalidState, q.QuoteNumber, q.Status)
+	}
+
+	orderDate := time.Now()
+	if req.OrderDate != "" {
+		if orderDate, err = time.Parse("2006-01-02", req.OrderDate); err != nil {
+			return nil, fmt.Errorf("%w: invalid orderDate, expected YYYY-MM-DD", ErrInvalidInput)
+		}
+	}
+	shipTo := req.ShippingAddress
+	if shipTo == "" {
+		shipTo = q.Customer.Address
+	}
+	if shipTo == "" {
+		return nil, fmt.Errorf("%w: shippingAddress is required", ErrInvalidInput)
+	}
+
+	qid := q.ID
+	o := &sales.Order{
+		QuoteID:         &qid,
+		StoreID:         q.StoreID,
+		CustomerID:      q.CustomerID,
+		OrderDate:       orderDate,
+		Currency:        q.Currency,
+		ShippingAddress: shipTo,
+		Location:        q.Store.Address,
+		CreatedBy:       userID,
+		SalesRepID:      q.SalesRepID,
+	}
+	var net float64
+	for _, qi := range q.Items {
+		if qi.ProductID == nil || qi.Quantity <= 0 {
+			continue
+		}
+		unit := sales.RoundMoney(qi.TotalPrice / float64(qi.Quantity))
+		o.Items = append(o.Items, sales.OrderItem{ProductID: *qi.ProductID, Quantity: qi.Quantity, UnitPrice: unit})
+		net += unit * float64(qi.Quantity)
+	}
+	if len(o.Items) == 0 {
+		return nil, fmt.Errorf("%w: quote %s has no product lines", ErrInvalidInput, q.QuoteNumber)
+	}
+	net = sales.RoundMoney(net)
+	o.TotalAmount = net + sales.GSTOf(net)
+
+	if err := s.orders.CreateDraft(ctx, o); err != nil {
+		s.logger.Error("Failed to create order from quote", zap.Uint("quoteID", quoteID), zap.Error(err))
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+	s.record(ctx, audit2.AuditedTableOrders, o.ID, "create", map[string]interface{}{
+		"orderNumber": o.OrderNumber,
+		"quoteId":     q.ID,
+		"quoteNumber": q.QuoteNumber,
+		"totalAmount": o.TotalAmount,
+	})
+
+	s.logger.Info("Order created from quote",
+		zap.String("orderNumber", o.OrderNumber),
+		zap.String("quoteNumber", q.QuoteNumber),
+		zap.Uint("userID", userID))
+	return s.orders.FindByID(ctx, o.ID)
+}
+
+// UpdateDraft 修改草稿订单抬头
+func (s *OrderService) UpdateDraft(ctx context.Context, id uint, req dto.UpdateOrderRequest, userID uint) (*sales.Order, error) {
+	updates := map[string]interface{}{}
+	if req.ShippingAddress != nil {
+		if *req.ShippingAddress == "" {
+			return nil, fmt.Errorf("%w: shippingAddress must not be empty", ErrInvalidInput)
+		}
+		updates["shipping_address"] = *req.ShippingAddress
+	}
+	if req.OrderDate != nil {
+		d, err := time.Parse("2006-01-02", *req.OrderDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid orderDate, expected YYYY-MM-DD", ErrInvalidInput)
+		}
+		updates["order_date"] = d
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidInput)
+	}
+	if userID != 0 {
+		updates["updated_by"] = userID
+	}
+	if err := s.orders.UpdateDraftHeader(ctx, id, updates); err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+	s.record(ctx, audit2.AuditedTableOrders, id, "update", updates)
+	return s.orders.FindByID(ctx, id)
+}
+
+// AddItem 草稿订单新增明细
+func (s *OrderService) AddItem(ctx context.Context, orderID uint, req dto.OrderItemRequest, userID uint) (*sales.Order, error) {
+	if req.ProductID == 0 {
+		return nil, fmt.Errorf("%w: productId is required", ErrInvalidInput)
+	}
+	item := &sales.OrderItem{ProductID: req.ProductID, Quantity: req.Quantity, UnitPrice: sales.RoundMoney(req.UnitPrice)}
+	if err := s.orders.AddItem(ctx, orderID, item, userID); err != nil {
+		return nil, fmt.Errorf("failed to add order item: %w", err)
+	}
+	s.record(ctx, audit2.AuditedTableOrderItems, item.ID, "create", item)
+	return s.orders.FindByID(ctx, orderID)
+}
+
+// UpdateItem 草稿订单修改明细
+func (s *OrderService) UpdateItem(ctx context.Context, orderID, itemID uint, req dto.OrderItemRequest, userID uint) (*sales.Order, error) {
+	price := sales.RoundMoney(req.UnitPrice)
+	if err := s.orders.UpdateItem(ctx, orderID, itemID, req.Quantity, price, userID); err != nil {
+		return nil, fmt.Errorf("failed to update order item: %w", err)
+	}
+	s.record(ctx, audit2.AuditedTableOrderItems, itemID, "update", map[string]interface{}{
+		"orderId": orderID, "quantity": req.Quantity, "unitPrice": price,
+	})
+	return s.orders.FindByID(ctx, orderID)
+}
+
+// DeleteItem 草稿订单删除明细
+func (s *OrderService) DeleteItem(ctx context.Context, orderID, itemID uint, userID uint) (*sales.Order, error) {
+	if err := s.orders.DeleteItem(ctx, orderID, itemID, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete order item: %w", err)
+	}
+	s.record(ctx, audit2.AuditedTableOrderItems, itemID, "delete", map[string]uint{"orderId": orderID})
+	return s.orders.FindByID(ctx, orderID)
+}
+
+// Transition 手工推进订单状态，同步库存预留并写审计；定金 / 尾款状态只能通过登记收款进入
+func (s *OrderService) Transition(ctx context.Context, id uint, to, operator string, userID uint) (*repository.OrderTransition, error) {
+	if sales.IsPaymentStatus(to) {
+		return nil, fmt.Errorf("%w: order status %s is set by recording a payment", ErrInvalidInput, to)
+	}
+	t, err := s.reservations.ChangeOrderStatus(ctx, id, to, operator, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.From != t.To {
+		s.record(ctx, audit2.AuditedTableOrders, id, "status:"+t.To, t)
+	}
+	return t, nil
+}
+
+// record 写审计；审计失败不影响业务结果，只记日志
+func (s *OrderService) record(ctx context.Context, table audit2.AuditedTableEnum, id uint, op string, payload interface{}) {
+	if err := s.aud.Record(ctx, table, id, op, payload); err != nil {
+		s.logger.Warn("Failed to record audit",
+			zap.String("table", string(table)), zap.Uint("id", id), zap.String("op", op), zap.Error(err))
+	}
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/sales"
+)
+
+// 定金 / 尾款状态只能由登记收款推进，手工修改在碰数据库之前就被拒绝
+func TestTransitionRejectsPaymentStatuses(t *testing.T) {
+	s := &OrderService{}
+	for _, to := range []string{sales.OrderStatusDepositReceived, sales.OrderStatusFinalPaymentReceived} {
+		if _, err := s.Transition(context.Background(), 1, to, "alice", 1); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("manual %s: err = %v, want ErrInvalidInput", to, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// internal/service/outbox_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"djj-inventory-system/internal/model/outbox"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// OutboxService 领域事件投递情况和死信处理
+type OutboxService struct {
+	repo   *repository.OutboxRepository
+	logger *zap.Logger
+}
+
+func NewOutboxService(repo *repository.OutboxRepository, logger *zap.Logger) *OutboxService {
+	return &OutboxService{repo: repo, logger: logger}
+}
+
+// OutboxEventDetail 事件及其在各下游的投递情况
+type OutboxEventDetail struct {
+	Event      *outbox.Event     `json:"event"`
+	Deliveries []outbox.Delivery `json:"deliveries"`
+}
+
+// ListDeliveries 按条件分页查询投递记录；status 只能是 pending / delivered / dead
+func (s *OutboxService) ListDeliveries(ctx context.Context, f repository.DeliveryFilter, offset, limit int) ([]outbox.Delivery, int64, error) {
+	switch f.Status {
+	case "", outbox.DeliveryPending, outbox.DeliveryDelivered, outbox.DeliveryDead:
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown delivery status %s", ErrInvalidInput, f.Status)
+	}
+	return s.repo.ListDeliveries(ctx, f, offset, limit)
+}
+
+// Summary 各下游各状态的投递数
+func (s *OutboxService) Summary(ctx context.Context) ([]repository.DeliverySummary, error) {
+	return s.repo.Summary(ctx)
+}
+
+// GetEvent 读取事件和投递记录，不存在返回 repository.ErrNotFound
+func (s *OutboxService) GetEvent(ctx context.Context, id uint) (*OutboxEventDetail, error) {
+	e, list, err := s.repo.FindEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxEventDetail{Event: e, Deliveries: list}, nil
+}
+
+// Retry 重新投递一条 dead 记录，下一轮投递时生效
+func (s *OutboxService) Retry(ctx context.Context, deliveryID uint, operator string) (*outbox.Delivery, error) {
+	d, err := s.repo.RetryDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Outbox delivery requeued", zap.Uint("deliveryID", d.ID), zap.Uint("eventID", d.EventID),
+		zap.String("consumer", d.Consumer), zap.String("operator", operator))
+	return d, nil
+}
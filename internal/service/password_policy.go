@@ -0,0 +1,72 @@
+// internal/service/password_policy.go
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicy 密码复杂度要求，创建用户、修改密码、重置密码时统一校验
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// DefaultPasswordPolicy 未配置时的默认要求：至少 8 位，包含字母和数字
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8, RequireLower: true, RequireDigit: true}
+
+// Validate 检查密码是否满足策略；不允许包含用户名或邮箱前缀。不满足时返回 ErrInvalidInput
+func (p PasswordPolicy) Validate(password, username, email string) error {
+	var problems []string
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "a symbol")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: password must contain %s", ErrInvalidInput, strings.Join(problems, ", "))
+	}
+
+	lowered := strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, id := range []string{username, local} {
+		if len(id) >= 3 && strings.Contains(lowered, strings.ToLower(id)) {
+			return fmt.Errorf("%w: password must not contain the username or email", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// LockoutPolicy 连续登录失败 MaxAttempts 次后锁定 Duration；MaxAttempts 为 0 表示不锁定
+type LockoutPolicy struct {
+	MaxAttempts int
+	Duration    time.Duration
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireDigit: true}
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Secret123", true},
+		{"Sec12", false},      // 太短
+		{"secret123", false},  // 缺大写
+		{"SecretPass", false}, // 缺数字
+		{"Alice2024x", false}, // 包含用户名
+		{"Wangli999", false},  // 包含邮箱前缀
+	}
+	for _, c := range cases {
+		err := p.Validate(c.password, "alice", "wangli@example.com")
+		if c.ok && err != nil {
+			t.Errorf("%q: unexpected error %v", c.password, err)
+		}
+		if !c.ok && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: want ErrInvalidInput, got %v", c.password, err)
+		}
+	}
+}
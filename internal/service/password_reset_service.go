@@ -0,0 +1,115 @@
+// internal/service/password_reset_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/mail"
+	"djj-inventory-system/internal/repository"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// PasswordResetService 找回密码：发送带一次性令牌的重置链接，凭令牌设置新密码
+// 重置成功后解除登录锁定，并注销该用户所有会话
+type PasswordResetService struct {
+	repo     *repository.PasswordResetRepository
+	users    repository.UserRepo
+	mailer   mail.Sender
+	sessions *SessionService
+	policy   PasswordPolicy
+	ttl      time.Duration
+	resetURL string
+	logger   *zap.Logger
+}
+
+func NewPasswordResetService(repo *repository.PasswordResetRepository, users repository.UserRepo, mailer mail.Sender,
+	sessions *SessionService, policy PasswordPolicy, ttl time.Duration, resetURL string, logger *zap.Logger) *PasswordResetService {
+	return &PasswordResetService{
+		repo:     repo,
+		users:    users,
+		mailer:   mailer,
+		sessions: sessions,
+		policy:   policy,
+		ttl:      ttl,
+		resetURL: resetURL,
+		logger:   logger,
+	}
+}
+
+// RequestReset 给邮箱对应的用户发送重置链接
+// 邮箱不存在时同样返回 nil，调用方无法据此判断账号是否存在
+func (s *PasswordResetService) RequestReset(ctx context.Context, email, ip string) error {
+	u, err := s.users.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Info("Password reset requested for unknown email", zap.String("ip", ip))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	raw := randomToken(32)
+	t := &rbac.PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.ttl),
+		IP:        ip,
+	}
+	if err := s.repo.Create(ctx, t); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%s，您好：\n\n我们收到了重置您账号密码的请求。请在 %d 分钟内打开以下链接设置新密码：\n\n%s?token=%s\n\n如果这不是您本人的操作，请忽略本邮件，您的密码不会改变。\n",
+		u.Username, int(s.ttl.Minutes()), s.resetURL, raw)
+	if err := s.mailer.Send(ctx, mail.Message{To: u.Email, Subject: "重置密码", Body: body}); err != nil {
+		s.logger.Error("Failed to send password reset mail", zap.Uint("userID", u.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ResetPassword 凭令牌设置新密码；令牌只能使用一次，无效或过期返回 ErrInvalidInput
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return fmt.Errorf("%w: reset token is required", ErrInvalidInput)
+	}
+	now := time.Now()
+	t, err := s.repo.FindValid(ctx, hashToken(token), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: reset link is invalid or has expired", ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	u, err := s.users.FindByID(ctx, t.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.policy.Validate(newPassword, u.Username, u.Email); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = s.repo.Consume(ctx, t.ID, string(hash), now)
+	if errors.Is(err, repository.ErrInvalidState) {
+		return fmt.Errorf("%w: reset link is invalid or has expired", ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 密码已改，旧会话全部作废；失败不影响重置结果
+	if _, err := s.sessions.LogoutAll(ctx, u.ID); err != nil {
+		s.logger.Error("Failed to revoke sessions after password reset", zap.Uint("userID", u.ID), zap.Error(err))
+	}
+	return nil
+}
@@ -17,12 +17,13 @@ type PermService interface {
 }
 
 type permService struct {
-	repo repository.PermRepo
-	aud  audit.Recorder
+	repo  repository.PermRepo
+	aud   audit.Recorder
+	perms PermissionInvalidator // 权限改名 / 删除后让权限缓存失效
 }
 
-func NewPermService(r repository.PermRepo, aud audit.Recorder) PermService {
-	return &permService{repo: r, aud: aud}
+func NewPermService(r repository.PermRepo, aud audit.Recorder, perms PermissionInvalidator) PermService {
+	return &permService{repo: r, aud: aud, perms: perms}
 }
 
 func (s *permService) Create(ctx context.Context, name string) (*rbac.Permission, error) {
@@ -53,6 +54,7 @@ func (s *permService) Update(ctx context.Context, id uint, name string) (*rbac.P
 	if err := s.repo.Update(p); err != nil {
 		return nil, err
 	}
+	s.perms.InvalidateAll()
 	s.aud.Record(ctx, audit2.AuditedTablePermissions, id, "update", before)
 	return p, nil
 }
@@ -66,6 +68,7 @@ func (s *permService) Delete(ctx context.Context, id uint) error {
 	if err := s.repo.Delete(id); err != nil {
 		return err
 	}
+	s.perms.InvalidateAll()
 	s.aud.Record(ctx, audit2.AuditedTablePermissions, id, "delete", before)
 	return nil
 }
@@ -0,0 +1,184 @@
+// internal/service/permission_cache.go
+package service
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/scope"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PermissionInvalidator 角色 / 权限变化时通知权限缓存失效
+type PermissionInvalidator interface {
+	InvalidateUser(userID uint)
+	InvalidateAll()
+}
+
+// CachedUserService 在 UserService 外包一层权限缓存：
+//   - GetWithAllPermissions 结果按用户缓存 ttl，供权限中间件每个请求查询
+//   - 分配 / 移除角色、授予 / 撤销直接权限、删除用户时清掉该用户的缓存
+//   - 角色、权限本身被修改 / 删除时由 RoleService、PermService 调 InvalidateAll
+//
+// ttl 只是兜底（例如直接改库），正常的权限变更立即生效，不必等 token 过期
+type CachedUserService struct {
+	UserService
+	ttl time.Duration
+
+	mu      sync.Mutex
+	gen     uint64 // 每次失效 +1，防止失效前发起的查询把旧结果写回缓存
+	entries map[uint]permissionEntry
+	scopes  map[uint]scope.Scope
+}
+
+type permissionEntry struct {
+	user     *rbac.User
+	loadedAt time.Time
+}
+
+func NewCachedUserService(inner UserService, ttl time.Duration) *CachedUserService {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &CachedUserService{
+		UserService: inner,
+		ttl:         ttl,
+		entries:     make(map[uint]permissionEntry),
+		scopes:      make(map[uint]scope.Scope),
+	}
+}
+
+// GetWithAllPermissions 优先读缓存，未命中或过期时查库
+func (s *CachedUserService) GetWithAllPermissions(ctx context.Context, userID uint) (*rbac.User, error) {
+	s.mu.Lock()
+	e, ok := s.entries[userID]
+	gen := s.gen
+	s.mu.Unlock()
+	if ok && time.Since(e.loadedAt) < s.ttl {
+		return e.user, nil
+	}
+
+	u, err := s.UserService.GetWithAllPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.gen == gen {
+		s.entries[userID] = permissionEntry{user: u, loadedAt: time.Now()}
+	}
+	s.mu.Unlock()
+	return u, nil
+}
+
+// PermissionNames 用户合并后的全部权限名
+func (s *CachedUserService) PermissionNames(ctx context.Context, userID uint) ([]string, error) {
+	u, err := s.GetWithAllPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(u.Permissions))
+	for i, p := range u.Permissions {
+		names[i] = p.Name
+	}
+	return names, nil
+}
+
+// Roles 用户的角色名，和权限取自同一条缓存
+func (s *CachedUserService) Roles(ctx context.Context, userID uint) ([]string, error) {
+	u, err := s.GetWithAllPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(u.Roles))
+	for i, r := range u.Roles {
+		names[i] = r.Name
+	}
+	return names, nil
+}
+
+// Scope 用户的数据范围：admin 看全部，*_leader 看本地区，其他人只看本门店
+// 与权限一起缓存、一起失效（换角色会改变范围）
+func (s *CachedUserService) Scope(ctx context.Context, userID uint) (scope.Scope, error) {
+	u, err := s.GetWithAllPermissions(ctx, userID)
+	if err != nil {
+		return scope.Scope{}, err
+	}
+	s.mu.Lock()
+	sc, ok := s.scopes[userID]
+	gen := s.gen
+	s.mu.Unlock()
+	if ok {
+		return sc, nil
+	}
+
+	sc = scope.Scope{Level: scopeLevel(u.Roles), StoreID: u.StoreID}
+	if sc.Level == scope.LevelRegion {
+		_, sd, err := s.UserService.GetProfile(ctx, userID)
+		if err != nil {
+			return scope.Scope{}, err
+		}
+		sc.RegionID = sd.Store.RegionID
+	}
+	s.mu.Lock()
+	if s.gen == gen {
+		s.scopes[userID] = sc
+	}
+	s.mu.Unlock()
+	return sc, nil
+}
+
+// scopeLevel 按角色取最大的数据范围
+func scopeLevel(roles []rbac.Role) scope.Level {
+	level := scope.LevelStore
+	for _, r := range roles {
+		switch {
+		case r.Name == "admin":
+			return scope.LevelAll
+		case strings.HasSuffix(r.Name, "_leader"):
+			level = scope.LevelRegion
+		}
+	}
+	return level
+}
+
+func (s *CachedUserService) InvalidateUser(userID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	delete(s.entries, userID)
+	delete(s.scopes, userID)
+}
+
+func (s *CachedUserService) InvalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	s.entries = make(map[uint]permissionEntry)
+	s.scopes = make(map[uint]scope.Scope)
+}
+
+func (s *CachedUserService) Delete(ctx context.Context, id uint) error {
+	defer s.InvalidateUser(id)
+	return s.UserService.Delete(ctx, id)
+}
+
+func (s *CachedUserService) AssignRole(ctx context.Context, userID, roleID uint) error {
+	defer s.InvalidateUser(userID)
+	return s.UserService.AssignRole(ctx, userID, roleID)
+}
+
+func (s *CachedUserService) RemoveRole(ctx context.Context, userID, roleID uint) error {
+	defer s.InvalidateUser(userID)
+	return s.UserService.RemoveRole(ctx, userID, roleID)
+}
+
+func (s *CachedUserService) GrantUserPermissions(ctx context.Context, userID uint, permIDs []uint) error {
+	defer s.InvalidateUser(userID)
+	return s.UserService.GrantUserPermissions(ctx, userID, permIDs)
+}
+
+func (s *CachedUserService) RevokeUserPermissions(ctx context.Context, userID uint, permIDs []uint) error {
+	defer s.InvalidateUser(userID)
+	return s.UserService.RevokeUserPermissions(ctx, userID, permIDs)
+}
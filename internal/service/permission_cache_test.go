@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"djj-inventory-system/internal/model/rbac"
+)
+
+// fakeUsers 只实现权限缓存用到的方法，每次查询返回当前的 perms
+type fakeUsers struct {
+	UserService
+	perms []string
+	loads int
+}
+
+func (f *fakeUsers) GetWithAllPermissions(ctx context.Context, userID uint) (*rbac.User, error) {
+	f.loads++
+	u := &rbac.User{ID: userID}
+	for _, p := range f.perms {
+		u.Permissions = append(u.Permissions, rbac.Permission{Name: p})
+	}
+	return u, nil
+}
+
+func (f *fakeUsers) RevokeUserPermissions(ctx context.Context, userID uint, permIDs []uint) error {
+	f.perms = nil
+	return nil
+}
+
+func TestCachedUserServiceInvalidatesOnRevoke(t *testing.T) {
+	inner := &fakeUsers{perms: []string{"inventory.out"}}
+	svc := NewCachedUserService(inner, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if perms, _ := svc.PermissionNames(ctx, 1); len(perms) != 1 {
+			t.Fatalf("expected cached permission, got %v", perms)
+		}
+	}
+	if inner.loads != 1 {
+		t.Fatalf("expected 1 load, got %d", inner.loads)
+	}
+
+	if err := svc.RevokeUserPermissions(ctx, 1, []uint{103}); err != nil {
+		t.Fatal(err)
+	}
+	if perms, _ := svc.PermissionNames(ctx, 1); len(perms) != 0 {
+		t.Fatalf("revoked permission still cached: %v", perms)
+	}
+
+	inner.perms = []string{"inventory.view"}
+	svc.InvalidateAll()
+	if perms, _ := svc.PermissionNames(ctx, 1); len(perms) != 1 || perms[0] != "inventory.view" {
+		t.Fatalf("expected reload after InvalidateAll, got %v", perms)
+	}
+}
@@ -0,0 +1,153 @@
+// internal/service/picking_service.go
+package service
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// PickingService 拣货：由订单生成拣货单 → 逐行确认实拣 → 出库；短拣生成缺货单，到货后重新分配
+type PickingService struct {
+	repo   *repository.PickingRepository
+	logger *zap.Logger
+}
+
+func NewPickingService(repo *repository.PickingRepository, logger *zap.Logger) *PickingService {
+	return &PickingService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// PickingResult 拣货操作的结果：当前拣货单，以及因此产生的缺货单（没有时为 nil）
+type PickingResult struct {
+	PickingList *sales.PickingList `json:"pickingList"`
+	Backorder   *sales.PickingList `json:"backorder,omitempty"`
+}
+
+// Get 读取拣货单
+func (s *PickingService) Get(ctx context.Context, id uint) (*sales.PickingList, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// List 分页列出拣货单
+func (s *PickingService) List(ctx context.Context, f repository.PickingFilter, offset, limit int) ([]sales.PickingList, int64, error) {
+	return s.repo.List(ctx, f, offset, limit)
+}
+
+// Generate 为订单生成拣货单；库存不足的数量直接生成缺货单
+func (s *PickingService) Generate(ctx context.Context, orderID, userID uint, operator string) ([]sales.PickingList, error) {
+	if operator == "" || userID == 0 {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	ids, err := s.repo.Generate(ctx, orderID, userID, operator)
+	if err != nil {
+		s.logger.Error("Failed to generate picking list", zap.Uint("orderID", orderID), zap.Error(err))
+		return nil, fmt.Errorf("failed to generate picking list: %w", err)
+	}
+
+	out := make([]sales.PickingList, 0, len(ids))
+	for _, id := range ids {
+		p, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *p)
+		s.logger.Info("Picking list generated",
+			zap.String("pickingNumber", p.PickingNumber),
+			zap.String("status", p.Status),
+			zap.Uint("orderID", orderID),
+			zap.Int("lines", len(p.Items)),
+			zap.String("operator", operator))
+	}
+	return out, nil
+}
+
+// Allocate 为缺货单重新分配库存
+func (s *PickingService) Allocate(ctx context.Context, id, userID uint, operator string) (*PickingResult, error) {
+	if operator == "" || userID == 0 {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	remainder, err := s.repo.Allocate(ctx, id, userID, operator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate backorder: %w", err)
+	}
+	s.logger.Info("Backorder allocated", zap.Uint("pickingListID", id), zap.Bool("stillShort", remainder != nil))
+	return s.result(ctx, id, remainder)
+}
+
+// ConfirmPicks 确认实拣数量并出库
+func (s *PickingService) ConfirmPicks(ctx context.Context, id uint, req dto.ConfirmPicksRequest, userID uint, operator string) (*PickingResult, error) {
+	if operator == "" || userID == 0 {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	p, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[uint]sales.PickingListItem, len(p.Items))
+	for _, ln := range p.Items {
+		lines[ln.ID] = ln
+	}
+
+	seen := make(map[uint]bool, len(req.Lines))
+	picks := make([]repository.PickConfirmation, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		ln, ok := lines[l.LineID]
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d is not on picking list %s", ErrInvalidInput, l.LineID, p.PickingNumber)
+		}
+		if seen[l.LineID] {
+			return nil, fmt.Errorf("%w: line %d is listed more than once", ErrInvalidInput, l.LineID)
+		}
+		seen[l.LineID] = true
+		if *l.PickedQty > ln.Quantity {
+			return nil, fmt.Errorf("%w: line %d: picked %d exceeds requested %d", ErrInvalidInput, l.LineID, *l.PickedQty, ln.Quantity)
+		}
+		picks = append(picks, repository.PickConfirmation{LineID: l.LineID, PickedQty: *l.PickedQty})
+	}
+
+	backorder, err := s.repo.ConfirmPicks(ctx, id, picks, userID, operator)
+	if err != nil {
+		s.logger.Error("Failed to confirm picks", zap.Uint("pickingListID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to confirm picks: %w", err)
+	}
+	s.logger.Info("Picks confirmed",
+		zap.String("pickingNumber", p.PickingNumber),
+		zap.Int("lines", len(picks)),
+		zap.Bool("backorder", backorder != nil),
+		zap.String("operator", operator))
+	return s.result(ctx, id, backorder)
+}
+
+// Cancel 取消尚未开始拣货的拣货单 / 缺货单
+func (s *PickingService) Cancel(ctx context.Context, id, userID uint) error {
+	if err := s.repo.Cancel(ctx, id, userID); err != nil {
+		return fmt.Errorf("failed to cancel picking list: %w", err)
+	}
+	return nil
+}
+
+// SetBinLocation 设置库位，之后生成的拣货单按新库位指引
+func (s *PickingService) SetBinLocation(ctx context.Context, req dto.SetBinLocationRequest) error {
+	return s.repo.SetBinLocation(ctx, req.ProductID, req.WarehouseID, req.BinLocation)
+}
+
+func (s *PickingService) result(ctx context.Context, id uint, backorderID *uint) (*PickingResult, error) {
+	p, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := &PickingResult{PickingList: p}
+	if backorderID != nil {
+		if out.Backorder, err = s.repo.FindByID(ctx, *backorderID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
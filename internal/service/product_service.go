@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"djj-inventory-system/internal/model/catalog"
@@ -13,15 +15,13 @@ import (
 )
 
 type ProductService struct {
-	ProdRepo  *repository.ProductRepository
-	StockRepo *repository.StockRepository
+	ProdRepo *repository.ProductRepository
 }
 
 func NewProductService(
 	pr *repository.ProductRepository,
-	sr *repository.StockRepository,
 ) *ProductService {
-	return &ProductService{ProdRepo: pr, StockRepo: sr}
+	return &ProductService{ProdRepo: pr}
 }
 
 // Create 新建产品
@@ -65,19 +65,15 @@ func (s *ProductService) Create(ctx context.Context, req dto.CreateProductReques
 			CreatedAt: time.Now(),
 		})
 	}
-	// 写库
-	if err := s.ProdRepo.Create(ctx, p); err != nil {
-		return nil, err
-	}
-	// 同步库存
-	if err := s.syncStocks(ctx, p.ID, req.Stocks); err != nil {
+	// 写库，库存和 product.created 事件在同一个事务里
+	if err := s.ProdRepo.Create(ctx, p, toStocks(req.Stocks), productSnapshot); err != nil {
 		return nil, err
 	}
 	// 返回 DTO
 	return s.toDTO(ctx, p.ID)
 }
 
-// Update 修改产品
+// Update 修改产品；各仓现有量只能通过库存调整等单据修改，表单里的现有量和库中不一致时返回 ErrInvalidInput
 func (s *ProductService) Update(ctx context.Context, id uint, req dto.UpdateProductRequest) (*dto.ProductResponse, error) {
 	p, err := s.ProdRepo.FindByID(ctx, id)
 	if err != nil {
@@ -113,10 +109,11 @@ func (s *ProductService) Update(ctx context.Context, id uint, req dto.UpdateProd
 	p.TechnicalSpecs = datatypes.JSON(req.TechnicalSpecs)
 	p.ExtraInfo = datatypes.JSON(req.OtherInfo)
 
-	if err := s.ProdRepo.Update(ctx, p); err != nil {
-		return nil, err
+	err = s.ProdRepo.Update(ctx, p, toStocks(req.Stocks), productSnapshot)
+	if errors.Is(err, repository.ErrInvalidState) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
 	}
-	if err := s.syncStocks(ctx, p.ID, req.Stocks); err != nil {
+	if err != nil {
 		return nil, err
 	}
 	return s.toDTO(ctx, p.ID)
@@ -145,23 +142,22 @@ func (s *ProductService) List(ctx context.Context, offset, limit int) ([]dto.Pro
 	return out, total, nil
 }
 
-// syncStocks 先删后增
-func (s *ProductService) syncStocks(ctx context.Context, pid uint, stocks []dto.StockEntry) error {
-	if err := s.StockRepo.DeleteByProduct(ctx, pid); err != nil {
-		return err
-	}
-	for _, e := range stocks {
-		ps := &catalog.ProductStock{
-			ProductID:   pid,
+// toStocks 请求里的各仓库存
+func toStocks(entries []dto.StockEntry) []catalog.ProductStock {
+	stocks := make([]catalog.ProductStock, len(entries))
+	for i, e := range entries {
+		stocks[i] = catalog.ProductStock{
 			WarehouseID: e.WarehouseID,
 			OnHand:      e.OnHand,
 			UpdatedAt:   time.Now(),
 		}
-		if err := s.StockRepo.Create(ctx, ps); err != nil {
-			return err
-		}
 	}
-	return nil
+	return stocks
+}
+
+// productSnapshot 商品事件的负载和接口返回一致
+func productSnapshot(p *catalog.Product) interface{} {
+	return mapProductToResponse(p)
 }
 
 // toDTO 读取并转换
@@ -0,0 +1,219 @@
+// internal/service/quote_service.go
+package service
+
+import (
+	"context"
+	audit2 "djj-inventory-system/internal/model/audit"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/pkg/audit"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// QuoteService 报价：金额服务端计算，每次修改生成新版本（A、B…），审批后可转订单
+type QuoteService struct {
+	quotes *repository.QuoteRepository
+	orders *OrderService
+	aud    audit.Recorder
+	logger *zap.Logger
+}
+
+func NewQuoteService(quotes *repository.QuoteRepository, orders *OrderService, aud audit.Recorder, logger *zap.Logger) *QuoteService {
+	return &QuoteService{
+		quotes: quotes,
+		orders: orders,
+		aud:    aud,
+		logger: logger,
+	}
+}
+
+// List 分页列出报价单
+func (s *QuoteService) List(ctx context.Context, f repository.QuoteFilter, offset, limit int) ([]sales.Quote, int64, error) {
+	return s.quotes.List(ctx, f, offset, limit)
+}
+
+// Get 读取报价单及明细
+func (s *QuoteService) Get(ctx context.Context, id uint) (*sales.Quote, error) {
+	q, err := s.quotes.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return nil, repository.ErrNotFound
+	}
+	return q, nil
+}
+
+// Revisions 列出同一报价的全部版本
+func (s *QuoteService) Revisions(ctx context.Context, id uint) ([]sales.Quote, error) {
+	q, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.quotes.Revisions(ctx, q.BaseNumber)
+}
+
+// Create 新建报价（版本 A，待审批）
+func (s *QuoteService) Create(ctx context.Context, req dto.CreateQuoteRequest, userID uint) (*sales.Quote, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	q, err := buildQuote(req.QuoteContentRequest, userID)
+	if err != nil {
+		return nil, err
+	}
+	q.StoreID = req.StoreID
+	q.CustomerID = req.CustomerID
+
+	if err := s.quotes.Create(ctx, q); err != nil {
+		s.logger.Error("Failed to create quote", zap.Uint("customerID", req.CustomerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to create quote: %w", err)
+	}
+	s.record(ctx, q.ID, "create", map[string]interface{}{
+		"quoteNumber": q.QuoteNumber,
+		"totalAmount": q.TotalAmount,
+	})
+
+	s.logger.Info("Quote created",
+		zap.String("quoteNumber", q.QuoteNumber),
+		zap.Float64("totalAmount", q.TotalAmount),
+		zap.Uint("userID", userID))
+	return s.Get(ctx, q.ID)
+}
+
+// Revise 以新内容生成下一个版本，旧版本保持原样只读
+func (s *QuoteService) Revise(ctx context.Context, id uint, req dto.QuoteContentRequest, userID uint) (*sales.Quote, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	next, err := buildQuote(req, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.quotes.CreateRevision(ctx, id, next); err != nil {
+		return nil, fmt.Errorf("failed to revise quote: %w", err)
+	}
+	s.record(ctx, next.ID, "revise", map[string]interface{}{
+		"quoteNumber": next.QuoteNumber,
+		"previousId":  id,
+		"totalAmount": next.TotalAmount,
+	})
+
+	s.logger.Info("Quote revised",
+		zap.String("quoteNumber", next.QuoteNumber),
+		zap.Uint("previousID", id),
+		zap.Uint("userID", userID))
+	return s.Get(ctx, next.ID)
+}
+
+// Approve 审批通过
+func (s *QuoteService) Approve(ctx context.Context, id uint, note string, reviewer uint) (*sales.Quote, error) {
+	return s.review(ctx, id, sales.QuoteStatusApproved, note, reviewer)
+}
+
+// Reject 拒绝
+func (s *QuoteService) Reject(ctx context.Context, id uint, note string, reviewer uint) (*sales.Quote, error) {
+	return s.review(ctx, id, sales.QuoteStatusRejected, note, reviewer)
+}
+
+// Convert 把已审批的最新版本转为草稿订单，订单通过 QuoteID 关联回报价
+func (s *QuoteService) Convert(ctx context.Context, id uint, req dto.CreateOrderFromQuoteRequest, userID uint) (*sales.Order, error) {
+	return s.orders.CreateFromQuote(ctx, id, req, userID)
+}
+
+// review 审批 / 拒绝，不能审核自己创建的报价
+func (s *QuoteService) review(ctx context.Context, id uint, status, note string, reviewer uint) (*sales.Quote, error) {
+	if reviewer == 0 {
+		return nil, fmt.Errorf("%w: reviewer is required", ErrInvalidInput)
+	}
+	q, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if q.CreatedBy == reviewer {
+		return nil, fmt.Errorf("%w: cannot review your own quote", ErrForbidden)
+	}
+	if err := s.quotes.Review(ctx, id, status, reviewer, note); err != nil {
+		return nil, fmt.Errorf("failed to review quote: %w", err)
+	}
+	s.record(ctx, id, status, map[string]interface{}{
+		"quoteNumber": q.QuoteNumber,
+		"note":        note,
+	})
+
+	s.logger.Info("Quote reviewed",
+		zap.String("quoteNumber", q.QuoteNumber),
+		zap.String("status", status),
+		zap.Uint("reviewer", reviewer))
+	return s.Get(ctx, id)
+}
+
+// record 写审计；审计失败不影响业务结果，只记日志
+func (s *QuoteService) record(ctx context.Context, id uint, op string, payload interface{}) {
+	if err := s.aud.Record(ctx, audit2.AuditedTableQuotes, id, op, payload); err != nil {
+		s.logger.Warn("Failed to record audit", zap.Uint("quoteID", id), zap.String("op", op), zap.Error(err))
+	}
+}
+
+// buildQuote 校验明细并计算金额：行金额 = 数量 × 单价 − 折扣，小计为各行之和，GST 按小计计算
+func buildQuote(req dto.QuoteContentRequest, userID uint) (*sales.Quote, error) {
+	quoteDate := time.Now()
+	if req.QuoteDate != "" {
+		var err error
+		if quoteDate, err = time.Parse("2006-01-02", req.QuoteDate); err != nil {
+			return nil, fmt.Errorf("%w: invalid quoteDate, expected YYYY-MM-DD", ErrInvalidInput)
+		}
+	}
+	q := &sales.Quote{
+		SalesRepID:    req.SalesRepID,
+		QuoteDate:     quoteDate,
+		Currency:      req.Currency,
+		Remarks:       req.Remarks,
+		WarrantyNotes: req.WarrantyNotes,
+		CreatedBy:     userID,
+	}
+	if q.SalesRepID == 0 {
+		q.SalesRepID = userID
+	}
+	if q.Currency == "" {
+		q.Currency = "AUD"
+	}
+
+	var sub float64
+	for i, it := range req.Items {
+		gross := sales.RoundMoney(float64(it.Quantity) * it.UnitPrice)
+		discount := sales.RoundMoney(it.Discount)
+		if discount > gross {
+			return nil, fmt.Errorf("%w: item %d: discount %.2f exceeds line amount %.2f", ErrInvalidInput, i+1, discount, gross)
+		}
+		unit := it.Unit
+		if unit == "" {
+			unit = "ea"
+		}
+		nature := it.GoodsNature
+		if nature == "" {
+			nature = "contract"
+		}
+		line := sales.QuoteItem{
+			ProductID:         it.ProductID,
+			Description:       it.Description,
+			DetailDescription: it.DetailDescription,
+			Quantity:          it.Quantity,
+			Unit:              unit,
+			UnitPrice:         sales.RoundMoney(it.UnitPrice),
+			Discount:          discount,
+			TotalPrice:        gross - discount,
+			GoodsNature:       nature,
+		}
+		sub += line.TotalPrice
+		q.Items = append(q.Items, line)
+	}
+	q.SubTotal = sales.RoundMoney(sub)
+	q.GSTTotal = sales.GSTOf(q.SubTotal)
+	q.TotalAmount = q.SubTotal + q.GSTTotal
+	return q, nil
+}
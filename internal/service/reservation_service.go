@@ -0,0 +1,102 @@
+// internal/service/reservation_service.go
+package service
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/model/sales"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sweeperOperator 过期清理任务写流水时使用的操作人
+const sweeperOperator = "system:reservation-sweeper"
+
+// ReservationService 订单驱动的库存预留：下单预留、发货转销售、取消释放，并定期清理过期预留
+type ReservationService struct {
+	repo   *repository.ReservationRepository
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+// NewReservationService ttl 为新预留的有效期，<= 0 表示预留不过期
+func NewReservationService(repo *repository.ReservationRepository, ttl time.Duration, logger *zap.Logger) *ReservationService {
+	return &ReservationService{
+		repo:   repo,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// ChangeOrderStatus 修改订单状态并同步预留，状态变更与库存变动在同一个事务里
+func (s *ReservationService) ChangeOrderStatus(ctx context.Context, orderID uint, status, operator string, operatorID uint) (*repository.OrderTransition, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if !sales.IsOrderStatus(status) {
+		return nil, fmt.Errorf("%w: unknown order status %s", ErrInvalidInput, status)
+	}
+	t, err := s.repo.TransitionOrder(ctx, orderID, status, operator, operatorID, s.ttl)
+	if err != nil {
+		s.logger.Error("Failed to change order status",
+			zap.Uint("orderID", orderID), zap.String("to", status), zap.Error(err))
+		return nil, fmt.Errorf("failed to change order status: %w", err)
+	}
+
+	s.logger.Info("Order status changed",
+		zap.String("orderNumber", t.OrderNumber),
+		zap.String("from", t.From),
+		zap.String("to", t.To),
+		zap.Int("shortages", len(t.Shortages)),
+		zap.String("operator", operator))
+	return t, nil
+}
+
+// ListForOrder 列出订单的全部预留
+func (s *ReservationService) ListForOrder(ctx context.Context, orderID uint) ([]inventory.StockReservation, error) {
+	return s.repo.ListByOrder(ctx, orderID)
+}
+
+// List 分页列出预留
+func (s *ReservationService) List(ctx context.Context, f repository.ReservationFilter, offset, limit int) ([]inventory.StockReservation, int64, error) {
+	return s.repo.List(ctx, f, offset, limit)
+}
+
+// SweepExpired 分批释放所有已过期的预留，返回释放条数
+func (s *ReservationService) SweepExpired(ctx context.Context) (int, error) {
+	const batch = 100
+	total := 0
+	for {
+		n, err := s.repo.ExpireDue(ctx, time.Now(), sweeperOperator, batch)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < batch {
+			break
+		}
+	}
+	if total > 0 {
+		s.logger.Info("Expired reservations released", zap.Int("count", total))
+	}
+	return total, nil
+}
+
+// RunSweeper 每隔 interval 清理一次过期预留，直到 ctx 取消
+func (s *ReservationService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.SweepExpired(ctx); err != nil {
+				s.logger.Error("Reservation sweep failed", zap.Error(err))
+			}
+		}
+	}
+}
@@ -23,12 +23,13 @@ type RoleService interface {
 }
 
 type roleService struct {
-	repo repository.RoleRepo
-	aud  audit.Recorder
+	repo  repository.RoleRepo
+	aud   audit.Recorder
+	perms PermissionInvalidator // 角色改名 / 删除后让权限缓存失效
 }
 
-func NewRoleService(r repository.RoleRepo, aud audit.Recorder) RoleService {
-	return &roleService{repo: r, aud: aud}
+func NewRoleService(r repository.RoleRepo, aud audit.Recorder, perms PermissionInvalidator) RoleService {
+	return &roleService{repo: r, aud: aud, perms: perms}
 }
 
 func (s *roleService) Create(ctx context.Context, name string) (*rbac.Role, error) {
@@ -70,6 +71,7 @@ func (s *roleService) Update(ctx context.Context, id uint, name string) (*rbac.R
 	if err := s.repo.Update(old); err != nil {
 		return nil, fmt.Errorf("update role %d: %w", id, err)
 	}
+	s.perms.InvalidateAll()
 
 	// 审计：写入更新前的快照
 	s.aud.Record(ctx, audit2.AuditedTableRoles, id, "update", string(before))
@@ -88,6 +90,7 @@ func (s *roleService) Delete(ctx context.Context, id uint) error {
 	if err := s.repo.Delete(id); err != nil {
 		return fmt.Errorf("delete role %d: %w", id, err)
 	}
+	s.perms.InvalidateAll()
 
 	// 审计：写入删除前的快照
 	s.aud.Record(ctx, audit2.AuditedTableRoles, id, "delete", string(before))
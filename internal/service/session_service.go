@@ -0,0 +1,342 @@
+// internal/service/session_service.go
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"djj-inventory-system/internal/model/catalog"
+	"djj-inventory-system/internal/model/rbac"
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/repository"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidToken 访问令牌 / 刷新令牌无效、过期或已被吊销
+var ErrInvalidToken = errors.New("invalid or revoked token")
+
+// SessionService 登录会话：短期访问令牌（JWT）+ 服务端保存、每次刷新都轮换的刷新令牌
+//   - 注销：吊销当前访问令牌并作废刷新令牌链路
+//   - 注销全部：作废用户所有刷新令牌及其访问令牌
+//   - 校验访问令牌时同时查吊销列表
+type SessionService struct {
+	repo       *repository.SessionRepository
+	users      UserService
+	keys       *auth.KeyRing
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	logger     *zap.Logger
+}
+
+func NewSessionService(repo *repository.SessionRepository, users UserService, keys *auth.KeyRing, accessTTL, refreshTTL time.Duration, logger *zap.Logger) *SessionService {
+	return &SessionService{
+		repo:       repo,
+		users:      users,
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		logger:     logger,
+	}
+}
+
+// TokenPair 一次签发的访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	AccessExpiresAt  time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// ClientInfo 登录设备信息，记在刷新令牌上便于排查
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// AccessClaims 校验通过的访问令牌里的关键字段
+type AccessClaims struct {
+	UserID    uint
+	JTI       string
+	ExpiresAt time.Time
+	Claims    jwt.MapClaims
+}
+
+// Principal 由访问令牌构造调用者；权限取签发时的快照，LoadPermissions 会按当前权限覆盖
+func (a *AccessClaims) Principal() *auth.Principal {
+	p := &auth.Principal{UserID: a.UserID}
+	p.Name, _ = a.Claims["name"].(string)
+	if roles, _ := a.Claims["role"].(string); roles != "" {
+		p.Roles = strings.Split(roles, ",")
+	}
+	if perms, ok := a.Claims["permissions"].([]interface{}); ok {
+		for _, v := range perms {
+			if s, ok := v.(string); ok {
+				p.Permissions = append(p.Permissions, s)
+			}
+		}
+	}
+	return p
+}
+
+// Login 为已通过密码校验的用户签发新会话
+func (s *SessionService) Login(ctx context.Context, u *rbac.User, sd *catalog.StoreDetails, client ClientInfo) (*TokenPair, error) {
+	now := time.Now()
+	raw, rt := s.newRefresh(client, now)
+	rt.UserID = u.ID
+	rt.FamilyID = randomToken(16)
+	if err := s.repo.CreateRefresh(ctx, rt); err != nil {
+		return nil, err
+	}
+	return s.pair(u, sd, raw, rt, now)
+}
+
+// Refresh 用刷新令牌换一对新令牌；旧刷新令牌立即失效，重放会作废整条链路
+// 同时返回最新的用户资料，权限变化随新访问令牌生效
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, *rbac.User, *catalog.StoreDetails, error) {
+	if refreshToken == "" {
+		return nil, nil, nil, ErrInvalidToken
+	}
+	now := time.Now()
+	raw, next := s.newRefresh(client, now)
+	old, err := s.repo.Rotate(ctx, hashToken(refreshToken), next, now)
+	if errors.Is(err, repository.ErrTokenReused) {
+		s.logger.Warn("Refresh token reuse detected, session family revoked")
+		return nil, nil, nil, ErrInvalidToken
+	}
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrInvalidState) {
+		return nil, nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	u, sd, err := s.users.GetProfile(ctx, old.UserID)
+	if err != nil {
+		// 用户已删除：刚发的令牌一并作废
+		if _, rerr := s.repo.RevokeFamily(ctx, next.TokenHash, now); rerr != nil {
+			s.logger.Error("Failed to revoke session of missing user", zap.Uint("userID", old.UserID), zap.Error(rerr))
+		}
+		return nil, nil, nil, ErrInvalidToken
+	}
+	pair, err := s.pair(u, sd, raw, next, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pair, u, sd, nil
+}
+
+// Verify 校验访问令牌签名、有效期和吊销列表
+func (s *SessionService) Verify(ctx context.Context, token string) (*AccessClaims, error) {
+	claims, err := s.keys.Parse(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	// 两步验证凭证等其他用途的令牌带 typ，不能当访问令牌用
+	if typ, _ := claims["typ"].(string); typ != "" {
+		return nil, ErrInvalidToken
+	}
+	return s.checkClaims(ctx, claims)
+}
+
+// mfaChallengeTTL 密码校验通过后，完成第二步验证（或绑定 TOTP）的时限
+const mfaChallengeTTL = 5 * time.Minute
+
+// MFAChallenge 密码已通过、等待第二步验证的登录凭证；Enroll 为 true 表示用户必须先绑定 TOTP
+type MFAChallenge struct {
+	AccessClaims
+	Enroll bool
+}
+
+// IssueChallenge 签发两步验证凭证，凭它才能调用登录第二步 / 登录时绑定接口
+func (s *SessionService) IssueChallenge(u *rbac.User, enroll bool) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(mfaChallengeTTL)
+	token, err := s.keys.Sign(jwt.MapClaims{
+		"sub":    u.ID,
+		"jti":    randomToken(16),
+		"typ":    "mfa",
+		"enroll": enroll,
+		"iat":    now.Unix(),
+		"exp":    exp.Unix(),
+	})
+	return token, exp, err
+}
+
+// VerifyChallenge 校验两步验证凭证
+func (s *SessionService) VerifyChallenge(ctx context.Context, token string) (*MFAChallenge, error) {
+	claims, err := s.keys.Parse(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if typ, _ := claims["typ"].(string); typ != "mfa" {
+		return nil, ErrInvalidToken
+	}
+	access, err := s.checkClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	enroll, _ := claims["enroll"].(bool)
+	return &MFAChallenge{AccessClaims: *access, Enroll: enroll}, nil
+}
+
+// ConsumeChallenge 第二步完成后作废凭证，不能再用来登录
+func (s *SessionService) ConsumeChallenge(ctx context.Context, ch *MFAChallenge) error {
+	return s.repo.RevokeAccess(ctx, ch.JTI, ch.UserID, ch.ExpiresAt)
+}
+
+// checkClaims 取出 sub / jti / exp 并查吊销列表
+func (s *SessionService) checkClaims(ctx context.Context, claims jwt.MapClaims) (*AccessClaims, error) {
+	sub, _ := claims["sub"].(float64)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if sub <= 0 || jti == "" {
+		return nil, ErrInvalidToken
+	}
+	revoked, err := s.repo.IsRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+	return &AccessClaims{UserID: uint(sub), JTI: jti, ExpiresAt: time.Unix(int64(exp), 0), Claims: claims}, nil
+}
+
+// Logout 注销当前会话；access / refreshToken 任一为空时只处理另一个
+func (s *SessionService) Logout(ctx context.Context, access *AccessClaims, refreshToken string) error {
+	now := time.Now()
+	if access != nil {
+		if err := s.repo.RevokeAccess(ctx, access.JTI, access.UserID, access.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	if refreshToken != "" {
+		if _, err := s.repo.RevokeFamily(ctx, hashToken(refreshToken), now); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogoutAll 注销用户在所有设备上的会话，返回作废的刷新令牌数
+func (s *SessionService) LogoutAll(ctx context.Context, userID uint) (int64, error) {
+	n, err := s.repo.RevokeUser(ctx, userID, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to revoke all sessions", zap.Uint("userID", userID), zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
+
+// RunSweeper 每隔 interval 清理一次过期的刷新令牌和吊销记录，直到 ctx 取消
+func (s *SessionService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.PurgeExpired(ctx, time.Now()); err != nil {
+				s.logger.Error("Session sweep failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// newRefresh 生成刷新令牌（尚未入库），同时确定配套访问令牌的 jti 和有效期
+func (s *SessionService) newRefresh(client ClientInfo, now time.Time) (string, *rbac.RefreshToken) {
+	raw := randomToken(32)
+	return raw, &rbac.RefreshToken{
+		TokenHash:       hashToken(raw),
+		AccessJTI:       randomToken(16),
+		AccessExpiresAt: now.Add(s.accessTTL),
+		UserAgent:       truncate(client.UserAgent, 255),
+		IP:              client.IP,
+		ExpiresAt:       now.Add(s.refreshTTL),
+	}
+}
+
+// pair 按刷新令牌上记录的 jti / 有效期签发访问令牌
+func (s *SessionService) pair(u *rbac.User, sd *catalog.StoreDetails, raw string, rt *rbac.RefreshToken, now time.Time) (*TokenPair, error) {
+	access, err := s.keys.Sign(accessClaims(u, sd, rt.AccessJTI, now, rt.AccessExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      access,
+		AccessExpiresAt:  rt.AccessExpiresAt,
+		RefreshToken:     raw,
+		RefreshExpiresAt: rt.ExpiresAt,
+	}, nil
+}
+
+// accessClaims 访问令牌内容：沿用原来的 sub/name/role/permissions/avatar_url/sd，加上 jti/iat/exp
+func accessClaims(u *rbac.User, sd *catalog.StoreDetails, jti string, now, exp time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":         u.ID,
+		"jti":         jti,
+		"iat":         now.Unix(),
+		"exp":         exp.Unix(),
+		"name":        u.Username,
+		"role":        RoleNames(u.Roles),
+		"permissions": FinalPermissions(u),
+		"avatar_url":  u.AvatarURL,
+		"sd":          sd,
+	}
+}
+
+// RoleNames 逗号拼接的角色名
+func RoleNames(roles []rbac.Role) string {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = r.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// FinalPermissions 角色权限与直接授予权限去重合并
+func FinalPermissions(user *rbac.User) []string {
+	set := make(map[string]struct{}, len(user.Permissions)+len(user.DirectPermissions))
+	for _, p := range user.Permissions {
+		set[p.Name] = struct{}{}
+	}
+	for _, p := range user.DirectPermissions {
+		set[p.Name] = struct{}{}
+	}
+	out := make([]string, 0, len(set))
+	for name := range set {
+		out = append(out, name)
+	}
+	return out
+}
+
+// randomToken n 字节随机数的 base64url 编码
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken 刷新令牌只存 SHA-256
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,274 @@
+// internal/service/stocktake_service.go
+package service
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+)
+
+// StocktakeService 盘点：冻结快照 → 录入 / 扫码计数 → 提交差异 → 主管审核 → 一次性过账
+type StocktakeService struct {
+	repo   *repository.StocktakeRepository
+	logger *zap.Logger
+}
+
+func NewStocktakeService(repo *repository.StocktakeRepository, logger *zap.Logger) *StocktakeService {
+	return &StocktakeService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Create 新建盘点单并冻结仓库快照
+func (s *StocktakeService) Create(ctx context.Context, req dto.CreateStocktakeRequest, operator string) (*inventory.Stocktake, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	st := &inventory.Stocktake{
+		WarehouseID: req.WarehouseID,
+		BlindCount:  req.BlindCount,
+		Note:        req.Note,
+		CreatedBy:   operator,
+	}
+	if err := s.repo.Create(ctx, st, req.ProductIDs); err != nil {
+		s.logger.Error("Failed to create stocktake", zap.Uint("warehouseID", req.WarehouseID), zap.Error(err))
+		return nil, fmt.Errorf("failed to create stocktake: %w", err)
+	}
+
+	s.logger.Info("Stocktake created",
+		zap.String("stocktakeNumber", st.StocktakeNumber),
+		zap.Uint("warehouseID", st.WarehouseID),
+		zap.Int("lines", len(st.Lines)),
+		zap.Bool("blindCount", st.BlindCount),
+		zap.String("operator", operator))
+	return s.repo.FindByID(ctx, st.ID)
+}
+
+// Get 读取盘点单
+func (s *StocktakeService) Get(ctx context.Context, id uint) (*inventory.Stocktake, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// List 分页列出盘点单
+func (s *StocktakeService) List(ctx context.Context, f repository.StocktakeFilter, offset, limit int) ([]inventory.Stocktake, int64, error) {
+	return s.repo.List(ctx, f, offset, limit)
+}
+
+// RecordCounts 录入计数，按 DJJ 编码扫码时先解析为产品 ID
+func (s *StocktakeService) RecordCounts(ctx context.Context, id uint, items []dto.StocktakeCountItem, operator string) (*inventory.Stocktake, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+
+	var codes []string
+	for _, it := range items {
+		if it.ProductID == 0 {
+			if it.DJJCode == "" {
+				return nil, fmt.Errorf("%w: productId or djjCode is required", ErrInvalidInput)
+			}
+			codes = append(codes, it.DJJCode)
+		}
+	}
+	ids, err := s.resolveCodes(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]repository.StocktakeCount, len(items))
+	for i, it := range items {
+		pid := it.ProductID
+		if pid == 0 {
+			pid = ids[it.DJJCode]
+		}
+		counts[i] = repository.StocktakeCount{ProductID: pid, Quantity: it.Quantity, Add: it.Mode == "add"}
+	}
+
+	if err := s.repo.RecordCounts(ctx, id, counts, operator); err != nil {
+		return nil, fmt.Errorf("failed to record counts: %w", err)
+	}
+	return s.repo.FindByID(ctx, id)
+}
+
+// ImportCounts 从 Excel 导入计数：第一个工作表，A 列 DJJ 编码，B 列实盘数量，首行为表头
+func (s *StocktakeService) ImportCounts(ctx context.Context, id uint, r io.Reader, operator string) (*inventory.Stocktake, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read excel file: %v", ErrInvalidInput, err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read rows: %v", ErrInvalidInput, err)
+	}
+
+	var items []dto.StocktakeCountItem
+	for i, row := range rows {
+		if i == 0 || len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+			continue // 跳过表头和空行
+		}
+		qty, err := strconv.Atoi(strings.TrimSpace(row[1]))
+		if err != nil || qty < 0 {
+			return nil, fmt.Errorf("%w: row %d: invalid quantity %q", ErrInvalidInput, i+1, row[1])
+		}
+		items = append(items, dto.StocktakeCountItem{DJJCode: strings.TrimSpace(row[0]), Quantity: qty})
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no counts found in file", ErrInvalidInput)
+	}
+	return s.RecordCounts(ctx, id, items, operator)
+}
+
+// Submit 结束计数并计算差异
+func (s *StocktakeService) Submit(ctx context.Context, id uint, uncountedAsZero bool, operator string) (*inventory.Stocktake, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if err := s.repo.Submit(ctx, id, uncountedAsZero, operator); err != nil {
+		return nil, fmt.Errorf("failed to submit stocktake: %w", err)
+	}
+	s.logger.Info("Stocktake submitted", zap.Uint("stocktakeID", id), zap.String("operator", operator))
+	return s.repo.FindByID(ctx, id)
+}
+
+// Review 审核差异行
+func (s *StocktakeService) Review(ctx context.Context, id uint, req dto.ReviewStocktakeRequest) (*inventory.Stocktake, error) {
+	var decisions []repository.StocktakeDecision
+	if req.ApproveAll {
+		st, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, ln := range st.Lines {
+			if ln.Variance != 0 {
+				decisions = append(decisions, repository.StocktakeDecision{LineID: ln.ID, Approved: true, Note: ln.ReviewNote})
+			}
+		}
+	}
+	for _, d := range req.Lines {
+		decisions = append(decisions, repository.StocktakeDecision{LineID: d.LineID, Approved: d.Approved, Note: d.Note})
+	}
+	if len(decisions) == 0 {
+		return nil, fmt.Errorf("%w: nothing to review", ErrInvalidInput)
+	}
+
+	if err := s.repo.Review(ctx, id, decisions); err != nil {
+		return nil, fmt.Errorf("failed to review stocktake: %w", err)
+	}
+	return s.repo.FindByID(ctx, id)
+}
+
+// Post 把已批准的差异一次性写成 ADJUST 流水
+func (s *StocktakeService) Post(ctx context.Context, id uint, operator string) (*inventory.Stocktake, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if err := s.repo.Post(ctx, id, operator); err != nil {
+		s.logger.Error("Failed to post stocktake", zap.Uint("stocktakeID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to post stocktake: %w", err)
+	}
+	s.logger.Info("Stocktake posted", zap.Uint("stocktakeID", id), zap.String("operator", operator))
+	return s.repo.FindByID(ctx, id)
+}
+
+// Cancel 取消未过账的盘点单
+func (s *StocktakeService) Cancel(ctx context.Context, id uint) error {
+	if err := s.repo.Cancel(ctx, id); err != nil {
+		return fmt.Errorf("failed to cancel stocktake: %w", err)
+	}
+	return nil
+}
+
+// VarianceReport 生成差异报表 xlsx，返回文件内容和建议文件名
+func (s *StocktakeService) VarianceReport(ctx context.Context, id uint) ([]byte, string, error) {
+	st, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if st.Status == inventory.StocktakeStatusCounting {
+		return nil, "", fmt.Errorf("%w: variances are only available after counts are submitted", repository.ErrInvalidState)
+	}
+	current, err := s.repo.CurrentOnHand(ctx, st.WarehouseID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Variance"
+	f.SetSheetName(f.GetSheetName(0), sheet)
+
+	f.SetCellValue(sheet, "A1", "盘点单号")
+	f.SetCellValue(sheet, "B1", st.StocktakeNumber)
+	f.SetCellValue(sheet, "A2", "仓库")
+	f.SetCellValue(sheet, "B2", st.Warehouse.Name)
+	f.SetCellValue(sheet, "A3", "快照时间")
+	f.SetCellValue(sheet, "B3", st.SnapshotAt.Format("2006-01-02 15:04:05"))
+	f.SetCellValue(sheet, "A4", "状态")
+	f.SetCellValue(sheet, "B4", string(st.Status))
+
+	header := []interface{}{"DJJ编码", "产品名称", "快照数量", "实盘数量", "差异", "当前现有量", "单价", "差异金额", "已批准", "审核备注"}
+	if err := f.SetSheetRow(sheet, "A6", &header); err != nil {
+		return nil, "", err
+	}
+
+	row := 7
+	var totalQty int
+	var totalValue float64
+	for _, ln := range st.Lines {
+		counted := 0
+		if ln.CountedQty != nil {
+			counted = *ln.CountedQty
+		}
+		value := float64(ln.Variance) * ln.Product.Price
+		totalQty += ln.Variance
+		totalValue += value
+		approved := "否"
+		if ln.Approved {
+			approved = "是"
+		}
+		cells := []interface{}{
+			ln.Product.DJJCode, ln.Product.NameCN, ln.SnapshotQty, counted, ln.Variance,
+			current[ln.ProductID], ln.Product.Price, value, approved, ln.ReviewNote,
+		}
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &cells); err != nil {
+			return nil, "", err
+		}
+		row++
+	}
+	f.SetCellValue(sheet, fmt.Sprintf("D%d", row), "合计")
+	f.SetCellValue(sheet, fmt.Sprintf("E%d", row), totalQty)
+	f.SetCellValue(sheet, fmt.Sprintf("H%d", row), totalValue)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), fmt.Sprintf("stocktake_%s_variance.xlsx", st.StocktakeNumber), nil
+}
+
+// resolveCodes 把 DJJ 编码解析为产品 ID，任何一个找不到都报错
+func (s *StocktakeService) resolveCodes(ctx context.Context, codes []string) (map[string]uint, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	ids, err := s.repo.ProductIDsByCode(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range codes {
+		if _, ok := ids[c]; !ok {
+			return nil, fmt.Errorf("%w: unknown product code %s", ErrInvalidInput, c)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,144 @@
+// internal/service/transfer_service.go
+package service
+
+import (
+	"context"
+	"djj-inventory-system/internal/model/dto"
+	"djj-inventory-system/internal/model/inventory"
+	"djj-inventory-system/internal/repository"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// TransferService 仓库间调拨：草稿 → 发货（在途）→ 部分 / 全部收货
+type TransferService struct {
+	repo   *repository.TransferRepository
+	logger *zap.Logger
+}
+
+func NewTransferService(repo *repository.TransferRepository, logger *zap.Logger) *TransferService {
+	return &TransferService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Create 新建调拨单，同一产品的多行会合并
+func (s *TransferService) Create(ctx context.Context, req dto.CreateTransferRequest, operator string) (*inventory.StockTransfer, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if req.FromWarehouseID == req.ToWarehouseID {
+		return nil, fmt.Errorf("%w: source and destination warehouse must be different", ErrInvalidInput)
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", ErrInvalidInput)
+	}
+
+	merged := make(map[uint]int, len(req.Items))
+	order := make([]uint, 0, len(req.Items))
+	for _, it := range req.Items {
+		if it.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: product %d: quantity must be positive", ErrInvalidInput, it.ProductID)
+		}
+		if _, ok := merged[it.ProductID]; !ok {
+			order = append(order, it.ProductID)
+		}
+		merged[it.ProductID] += it.Quantity
+	}
+
+	t := &inventory.StockTransfer{
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		Note:            req.Note,
+		CreatedBy:       operator,
+	}
+	for _, pid := range order {
+		t.Items = append(t.Items, inventory.StockTransferItem{
+			ProductID: pid,
+			Quantity:  merged[pid],
+		})
+	}
+
+	if err := s.repo.Create(ctx, t); err != nil {
+		s.logger.Error("Failed to create transfer", zap.Error(err))
+		return nil, fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	s.logger.Info("Transfer created",
+		zap.String("transferNumber", t.TransferNumber),
+		zap.Uint("fromWarehouseID", t.FromWarehouseID),
+		zap.Uint("toWarehouseID", t.ToWarehouseID),
+		zap.String("operator", operator))
+
+	return s.repo.FindByID(ctx, t.ID)
+}
+
+// Get 读取调拨单
+func (s *TransferService) Get(ctx context.Context, id uint) (*inventory.StockTransfer, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// List 分页列出调拨单
+func (s *TransferService) List(ctx context.Context, f repository.TransferFilter, offset, limit int) ([]inventory.StockTransfer, int64, error) {
+	return s.repo.List(ctx, f, offset, limit)
+}
+
+// Dispatch 发货，源仓库扣减现有量，货物进入在途
+func (s *TransferService) Dispatch(ctx context.Context, id uint, operator string) (*inventory.StockTransfer, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if err := s.repo.Dispatch(ctx, id, operator); err != nil {
+		s.logger.Error("Failed to dispatch transfer", zap.Uint("transferID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to dispatch transfer: %w", err)
+	}
+
+	s.logger.Info("Transfer dispatched", zap.Uint("transferID", id), zap.String("operator", operator))
+	return s.repo.FindByID(ctx, id)
+}
+
+// Receive 收货，目标仓库按实收数量增加现有量；Close 时短缺部分在源仓库报损
+func (s *TransferService) Receive(ctx context.Context, id uint, req dto.ReceiveTransferRequest, operator string) (*inventory.StockTransfer, error) {
+	if operator == "" {
+		return nil, fmt.Errorf("%w: operator is required", ErrInvalidInput)
+	}
+	if len(req.Items) == 0 && !req.Close {
+		return nil, fmt.Errorf("%w: nothing to receive", ErrInvalidInput)
+	}
+
+	receipts := make([]repository.TransferReceipt, len(req.Items))
+	for i, it := range req.Items {
+		receipts[i] = repository.TransferReceipt{
+			ItemID:          it.ItemID,
+			Quantity:        it.Quantity,
+			DiscrepancyNote: it.DiscrepancyNote,
+		}
+	}
+
+	if err := s.repo.Receive(ctx, id, receipts, req.Close, operator); err != nil {
+		s.logger.Error("Failed to receive transfer", zap.Uint("transferID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to receive transfer: %w", err)
+	}
+
+	s.logger.Info("Transfer received",
+		zap.Uint("transferID", id),
+		zap.Int("lines", len(receipts)),
+		zap.Bool("close", req.Close),
+		zap.String("operator", operator))
+	return s.repo.FindByID(ctx, id)
+}
+
+// Cancel 取消草稿调拨单
+func (s *TransferService) Cancel(ctx context.Context, id uint) error {
+	if err := s.repo.Cancel(ctx, id); err != nil {
+		return fmt.Errorf("failed to cancel transfer: %w", err)
+	}
+	return nil
+}
+
+// InTransit 查询发往某仓库的在途库存
+func (s *TransferService) InTransit(ctx context.Context, warehouseID uint) ([]repository.InTransitStock, error) {
+	return s.repo.InTransitByWarehouse(ctx, warehouseID)
+}
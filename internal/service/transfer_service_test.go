@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"djj-inventory-system/internal/model/dto"
+
+	"go.uber.org/zap"
+)
+
+// 请求校验失败返回 ErrInvalidInput（映射为 400），校验在访问仓储之前完成
+func TestTransferValidation(t *testing.T) {
+	s := NewTransferService(nil, zap.NewNop())
+	ctx := context.Background()
+	item := []dto.TransferItemRequest{{ProductID: 1, Quantity: 1}}
+
+	cases := map[string]error{}
+	_, cases["no operator"] = s.Create(ctx, dto.CreateTransferRequest{FromWarehouseID: 1, ToWarehouseID: 2, Items: item}, "")
+	_, cases["same warehouse"] = s.Create(ctx, dto.CreateTransferRequest{FromWarehouseID: 1, ToWarehouseID: 1, Items: item}, "alice")
+	_, cases["no items"] = s.Create(ctx, dto.CreateTransferRequest{FromWarehouseID: 1, ToWarehouseID: 2}, "alice")
+	_, cases["zero quantity"] = s.Create(ctx, dto.CreateTransferRequest{FromWarehouseID: 1, ToWarehouseID: 2,
+		Items: []dto.TransferItemRequest{{ProductID: 1}}}, "alice")
+	_, cases["dispatch without operator"] = s.Dispatch(ctx, 1, "")
+	_, cases["nothing to receive"] = s.Receive(ctx, 1, dto.ReceiveTransferRequest{}, "alice")
+	for name, err := range cases {
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
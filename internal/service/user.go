@@ -7,11 +7,12 @@ import (
 	"djj-inventory-system/internal/model/catalog"
 	"djj-inventory-system/internal/model/rbac"
 	_ "encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	_ "gorm.io/gorm"
+	"gorm.io/gorm"
 
 	"djj-inventory-system/internal/pkg/audit"
 	"djj-inventory-system/internal/repository"
@@ -48,24 +49,47 @@ type UserPermissionData struct {
 }
 
 type userService struct {
-	repo repository.UserRepo
-	aud  audit.Recorder
+	repo    repository.UserRepo
+	aud     audit.Recorder
+	policy  PasswordPolicy
+	lockout LockoutPolicy
 }
 
-func NewUserService(r repository.UserRepo, aud audit.Recorder) UserService {
-	return &userService{repo: r, aud: aud}
+func NewUserService(r repository.UserRepo, aud audit.Recorder, policy PasswordPolicy, lockout LockoutPolicy) UserService {
+	return &userService{repo: r, aud: aud, policy: policy, lockout: lockout}
 }
 
 // ---- 实现 Authenticate ----
+// 连续失败达到 lockout.MaxAttempts 次后锁定；锁定期内即使密码正确也拒绝登录
 func (s *userService) Authenticate(ctx context.Context, username, password string) (*rbac.User, *catalog.StoreDetails, error) {
 	// 1) 根据用户名查用户
 	u, err := s.repo.FindByEmail(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, nil, err
 	}
+	now := time.Now()
+	if u.IsLocked(now) {
+		return nil, nil, fmt.Errorf("%w until %s", ErrAccountLocked, u.LockedUntil.Format(time.RFC3339))
+	}
 	// 2) 校验密码
 	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
-		return nil, nil, fmt.Errorf("invalid credentials")
+		until, err := s.repo.RecordLoginFailure(ctx, u.ID, s.lockout.MaxAttempts, s.lockout.Duration, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if until != nil {
+			s.aud.Record(ctx, audit2.AuditedTableUsers, u.ID, "lock", map[string]interface{}{"lockedUntil": until})
+			return nil, nil, fmt.Errorf("%w until %s", ErrAccountLocked, until.Format(time.RFC3339))
+		}
+		return nil, nil, ErrInvalidCredentials
+	}
+	if u.FailedLogins > 0 || u.LockedUntil != nil {
+		if err := s.repo.ResetLoginFailures(ctx, u.ID); err != nil {
+			return nil, nil, err
+		}
 	}
 	// 3. 载入这个用户的角色列表
 	//roles, err := s.repo.ListRoles(u.ID)
@@ -153,16 +177,21 @@ func (s *userService) GetProfile(ctx context.Context, id uint) (*rbac.User, *cat
 }
 
 func (s *userService) Create(ctx context.Context, username, email, password string, roleNames []string) (*rbac.User, error) {
+	if err := s.policy.Validate(password, username, email); err != nil {
+		return nil, err
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	u := &rbac.User{
-		Username:     username,
-		Email:        email,
-		PasswordHash: string(hash),
-		Version:      1,
+		Username:          username,
+		Email:             email,
+		PasswordHash:      string(hash),
+		PasswordChangedAt: &now,
+		Version:           1,
 	}
 
 	// 用新加的 CreateWithRoles 一步完成创建 + 关联
@@ -210,11 +239,16 @@ func (s *userService) Update(ctx context.Context, id uint, email, password *stri
 		u.Email = *email
 	}
 	if password != nil {
+		if err := s.policy.Validate(*password, u.Username, u.Email); err != nil {
+			return nil, err
+		}
 		hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
 		if err != nil {
 			return nil, err
 		}
+		now := time.Now()
 		u.PasswordHash = string(hash)
+		u.PasswordChangedAt = &now
 	}
 	if err := s.repo.Update(u); err != nil {
 		return nil, err
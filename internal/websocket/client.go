@@ -0,0 +1,124 @@
+// internal/websocket/client.go
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"djj-inventory-system/internal/pkg/auth"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client 一条 websocket 连接：写协程独占写，读循环处理订阅控制消息并发现断线
+type Client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	principal *auth.Principal
+	aud       Audience
+	topics    map[string]uint64 // topic -> 已补发到的 seq，由 hub.mu 保护
+	// send 待发队列，一项是一批按顺序发出的消息：实时事件一条一批，订阅回复和补发的事件一起占一项
+	send chan [][]byte
+	once sync.Once
+}
+
+// controlMessage 客户端发来的控制消息
+//
+//	{"type":"subscribe","topics":["orders","quotes"],"since":1234}
+//	{"type":"unsubscribe","topics":["quotes"]}
+//
+// since 是客户端最后收到的事件 seq（全局递增，所有 topic 共用），带上时先补发漏掉的事件
+type controlMessage struct {
+	Type   string   `json:"type"`
+	Topics []string `json:"topics"`
+	Since  *uint64  `json:"since"`
+}
+
+// close 关闭发送队列让写协程退出；被踢掉的客户端同时立即断开，不再等队列发完
+func (c *Client) close(now bool) {
+	c.once.Do(func() {
+		close(c.send)
+		if now && c.conn != nil {
+			c.conn.Close()
+		}
+	})
+}
+
+// ReadLoop 处理客户端的订阅控制消息，直到连接断开或超时未收到 pong，然后注销客户端
+func (c *Client) ReadLoop(ctx context.Context) {
+	defer c.hub.Unregister(c)
+	cfg := c.hub.cfg
+	c.conn.SetReadLimit(cfg.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.handle(ctx, data)
+	}
+}
+
+func (c *Client) handle(ctx context.Context, data []byte) {
+	var m controlMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		c.hub.deliver(c, encode(reply{Type: "error", Error: "invalid message: " + err.Error()}))
+		return
+	}
+	switch m.Type {
+	case "subscribe":
+		for _, t := range m.Topics {
+			c.hub.Subscribe(ctx, c, t, m.Since)
+		}
+	case "unsubscribe":
+		for _, t := range m.Topics {
+			c.hub.Unsubscribe(c, t)
+		}
+	default:
+		c.hub.deliver(c, encode(reply{Type: "error", Error: "unknown message type: " + m.Type}))
+	}
+}
+
+// writeLoop 把队列里的消息写给客户端，定时发 ping；写失败或队列关闭后断开连接
+func (c *Client) writeLoop() {
+	cfg := c.hub.cfg
+	ticker := time.NewTicker(cfg.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.hub.Unregister(c)
+	}()
+	for {
+		select {
+		case msgs, ok := <-c.send:
+			if !ok {
+				c.conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if !c.write(msgs) {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) write(msgs [][]byte) bool {
+	for _, msg := range msgs {
+		c.conn.SetWriteDeadline(time.Now().Add(c.hub.cfg.WriteWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -1,29 +1,130 @@
 package websocket
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+
+	"djj-inventory-system/internal/pkg/auth"
+	"djj-inventory-system/internal/pkg/scope"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+// TopicPermissions 各 topic 需要的查看权限，拥有任意一个即可订阅；和对应 REST 列表接口一致
+var TopicPermissions = map[string][]string{
+	"customers": {"sales.view", "quote.view", "finance.view"},
+	"products":  {"inventory.view", "sales.view", "quote.view"},
+	"orders":    {"sales.view"},
+	"quotes":    {"quote.view"},
+	"inventory": {"inventory.view"},
+}
+
+// StoreResolver 把数据范围展开成门店 ID；all 为 true 时不限门店
+type StoreResolver func(ctx context.Context, s scope.Scope) (ids []uint, all bool, err error)
+
+// Options 连接校验参数
+type Options struct {
+	Origins []string // 允许的 Origin，和 CORS 配置保持一致；"*" 表示不限
+	Stores  StoreResolver
+}
 
-// ServeWS upgrades HTTP => WS and registers conn under :topic
-func ServeWS(hub *Hub) gin.HandlerFunc {
+// ServeWS upgrades HTTP => WS；连接建立后客户端用控制消息订阅 / 取消订阅多个 topic
+// 路径带 :topic 时直接订阅该 topic（?since= 可选），兼容一个 topic 一个连接的旧客户端
+// 必须挂在认证和加载权限的中间件之后：校验 Origin、topic 的查看权限，再按数据范围过滤推送的门店
+// 权限和数据范围在连接建立时确定，变更后客户端重连才生效
+func ServeWS(hub *Hub, opts Options) gin.HandlerFunc {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool {
+		return originAllowed(opts.Origins, r.Header.Get("Origin"))
+	}}
 	return func(c *gin.Context) {
+		if !originAllowed(opts.Origins, c.GetHeader("Origin")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+			return
+		}
+		p, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要先登录"})
+			return
+		}
 		topic := c.Param("topic") // e.g. "customers", "quotes"
+		var since *uint64
+		if topic != "" {
+			perms, ok := TopicPermissions[topic]
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "unknown topic: " + topic})
+				return
+			}
+			if !hasAny(p, perms) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "没有权限订阅 " + topic})
+				return
+			}
+			if v := c.Query("since"); v != "" {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+					return
+				}
+				since = &n
+			}
+		}
+		aud, err := audience(c.Request.Context(), opts.Stores, p.Scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "加载数据范围失败"})
+			return
+		}
+
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			return
 		}
-		hub.Register(topic, conn)
-		defer hub.Unregister(topic, conn)
-		// keep connection alive until client disconnects
-		for {
-			if _, _, err := conn.ReadMessage(); err != nil {
-				break
-			}
+		client := hub.Register(conn, p, aud)
+		if topic != "" {
+			hub.Subscribe(c.Request.Context(), client, topic, since)
 		}
+		// keep connection alive until client disconnects or stops answering pings
+		client.ReadLoop(c.Request.Context())
+	}
+}
+
+// ServeStats 在线连接数，以及各 topic 的订阅数和消息计数
+func ServeStats(hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"connections": hub.Connections(), "topics": hub.Stats()})
+	}
+}
+
+// originAllowed 没有 Origin 头的是非浏览器客户端（脚本、API Key 调用），不受跨站劫持影响，放行
+func originAllowed(allowed []string, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, o := range allowed {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAny(p *auth.Principal, perms []string) bool {
+	for _, perm := range perms {
+		if p.HasPermission(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+func audience(ctx context.Context, resolve StoreResolver, s scope.Scope) (Audience, error) {
+	ids, all, err := resolve(ctx, s)
+	if err != nil || all {
+		return Audience{AllStores: all}, err
+	}
+	aud := Audience{Stores: make(map[uint]bool, len(ids))}
+	for _, id := range ids {
+		aud.Stores[id] = true
 	}
+	return aud, nil
 }
@@ -2,47 +2,434 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/pkg/auth"
 
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
+// Config 连接参数
+type Config struct {
+	SendBuffer     int           // 每个客户端的待发队列长度，满了说明客户端跟不上，直接踢掉
+	WriteWait      time.Duration // 单条消息写超时
+	PongWait       time.Duration // 多久收不到 pong 就认为连接已断
+	PingPeriod     time.Duration // ping 间隔，必须小于 PongWait
+	MaxMessageSize int64         // 客户端发来的消息上限
+	ReplayLimit    int           // 单个 topic 最多补发的事件数，漏掉更多时让客户端重新拉取
+}
+
+// DefaultConfig 默认连接参数
+var DefaultConfig = Config{
+	SendBuffer:     64,
+	WriteWait:      10 * time.Second,
+	PongWait:       60 * time.Second,
+	PingPeriod:     54 * time.Second,
+	MaxMessageSize: 4096,
+	ReplayLimit:    500,
+}
+
+// EventLog 有界的持久化事件日志，供补发和裁剪；写入由事件总线负责
+type EventLog interface {
+	Since(ctx context.Context, topic string, seq uint64, limit int) ([]realtime.Event, error)
+	FirstSeq(ctx context.Context) (uint64, error)
+	Trim(ctx context.Context, keep int) (int64, error)
+}
+
 // Hub manages client connections and broadcasts messages
-// supports multiple topics (e.g. "customers", "quotes", "orders", "inventory")
+// 一个连接可以订阅多个 topic（e.g. "customers", "quotes", "orders", "products", "inventory"）
+// 事件由业务代码发布到事件总线，总线把所有实例的事件交给 Dispatch
+// 每个客户端一个写协程和一个有界队列，Dispatch 只往队列里放，不做网络 IO；队列满的客户端被踢掉
 type Hub struct {
-	mu      sync.Mutex
-	clients map[string]map[*websocket.Conn]bool // topic -> conns
+	cfg Config
+	log EventLog // 为 nil 时不支持补发
+
+	// pubMu 串行化投递和补发接入：事件按 seq 顺序进入各客户端队列，补发和实时事件之间不重不漏
+	// 补发在锁外读日志，持锁时只用 recent 补上读日志期间已经投递的事件
+	pubMu  sync.Mutex
+	recent map[string]*recentEvents // topic -> 最近投递的事件，由 pubMu 保护
+
+	mu      sync.RWMutex
+	conns   map[*Client]struct{}
+	clients map[string]map[*Client]struct{} // topic -> clients
+	stats   map[string]*topicCounters
 }
 
-func NewHub() *Hub {
-	return &Hub{clients: make(map[string]map[*websocket.Conn]bool)}
+func NewHub(cfg Config, log EventLog) *Hub {
+	return &Hub{
+		cfg:     cfg,
+		log:     log,
+		conns:   make(map[*Client]struct{}),
+		clients: make(map[string]map[*Client]struct{}),
+		stats:   make(map[string]*topicCounters),
+		recent:  make(map[string]*recentEvents),
+	}
 }
 
-// Register adds a connection for a given topic
-func (h *Hub) Register(topic string, conn *websocket.Conn) {
+// recentKeep 每个 topic 在内存里保留的最近投递事件数，只需覆盖一次补发读日志的耗时
+const recentKeep = 256
+
+// recentEvents 某个 topic 最近投递的事件，按投递顺序
+type recentEvents struct {
+	events  []*realtime.Event
+	dropped uint64 // 已经移出缓冲的最大 seq
+}
+
+// topicCounters 按 topic 累计的计数
+type topicCounters struct {
+	sent    uint64
+	dropped uint64
+	evicted uint64
+}
+
+// TopicStats 某个 topic 的当前订阅数和累计计数
+type TopicStats struct {
+	Topic   string `json:"topic"`
+	Clients int    `json:"clients"`
+	Sent    uint64 `json:"sent"`    // 进入发送队列的消息数
+	Dropped uint64 `json:"dropped"` // 因队列满丢弃的消息数
+	Evicted uint64 `json:"evicted"` // 因跟不上被踢掉的客户端数
+}
+
+// Audience 客户端能收到哪些门店的事件，连接时按用户的数据范围算好
+type Audience struct {
+	AllStores bool
+	Stores    map[uint]bool
+}
+
+// allows storeID 为 0 的事件不属于某个门店（如商品），所有订阅者都能收到
+func (a Audience) allows(storeID uint) bool {
+	return storeID == 0 || a.AllStores || a.Stores[storeID]
+}
+
+// Register adds a connection and starts its writer；连接建立后还没有订阅任何 topic
+// 调用方负责在同一个协程里跑 client.ReadLoop，返回后连接即关闭
+func (h *Hub) Register(conn *websocket.Conn, p *auth.Principal, aud Audience) *Client {
+	c := h.newClient(conn, p, aud)
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	if h.clients[topic] == nil {
-		h.clients[topic] = make(map[*websocket.Conn]bool)
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+	go c.writeLoop()
+	return c
+}
+
+func (h *Hub) newClient(conn *websocket.Conn, p *auth.Principal, aud Audience) *Client {
+	return &Client{
+		hub:       h,
+		conn:      conn,
+		principal: p,
+		aud:       aud,
+		topics:    make(map[string]uint64),
+		send:      make(chan [][]byte, h.cfg.SendBuffer),
 	}
-	h.clients[topic][conn] = true
 }
 
-// Unregister removes a connection
-func (h *Hub) Unregister(topic string, conn *websocket.Conn) {
+// Unregister removes a client from all topics and closes its connection; 重复调用无副作用
+func (h *Hub) Unregister(c *Client) {
+	h.remove(c, "")
+}
+
+// remove evictedFrom 非空表示客户端在该 topic 上跟不上被踢掉
+func (h *Hub) remove(c *Client, evictedFrom string) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	_, ok := h.conns[c]
+	if ok {
+		delete(h.conns, c)
+		for topic := range c.topics {
+			h.leave(c, topic)
+		}
+		if evictedFrom != "" {
+			atomic.AddUint64(&h.counters(evictedFrom).evicted, 1)
+		}
+	}
+	h.mu.Unlock()
+	if ok {
+		c.close(evictedFrom != "")
+	}
+}
+
+// join / leave 调用方需持有写锁；after 是已经补发到的 seq，之后投递的事件不超过它的跳过
+func (h *Hub) join(c *Client, topic string, after uint64) {
+	if h.clients[topic] == nil {
+		h.clients[topic] = make(map[*Client]struct{})
+	}
+	h.clients[topic][c] = struct{}{}
+	c.topics[topic] = after
+	h.counters(topic)
+}
+
+func (h *Hub) leave(c *Client, topic string) {
+	delete(c.topics, topic)
 	if conns := h.clients[topic]; conns != nil {
-		delete(conns, conn)
-		conn.Close()
+		delete(conns, c)
+		if len(conns) == 0 {
+			delete(h.clients, topic)
+		}
+	}
+}
+
+// counters 调用方需持有写锁
+func (h *Hub) counters(topic string) *topicCounters {
+	tc := h.stats[topic]
+	if tc == nil {
+		tc = &topicCounters{}
+		h.stats[topic] = tc
+	}
+	return tc
+}
+
+// reply 发给单个客户端的控制消息
+type reply struct {
+	Type  string `json:"type"` // subscribed / unsubscribed / replayed / resync / error
+	Topic string `json:"topic,omitempty"`
+	Seq   uint64 `json:"seq,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// envelope 推送给客户端的事件
+type envelope struct {
+	Type string `json:"type"` // 固定为 event
+	*realtime.Event
+}
+
+func encode(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// Subscribe 订阅 topic；since 非空时先补发 since 之后、客户端数据范围内的事件，再接上实时事件
+// 读日志时不挡住发布；补发的事件作为一批放进队列，保证客户端收到的同一 topic 事件按 seq 递增、不重不漏
+// 日志里已经没有 since 之后的全部事件，或漏掉的太多时回复 resync，客户端应通过 REST 重新拉取
+func (h *Hub) Subscribe(ctx context.Context, c *Client, topic string, since *uint64) {
+	perms, ok := TopicPermissions[topic]
+	if !ok {
+		h.deliver(c, encode(reply{Type: "error", Topic: topic, Error: "unknown topic"}))
+		return
+	}
+	if c.principal == nil || !hasAny(c.principal, perms) {
+		h.deliver(c, encode(reply{Type: "error", Topic: topic, Error: "没有权限订阅 " + topic}))
+		return
+	}
+
+	// 先在锁外读日志，读日志期间投递的事件加锁后从 recent 补上
+	msgs := [][]byte{encode(reply{Type: "subscribed", Topic: topic})}
+	var (
+		after      uint64
+		replayed   [][]byte
+		replayedOK bool
+	)
+	if since != nil {
+		replayed, after, replayedOK = h.replay(ctx, c, topic, *since)
+	}
+
+	h.pubMu.Lock()
+	defer h.pubMu.Unlock()
+	if replayedOK {
+		tail, last, covered := h.tail(c, topic, after)
+		if covered {
+			replayed = append(replayed, tail...)
+			if last > after {
+				after = last
+			}
+		} else {
+			// 读日志期间投递的事件太多、已经移出缓冲，持锁重新补发
+			replayed, after, _ = h.replay(ctx, c, topic, *since)
+		}
+	}
+	msgs = append(msgs, replayed...)
+	h.mu.Lock()
+	_, connected := h.conns[c]
+	if connected {
+		h.join(c, topic, after)
+	}
+	h.mu.Unlock()
+	if connected {
+		h.deliver(c, msgs...)
 	}
 }
 
-// Broadcast sends a message to all clients subscribed to a topic
-func (h *Hub) Broadcast(topic string, message []byte) {
+// deliver 把一批消息作为一项放进客户端队列；队列已满的客户端被踢掉
+func (h *Hub) deliver(c *Client, msgs ...[]byte) {
+	h.mu.RLock()
+	_, ok := h.conns[c]
+	full := false
+	if ok {
+		select {
+		case c.send <- msgs:
+		default:
+			full = true
+		}
+	}
+	h.mu.RUnlock()
+	if full {
+		h.remove(c, "")
+	}
+}
+
+// replay 从日志读 since 之后的事件，返回补发的消息、补发到的 seq，以及是否补发成功（失败时消息为 resync / error）
+// 事件总线可能还没把日志里的新事件投递过来，补发过的事件之后再投递时跳过
+func (h *Hub) replay(ctx context.Context, c *Client, topic string, since uint64) ([][]byte, uint64, bool) {
+	fail := func(typ, msg string) ([][]byte, uint64, bool) {
+		return [][]byte{encode(reply{Type: typ, Topic: topic, Error: msg})}, 0, false
+	}
+	if h.log == nil {
+		return fail("resync", "event log disabled")
+	}
+	first, err := h.log.FirstSeq(ctx)
+	if err != nil {
+		return fail("error", "load event log failed")
+	}
+	if first > 0 && since+1 < first {
+		return fail("resync", fmt.Sprintf("events before seq %d are no longer kept", first))
+	}
+	events, err := h.log.Since(ctx, topic, since, h.cfg.ReplayLimit+1)
+	if err != nil {
+		return fail("error", "load event log failed")
+	}
+	if len(events) > h.cfg.ReplayLimit {
+		return fail("resync", "too many missed events")
+	}
+	var msgs [][]byte
+	last := since
+	for i := range events {
+		e := &events[i]
+		last = e.Seq
+		if c.aud.allows(e.StoreID) {
+			msgs = append(msgs, encode(envelope{Type: "event", Event: e}))
+		}
+	}
+	return append(msgs, encode(reply{Type: "replayed", Topic: topic, Seq: last})), last, true
+}
+
+// tail 调用方需持有 pubMu；返回 recent 里 after 之后、客户端数据范围内的事件和其中最大的 seq
+// 缓冲已经移出过 after 之后的事件时 covered 为 false
+func (h *Hub) tail(c *Client, topic string, after uint64) (msgs [][]byte, last uint64, covered bool) {
+	r := h.recent[topic]
+	if r == nil {
+		return nil, 0, true
+	}
+	if r.dropped > after {
+		return nil, 0, false
+	}
+	for _, e := range r.events {
+		if e.Seq <= after {
+			continue
+		}
+		if e.Seq > last {
+			last = e.Seq
+		}
+		if c.aud.allows(e.StoreID) {
+			msgs = append(msgs, encode(envelope{Type: "event", Event: e}))
+		}
+	}
+	return msgs, last, true
+}
+
+// remember 调用方需持有 pubMu；记下刚投递的事件，超出 recentKeep 的移出缓冲
+func (h *Hub) remember(e *realtime.Event) {
+	r := h.recent[e.Topic]
+	if r == nil {
+		r = &recentEvents{}
+		h.recent[e.Topic] = r
+	}
+	r.events = append(r.events, e)
+	if n := len(r.events) - recentKeep; n > 0 {
+		for _, old := range r.events[:n] {
+			if old.Seq > r.dropped {
+				r.dropped = old.Seq
+			}
+		}
+		r.events = append(r.events[:0:0], r.events[n:]...)
+	}
+}
+
+// Unsubscribe 取消订阅 topic
+func (h *Hub) Unsubscribe(c *Client, topic string) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	for conn := range h.clients[topic] {
-		conn.WriteMessage(websocket.TextMessage, message)
+	h.leave(c, topic)
+	h.mu.Unlock()
+	h.deliver(c, encode(reply{Type: "unsubscribed", Topic: topic}))
+}
+
+// Dispatch 把事件总线送来的事件推送给 e.Topic 下数据范围包含 e.StoreID 的订阅者
+func (h *Hub) Dispatch(e *realtime.Event) {
+	h.pubMu.Lock()
+	defer h.pubMu.Unlock()
+	h.fanOut(e, [][]byte{encode(envelope{Type: "event", Event: e})})
+	h.remember(e)
+}
+
+// fanOut 不阻塞：消息放进各客户端的队列，队列已满的客户端被踢掉
+func (h *Hub) fanOut(e *realtime.Event, message [][]byte) {
+	var slow []*Client
+	h.mu.RLock()
+	tc := h.stats[e.Topic]
+	for c := range h.clients[e.Topic] {
+		if !c.aud.allows(e.StoreID) || e.Seq <= c.topics[e.Topic] {
+			continue
+		}
+		select {
+		case c.send <- message:
+			atomic.AddUint64(&tc.sent, 1)
+		default:
+			atomic.AddUint64(&tc.dropped, 1)
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range slow {
+		h.remove(c, e.Topic)
+	}
+}
+
+// RunTrimmer 每隔 interval 把事件日志裁到最近 keep 条，直到 ctx 取消
+func (h *Hub) RunTrimmer(ctx context.Context, interval time.Duration, keep int, logger *zap.Logger) {
+	if h.log == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := h.log.Trim(ctx, keep); err != nil {
+				logger.Error("Realtime event log trim failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Connections 当前连接数
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// Stats 各 topic 的订阅数和计数，按 topic 排序
+func (h *Hub) Stats() []TopicStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]TopicStats, 0, len(h.stats))
+	for topic, tc := range h.stats {
+		out = append(out, TopicStats{
+			Topic:   topic,
+			Clients: len(h.clients[topic]),
+			Sent:    atomic.LoadUint64(&tc.sent),
+			Dropped: atomic.LoadUint64(&tc.dropped),
+			Evicted: atomic.LoadUint64(&tc.evicted),
+		})
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Topic < out[j].Topic })
+	return out
 }
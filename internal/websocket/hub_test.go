@@ -0,0 +1,267 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"djj-inventory-system/internal/model/realtime"
+	"djj-inventory-system/internal/pkg/auth"
+)
+
+// memLog 内存事件日志，保留最近 keep 条
+type memLog struct {
+	events []realtime.Event
+	keep   int
+}
+
+func (l *memLog) Append(_ context.Context, e *realtime.Event) error {
+	e.Seq = 1
+	if n := len(l.events); n > 0 {
+		e.Seq = l.events[n-1].Seq + 1
+	}
+	l.events = append(l.events, *e)
+	if len(l.events) > l.keep {
+		l.events = l.events[len(l.events)-l.keep:]
+	}
+	return nil
+}
+
+func (l *memLog) Since(_ context.Context, topic string, seq uint64, limit int) ([]realtime.Event, error) {
+	var out []realtime.Event
+	for _, e := range l.events {
+		if e.Topic == topic && e.Seq > seq && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (l *memLog) FirstSeq(context.Context) (uint64, error) {
+	if len(l.events) == 0 {
+		return 0, nil
+	}
+	return l.events[0].Seq, nil
+}
+
+func (l *memLog) Trim(context.Context, int) (int64, error) { return 0, nil }
+
+var viewer = &auth.Principal{Permissions: []string{"sales.view", "quote.view"}}
+
+// connect 不启动写协程的客户端，测试直接读队列
+func connect(h *Hub, aud Audience) *Client {
+	c := h.newClient(nil, viewer, aud)
+	h.conns[c] = struct{}{}
+	return c
+}
+
+// drain 按写协程的顺序取出队列里的全部消息
+func drain(c *Client) []map[string]interface{} {
+	var out []map[string]interface{}
+	for {
+		select {
+		case batch := <-c.send:
+			for _, m := range batch {
+				var v map[string]interface{}
+				json.Unmarshal(m, &v)
+				out = append(out, v)
+			}
+		default:
+			return out
+		}
+	}
+}
+
+// publishOrder 模拟事件总线：写日志分配 seq 后立即投递；没有日志时按 id 编号
+func publishOrder(h *Hub, log *memLog, id, storeID uint) *realtime.Event {
+	e := &realtime.Event{Seq: uint64(id), Topic: "orders", Event: "orderUpdated", Entity: "order", EntityID: id, StoreID: storeID}
+	if log != nil {
+		log.Append(context.Background(), e)
+	}
+	h.Dispatch(e)
+	return e
+}
+
+func TestPublishEvictsSlowClient(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.SendBuffer = 1
+	h := NewHub(cfg, nil)
+	fast := connect(h, Audience{AllStores: true})
+	fast.send = make(chan [][]byte, 4)
+	slow := connect(h, Audience{AllStores: true})
+	ctx := context.Background()
+	h.Subscribe(ctx, fast, "orders", nil)
+	h.Subscribe(ctx, slow, "orders", nil)
+
+	publishOrder(h, nil, 1, 0) // slow 的队列已被订阅回复占满
+	publishOrder(h, nil, 2, 0)
+
+	st := h.Stats()
+	if len(st) != 1 || st[0].Clients != 1 || st[0].Sent != 2 || st[0].Dropped != 1 || st[0].Evicted != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if h.Connections() != 1 || len(fast.send) != 3 {
+		t.Fatalf("connections = %d, fast queued %d", h.Connections(), len(fast.send))
+	}
+	// 被踢掉的客户端队列已关闭，写协程取完剩余消息后退出
+	<-slow.send
+	if _, ok := <-slow.send; ok {
+		t.Error("slow client queue still open")
+	}
+	h.Unregister(slow) // 重复注销无副作用
+}
+
+func TestSubscribeFiltersTopicsPermissionsAndStores(t *testing.T) {
+	h := NewHub(DefaultConfig, nil)
+	ctx := context.Background()
+	c := connect(h, Audience{Stores: map[uint]bool{1: true}})
+	h.Subscribe(ctx, c, "orders", nil)
+	h.Subscribe(ctx, c, "customers", nil)
+	h.Subscribe(ctx, c, "products", nil) // 有 sales.view 即可
+	h.Subscribe(ctx, c, "nope", nil)
+
+	publishOrder(h, nil, 1, 1)
+	publishOrder(h, nil, 2, 2) // 范围外的门店
+	h.Unsubscribe(c, "orders")
+	publishOrder(h, nil, 3, 1)
+
+	var got []string
+	for _, m := range drain(c) {
+		switch m["type"] {
+		case "event":
+			got = append(got, fmt.Sprintf("%s:%v", m["topic"], m["id"]))
+		case "error":
+			got = append(got, "error:"+m["topic"].(string))
+		default:
+			got = append(got, m["type"].(string)+":"+m["topic"].(string))
+		}
+	}
+	want := []string{"subscribed:orders", "subscribed:customers", "subscribed:products", "error:nope", "orders:1", "unsubscribed:orders"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSubscribeReplaysMissedEvents(t *testing.T) {
+	log := &memLog{keep: 5}
+	h := NewHub(DefaultConfig, log)
+	ctx := context.Background()
+	for i := uint(1); i <= 4; i++ {
+		publishOrder(h, log, i, i%2) // 偶数 id 不属于门店，奇数 id 在门店 1
+	}
+
+	c := connect(h, Audience{Stores: map[uint]bool{1: true}})
+	since := uint64(1)
+	h.Subscribe(ctx, c, "orders", &since)
+	publishOrder(h, log, 5, 1)
+
+	var seqs []float64
+	var replayed float64
+	for _, m := range drain(c) {
+		switch m["type"] {
+		case "event":
+			seqs = append(seqs, m["seq"].(float64))
+		case "replayed":
+			replayed = m["seq"].(float64)
+		}
+	}
+	if len(seqs) != 4 || seqs[0] != 2 || seqs[3] != 5 || replayed != 4 {
+		t.Fatalf("seqs = %v, replayed = %v", seqs, replayed)
+	}
+
+	// 日志只留最近 5 条：seq 1 之后的已经不全，要求重新拉取
+	publishOrder(h, log, 6, 0)
+	publishOrder(h, log, 7, 0)
+	late := connect(h, Audience{AllStores: true})
+	h.Subscribe(ctx, late, "orders", &since)
+	msgs := drain(late)
+	if last := msgs[len(msgs)-1]; last["type"] != "resync" {
+		t.Fatalf("last message = %v", last)
+	}
+}
+
+func TestDispatchSkipsReplayedEvents(t *testing.T) {
+	log := &memLog{keep: 10}
+	h := NewHub(DefaultConfig, log)
+	ctx := context.Background()
+	// 其他实例发布的事件已经写进日志，总线还没投递过来
+	lagging := &realtime.Event{Topic: "orders", Event: "orderUpdated", Entity: "order", EntityID: 1}
+	log.Append(ctx, lagging)
+
+	c := connect(h, Audience{AllStores: true})
+	since := uint64(0)
+	h.Subscribe(ctx, c, "orders", &since)
+	h.Dispatch(lagging)
+	publishOrder(h, log, 2, 0)
+
+	var seqs []float64
+	for _, m := range drain(c) {
+		if m["type"] == "event" {
+			seqs = append(seqs, m["seq"].(float64))
+		}
+	}
+	if len(seqs) != 2 || seqs[0] != 1 || seqs[1] != 2 {
+		t.Fatalf("seqs = %v", seqs)
+	}
+}
+
+// slowLog 读日志之后、返回之前调用一次 during，模拟补发读日志期间有新事件发布
+type slowLog struct {
+	*memLog
+	during func()
+}
+
+func (l *slowLog) Since(ctx context.Context, topic string, seq uint64, limit int) ([]realtime.Event, error) {
+	out, err := l.memLog.Since(ctx, topic, seq, limit)
+	if f := l.during; f != nil {
+		l.during = nil
+		f()
+	}
+	return out, err
+}
+
+// 补发读日志时不持有 pubMu：期间发布的事件不被挡住，加锁后从最近投递的事件里补上，不重不漏
+func TestSubscribeReplaysOutsidePublishLock(t *testing.T) {
+	for _, published := range []int{1, recentKeep + 10} {
+		t.Run(fmt.Sprint(published), func(t *testing.T) {
+			log := &memLog{keep: 1000}
+			slow := &slowLog{memLog: log}
+			h := NewHub(DefaultConfig, slow)
+			ctx := context.Background()
+			publishOrder(h, log, 1, 0)
+			publishOrder(h, log, 2, 0)
+
+			c := connect(h, Audience{AllStores: true})
+			c.send = make(chan [][]byte, published+10)
+			slow.during = func() {
+				for i := 0; i < published; i++ {
+					publishOrder(h, log, uint(3+i), 0)
+				}
+			}
+			since := uint64(0)
+			h.Subscribe(ctx, c, "orders", &since)
+			publishOrder(h, log, uint(3+published), 0)
+
+			var seqs []uint64
+			for _, m := range drain(c) {
+				if m["type"] == "event" {
+					seqs = append(seqs, uint64(m["seq"].(float64)))
+				}
+			}
+			if len(seqs) != published+3 {
+				t.Fatalf("got %d events, want %d", len(seqs), published+3)
+			}
+			for i, s := range seqs {
+				if s != uint64(i+1) {
+					t.Fatalf("event %d has seq %d", i, s)
+				}
+			}
+		})
+	}
+}